@@ -17,6 +17,7 @@ limitations under the License.
 package v1beta1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -24,13 +25,200 @@ const (
 	// SnapshotFinalizer allows SnapshotReconciler to clean up resources associated with
 	// Snapshot instance before removing it from the apiserver.
 	SnapshotFinalizer = "snapshotfinalizer.projectsveltos.io"
+
+	// SnapshotTriggerAnnotation, when set on a Snapshot instance, causes a sample to be
+	// collected immediately, outside the schedule. Value is opaque: every time it changes
+	// a new sample is collected.
+	SnapshotTriggerAnnotation = "snapshot.projectsveltos.io/trigger"
+)
+
+// StorageBackendType specifies where snapshots are stored
+// +kubebuilder:validation:Enum:=Filesystem;S3
+type StorageBackendType string
+
+const (
+	// StorageBackendTypeFilesystem stores snapshots in a local directory
+	StorageBackendTypeFilesystem = StorageBackendType("Filesystem")
+
+	// StorageBackendTypeS3 stores snapshots in an S3-compatible object store
+	// (AWS S3, MinIO, etc.)
+	StorageBackendTypeS3 = StorageBackendType("S3")
+)
+
+const (
+	// S3AccessKeyIDKey is the key, in the credentials Secret, containing the access key ID
+	S3AccessKeyIDKey = "accesskey"
+
+	// S3SecretAccessKeyKey is the key, in the credentials Secret, containing the secret access key
+	S3SecretAccessKeyKey = "secretkey"
+
+	// S3SessionTokenKey is the optional key, in the credentials Secret, containing a session token
+	S3SessionTokenKey = "sessiontoken"
+)
+
+// S3Storage contains the configuration to store snapshots in an S3-compatible object store
+type S3Storage struct {
+	// Endpoint is the S3 endpoint in the form host[:port].
+	// For instance s3.amazonaws.com or minio.minio.svc:9000
+	Endpoint string `json:"endpoint"`
+
+	// Bucket is the name of the bucket where snapshots are stored.
+	// Bucket must exist.
+	Bucket string `json:"bucket"`
+
+	// Region of the bucket
+	// +optional
+	Region string `json:"region,omitempty"`
+
+	// Insecure, when set, makes sveltosctl connect to Endpoint over plain HTTP
+	// +optional
+	Insecure bool `json:"insecure,omitempty"`
+
+	// CredentialsSecretRef references the Secret containing the credentials
+	// used to access the bucket. Secret Data must contain keys accesskey and
+	// secretkey. Key sessiontoken is optional.
+	CredentialsSecretRef corev1.SecretReference `json:"credentialsSecretRef"`
+}
+
+const (
+	// EncryptionKeyKey is the key, in the encryption Secret, containing the key used
+	// to encrypt Secrets. Any other key in such Secret is a previous key, only used to decrypt.
+	EncryptionKeyKey = "key"
 )
 
+// SnapshotEncryption contains the configuration to encrypt Secrets stored in samples
+type SnapshotEncryption struct {
+	// KeySecretRef references the Secret containing the encryption keys.
+	// Secret Data must contain key "key" with a 32 bytes long key, used to encrypt
+	// Secrets with AES-256-GCM. Any other entry is a previous key, kept to decrypt
+	// Secrets stored before the key was rotated.
+	KeySecretRef corev1.SecretReference `json:"keySecretRef"`
+}
+
+const (
+	// SigningPrivateKeyKey is the key, in the signing Secret, containing the ed25519 private
+	// key (either the 32 bytes seed or the 64 bytes key) used to sign sample manifests
+	SigningPrivateKeyKey = "privatekey"
+
+	// SigningPublicKeyKey is the optional key, in the signing Secret, containing the
+	// 32 bytes ed25519 public key. If not set, it is derived from the private key.
+	SigningPublicKeyKey = "publickey"
+)
+
+// SnapshotSigning contains the configuration to sign sample manifests
+type SnapshotSigning struct {
+	// KeySecretRef references the Secret containing the ed25519 keys.
+	// Secret Data must contain key "privatekey" to sign manifests. Verification uses
+	// key "publickey" when present and derives the public key from "privatekey" otherwise.
+	KeySecretRef corev1.SecretReference `json:"keySecretRef"`
+}
+
+// SecretMode defines how Secrets are stored in samples
+// +kubebuilder:validation:Enum:=Full;Hashed;Skip
+type SecretMode string
+
+const (
+	// SecretModeFull stores Secrets as they are
+	SecretModeFull = SecretMode("Full")
+
+	// SecretModeHashed replaces every value in Secret Data with its SHA-256 digest.
+	// Changes are still detected, but such Secrets cannot be rolled back.
+	// Digest is not keyed: unless encryption is enabled, anyone with access to the storage
+	// can confirm a guessed value, so Hashed is not suited to low entropy values.
+	SecretModeHashed = SecretMode("Hashed")
+
+	// SecretModeSkip does not store Secrets at all
+	SecretModeSkip = SecretMode("Skip")
+)
+
+// SecretPolicy defines how Secrets matching it are stored in samples
+type SecretPolicy struct {
+	// Selector selects Secrets by labels.
+	// If not set, Secrets are not filtered by labels.
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+
+	// Namespaces selects Secrets by namespace.
+	// If empty, Secrets in any namespace are selected.
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// Mode defines how selected Secrets are stored
+	Mode SecretMode `json:"mode"`
+}
+
+// ChangeTrigger configures samples taken when Sveltos configuration changes.
+// ClusterProfiles, Profiles, ConfigMaps/Secrets they reference and cluster labels
+// are watched.
+type ChangeTrigger struct {
+	// DebounceSeconds is how long to wait, after the last detected change, before
+	// taking a sample. Changes occurring within this window end up in the same sample.
+	// Defaults to 30 seconds.
+	// +kubebuilder:default:=30
+	// +kubebuilder:validation:Minimum:=0
+	// +optional
+	DebounceSeconds *int32 `json:"debounceSeconds,omitempty"`
+
+	// MinIntervalSeconds is the minimum time between two samples taken because of
+	// changes. Changes detected earlier are collected once such interval has elapsed.
+	// Defaults to 300 seconds.
+	// +kubebuilder:default:=300
+	// +kubebuilder:validation:Minimum:=0
+	// +optional
+	MinIntervalSeconds *int32 `json:"minIntervalSeconds,omitempty"`
+}
+
+// RetentionPolicy defines which samples are kept, following a grandfather-father-son
+// scheme. Each rule keeps the most recent sample of each of the last N hours, days,
+// weeks or months which have samples. A sample is kept if at least one rule keeps it.
+type RetentionPolicy struct {
+	// Hourly is the number of hours for which the most recent sample is kept
+	// +kubebuilder:validation:Minimum:=0
+	// +optional
+	Hourly *int32 `json:"hourly,omitempty"`
+
+	// Daily is the number of days for which the most recent sample is kept
+	// +kubebuilder:validation:Minimum:=0
+	// +optional
+	Daily *int32 `json:"daily,omitempty"`
+
+	// Weekly is the number of ISO weeks for which the most recent sample is kept
+	// +kubebuilder:validation:Minimum:=0
+	// +optional
+	Weekly *int32 `json:"weekly,omitempty"`
+
+	// Monthly is the number of months for which the most recent sample is kept
+	// +kubebuilder:validation:Minimum:=0
+	// +optional
+	Monthly *int32 `json:"monthly,omitempty"`
+
+	// MaxAge, when set, causes samples older than MaxAge to be removed even if
+	// a rule keeps them. If no rule is set, all samples younger than MaxAge are kept.
+	// +optional
+	MaxAge *metav1.Duration `json:"maxAge,omitempty"`
+}
+
+// StorageBackend defines where snapshots are stored
+type StorageBackend struct {
+	// Type of the storage backend
+	// +kubebuilder:default:=Filesystem
+	Type StorageBackendType `json:"type"`
+
+	// S3 contains the S3 configuration. Required when Type is S3.
+	// +optional
+	S3 *S3Storage `json:"s3,omitempty"`
+}
+
 // SnapshotSpec defines the desired state of Snapshot
 type SnapshotSpec struct {
 	// Schedule in Cron format, see https://en.wikipedia.org/wiki/Cron.
 	Schedule string `json:"schedule"`
 
+	// ChangeTrigger, when set, causes a sample to be taken, in addition to the
+	// scheduled ones, every time Sveltos configuration changes.
+	// +optional
+	ChangeTrigger *ChangeTrigger `json:"changeTrigger,omitempty"`
+
 	// Optional deadline in seconds for starting the job if it misses scheduled
 	// time for any reason.  Missed jobs executions will be counted as failed ones.
 	// +optional
@@ -40,14 +228,63 @@ type SnapshotSpec struct {
 	// It must be an existing directory.
 	// Snapshots will be stored in this directory in a subdirectory named
 	// with Snapshot instance name.
+	// When StorageBackend is S3, Storage is the prefix of all keys in the bucket.
 	Storage string `json:"storage"`
 
+	// StorageBackend defines where snapshots are stored.
+	// If not set, snapshots are stored in the local directory Storage.
+	// +optional
+	StorageBackend *StorageBackend `json:"storageBackend,omitempty"`
+
+	// Encryption, when set, causes every Secret stored in a sample to be encrypted.
+	// Secrets are transparently decrypted when a sample is read.
+	// +optional
+	Encryption *SnapshotEncryption `json:"encryption,omitempty"`
+
+	// Signing, when set, causes the manifest of each sample to be signed.
+	// Signature is then verified before a sample is used.
+	// +optional
+	Signing *SnapshotSigning `json:"signing,omitempty"`
+
+	// SecretPolicies defines how Secrets are stored in samples.
+	// Policies are evaluated in order and the first one selecting a Secret applies.
+	// Secrets not selected by any policy are stored in full.
+	// +optional
+	SecretPolicies []SecretPolicy `json:"secretPolicies,omitempty"`
+
+	// IncludeKinds, when not empty, restricts collection to these kinds (for instance
+	// ClusterProfile, Profile, ClusterConfiguration, Cluster, SveltosCluster, Classifier,
+	// RoleRequest, EventSource, EventTrigger, HealthCheck, ClusterHealthCheck, ClusterSet, Set).
+	// Resources referenced by collected instances are collected unless their kind is excluded.
+	// +optional
+	IncludeKinds []string `json:"includeKinds,omitempty"`
+
+	// ExcludeKinds lists kinds which are never collected. It can contain referenced
+	// kinds as well (for instance Secret).
+	// +optional
+	ExcludeKinds []string `json:"excludeKinds,omitempty"`
+
+	// NamespaceSelector, when set, restricts collection of namespaced resources to
+	// namespaces matching it. Cluster wide resources are not affected.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// ProfileSelector, when set, restricts collection of ClusterProfiles/Profiles (and
+	// of the resources they reference) to the ones whose labels match it.
+	// +optional
+	ProfileSelector *metav1.LabelSelector `json:"profileSelector,omitempty"`
+
 	// The number of successful finished snapshots to retains.
 	// If specified, only SuccessfulSnapshotLimit will be retained. Once such
 	// number is reached, for any new successful snapshots, the oldest one is
 	// deleted.
 	// +optional
 	SuccessfulSnapshotLimit *int32 `json:"successfulSnapshotLimit,omitempty"`
+
+	// Retention defines which samples are kept. It is evaluated after each
+	// collection, on top of SuccessfulSnapshotLimit.
+	// +optional
+	Retention *RetentionPolicy `json:"retention,omitempty"`
 }
 
 // SnapshotStatus defines the observed state of Snapshot
@@ -66,6 +303,22 @@ type SnapshotStatus struct {
 	// FailureMessage provides more information about the error, if
 	// any occurred
 	FailureMessage *string `json:"failureMessage,omitempty"`
+
+	// LastTrigger is the value of the trigger annotation last served. It is set
+	// only once the sample serving it is published: until then, failed or interrupted
+	// collections are retried.
+	// +optional
+	LastTrigger string `json:"lastTrigger,omitempty"`
+
+	// LastTriggeredSample is the name of the sample collected because of
+	// LastTrigger
+	// +optional
+	LastTriggeredSample string `json:"lastTriggeredSample,omitempty"`
+
+	// LastChangeTriggeredTime is the last time a sample was queued because
+	// Sveltos configuration changed
+	// +optional
+	LastChangeTriggeredTime *metav1.Time `json:"lastChangeTriggeredTime,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -0,0 +1,240 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SnapshotRollbackPhase describes the state of a SnapshotRollback
+// +kubebuilder:validation:Enum:=Running;Completed;Failed
+type SnapshotRollbackPhase string
+
+const (
+	// SnapshotRollbackPhaseRunning indicates rollback is being executed
+	SnapshotRollbackPhaseRunning = SnapshotRollbackPhase("Running")
+
+	// SnapshotRollbackPhaseCompleted indicates rollback succeeded
+	SnapshotRollbackPhaseCompleted = SnapshotRollbackPhase("Completed")
+
+	// SnapshotRollbackPhaseFailed indicates rollback failed
+	SnapshotRollbackPhaseFailed = SnapshotRollbackPhase("Failed")
+)
+
+// SnapshotRollbackSpec defines the desired state of SnapshotRollback
+type SnapshotRollbackSpec struct {
+	// SnapshotName is the name of the Snapshot instance whose sample is restored
+	SnapshotName string `json:"snapshotName"`
+
+	// Sample is the name of the sample to restore.
+	// Use sveltosctl snapshot list to see all collected samples.
+	Sample string `json:"sample"`
+
+	// Namespace, if set, restricts rollback of ConfigMaps/Secrets, Cluster labels,
+	// Profiles and Sets to this namespace
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Cluster, if set, restricts rollback of cluster labels to clusters with this name
+	// +optional
+	Cluster string `json:"cluster,omitempty"`
+
+	// Profile, if set, restricts rollback to the ClusterProfile/Profile in the
+	// form kind/name
+	// +optional
+	Profile string `json:"profile,omitempty"`
+
+	// Classifier, if set, restricts rollback to the Classifier with this name
+	// +optional
+	Classifier string `json:"classifier,omitempty"`
+
+	// RoleRequest, if set, restricts rollback to the RoleRequest with this name
+	// +optional
+	RoleRequest string `json:"roleRequest,omitempty"`
+
+	// EventSource, if set, restricts rollback to the EventSource with this name
+	// +optional
+	EventSource string `json:"eventSource,omitempty"`
+
+	// EventTrigger, if set, restricts rollback to the EventTrigger with this name
+	// +optional
+	EventTrigger string `json:"eventTrigger,omitempty"`
+
+	// HealthCheck, if set, restricts rollback to the HealthCheck with this name
+	// +optional
+	HealthCheck string `json:"healthCheck,omitempty"`
+
+	// ClusterHealthCheck, if set, restricts rollback to the ClusterHealthCheck with this name
+	// +optional
+	ClusterHealthCheck string `json:"clusterHealthCheck,omitempty"`
+
+	// ClusterSet, if set, restricts rollback to the ClusterSet with this name
+	// +optional
+	ClusterSet string `json:"clusterSet,omitempty"`
+
+	// Set, if set, restricts rollback to the Set with this name
+	// +optional
+	Set string `json:"set,omitempty"`
+
+	// Prune, when set, causes resources within the rollback scope which are not
+	// present in the sample to be deleted
+	// +optional
+	Prune bool `json:"prune,omitempty"`
+
+	// DryRun, when set, causes nothing to be modified. Status reports what
+	// rollback would do.
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// Force, when set, restores the sample even if it cannot be verified against
+	// its manifest (for instance samples taken before manifests were introduced)
+	// +optional
+	Force bool `json:"force,omitempty"`
+
+	// IgnoreFields lists JSONPath-style field paths which are not compared, for instance
+	// metadata.annotations["example.com/build"]. A path can be prefixed by a kind and a
+	// colon (ClusterProfile:spec.helmCharts[*].values) to apply to that kind only.
+	// Objects whose only differences are ignored fields are left unchanged.
+	// +optional
+	IgnoreFields []string `json:"ignoreFields,omitempty"`
+
+	// SkipDefaultIgnoreRules, when set, causes fields set by the API server or kubectl
+	// (status, resourceVersion, managedFields, ...) to be compared as well
+	// +optional
+	SkipDefaultIgnoreRules bool `json:"skipDefaultIgnoreRules,omitempty"`
+}
+
+// MaxSnapshotRollbackChanges is the maximum number of field level changes reported
+// for a single object
+const MaxSnapshotRollbackChanges = 20
+
+// MaxSnapshotRollbackResults is the maximum number of objects reported in
+// SnapshotRollback status
+const MaxSnapshotRollbackResults = 100
+
+// SnapshotRollbackFieldChange is a change of a single field of an object
+type SnapshotRollbackFieldChange struct {
+	// Path is the field path, for instance spec.helmCharts[0].chartVersion
+	Path string `json:"path"`
+
+	// From is the value before rollback. Empty if field is added.
+	// Secret values are redacted.
+	// +optional
+	From string `json:"from,omitempty"`
+
+	// To is the value after rollback. Empty if field is removed.
+	// Secret values are redacted.
+	// +optional
+	To string `json:"to,omitempty"`
+}
+
+// SnapshotRollbackObjectResult reports what rollback did to a single object
+type SnapshotRollbackObjectResult struct {
+	// Kind of the object
+	Kind string `json:"kind"`
+
+	// Namespace of the object. Empty for cluster wide objects.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Name of the object
+	Name string `json:"name"`
+
+	// Action taken on the object (or, in DryRun mode, which would be taken)
+	// +kubebuilder:validation:Enum:=create;update;unchanged;delete;failed;skipped
+	Action string `json:"action"`
+
+	// Message provides more information, for instance why the action failed
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// Changes contains the field level changes of an update. At most
+	// MaxSnapshotRollbackChanges changes are reported.
+	// +kubebuilder:validation:MaxItems=20
+	// +optional
+	Changes []SnapshotRollbackFieldChange `json:"changes,omitempty"`
+}
+
+// SnapshotRollbackStatus defines the observed state of SnapshotRollback
+type SnapshotRollbackStatus struct {
+	// Phase indicates the state of the rollback
+	// +optional
+	Phase *SnapshotRollbackPhase `json:"phase,omitempty"`
+
+	// StartTime is when rollback started
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is when rollback either completed or failed
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// PreRollbackSample is the name of the sample taken right before
+	// anything was modified. Use sveltosctl snapshot undo to restore it.
+	// +optional
+	PreRollbackSample string `json:"preRollbackSample,omitempty"`
+
+	// Results contains, for each object processed and not left unchanged, the action
+	// taken. At most MaxSnapshotRollbackResults objects are reported.
+	// +kubebuilder:validation:MaxItems=100
+	// +optional
+	Results []SnapshotRollbackObjectResult `json:"results,omitempty"`
+
+	// UnchangedObjects is the number of objects processed and left unchanged.
+	// Those are not reported in Results.
+	// +optional
+	UnchangedObjects int32 `json:"unchangedObjects,omitempty"`
+
+	// UnreportedObjects is the number of objects processed, not left unchanged,
+	// and not reported in Results because of MaxSnapshotRollbackResults
+	// +optional
+	UnreportedObjects int32 `json:"unreportedObjects,omitempty"`
+
+	// FailureMessage provides more information about the error, if
+	// any occurred
+	// +optional
+	FailureMessage *string `json:"failureMessage,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:path=snapshotrollbacks,scope=Cluster
+//+kubebuilder:subresource:status
+//+kubebuilder:storageversion
+
+// SnapshotRollback is the Schema for the snapshotrollback API.
+// A SnapshotRollback is executed once. Spec cannot be changed afterwards.
+type SnapshotRollback struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="spec is immutable"
+	Spec   SnapshotRollbackSpec   `json:"spec,omitempty"`
+	Status SnapshotRollbackStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// SnapshotRollbackList contains a list of SnapshotRollback
+type SnapshotRollbackList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SnapshotRollback `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SnapshotRollback{}, &SnapshotRollbackList{})
+}
@@ -33,6 +33,7 @@ import (
 
 	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
 	"github.com/projectsveltos/sveltosctl/internal/commands"
+	"github.com/projectsveltos/sveltosctl/internal/output"
 	"github.com/projectsveltos/sveltosctl/internal/utils"
 )
 
@@ -56,10 +57,12 @@ func main() {
     generate       Generates a Kubeconfig that can later be used to register a cluster.
                    Run this command with sveltosctl pointing to the cluster you want Sveltos to manage.
     log-level      Allows changing the log verbosity.
+    techsupport    Collects logs, Sveltos resources and Events in a single archive to attach to support tickets.
     version        Display the version of sveltosctl.
 
 Options:
-	-h --help          Show this screen.
+	-h --help               Show this screen.
+	-o --output=<format>    Default output format of commands supporting --output: table, wide, json or yaml.
 
 Description:
   The sveltosctl command line tool is used to display various type of information
@@ -105,6 +108,15 @@ Description:
 		os.Exit(1)
 	}
 
+	if passedFormat := opts["--output"]; passedFormat != nil {
+		format, err := output.ParseFormat(passedFormat.(string))
+		if err != nil {
+			logger.V(logs.LogInfo).Info(fmt.Sprintf("%v\n", err))
+			os.Exit(1)
+		}
+		output.SetDefaultFormat(format)
+	}
+
 	if opts["<command>"] != nil {
 		command := opts["<command>"].(string)
 		args := append([]string{command}, opts["<args>"].([]string)...)
@@ -121,6 +133,8 @@ Description:
 			err = commands.Generate(ctx, args, logger)
 		case "log-level":
 			err = commands.LogLevel(ctx, args, logger)
+		case "techsupport":
+			err = commands.TechSupport(ctx, args, logger)
 		case "version":
 			err = commands.Version(args, logger)
 		default:
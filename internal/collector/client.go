@@ -21,13 +21,13 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"os"
 	"path"
 	"path/filepath"
-	"strings"
 	"sync"
 	"time"
 
@@ -49,10 +49,6 @@ var (
 	collectorInstance *Collector
 )
 
-const (
-	tarExtension = ".tar.gz"
-)
-
 // Collector represents a client implementing the CollectorInterface
 type Collector struct {
 	log logr.Logger
@@ -169,29 +165,35 @@ func (d *Collector) GetResult(ctx context.Context, requestorName string,
 	}
 }
 
-func (d *Collector) ListCollections(storage, requestorName string, collectionType CollectionType,
+func (d *Collector) ListCollections(storage Storage, requestorName string, collectionType CollectionType,
 	logger logr.Logger) ([]string, error) {
 
 	return listCollectionsForRequestor(storage, requestorName, collectionType, logger)
 }
 
-func (d *Collector) GetFolder(storage, requestorName string, collectionType CollectionType,
+func (d *Collector) GetFolder(storage Storage, requestorName string, collectionType CollectionType,
 	logger logr.Logger) (*string, error) {
 
 	l := logger.WithValues("requestor", requestorName, "type", collectionType.string())
 	l.V(logs.LogDebug).Info("getting directory containing collections for instance")
 
-	artifactFolder := getArtifactFolderName(storage, requestorName, collectionType)
+	artifactFolder := getArtifactFolderName(requestorName, collectionType)
 
-	if _, err := os.Stat(artifactFolder); os.IsNotExist(err) {
-		logger.V(logs.LogDebug).Info(fmt.Sprintf("directory %s not found", artifactFolder))
+	exist, err := storage.Exists(artifactFolder)
+	if err != nil {
 		return nil, err
 	}
+	if !exist {
+		logger.V(logs.LogDebug).Info(fmt.Sprintf("directory %s not found", artifactFolder))
+		return nil, &fs.PathError{Op: "stat", Path: artifactFolder, Err: fs.ErrNotExist}
+	}
 
 	return &artifactFolder, nil
 }
 
-func (d *Collector) TarDir(src string, logger logr.Logger) error {
+// TarDir creates a gzip compressed tar archive, output, containing src directory.
+// src is left untouched: removing it, if needed, is up to the caller.
+func (d *Collector) TarDir(src, output string, logger logr.Logger) error {
 	logger = logger.WithValues("folder", src)
 	logger.V(logs.LogDebug).Info("compress directory")
 	var buf bytes.Buffer
@@ -200,19 +202,18 @@ func (d *Collector) TarDir(src string, logger logr.Logger) error {
 		return err
 	}
 
-	base := filepath.Base(src)
-	output := filepath.Join(src, base+tarExtension)
-
 	err := os.MkdirAll(filepath.Dir(output), permission0755)
 	if err != nil {
 		return err
 	}
 
-	fileToWrite, err := os.OpenFile(output, os.O_CREATE|os.O_RDWR, os.FileMode(permission0600))
+	fileToWrite, err := os.OpenFile(output, os.O_CREATE|os.O_RDWR|os.O_TRUNC, os.FileMode(permission0600))
 	if err != nil {
 		logger.V(logs.LogInfo).Info(fmt.Sprintf("compress failed with error :%v", err))
 		return err
 	}
+	defer fileToWrite.Close()
+
 	if _, err := io.Copy(fileToWrite, &buf); err != nil {
 		logger.V(logs.LogInfo).Info(fmt.Sprintf("compress failed with error :%v", err))
 		return err
@@ -220,27 +221,16 @@ func (d *Collector) TarDir(src string, logger logr.Logger) error {
 	return nil
 }
 
-func (d *Collector) GetFolderPath(storage, requestorName string, collectionType CollectionType, t time.Time) string {
-	artifactFolder := getArtifactFolderName(storage, requestorName, collectionType)
+func (d *Collector) GetFolderPath(requestorName string, collectionType CollectionType, t time.Time) string {
+	artifactFolder := getArtifactFolderName(requestorName, collectionType)
 	timeFolder := t.Format(timeFormat)
-	return filepath.Join(artifactFolder, timeFolder)
+	return path.Join(artifactFolder, timeFolder)
 }
 
-func (d *Collector) GetNamespacedResources(folder, kind string, logger logr.Logger,
+func (d *Collector) GetNamespacedResources(storage Storage, folder, kind string, logger logr.Logger,
 ) (map[string][]*unstructured.Unstructured, error) {
 
-	fileInfo, err := getFileInfo(folder, logger)
-	if err != nil {
-		return nil, err
-	}
-
-	if !fileInfo.IsDir() {
-		msg := fmt.Sprintf("file %s is not a collection directory", folder)
-		logger.V(logs.LogDebug).Info(msg)
-		return nil, fmt.Errorf("%s", msg)
-	}
-
-	files, err := os.ReadDir(folder)
+	files, err := storage.ReadDir(folder)
 	if err != nil {
 		logger.V(logs.LogDebug).Info(fmt.Sprintf("failed to list subdirectories in %s. Err: %v",
 			folder, err))
@@ -251,16 +241,16 @@ func (d *Collector) GetNamespacedResources(folder, kind string, logger logr.Logg
 	// and collect resources of the specified Kind in each subdirectory
 	result := make(map[string][]*unstructured.Unstructured)
 	for i := range files {
-		if files[i].IsDir() {
-			namespaceDirectory := filepath.Join(folder, files[i].Name())
-			r, err := d.getResourcesForKind(namespaceDirectory, kind, logger)
+		if files[i].IsDir {
+			namespaceDirectory := path.Join(folder, files[i].Name)
+			r, err := d.getResourcesForKind(storage, namespaceDirectory, kind, logger)
 			if err != nil {
 				return nil, err
 			}
 			if len(r) > 0 {
 				logger.V(logs.LogDebug).Info(fmt.Sprintf("found %d resources of kind %s in namespace %s (folder %s)",
-					len(r), kind, files[i].Name(), namespaceDirectory))
-				result[files[i].Name()] = r
+					len(r), kind, files[i].Name, namespaceDirectory))
+				result[files[i].Name] = r
 			}
 		}
 	}
@@ -268,39 +258,39 @@ func (d *Collector) GetNamespacedResources(folder, kind string, logger logr.Logg
 	return result, nil
 }
 
-func (d *Collector) GetClusterResources(folder, kind string, logger logr.Logger,
+func (d *Collector) GetClusterResources(storage Storage, folder, kind string, logger logr.Logger,
 ) ([]*unstructured.Unstructured, error) {
 
-	fileInfo, err := getFileInfo(folder, logger)
+	exist, err := storage.Exists(folder)
 	if err != nil {
 		return nil, err
 	}
-
-	if !fileInfo.IsDir() {
-		msg := fmt.Sprintf("file %s is not a collection directory", folder)
+	if !exist {
+		msg := fmt.Sprintf("directory %s does not exist", folder)
 		logger.V(logs.LogDebug).Info(msg)
-		return nil, fmt.Errorf("%s", msg)
+		return nil, &fs.PathError{Op: "stat", Path: folder, Err: fs.ErrNotExist}
 	}
 
-	return d.getResourcesForKind(folder, kind, logger)
+	return d.getResourcesForKind(storage, folder, kind, logger)
 }
 
-func (d *Collector) getResourcesForKind(directory, kind string, logger logr.Logger) ([]*unstructured.Unstructured, error) {
+func (d *Collector) getResourcesForKind(storage Storage, directory, kind string, logger logr.Logger,
+) ([]*unstructured.Unstructured, error) {
+
 	// Each directory, contains one subdirectory per Kind
 	// For instance /<whatever>/<snapshotInstanceName>/<dateSnaphostTaken>/<namespaceName>/<kindName>
 	// within such directory there all resources of that type found at the time snapshot was taken
 
-	kindPath := filepath.Join(directory, kind)
+	kindPath := path.Join(directory, kind)
 	logger.V(logs.LogDebug).Info(fmt.Sprintf("find resource of kind %s in folder %s", kind, kindPath))
 
-	if _, err := os.Stat(kindPath); os.IsNotExist(err) {
-		logger.V(logs.LogDebug).Info(fmt.Sprintf("Subdirectory %s contains no resource of kind %s",
-			directory, kind))
-		return nil, nil
-	}
-
-	files, err := os.ReadDir(kindPath)
+	files, err := storage.ReadDir(kindPath)
 	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			logger.V(logs.LogDebug).Info(fmt.Sprintf("Subdirectory %s contains no resource of kind %s",
+				directory, kind))
+			return nil, nil
+		}
 		logger.V(logs.LogDebug).Info(fmt.Sprintf("failed to list subdirectories in %s. Err: %v",
 			kindPath, err))
 		return nil, err
@@ -308,12 +298,12 @@ func (d *Collector) getResourcesForKind(directory, kind string, logger logr.Logg
 
 	result := make([]*unstructured.Unstructured, 0)
 	for i := range files {
-		if files[i].IsDir() {
+		if files[i].IsDir {
 			continue
 		}
 		logger.V(logs.LogDebug).Info(fmt.Sprintf("collecting %s resources in directory %s",
 			kind, kindPath))
-		content, err := os.ReadFile(filepath.Join(kindPath, files[i].Name()))
+		content, err := storage.ReadFile(path.Join(kindPath, files[i].Name))
 		if err != nil {
 			return nil, err
 		}
@@ -328,6 +318,35 @@ func (d *Collector) getResourcesForKind(directory, kind string, logger logr.Logg
 	return result, nil
 }
 
+// GetSampleObjects returns all objects, of any kind, stored in the sample in folder
+func (d *Collector) GetSampleObjects(storage Storage, folder string, logger logr.Logger,
+) ([]*unstructured.Unstructured, error) {
+
+	files, err := listSampleFiles(storage, folder)
+	if err != nil {
+		logger.V(logs.LogDebug).Info(fmt.Sprintf("failed to list objects in %s. Err: %v", folder, err))
+		return nil, err
+	}
+
+	result := make([]*unstructured.Unstructured, 0, len(files))
+	for i := range files {
+		if !isObjectFile(files[i]) {
+			continue
+		}
+		content, err := storage.ReadFile(path.Join(folder, files[i]))
+		if err != nil {
+			return nil, err
+		}
+		u, err := d.GetUnstructured(content)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, u)
+	}
+
+	return result, nil
+}
+
 // GetUnstructured returns an unstructured given a []bytes containing it
 func (d *Collector) GetUnstructured(object []byte) (*unstructured.Unstructured, error) {
 	request := &unstructured.Unstructured{}
@@ -357,7 +376,7 @@ func (d *Collector) IsInProgress(requestorName string, collectionType Collection
 	return false
 }
 
-func (d *Collector) CleanupEntries(storage, requestorName string, collectionType CollectionType) error {
+func (d *Collector) CleanupEntries(storage Storage, requestorName string, collectionType CollectionType) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
@@ -381,11 +400,11 @@ func (d *Collector) CleanupEntries(storage, requestorName string, collectionType
 
 	delete(d.results, key)
 
-	artifactFolder := getArtifactFolderName(storage, requestorName, collectionType)
-	return os.RemoveAll(artifactFolder)
+	artifactFolder := getArtifactFolderName(requestorName, collectionType)
+	return storage.RemoveAll(artifactFolder)
 }
 
-func (d *Collector) CleanOldCollections(storage, requestorName string, collectionType CollectionType,
+func (d *Collector) CleanOldCollections(storage Storage, requestorName string, collectionType CollectionType,
 	limit int32, logger logr.Logger) error {
 
 	return cleanOldCollections(storage, requestorName, collectionType, limit, logger)
@@ -393,7 +412,7 @@ func (d *Collector) CleanOldCollections(storage, requestorName string, collectio
 
 // DumpObject is a helper function to generically dump resource definition
 // given the resource reference and file path for dumping location.
-func (d *Collector) DumpObject(resource client.Object, logPath string, logger logr.Logger) error {
+func (d *Collector) DumpObject(storage Storage, resource client.Object, logPath string, logger logr.Logger) error {
 	// Do not store resource version
 	resource.SetResourceVersion("")
 	err := addTypeInformationToObject(resource)
@@ -424,24 +443,14 @@ func (d *Collector) DumpObject(resource client.Object, logPath string, logger lo
 	name := metaObj.GetName()
 
 	resourceFilePath := path.Join(logPath, namespace, kind, name+".yaml")
-	err = os.MkdirAll(filepath.Dir(resourceFilePath), permission0755)
-	if err != nil {
-		return err
-	}
-
-	f, err := os.OpenFile(resourceFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, permission0644)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
 
 	logger.V(logs.LogDebug).Info(fmt.Sprintf("storing resource in %s", resourceFilePath))
-	return os.WriteFile(f.Name(), resourceYAML, permission0600)
+	return storage.WriteFile(resourceFilePath, resourceYAML)
 }
 
 // DumpPodLogs collects logs for all containers in a pod and store them.
 // If pod has restarted, it will try to collect log from previous run as well.
-func (d *Collector) DumpPodLogs(ctx context.Context, clientSet *kubernetes.Clientset, logPath string,
+func (d *Collector) DumpPodLogs(ctx context.Context, clientSet kubernetes.Interface, logPath string,
 	since *int64, pod *corev1.Pod) error {
 
 	for i := range pod.Spec.Containers {
@@ -483,7 +492,7 @@ func (d *Collector) DumpPodLogs(ctx context.Context, clientSet *kubernetes.Clien
 }
 
 // collectLogs collect logs for a given namespace/pod container
-func collectLogs(ctx context.Context, clientset *kubernetes.Clientset,
+func collectLogs(ctx context.Context, clientset kubernetes.Interface,
 	namespace, podName, containerName, filename string, since *int64, previous bool) (err error) {
 	// open output file
 	var fo *os.File
@@ -526,24 +535,6 @@ func collectLogs(ctx context.Context, clientset *kubernetes.Clientset,
 	return err
 }
 
-func getFileInfo(folder string, logger logr.Logger) (fs.FileInfo, error) {
-	file, err := os.Open(folder)
-	if err != nil {
-		logger.V(logs.LogDebug).Info(fmt.Sprintf("failed to open directory %s. Err: %v",
-			folder, err))
-		return nil, err
-	}
-
-	fileInfo, err := file.Stat()
-	if err != nil {
-		logger.V(logs.LogDebug).Info(fmt.Sprintf("failed to get FileInfo for %s. Err: %v",
-			folder, err))
-		return nil, err
-	}
-
-	return fileInfo, nil
-}
-
 // startWorkloadWorkers initializes all internal structures and starts
 // pool of workers
 // - numWorker is number of requested workers
@@ -576,8 +567,15 @@ func compress(src string, buf io.Writer, logger logr.Logger) error {
 	zr := gzip.NewWriter(buf)
 	tw := tar.NewWriter(zr)
 
+	// entries in the archive are relative to src parent directory
+	base := filepath.Dir(src)
+
 	// walk through every file in the folder
 	err := filepath.Walk(src, func(file string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
 		// generate tar header
 		header, inErr := tar.FileInfoHeader(fi, file)
 		if inErr != nil {
@@ -586,7 +584,11 @@ func compress(src string, buf io.Writer, logger logr.Logger) error {
 
 		// must provide real name
 		// (see https://golang.org/src/archive/tar/common.go?#L626)
-		header.Name = filepath.ToSlash(file)
+		name, inErr := filepath.Rel(base, file)
+		if inErr != nil {
+			return inErr
+		}
+		header.Name = filepath.ToSlash(name)
 
 		// write header
 		if inErr := tw.WriteHeader(header); inErr != nil {
@@ -598,6 +600,7 @@ func compress(src string, buf io.Writer, logger logr.Logger) error {
 			if inErr != nil {
 				return inErr
 			}
+			defer data.Close()
 			if _, inErr := io.Copy(tw, data); inErr != nil {
 				return inErr
 			}
@@ -617,34 +620,5 @@ func compress(src string, buf io.Writer, logger logr.Logger) error {
 		return err
 	}
 
-	logger.V(logs.LogDebug).Info("removing old content")
-	if err := removeContents(src); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func removeContents(dir string) error {
-	d, err := os.Open(dir)
-	if err != nil {
-		return err
-	}
-	defer d.Close()
-	names, err := d.Readdirnames(-1)
-	if err != nil {
-		return err
-	}
-
-	for _, name := range names {
-		if strings.Contains(name, tarExtension) {
-			// Do not remove the tar.gz file
-			continue
-		}
-		err = os.RemoveAll(filepath.Join(dir, name))
-		if err != nil {
-			return err
-		}
-	}
 	return nil
 }
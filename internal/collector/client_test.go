@@ -17,8 +17,11 @@ limitations under the License.
 package collector_test
 
 import (
+	"archive/tar"
+	"compress/gzip"
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"time"
@@ -228,7 +231,7 @@ var _ = Describe("Client", func() {
 		d.SetJobQueue(snapshotName, collector.Snapshot)
 		Expect(len(d.GetJobQueue())).To(Equal(1))
 
-		Expect(d.CleanupEntries(storageDir, snapshotName, collector.Snapshot)).To(Succeed())
+		Expect(d.CleanupEntries(collector.NewFilesystemStorage(storageDir), snapshotName, collector.Snapshot)).To(Succeed())
 		Expect(len(d.GetDirty())).To(Equal(0))
 		Expect(len(d.GetInProgress())).To(Equal(1))
 		Expect(len(d.GetJobQueue())).To(Equal(0))
@@ -258,7 +261,7 @@ var _ = Describe("Client", func() {
 			namespaceFolder := filepath.Join(snapshotFolder, files[i].Name())
 			By(fmt.Sprintf("finding resources in folder %s", namespaceFolder))
 			instance := collector.GetClient()
-			list, err := collector.GetResourcesForKind(instance, namespaceFolder, configv1beta1.ClusterConfigurationKind,
+			list, err := collector.GetResourcesForKind(instance, localStorage, namespaceFolder, configv1beta1.ClusterConfigurationKind,
 				textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
 			Expect(err).To(BeNil())
 			Expect(list).ToNot(BeNil())
@@ -277,7 +280,7 @@ var _ = Describe("Client", func() {
 
 		By(fmt.Sprintf("finding resources in folder %s", snapshotFolder))
 		instance := collector.GetClient()
-		list, err := collector.GetResourcesForKind(instance, snapshotFolder, configv1beta1.ClusterProfileKind,
+		list, err := collector.GetResourcesForKind(instance, localStorage, snapshotFolder, configv1beta1.ClusterProfileKind,
 			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
 		Expect(err).To(BeNil())
 		Expect(list).ToNot(BeNil())
@@ -289,7 +292,7 @@ var _ = Describe("Client", func() {
 		defer os.RemoveAll(snapshotFolder)
 
 		d := collector.GetClient()
-		resourceMap, err := d.GetNamespacedResources(snapshotFolder, configv1beta1.ClusterConfigurationKind,
+		resourceMap, err := d.GetNamespacedResources(localStorage, snapshotFolder, configv1beta1.ClusterConfigurationKind,
 			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
 		Expect(err).To(BeNil())
 		Expect(resourceMap).ToNot(BeNil())
@@ -332,17 +335,62 @@ var _ = Describe("Client", func() {
 		By(fmt.Sprintf("Adding ClusterConfiguration %s/%s to directory %s",
 			cc.Namespace, cc.Name, secondSnapshotFolder))
 		Expect(collector.AddTypeInformationToObject(cc)).To(Succeed())
-		Expect(d.DumpObject(cc, secondSnapshotFolder,
+		Expect(d.DumpObject(localStorage, cc, secondSnapshotFolder,
 			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
 
 		// Set storage properly for ListCollection
 		storage := filepath.Dir(filepath.Dir(filepath.Dir(snapshotFolder)))
-		results, err := d.ListCollections(storage, snapshotName, collector.Snapshot,
+		results, err := d.ListCollections(collector.NewFilesystemStorage(storage), snapshotName, collector.Snapshot,
 			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
 		Expect(err).To(BeNil())
 		Expect(len(results)).To(Equal(2))
 	})
 
+	It("TarDir creates an archive with directory content", func() {
+		dir, err := os.MkdirTemp("", randomString())
+		Expect(err).To(BeNil())
+		defer os.RemoveAll(dir)
+
+		src := filepath.Join(dir, randomString())
+		fileName := randomString()
+		content := randomString()
+		Expect(localStorage.WriteFile(filepath.Join(src, "logs", fileName), []byte(content))).To(Succeed())
+
+		output := filepath.Join(dir, randomString(), "bundle.tar.gz")
+		d := collector.GetClient()
+		Expect(d.TarDir(src, output,
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
+
+		By("verifying archive entries are relative to src parent directory")
+		f, err := os.Open(output)
+		Expect(err).To(BeNil())
+		defer f.Close()
+		zr, err := gzip.NewReader(f)
+		Expect(err).To(BeNil())
+		tr := tar.NewReader(zr)
+
+		found := false
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			Expect(err).To(BeNil())
+			if header.Name == filepath.Base(src)+"/logs/"+fileName {
+				data, err := io.ReadAll(tr)
+				Expect(err).To(BeNil())
+				Expect(string(data)).To(Equal(content))
+				found = true
+			}
+		}
+		Expect(found).To(BeTrue())
+
+		By("verifying src is left untouched")
+		data, err := os.ReadFile(filepath.Join(src, "logs", fileName))
+		Expect(err).To(BeNil())
+		Expect(string(data)).To(Equal(content))
+	})
+
 	It("GetFolder returns the folder for a given requestor instance", func() {
 		snapshotName := randomString()
 		storage := randomString()
@@ -363,9 +411,71 @@ var _ = Describe("Client", func() {
 		storage = parentUp3
 
 		d := collector.GetClient()
-		_, err := d.GetFolder(storage, snapshotName, collector.Snapshot,
+		_, err := d.GetFolder(collector.NewFilesystemStorage(storage), snapshotName, collector.Snapshot,
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
+		Expect(err).To(BeNil())
+	})
+
+	It("WriteSampleMetadata/GetSampleMetadata store and return sample metadata", func() {
+		snapshotName := randomString()
+		storage := randomString()
+		By(fmt.Sprintf("snapshot instance %s (storage %s)", snapshotName, storage))
+
+		snapshotFolder := createDirectoryWithClusterConfigurations(storage, snapshotName)
+		defer os.RemoveAll(snapshotFolder)
+
+		d := collector.GetClient()
+
+		// Samples without metadata are reported as scheduled
+		metadata, err := d.GetSampleMetadata(localStorage, snapshotFolder)
+		Expect(err).To(BeNil())
+		Expect(metadata.Trigger).To(Equal(collector.SampleTriggerSchedule))
+
+		Expect(d.WriteSampleMetadata(localStorage, snapshotFolder,
+			&collector.SampleMetadata{Trigger: collector.SampleTriggerPreRollback})).To(Succeed())
+
+		metadata, err = d.GetSampleMetadata(localStorage, snapshotFolder)
+		Expect(err).To(BeNil())
+		Expect(metadata.Trigger).To(Equal(collector.SampleTriggerPreRollback))
+
+		// Metadata file must not be reported as a resource
+		resourceMap, err := d.GetNamespacedResources(localStorage, snapshotFolder, configv1beta1.ClusterConfigurationKind,
 			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
 		Expect(err).To(BeNil())
+		Expect(len(resourceMap)).ToNot(BeZero())
+	})
+
+	It("GetLatestSample returns the most recent sample taken because of a given trigger", func() {
+		snapshotName := randomString()
+		storage := randomString()
+		By(fmt.Sprintf("snapshot instance %s (storage %s)", snapshotName, storage))
+
+		snapshotFolder := createDirectoryWithClusterConfigurations(storage, snapshotName)
+		defer os.RemoveAll(snapshotFolder)
+
+		// Set storage so keys are relative to it
+		storage = filepath.Dir(filepath.Dir(filepath.Dir(snapshotFolder)))
+		storageInstance := collector.NewFilesystemStorage(storage)
+		artifactFolder := collector.GetArtifactFolderName(snapshotName, collector.Snapshot)
+
+		d := collector.GetClient()
+		now := time.Now()
+		olderSample := now.Add(-time.Hour).Format(collector.TimeFormat)
+		newerSample := now.Add(-time.Minute).Format(collector.TimeFormat)
+		for _, sample := range []string{olderSample, newerSample} {
+			Expect(d.WriteSampleMetadata(storageInstance, filepath.Join(artifactFolder, sample),
+				&collector.SampleMetadata{Trigger: collector.SampleTriggerOnDemand})).To(Succeed())
+		}
+
+		sample, err := d.GetLatestSample(storageInstance, snapshotName, collector.Snapshot,
+			collector.SampleTriggerOnDemand, textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
+		Expect(err).To(BeNil())
+		Expect(sample).To(Equal(newerSample))
+
+		sample, err = d.GetLatestSample(storageInstance, snapshotName, collector.Snapshot,
+			collector.SampleTriggerPreRollback, textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
+		Expect(err).To(BeNil())
+		Expect(sample).To(BeEmpty())
 	})
 })
 
@@ -380,7 +490,7 @@ func createDirectoryWithClusterConfigurations(storage, requestorName string) str
 		By(fmt.Sprintf("Adding ClusterConfiguration %s/%s to directory %s",
 			cc.Namespace, cc.Name, snapshotDir))
 		Expect(collector.AddTypeInformationToObject(cc)).To(Succeed())
-		Expect(d.DumpObject(cc, snapshotDir,
+		Expect(d.DumpObject(localStorage, cc, snapshotDir,
 			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
 	}
 
@@ -397,7 +507,7 @@ func createDirectoryWithClusterProfiles(storage, requestorName string) string {
 		By(fmt.Sprintf("Adding ClusterProfile %s to directory %s",
 			cp.Name, snapshotDir))
 		Expect(collector.AddTypeInformationToObject(cp)).To(Succeed())
-		Expect(d.DumpObject(cp, snapshotDir,
+		Expect(d.DumpObject(localStorage, cp, snapshotDir,
 			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
 	}
 
@@ -27,6 +27,12 @@ import (
 
 	configv1beta1 "github.com/projectsveltos/addon-controller/api/v1beta1"
 	libsveltosv1beta1 "github.com/projectsveltos/libsveltos/api/v1beta1"
+	"github.com/projectsveltos/sveltosctl/internal/collector"
+)
+
+var (
+	// localStorage is a filesystem Storage where keys are paths on the local filesystem
+	localStorage = collector.NewFilesystemStorage("")
 )
 
 func TestSnapshotter(t *testing.T) {
@@ -26,10 +26,12 @@ var (
 	GetResourcesForKind        = (*Collector).getResourcesForKind
 	AddTypeInformationToObject = addTypeInformationToObject
 	GetKey                     = getKey
+	AcquireGCLease             = acquireGCLease
 )
 
 const (
-	TimeFormat = timeFormat
+	TimeFormat          = timeFormat
+	PublishMarkerSuffix = publishMarkerSuffix
 )
 
 func (d *Collector) ClearInternalStruct() {
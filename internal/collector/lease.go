@@ -0,0 +1,179 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+)
+
+// Packing a collection skips objects already in the objects folder, while garbage collection
+// removes objects no collection references. Running both at the same time could leave a
+// collection referencing removed objects. Leases prevent that, across processes (snapshot
+// reconciler and CLI) sharing the same storage.
+// Each process first records its lease, then looks for leases of the other kind. So, of two
+// processes starting at the same time, at least one sees the other: garbage collection
+// is skipped when a collection is being packed, packing waits for garbage collection to complete.
+const (
+	// leasePrefix is prepended to the name of lease files. Leases are files stored next
+	// to the collections of a requestor, so they are never mistaken for collections.
+	leasePrefix = ".lease-"
+
+	packLeaseKind = "pack"
+	gcLeaseKind   = "gc"
+
+	// leaseDuration is how long a lease is honored. Leases left behind by a process
+	// which died are ignored once expired.
+	leaseDuration = 30 * time.Minute
+
+	leasePollInterval = time.Second
+
+	// randomIDLength is the number of random bytes identifying leases and staging folders
+	randomIDLength = 8
+)
+
+// getRandomID returns a random identifier, so names built from it are unique across processes
+func getRandomID() (string, error) {
+	id := make([]byte, randomIDLength)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(id), nil
+}
+
+// writeLease stores, next to the collections of requestorName, a lease of kind leaseKind.
+// Returns the lease key.
+func writeLease(storage Storage, requestorName string, collectionType CollectionType, leaseKind string,
+) (string, error) {
+
+	id, err := getRandomID()
+	if err != nil {
+		return "", err
+	}
+
+	artifactFolder := getArtifactFolderName(requestorName, collectionType)
+	key := path.Join(artifactFolder, fmt.Sprintf("%s%s-%s", leasePrefix, leaseKind, id))
+	expiration := time.Now().Add(leaseDuration).UTC().Format(time.RFC3339)
+
+	return key, storage.WriteFile(key, []byte(expiration))
+}
+
+// hasActiveLease returns true if a lease of kind leaseKind, not expired, exists for requestorName
+func hasActiveLease(storage Storage, requestorName string, collectionType CollectionType, leaseKind string,
+) (bool, error) {
+
+	artifactFolder := getArtifactFolderName(requestorName, collectionType)
+	entries, err := storage.ReadDir(artifactFolder)
+	if err != nil {
+		return false, err
+	}
+
+	prefix := leasePrefix + leaseKind + "-"
+	for i := range entries {
+		if entries[i].IsDir || !strings.HasPrefix(entries[i].Name, prefix) {
+			continue
+		}
+
+		data, err := storage.ReadFile(path.Join(artifactFolder, entries[i].Name))
+		if err != nil {
+			// Lease was released in the meantime
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return false, err
+		}
+
+		expiration, err := time.Parse(time.RFC3339, strings.TrimSpace(string(data)))
+		if err != nil || time.Now().After(expiration) {
+			continue
+		}
+
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func releaseLease(storage Storage, key string, logger logr.Logger) {
+	if err := storage.RemoveAll(key); err != nil {
+		logger.V(logs.LogInfo).Info(fmt.Sprintf("failed to release lease %s: %v", key, err))
+	}
+}
+
+// AcquirePackLease records that a collection for requestorName is being packed, so garbage
+// collection, by this or any other process, does not run until the returned function is called.
+// Waits for garbage collection in progress, if any, to complete.
+func (d *Collector) AcquirePackLease(storage Storage, requestorName string, collectionType CollectionType,
+	logger logr.Logger) (func(), error) {
+
+	key, err := writeLease(storage, requestorName, collectionType, packLeaseKind)
+	if err != nil {
+		return nil, err
+	}
+
+	release := func() {
+		releaseLease(storage, key, logger)
+	}
+
+	for {
+		collecting, err := hasActiveLease(storage, requestorName, collectionType, gcLeaseKind)
+		if err != nil {
+			release()
+			return nil, err
+		}
+		if !collecting {
+			return release, nil
+		}
+
+		logger.V(logs.LogDebug).Info("waiting for garbage collection to complete")
+		time.Sleep(leasePollInterval)
+	}
+}
+
+// acquireGCLease records that garbage is being collected for requestorName.
+// Returns false, and no lease, if a collection for requestorName is being packed.
+func acquireGCLease(storage Storage, requestorName string, collectionType CollectionType,
+	logger logr.Logger) (func(), bool, error) {
+
+	key, err := writeLease(storage, requestorName, collectionType, gcLeaseKind)
+	if err != nil {
+		return nil, false, err
+	}
+
+	release := func() {
+		releaseLease(storage, key, logger)
+	}
+
+	packing, err := hasActiveLease(storage, requestorName, collectionType, packLeaseKind)
+	if err != nil || packing {
+		release()
+		return nil, false, err
+	}
+
+	return release, true, nil
+}
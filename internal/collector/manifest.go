@@ -0,0 +1,399 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	utilsv1beta1 "github.com/projectsveltos/sveltosctl/api/v1beta1"
+)
+
+const (
+	// sampleManifestFile is the file, at the root of a sample folder, listing
+	// every file stored in the sample
+	sampleManifestFile = "sample-manifest.yaml"
+
+	// sampleSignatureFile is the file, at the root of a sample folder, containing the
+	// base64 encoded ed25519 signature of the manifest
+	sampleSignatureFile = "sample-manifest.sig"
+)
+
+// ManifestFile is a file stored in a sample
+type ManifestFile struct {
+	// Path of the file, relative to the sample folder
+	Path string `json:"path"`
+
+	// Digest is the SHA-256 digest of the file content. When Secrets are encrypted,
+	// digest of a Secret is an HMAC-SHA256 instead, so it reveals nothing about its content.
+	Digest string `json:"digest"`
+}
+
+// SampleManifest lists every file stored in a sample, sample metadata included.
+// Sample annotations are not listed: notes and pin state can be changed at any time.
+type SampleManifest struct {
+	// Files contains all files, sorted by path
+	Files []ManifestFile `json:"files"`
+
+	// Counts contains, per kind, the number of stored objects
+	Counts map[string]int `json:"counts"`
+}
+
+// isSampleControlFile returns true for the files, at the root of a sample, which
+// are not listed in the manifest
+func isSampleControlFile(relativePath string) bool {
+	return relativePath == sampleAnnotationsFile || relativePath == sampleManifestFile ||
+		relativePath == sampleSignatureFile
+}
+
+// isObjectFile returns true if file at relativePath, relative to the sample folder, contains
+// an object. Objects are always stored in a directory (namespace and/or kind).
+func isObjectFile(relativePath string) bool {
+	return path.Dir(relativePath) != "."
+}
+
+// listSampleFiles returns, sorted, the paths relative to folder of all files, but control files,
+// stored in folder
+func listSampleFiles(storage Storage, folder string) ([]string, error) {
+	files := make([]string, 0)
+
+	var walk func(relativeDir string) error
+	walk = func(relativeDir string) error {
+		entries, err := storage.ReadDir(path.Join(folder, relativeDir))
+		if err != nil {
+			return err
+		}
+		for i := range entries {
+			relativePath := path.Join(relativeDir, entries[i].Name)
+			if entries[i].IsDir {
+				if err := walk(relativePath); err != nil {
+					return err
+				}
+				continue
+			}
+			if !isSampleControlFile(relativePath) {
+				files = append(files, relativePath)
+			}
+		}
+		return nil
+	}
+
+	if err := walk(""); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// getFileDigest returns the digest of data, content of the file at relativePath of the sample
+// in folder. expected is the digest the manifest of the sample has for the file, if any.
+func getFileDigest(storage Storage, folder, relativePath string, data []byte, expected string,
+) (string, error) {
+
+	s, ok := getEncryptedStorage(storage)
+	// Samples stored before Secrets were encrypted keep plain digests
+	if !ok || !isSecretFile(relativePath) || strings.HasPrefix(expected, secretDigestPrefix) {
+		return getDigest(data), nil
+	}
+
+	return s.getSecretDigest(path.Dir(folder), data)
+}
+
+// buildSampleManifest returns the manifest describing the current content of folder.
+// Objects are stored in <namespace>/<kind>/<name>.yaml or <kind>/<name>.yaml, so
+// kind is the name of the directory containing the file. Files at the root of folder
+// (sample metadata) are not objects and are not counted.
+// expected, if set, is the manifest stored for the sample.
+func buildSampleManifest(storage Storage, folder string, expected *SampleManifest) (*SampleManifest, error) {
+	files, err := listSampleFiles(storage, folder)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &SampleManifest{
+		Files:  make([]ManifestFile, len(files)),
+		Counts: make(map[string]int),
+	}
+	for i := range files {
+		data, err := storage.ReadFile(path.Join(folder, files[i]))
+		if err != nil {
+			return nil, err
+		}
+		digest, err := getFileDigest(storage, folder, files[i], data, expected.getDigest(files[i]))
+		if err != nil {
+			return nil, err
+		}
+		manifest.Files[i] = ManifestFile{Path: files[i], Digest: digest}
+		if isObjectFile(files[i]) {
+			manifest.Counts[path.Base(path.Dir(files[i]))]++
+		}
+	}
+
+	return manifest, nil
+}
+
+// WriteSampleManifest stores, in folder, the manifest of the sample stored in folder.
+// If key is set, manifest is signed as well.
+// Digests are computed on content as returned by storage, so Secrets re-encrypted with
+// a new key still match the manifest.
+func (d *Collector) WriteSampleManifest(storage Storage, folder string, key ed25519.PrivateKey) error {
+	manifest, err := buildSampleManifest(storage, folder, nil)
+	if err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+
+	if err := storage.WriteFile(path.Join(folder, sampleManifestFile), data); err != nil {
+		return err
+	}
+
+	if key == nil {
+		return nil
+	}
+
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(key, data))
+	return storage.WriteFile(path.Join(folder, sampleSignatureFile), []byte(signature))
+}
+
+// VerifySample verifies the content of the sample stored in folder matches its manifest.
+// If publicKey is set, manifest signature is verified as well.
+// Returns the manifest.
+func (d *Collector) VerifySample(storage Storage, folder string, publicKey ed25519.PublicKey,
+) (*SampleManifest, error) {
+
+	manifestPath := path.Join(folder, sampleManifestFile)
+	exist, err := storage.Exists(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, errors.New("sample has no manifest")
+	}
+
+	data, err := storage.ReadFile(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if publicKey != nil {
+		if err := verifyManifestSignature(storage, folder, data, publicKey); err != nil {
+			return nil, err
+		}
+	}
+
+	expected := &SampleManifest{}
+	if err := yaml.Unmarshal(data, expected); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+
+	current, err := buildSampleManifest(storage, folder, expected)
+	if err != nil {
+		return nil, err
+	}
+
+	if problems := compareManifests(expected, current); len(problems) > 0 {
+		return nil, fmt.Errorf("sample does not match its manifest: %s", strings.Join(problems, "; "))
+	}
+
+	return expected, nil
+}
+
+func verifyManifestSignature(storage Storage, folder string, manifest []byte, publicKey ed25519.PublicKey) error {
+	signaturePath := path.Join(folder, sampleSignatureFile)
+	exist, err := storage.Exists(signaturePath)
+	if err != nil {
+		return err
+	}
+	if !exist {
+		return errors.New("sample manifest is not signed")
+	}
+
+	encoded, err := storage.ReadFile(signaturePath)
+	if err != nil {
+		return err
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+	if err != nil {
+		return fmt.Errorf("invalid manifest signature: %w", err)
+	}
+
+	if !ed25519.Verify(publicKey, manifest, signature) {
+		return errors.New("manifest signature does not match")
+	}
+
+	return nil
+}
+
+// getDigest returns the digest of file at relativePath, empty if m is nil or does not
+// list the file
+func (m *SampleManifest) getDigest(relativePath string) string {
+	if m == nil {
+		return ""
+	}
+
+	i, found := sort.Find(len(m.Files), func(i int) int {
+		return strings.Compare(relativePath, m.Files[i].Path)
+	})
+	if !found {
+		return ""
+	}
+	return m.Files[i].Digest
+}
+
+// compareManifests returns a description of each difference between expected and current
+func compareManifests(expected, current *SampleManifest) []string {
+	problems := make([]string, 0)
+
+	currentDigests := make(map[string]string, len(current.Files))
+	for i := range current.Files {
+		currentDigests[current.Files[i].Path] = current.Files[i].Digest
+	}
+
+	expectedFiles := make(map[string]bool, len(expected.Files))
+	for i := range expected.Files {
+		f := &expected.Files[i]
+		expectedFiles[f.Path] = true
+		digest, ok := currentDigests[f.Path]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s is missing", f.Path))
+		} else if digest != f.Digest {
+			problems = append(problems, fmt.Sprintf("%s was modified", f.Path))
+		}
+	}
+
+	for i := range current.Files {
+		if !expectedFiles[current.Files[i].Path] {
+			problems = append(problems, fmt.Sprintf("%s is not in manifest", current.Files[i].Path))
+		}
+	}
+
+	kinds := make([]string, 0, len(expected.Counts))
+	for kind := range expected.Counts {
+		kinds = append(kinds, kind)
+	}
+	for kind := range current.Counts {
+		if _, ok := expected.Counts[kind]; !ok {
+			kinds = append(kinds, kind)
+		}
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		if expected.Counts[kind] != current.Counts[kind] {
+			problems = append(problems, fmt.Sprintf("%d %s objects expected, %d found",
+				expected.Counts[kind], kind, current.Counts[kind]))
+		}
+	}
+
+	return problems
+}
+
+func getSigningSecret(ctx context.Context, c client.Reader, signing *utilsv1beta1.SnapshotSigning,
+) (*corev1.Secret, error) {
+
+	secretRef := signing.KeySecretRef
+	secret := &corev1.Secret{}
+	err := c.Get(ctx, types.NamespacedName{Namespace: secretRef.Namespace, Name: secretRef.Name}, secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get signing Secret %s/%s: %w",
+			secretRef.Namespace, secretRef.Name, err)
+	}
+
+	return secret, nil
+}
+
+func getPrivateKey(data []byte) (ed25519.PrivateKey, error) {
+	switch len(data) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(data), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(data), nil
+	default:
+		return nil, fmt.Errorf("private key must be %d or %d bytes long",
+			ed25519.SeedSize, ed25519.PrivateKeySize)
+	}
+}
+
+// GetSigningKey returns the private key used to sign sample manifests
+func GetSigningKey(ctx context.Context, c client.Reader, signing *utilsv1beta1.SnapshotSigning,
+) (ed25519.PrivateKey, error) {
+
+	secret, err := getSigningSecret(ctx, c, signing)
+	if err != nil {
+		return nil, err
+	}
+
+	data, ok := secret.Data[utilsv1beta1.SigningPrivateKeyKey]
+	if !ok {
+		return nil, fmt.Errorf("signing Secret %s/%s does not contain key %s",
+			secret.Namespace, secret.Name, utilsv1beta1.SigningPrivateKeyKey)
+	}
+
+	key, err := getPrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing Secret %s/%s: %w", secret.Namespace, secret.Name, err)
+	}
+
+	return key, nil
+}
+
+// GetVerificationKey returns the public key used to verify sample manifest signatures
+func GetVerificationKey(ctx context.Context, c client.Reader, signing *utilsv1beta1.SnapshotSigning,
+) (ed25519.PublicKey, error) {
+
+	secret, err := getSigningSecret(ctx, c, signing)
+	if err != nil {
+		return nil, err
+	}
+
+	if data, ok := secret.Data[utilsv1beta1.SigningPublicKeyKey]; ok {
+		if len(data) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid signing Secret %s/%s: public key must be %d bytes long",
+				secret.Namespace, secret.Name, ed25519.PublicKeySize)
+		}
+		return ed25519.PublicKey(data), nil
+	}
+
+	data, ok := secret.Data[utilsv1beta1.SigningPrivateKeyKey]
+	if !ok {
+		return nil, fmt.Errorf("signing Secret %s/%s contains neither key %s nor key %s",
+			secret.Namespace, secret.Name, utilsv1beta1.SigningPublicKeyKey, utilsv1beta1.SigningPrivateKeyKey)
+	}
+
+	key, err := getPrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing Secret %s/%s: %w", secret.Namespace, secret.Name, err)
+	}
+
+	return key.Public().(ed25519.PublicKey), nil
+}
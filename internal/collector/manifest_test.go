@@ -0,0 +1,183 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"os"
+	"path"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/v2/textlogger"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	utilsv1beta1 "github.com/projectsveltos/sveltosctl/api/v1beta1"
+	"github.com/projectsveltos/sveltosctl/internal/collector"
+)
+
+var _ = Describe("Sample manifest", func() {
+	var root string
+	var storage collector.Storage
+	var folder string
+	var configMap *corev1.ConfigMap
+
+	BeforeEach(func() {
+		var err error
+		root, err = os.MkdirTemp("", randomString())
+		Expect(err).To(BeNil())
+
+		storage = collector.NewFilesystemStorage(root)
+		folder = path.Join("snapshot", randomString(), randomString())
+
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: randomString(), Name: randomString()},
+			Data:       map[string]string{"key": randomString()},
+		}
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: randomString(), Name: randomString()},
+			Data:       map[string][]byte{"password": []byte(randomString())},
+		}
+
+		logger := textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1)))
+		Expect(collector.GetClient().DumpObject(storage, configMap.DeepCopy(), folder, logger)).To(Succeed())
+		Expect(collector.GetClient().DumpObject(storage, secret, folder, logger)).To(Succeed())
+		Expect(collector.GetClient().WriteSampleMetadata(storage, folder,
+			&collector.SampleMetadata{Trigger: collector.SampleTriggerOnDemand})).To(Succeed())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(root)
+	})
+
+	It("VerifySample detects modified, added and removed files", func() {
+		d := collector.GetClient()
+		_, err := d.VerifySample(storage, folder, nil)
+		Expect(err).ToNot(BeNil())
+
+		Expect(d.WriteSampleManifest(storage, folder, nil)).To(Succeed())
+		manifest, err := d.VerifySample(storage, folder, nil)
+		Expect(err).To(BeNil())
+		// Metadata is listed but not counted as an object
+		Expect(manifest.Files).To(HaveLen(3))
+		Expect(manifest.Counts).To(Equal(map[string]int{"ConfigMap": 1, "Secret": 1}))
+
+		// Annotations can change at any time
+		Expect(d.WriteSampleAnnotations(storage, folder,
+			&collector.SampleAnnotations{Note: randomString(), Pinned: true})).To(Succeed())
+		_, err = d.VerifySample(storage, folder, nil)
+		Expect(err).To(BeNil())
+
+		// Metadata cannot
+		Expect(d.WriteSampleMetadata(storage, folder,
+			&collector.SampleMetadata{Trigger: collector.SampleTriggerOnDemand,
+				Scope: &collector.SampleScope{IncludeKinds: []string{"ConfigMap"}}})).To(Succeed())
+		_, err = d.VerifySample(storage, folder, nil)
+		Expect(err).ToNot(BeNil())
+		Expect(err.Error()).To(ContainSubstring("sample-metadata.yaml was modified"))
+		Expect(d.WriteSampleMetadata(storage, folder,
+			&collector.SampleMetadata{Trigger: collector.SampleTriggerOnDemand})).To(Succeed())
+		_, err = d.VerifySample(storage, folder, nil)
+		Expect(err).To(BeNil())
+
+		configMapFile := path.Join(folder, configMap.Namespace, "ConfigMap", configMap.Name+".yaml")
+		original, err := storage.ReadFile(configMapFile)
+		Expect(err).To(BeNil())
+		Expect(storage.WriteFile(configMapFile, append(original, []byte("# modified")...))).To(Succeed())
+		_, err = d.VerifySample(storage, folder, nil)
+		Expect(err).ToNot(BeNil())
+		Expect(err.Error()).To(ContainSubstring("was modified"))
+
+		Expect(storage.RemoveAll(configMapFile)).To(Succeed())
+		_, err = d.VerifySample(storage, folder, nil)
+		Expect(err).ToNot(BeNil())
+		Expect(err.Error()).To(ContainSubstring("is missing"))
+
+		Expect(storage.WriteFile(configMapFile, original)).To(Succeed())
+		Expect(storage.WriteFile(path.Join(folder, "ClusterProfile", randomString()+".yaml"),
+			[]byte(randomString()))).To(Succeed())
+		_, err = d.VerifySample(storage, folder, nil)
+		Expect(err).ToNot(BeNil())
+		Expect(err.Error()).To(ContainSubstring("is not in manifest"))
+	})
+
+	It("VerifySample verifies manifest signature", func() {
+		publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		Expect(err).To(BeNil())
+		otherPublicKey, _, err := ed25519.GenerateKey(rand.Reader)
+		Expect(err).To(BeNil())
+
+		d := collector.GetClient()
+		Expect(d.WriteSampleManifest(storage, folder, nil)).To(Succeed())
+		_, err = d.VerifySample(storage, folder, publicKey)
+		Expect(err).ToNot(BeNil())
+		Expect(err.Error()).To(ContainSubstring("not signed"))
+
+		Expect(d.WriteSampleManifest(storage, folder, privateKey)).To(Succeed())
+		_, err = d.VerifySample(storage, folder, publicKey)
+		Expect(err).To(BeNil())
+		_, err = d.VerifySample(storage, folder, otherPublicKey)
+		Expect(err).ToNot(BeNil())
+		Expect(err.Error()).To(ContainSubstring("signature does not match"))
+	})
+
+	It("GetSigningKey and GetVerificationKey read keys from Secret", func() {
+		scheme := runtime.NewScheme()
+		Expect(corev1.AddToScheme(scheme)).To(Succeed())
+
+		signing := &utilsv1beta1.SnapshotSigning{
+			KeySecretRef: corev1.SecretReference{Namespace: randomString(), Name: randomString()},
+		}
+
+		c := fake.NewClientBuilder().WithScheme(scheme).Build()
+		_, err := collector.GetSigningKey(context.TODO(), c, signing)
+		Expect(err).ToNot(BeNil())
+
+		seed := generateKey()
+		keySecret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: signing.KeySecretRef.Namespace,
+				Name:      signing.KeySecretRef.Name,
+			},
+			Data: map[string][]byte{utilsv1beta1.SigningPrivateKeyKey: seed},
+		}
+		c = fake.NewClientBuilder().WithScheme(scheme).WithObjects(keySecret).Build()
+		privateKey, err := collector.GetSigningKey(context.TODO(), c, signing)
+		Expect(err).To(BeNil())
+		Expect(privateKey).To(Equal(ed25519.NewKeyFromSeed(seed)))
+
+		// Public key is derived from private key when not set
+		publicKey, err := collector.GetVerificationKey(context.TODO(), c, signing)
+		Expect(err).To(BeNil())
+		Expect(publicKey).To(Equal(privateKey.Public()))
+
+		// A Secret with only the public key can verify but not sign
+		keySecret.Data = map[string][]byte{utilsv1beta1.SigningPublicKeyKey: publicKey}
+		c = fake.NewClientBuilder().WithScheme(scheme).WithObjects(keySecret).Build()
+		_, err = collector.GetSigningKey(context.TODO(), c, signing)
+		Expect(err).ToNot(BeNil())
+		verificationKey, err := collector.GetVerificationKey(context.TODO(), c, signing)
+		Expect(err).To(BeNil())
+		Expect(verificationKey).To(Equal(publicKey))
+	})
+})
@@ -0,0 +1,207 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"path"
+	"sort"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// sampleMetadataFile is the file, at the root of a sample folder, containing
+	// the sample metadata. Being a file, it is never mistaken for a namespace or kind.
+	sampleMetadataFile = "sample-metadata.yaml"
+
+	// sampleAnnotationsFile is the file, at the root of a sample folder, containing
+	// the sample annotations. Unlike metadata, annotations can be changed at any time.
+	sampleAnnotationsFile = "sample-annotations.yaml"
+)
+
+// SampleTrigger describes why a sample was taken
+type SampleTrigger string
+
+const (
+	// SampleTriggerSchedule is for samples taken because of the Snapshot schedule.
+	// Samples without metadata are considered scheduled ones.
+	SampleTriggerSchedule = SampleTrigger("schedule")
+
+	// SampleTriggerPreRollback is for samples taken right before a rollback
+	// modified the management cluster
+	SampleTriggerPreRollback = SampleTrigger("pre-rollback")
+
+	// SampleTriggerOnDemand is for samples explicitly requested, outside
+	// the Snapshot schedule
+	SampleTriggerOnDemand = SampleTrigger("on-demand")
+
+	// SampleTriggerChange is for samples taken because Sveltos configuration changed
+	SampleTriggerChange = SampleTrigger("change")
+
+	// SampleTriggerLive is for in-memory samples of the live configuration,
+	// built to be compared with a stored sample and never persisted
+	SampleTriggerLive = SampleTrigger("live")
+)
+
+// SampleChange identifies a resource whose change caused a sample to be taken
+type SampleChange struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+// SampleMetadata contains information about a sample. It is set when sample is taken
+// and never modified afterwards, so it is listed in the sample manifest.
+type SampleMetadata struct {
+	// Trigger is the reason the sample was taken
+	Trigger SampleTrigger `json:"trigger"`
+
+	// Scope describes which resources the sample contains. Not set when sample
+	// contains every resource.
+	Scope *SampleScope `json:"scope,omitempty"`
+
+	// Changes lists the resources whose change caused the sample to be taken.
+	// Only set when Trigger is change.
+	Changes []SampleChange `json:"changes,omitempty"`
+}
+
+// SampleAnnotations contains information about a sample set by snapshot annotate.
+// Annotations are not listed in the sample manifest.
+type SampleAnnotations struct {
+	// Note is a free text describing the sample
+	Note string `json:"note,omitempty"`
+
+	// Pinned samples are never removed by retention
+	Pinned bool `json:"pinned,omitempty"`
+}
+
+// WriteSampleMetadata stores metadata in the sample folder
+func (d *Collector) WriteSampleMetadata(storage Storage, folder string, metadata *SampleMetadata) error {
+	data, err := yaml.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+
+	return storage.WriteFile(path.Join(folder, sampleMetadataFile), data)
+}
+
+// GetSampleMetadata returns metadata of the sample stored in folder.
+// Samples taken before metadata was introduced are reported as scheduled.
+func (d *Collector) GetSampleMetadata(storage Storage, folder string) (*SampleMetadata, error) {
+	return getSampleMetadata(storage, folder)
+}
+
+func getSampleMetadata(storage Storage, folder string) (*SampleMetadata, error) {
+	metadataFile := path.Join(folder, sampleMetadataFile)
+	exist, err := storage.Exists(metadataFile)
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return &SampleMetadata{Trigger: SampleTriggerSchedule}, nil
+	}
+
+	data, err := storage.ReadFile(metadataFile)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := &SampleMetadata{}
+	err = yaml.Unmarshal(data, metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	return metadata, nil
+}
+
+// WriteSampleAnnotations stores annotations in the sample folder
+func (d *Collector) WriteSampleAnnotations(storage Storage, folder string, annotations *SampleAnnotations) error {
+	data, err := yaml.Marshal(annotations)
+	if err != nil {
+		return err
+	}
+
+	return storage.WriteFile(path.Join(folder, sampleAnnotationsFile), data)
+}
+
+// GetSampleAnnotations returns annotations of the sample stored in folder.
+// Samples never annotated have no note and are not pinned.
+func (d *Collector) GetSampleAnnotations(storage Storage, folder string) (*SampleAnnotations, error) {
+	return getSampleAnnotations(storage, folder)
+}
+
+func getSampleAnnotations(storage Storage, folder string) (*SampleAnnotations, error) {
+	annotationsFile := path.Join(folder, sampleAnnotationsFile)
+	exist, err := storage.Exists(annotationsFile)
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return &SampleAnnotations{}, nil
+	}
+
+	data, err := storage.ReadFile(annotationsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	annotations := &SampleAnnotations{}
+	err = yaml.Unmarshal(data, annotations)
+	if err != nil {
+		return nil, err
+	}
+
+	return annotations, nil
+}
+
+// isCollectionPinned returns true if the sample stored in folder is pinned
+func isCollectionPinned(storage Storage, folder string) (bool, error) {
+	annotations, err := getSampleAnnotations(storage, folder)
+	if err != nil {
+		return false, err
+	}
+	return annotations.Pinned, nil
+}
+
+// GetLatestSample returns the name of the most recent collection for requestorName
+// taken because of trigger. Returns an empty name if there is none.
+func (d *Collector) GetLatestSample(storage Storage, requestorName string, collectionType CollectionType,
+	trigger SampleTrigger, logger logr.Logger) (string, error) {
+
+	samples, err := listCollectionsForRequestor(storage, requestorName, collectionType, logger)
+	if err != nil {
+		return "", err
+	}
+
+	// Sample names are times formatted from the most to the least significant unit,
+	// so sorting names sorts samples by time.
+	sort.Sort(sort.Reverse(sort.StringSlice(samples)))
+	artifactFolder := getArtifactFolderName(requestorName, collectionType)
+	for i := range samples {
+		metadata, err := d.GetSampleMetadata(storage, path.Join(artifactFolder, samples[i]))
+		if err != nil {
+			return "", err
+		}
+		if metadata.Trigger == trigger {
+			return samples[i], nil
+		}
+	}
+
+	return "", nil
+}
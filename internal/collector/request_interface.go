@@ -18,6 +18,7 @@ package collector
 
 import (
 	"context"
+	"time"
 
 	"github.com/go-logr/logr"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -91,37 +92,38 @@ type CollectorInterface interface {
 	GetResult(ctx context.Context, requestorName string, collectionType CollectionType) Result
 
 	// ListCollections returns list all collections taken for a given requestorName
-	ListCollections(storage, requestorName string, collectionType CollectionType,
+	ListCollections(storage Storage, requestorName string, collectionType CollectionType,
 		logger logr.Logger) ([]string, error)
 
-	// GetFolderPath returns the path of folder where resources can be stored
-	GetFolderPath(storage, requestorName string, collectionType CollectionType) string
+	// GetFolderPath returns the path, relative to the storage root, of folder where resources
+	// collected at time t can be stored
+	GetFolderPath(requestorName string, collectionType CollectionType, t time.Time) string
 
 	// GetFolder returns the artifact folder where all collections for a given
 	// requestorName are stored
-	GetFolder(storage, requestorName string, collectionType CollectionType,
+	GetFolder(storage Storage, requestorName string, collectionType CollectionType,
 		logger logr.Logger) (*string, error)
 
 	// CleanupEntries removes any entry (from any internal data structure) for
 	// given requestorName
-	CleanupEntries(storage, requestorName string, collectionType CollectionType) error
+	CleanupEntries(storage Storage, requestorName string, collectionType CollectionType) error
 
 	// GetNamespacedResources returns all namespaced resources contained in the
 	// folder.
 	// Returns a map with:
 	// - key: <namespace name>
 	// - value: list of resources of the Kind specified
-	GetNamespacedResources(folder, kind string, logger logr.Logger) (map[string][]*unstructured.Unstructured, error)
+	GetNamespacedResources(storage Storage, folder, kind string, logger logr.Logger) (map[string][]*unstructured.Unstructured, error)
 
 	// GetClusterResources	returns all cluster resources contained in the folder
-	GetClusterResources(folder, kind string, logger logr.Logger) ([]*unstructured.Unstructured, error)
+	GetClusterResources(storage Storage, folder, kind string, logger logr.Logger) ([]*unstructured.Unstructured, error)
 
 	// CleanOldCollections removes old collection for requestorName. If more than limit collections
 	// are present, the oldest ones are remove up till there are only limit-1 collections left.
-	CleanOldCollections(storage, requestorName string, collectionType CollectionType,
+	CleanOldCollections(storage Storage, requestorName string, collectionType CollectionType,
 		limit int32, logger logr.Logger) error
 
 	// DumpObject is a helper function to generically dump resource definition
 	// given the resource reference and file path for dumping location.
-	DumpObject(resource client.Object, logPath string, logger logr.Logger) error
+	DumpObject(storage Storage, resource client.Object, logPath string, logger logr.Logger) error
 }
@@ -0,0 +1,147 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+	utilsv1beta1 "github.com/projectsveltos/sveltosctl/api/v1beta1"
+)
+
+// retentionRule keeps the most recent sample in each of the last count periods.
+// period returns the identifier of the period a sample time belongs to.
+type retentionRule struct {
+	count  *int32
+	period func(t time.Time) string
+}
+
+func getRetentionRules(retention *utilsv1beta1.RetentionPolicy) []retentionRule {
+	return []retentionRule{
+		{count: retention.Hourly, period: func(t time.Time) string { return t.Format("2006-01-02:15") }},
+		{count: retention.Daily, period: func(t time.Time) string { return t.Format("2006-01-02") }},
+		{count: retention.Weekly, period: func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-%02d", year, week)
+		}},
+		{count: retention.Monthly, period: func(t time.Time) string { return t.Format("2006-01") }},
+	}
+}
+
+// GetCollectionsToPrune returns, sorted from the oldest to the most recent, the collections
+// which retention does not keep at time now.
+// Collections whose name is not a time are never pruned.
+func GetCollectionsToPrune(collections []string, retention *utilsv1beta1.RetentionPolicy, now time.Time) []string {
+	type collection struct {
+		name string
+		time time.Time
+	}
+
+	sorted := make([]collection, 0, len(collections))
+	for i := range collections {
+		t, err := time.Parse(timeFormat, collections[i])
+		if err != nil {
+			continue
+		}
+		sorted = append(sorted, collection{name: collections[i], time: t})
+	}
+
+	// Most recent first
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].time.After(sorted[j].time)
+	})
+
+	keep := make(map[string]bool, len(sorted))
+	tiered := false
+	for _, rule := range getRetentionRules(retention) {
+		if rule.count == nil {
+			continue
+		}
+		tiered = true
+
+		periods := make(map[string]bool)
+		for i := range sorted {
+			if len(periods) >= int(*rule.count) {
+				break
+			}
+			period := rule.period(sorted[i].time)
+			if periods[period] {
+				continue
+			}
+			periods[period] = true
+			keep[sorted[i].name] = true
+		}
+	}
+
+	toPrune := make([]string, 0)
+	for i := len(sorted) - 1; i >= 0; i-- {
+		c := &sorted[i]
+		expired := retention.MaxAge != nil && c.time.Before(now.Add(-retention.MaxAge.Duration))
+		if expired || (tiered && !keep[c.name]) {
+			toPrune = append(toPrune, c.name)
+		}
+	}
+
+	return toPrune
+}
+
+// PruneCollections removes the collections for requestorName which retention does not
+// keep at time now. Pinned collections are never removed. If dryRun is set, nothing is removed.
+// Returns the collections removed (or which would be removed), from the oldest.
+func (d *Collector) PruneCollections(storage Storage, requestorName string, collectionType CollectionType,
+	retention *utilsv1beta1.RetentionPolicy, now time.Time, dryRun bool, logger logr.Logger) ([]string, error) {
+
+	collections, err := listCollectionsForRequestor(storage, requestorName, collectionType, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	artifactFolder := getArtifactFolderName(requestorName, collectionType)
+
+	// Pinned collections are never removed
+	toPrune := make([]string, 0)
+	for _, c := range GetCollectionsToPrune(collections, retention, now) {
+		pinned, err := isCollectionPinned(storage, path.Join(artifactFolder, c))
+		if err != nil {
+			return nil, err
+		}
+		if pinned {
+			logger.V(logs.LogDebug).Info(fmt.Sprintf("collection %s is pinned", c))
+			continue
+		}
+		toPrune = append(toPrune, c)
+	}
+
+	if dryRun {
+		return toPrune, nil
+	}
+
+	for i := range toPrune {
+		logger.V(logs.LogDebug).Info(fmt.Sprintf("removing collection %s", toPrune[i]))
+		err = storage.RemoveAll(path.Join(artifactFolder, toPrune[i]))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return toPrune, nil
+}
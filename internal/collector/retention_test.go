@@ -0,0 +1,151 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector_test
+
+import (
+	"os"
+	"path"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2/textlogger"
+
+	utilsv1beta1 "github.com/projectsveltos/sveltosctl/api/v1beta1"
+	"github.com/projectsveltos/sveltosctl/internal/collector"
+)
+
+var _ = Describe("Retention", func() {
+	var now time.Time
+	var samples []string
+
+	BeforeEach(func() {
+		now = time.Date(2024, time.March, 15, 12, 30, 0, 0, time.UTC)
+
+		// One sample every hour for the last 60 days
+		samples = make([]string, 0)
+		for i := 0; i < 60*24; i++ {
+			samples = append(samples, now.Add(-time.Duration(i)*time.Hour).Format(collector.TimeFormat))
+		}
+	})
+
+	It("GetCollectionsToPrune keeps the most recent sample of each period", func() {
+		hourly := int32(3)
+		daily := int32(2)
+		retention := &utilsv1beta1.RetentionPolicy{Hourly: &hourly, Daily: &daily}
+
+		toPrune := collector.GetCollectionsToPrune(samples, retention, now)
+		kept := getKept(samples, toPrune)
+		Expect(kept).To(ConsistOf(
+			now.Format(collector.TimeFormat),                   // this hour and today
+			now.Add(-time.Hour).Format(collector.TimeFormat),   // previous hour
+			now.Add(-2*time.Hour).Format(collector.TimeFormat), // two hours ago
+			"2024-03-14:23:30:00",                              // yesterday
+		))
+
+		// Oldest first
+		Expect(toPrune[0]).To(Equal(samples[len(samples)-1]))
+	})
+
+	It("GetCollectionsToPrune keeps weekly and monthly samples", func() {
+		weekly := int32(2)
+		monthly := int32(3)
+		retention := &utilsv1beta1.RetentionPolicy{Weekly: &weekly, Monthly: &monthly}
+
+		kept := getKept(samples, collector.GetCollectionsToPrune(samples, retention, now))
+		Expect(kept).To(ConsistOf(
+			now.Format(collector.TimeFormat), // this week and this month
+			"2024-03-10:23:30:00",            // last sample of previous ISO week (Sunday)
+			"2024-02-29:23:30:00",            // last sample of February
+			"2024-01-31:23:30:00",            // last sample of January
+		))
+	})
+
+	It("GetCollectionsToPrune removes samples older than MaxAge", func() {
+		monthly := int32(3)
+		retention := &utilsv1beta1.RetentionPolicy{
+			Monthly: &monthly,
+			MaxAge:  &metav1.Duration{Duration: 20 * 24 * time.Hour},
+		}
+
+		kept := getKept(samples, collector.GetCollectionsToPrune(samples, retention, now))
+		Expect(kept).To(ConsistOf(
+			now.Format(collector.TimeFormat),
+			"2024-02-29:23:30:00",
+		))
+
+		// With MaxAge only, every sample younger than MaxAge is kept
+		retention.Monthly = nil
+		retention.MaxAge = &metav1.Duration{Duration: 2 * time.Hour}
+		kept = getKept(samples, collector.GetCollectionsToPrune(samples, retention, now))
+		Expect(kept).To(ConsistOf(
+			now.Format(collector.TimeFormat),
+			now.Add(-time.Hour).Format(collector.TimeFormat),
+			now.Add(-2*time.Hour).Format(collector.TimeFormat),
+		))
+	})
+
+	It("PruneCollections and CleanOldCollections never remove pinned samples", func() {
+		storageDir, err := os.MkdirTemp("", randomString())
+		Expect(err).To(BeNil())
+		defer os.RemoveAll(storageDir)
+
+		storage := collector.NewFilesystemStorage(storageDir)
+		snapshotName := randomString()
+		artifactFolder := collector.GetArtifactFolderName(snapshotName, collector.Snapshot)
+		d := collector.GetClient()
+
+		current := time.Now()
+		pinnedSample := current.Add(-72 * time.Hour).Format(collector.TimeFormat)
+		oldSample := current.Add(-48 * time.Hour).Format(collector.TimeFormat)
+		newSample := current.Format(collector.TimeFormat)
+		for _, sample := range []string{pinnedSample, oldSample, newSample} {
+			Expect(d.WriteSampleAnnotations(storage, path.Join(artifactFolder, sample),
+				&collector.SampleAnnotations{Pinned: sample == pinnedSample})).To(Succeed())
+		}
+
+		daily := int32(1)
+		logger := textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1)))
+		pruned, err := d.PruneCollections(storage, snapshotName, collector.Snapshot,
+			&utilsv1beta1.RetentionPolicy{Daily: &daily}, current, true, logger)
+		Expect(err).To(BeNil())
+		Expect(pruned).To(ConsistOf(oldSample))
+
+		// Pinned samples do not count against the limit either
+		Expect(d.CleanOldCollections(storage, snapshotName, collector.Snapshot, 1, logger)).To(Succeed())
+		collections, err := d.ListCollections(storage, snapshotName, collector.Snapshot, logger)
+		Expect(err).To(BeNil())
+		Expect(collections).To(ConsistOf(pinnedSample, newSample))
+	})
+})
+
+func getKept(samples, toPrune []string) []string {
+	pruned := make(map[string]bool)
+	for i := range toPrune {
+		pruned[toPrune[i]] = true
+	}
+
+	kept := make([]string, 0)
+	for i := range samples {
+		if !pruned[samples[i]] {
+			kept = append(kept, samples[i])
+		}
+	}
+	return kept
+}
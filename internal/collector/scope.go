@@ -0,0 +1,112 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"slices"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	configv1beta1 "github.com/projectsveltos/addon-controller/api/v1beta1"
+)
+
+// SampleScope describes which resources a sample contains. Resources out of scope are
+// absent on purpose and must not be considered deleted.
+// A nil SampleScope means sample contains every resource.
+type SampleScope struct {
+	// IncludeKinds, when not empty, lists the only kinds collected.
+	// Referenced resources are collected unless excluded.
+	IncludeKinds []string `json:"includeKinds,omitempty"`
+
+	// ExcludeKinds lists kinds never collected
+	ExcludeKinds []string `json:"excludeKinds,omitempty"`
+
+	// NamespaceSelector, when set, restricts namespaced resources to Namespaces
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// Namespaces contains the namespaces matching NamespaceSelector at the time
+	// sample was taken
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// ProfileSelector, when set, restricts ClusterProfiles/Profiles to the ones
+	// matching it
+	ProfileSelector *metav1.LabelSelector `json:"profileSelector,omitempty"`
+}
+
+// IsKindInScope returns true if instances of kind are collected
+func (s *SampleScope) IsKindInScope(kind string) bool {
+	if s == nil {
+		return true
+	}
+
+	if slices.Contains(s.ExcludeKinds, kind) {
+		return false
+	}
+
+	return len(s.IncludeKinds) == 0 || slices.Contains(s.IncludeKinds, kind)
+}
+
+// IsReferencedKindInScope returns true if resources of kind are collected when
+// referenced by a collected instance
+func (s *SampleScope) IsReferencedKindInScope(kind string) bool {
+	return s == nil || !slices.Contains(s.ExcludeKinds, kind)
+}
+
+// IsNamespaceInScope returns true if namespaced resources in namespace are collected.
+// Empty namespace, used for cluster wide resources, is always in scope.
+func (s *SampleScope) IsNamespaceInScope(namespace string) bool {
+	if s == nil || s.NamespaceSelector == nil || namespace == "" {
+		return true
+	}
+
+	return slices.Contains(s.Namespaces, namespace)
+}
+
+// IsObjectInScope returns true if object, an instance of kind, is collected
+func (s *SampleScope) IsObjectInScope(kind string, object metav1.Object) (bool, error) {
+	if s == nil {
+		return true, nil
+	}
+
+	if !s.IsKindInScope(kind) || !s.IsNamespaceInScope(object.GetNamespace()) {
+		return false, nil
+	}
+
+	return s.isProfileInScope(kind, object)
+}
+
+// IsReferencedObjectInScope returns true if object, a resource of kind referenced by
+// a collected instance, is collected
+func (s *SampleScope) IsReferencedObjectInScope(kind string, object metav1.Object) bool {
+	return s.IsReferencedKindInScope(kind) && s.IsNamespaceInScope(object.GetNamespace())
+}
+
+func (s *SampleScope) isProfileInScope(kind string, object metav1.Object) (bool, error) {
+	if s.ProfileSelector == nil ||
+		(kind != configv1beta1.ClusterProfileKind && kind != configv1beta1.ProfileKind) {
+
+		return true, nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(s.ProfileSelector)
+	if err != nil {
+		return false, err
+	}
+
+	return selector.Matches(labels.Set(object.GetLabels())), nil
+}
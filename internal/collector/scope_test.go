@@ -0,0 +1,88 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	configv1beta1 "github.com/projectsveltos/addon-controller/api/v1beta1"
+	libsveltosv1beta1 "github.com/projectsveltos/libsveltos/api/v1beta1"
+	"github.com/projectsveltos/sveltosctl/internal/collector"
+)
+
+var _ = Describe("Sample scope", func() {
+	It("nil scope contains everything", func() {
+		var scope *collector.SampleScope
+		Expect(scope.IsKindInScope(libsveltosv1beta1.ClassifierKind)).To(BeTrue())
+		Expect(scope.IsReferencedKindInScope("Secret")).To(BeTrue())
+		Expect(scope.IsNamespaceInScope(randomString())).To(BeTrue())
+
+		inScope, err := scope.IsObjectInScope(configv1beta1.ProfileKind, &configv1beta1.Profile{
+			ObjectMeta: metav1.ObjectMeta{Namespace: randomString(), Name: randomString()},
+		})
+		Expect(err).To(BeNil())
+		Expect(inScope).To(BeTrue())
+	})
+
+	It("IsKindInScope honors included and excluded kinds", func() {
+		scope := &collector.SampleScope{
+			IncludeKinds: []string{configv1beta1.ClusterProfileKind, configv1beta1.ProfileKind},
+			ExcludeKinds: []string{configv1beta1.ProfileKind, "Secret"},
+		}
+		Expect(scope.IsKindInScope(configv1beta1.ClusterProfileKind)).To(BeTrue())
+		Expect(scope.IsKindInScope(configv1beta1.ProfileKind)).To(BeFalse())
+		Expect(scope.IsKindInScope(libsveltosv1beta1.ClassifierKind)).To(BeFalse())
+
+		// Referenced resources are collected unless excluded
+		Expect(scope.IsReferencedKindInScope("ConfigMap")).To(BeTrue())
+		Expect(scope.IsReferencedKindInScope("Secret")).To(BeFalse())
+	})
+
+	It("IsObjectInScope honors namespaces and profile selector", func() {
+		namespace := randomString()
+		scope := &collector.SampleScope{
+			NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "platform"}},
+			Namespaces:        []string{namespace},
+			ProfileSelector:   &metav1.LabelSelector{MatchLabels: map[string]string{"team": "platform"}},
+		}
+
+		configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: randomString()}}
+		Expect(scope.IsReferencedObjectInScope("ConfigMap", configMap)).To(BeTrue())
+		configMap.Namespace = randomString()
+		Expect(scope.IsReferencedObjectInScope("ConfigMap", configMap)).To(BeFalse())
+
+		clusterProfile := &configv1beta1.ClusterProfile{ObjectMeta: metav1.ObjectMeta{Name: randomString()}}
+		inScope, err := scope.IsObjectInScope(configv1beta1.ClusterProfileKind, clusterProfile)
+		Expect(err).To(BeNil())
+		Expect(inScope).To(BeFalse())
+
+		clusterProfile.Labels = map[string]string{"team": "platform"}
+		inScope, err = scope.IsObjectInScope(configv1beta1.ClusterProfileKind, clusterProfile)
+		Expect(err).To(BeNil())
+		Expect(inScope).To(BeTrue())
+
+		// Cluster wide resources are not affected by the namespace selector
+		classifier := &libsveltosv1beta1.Classifier{ObjectMeta: metav1.ObjectMeta{Name: randomString()}}
+		inScope, err = scope.IsObjectInScope(libsveltosv1beta1.ClassifierKind, classifier)
+		Expect(err).To(BeNil())
+		Expect(inScope).To(BeTrue())
+	})
+})
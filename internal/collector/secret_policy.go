@@ -0,0 +1,130 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	utilsv1beta1 "github.com/projectsveltos/sveltosctl/api/v1beta1"
+)
+
+const (
+	// SecretModeAnnotation is set on Secrets stored in a sample but not in full.
+	// Value is the SecretMode used to store the Secret.
+	SecretModeAnnotation = "snapshot.projectsveltos.io/secret-mode"
+
+	secretDigestPrefix = "sha256:"
+)
+
+// GetSecretMode returns the mode of the first policy selecting secret.
+// If no policy selects secret, Secret is stored in full.
+func GetSecretMode(policies []utilsv1beta1.SecretPolicy, secret *corev1.Secret) (utilsv1beta1.SecretMode, error) {
+	for i := range policies {
+		policy := &policies[i]
+		if len(policy.Namespaces) > 0 && !slices.Contains(policy.Namespaces, secret.Namespace) {
+			continue
+		}
+
+		if policy.Selector != nil {
+			selector, err := metav1.LabelSelectorAsSelector(policy.Selector)
+			if err != nil {
+				return "", err
+			}
+			if !selector.Matches(labels.Set(secret.Labels)) {
+				continue
+			}
+		}
+
+		return policy.Mode, nil
+	}
+
+	return utilsv1beta1.SecretModeFull, nil
+}
+
+// HashSecret replaces every value in secret Data with its SHA-256 digest
+// and marks secret as not stored in full. The last-applied-configuration
+// annotation and managedFields are removed, as the former contains secret
+// Data when secret was created with kubectl apply.
+func HashSecret(secret *corev1.Secret) {
+	for k := range secret.StringData {
+		if secret.Data == nil {
+			secret.Data = make(map[string][]byte)
+		}
+		secret.Data[k] = []byte(secret.StringData[k])
+	}
+	secret.StringData = nil
+
+	for k := range secret.Data {
+		secret.Data[k] = []byte(getDigest(secret.Data[k]))
+	}
+
+	secret.ManagedFields = nil
+
+	annotations := secret.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	delete(annotations, corev1.LastAppliedConfigAnnotation)
+	annotations[SecretModeAnnotation] = string(utilsv1beta1.SecretModeHashed)
+	secret.SetAnnotations(annotations)
+}
+
+// IsSecretStoredInFull returns true if secret, read from a sample, contains the
+// actual Secret Data
+func IsSecretStoredInFull(secret *unstructured.Unstructured) bool {
+	mode, ok := secret.GetAnnotations()[SecretModeAnnotation]
+	return !ok || mode == string(utilsv1beta1.SecretModeFull)
+}
+
+// GetSecretDigests returns, for each key in secret Data, the SHA-256 digest of its value.
+// Secrets stored in full and hashed ones can be compared this way.
+func GetSecretDigests(secret *unstructured.Unstructured) (map[string]string, error) {
+	s := &corev1.Secret{}
+	err := runtime.DefaultUnstructuredConverter.FromUnstructured(secret.UnstructuredContent(), s)
+	if err != nil {
+		return nil, err
+	}
+
+	storedInFull := IsSecretStoredInFull(secret)
+	digests := make(map[string]string, len(s.Data)+len(s.StringData))
+	for k := range s.StringData {
+		digests[k] = getDigest([]byte(s.StringData[k]))
+	}
+	for k := range s.Data {
+		if !storedInFull && strings.HasPrefix(string(s.Data[k]), secretDigestPrefix) {
+			digests[k] = string(s.Data[k])
+			continue
+		}
+		digests[k] = getDigest(s.Data[k])
+	}
+
+	return digests, nil
+}
+
+func getDigest(value []byte) string {
+	h := sha256.Sum256(value)
+	return secretDigestPrefix + hex.EncodeToString(h[:])
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector_test
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+
+	utilsv1beta1 "github.com/projectsveltos/sveltosctl/api/v1beta1"
+	"github.com/projectsveltos/sveltosctl/internal/collector"
+)
+
+var _ = Describe("Secret policy", func() {
+	var secret *corev1.Secret
+
+	BeforeEach(func() {
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: randomString(),
+				Name:      randomString(),
+				Labels:    map[string]string{"env": "production"},
+			},
+			Data: map[string][]byte{
+				"password": []byte(randomString()),
+				"username": []byte(randomString()),
+			},
+		}
+	})
+
+	It("GetSecretMode returns the mode of the first policy selecting the Secret", func() {
+		mode, err := collector.GetSecretMode(nil, secret)
+		Expect(err).To(BeNil())
+		Expect(mode).To(Equal(utilsv1beta1.SecretModeFull))
+
+		policies := []utilsv1beta1.SecretPolicy{
+			{
+				Namespaces: []string{randomString()},
+				Mode:       utilsv1beta1.SecretModeSkip,
+			},
+			{
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "staging"}},
+				Mode:     utilsv1beta1.SecretModeSkip,
+			},
+			{
+				Namespaces: []string{secret.Namespace},
+				Selector:   &metav1.LabelSelector{MatchLabels: map[string]string{"env": "production"}},
+				Mode:       utilsv1beta1.SecretModeHashed,
+			},
+			{
+				Mode: utilsv1beta1.SecretModeSkip,
+			},
+		}
+
+		mode, err = collector.GetSecretMode(policies, secret)
+		Expect(err).To(BeNil())
+		Expect(mode).To(Equal(utilsv1beta1.SecretModeHashed))
+
+		secret.Labels = nil
+		mode, err = collector.GetSecretMode(policies, secret)
+		Expect(err).To(BeNil())
+		Expect(mode).To(Equal(utilsv1beta1.SecretModeSkip))
+	})
+
+	It("HashSecret replaces values with digests which match digests of the Secret in full", func() {
+		full := toUnstructured(secret)
+		Expect(collector.IsSecretStoredInFull(full)).To(BeTrue())
+
+		hashedSecret := secret.DeepCopy()
+		collector.HashSecret(hashedSecret)
+		for k := range secret.Data {
+			Expect(hashedSecret.Data[k]).ToNot(Equal(secret.Data[k]))
+			Expect(string(hashedSecret.Data[k])).To(HavePrefix("sha256:"))
+		}
+
+		hashed := toUnstructured(hashedSecret)
+		Expect(collector.IsSecretStoredInFull(hashed)).To(BeFalse())
+
+		fullDigests, err := collector.GetSecretDigests(full)
+		Expect(err).To(BeNil())
+		hashedDigests, err := collector.GetSecretDigests(hashed)
+		Expect(err).To(BeNil())
+		Expect(hashedDigests).To(Equal(fullDigests))
+
+		secret.Data["password"] = []byte(randomString())
+		fullDigests, err = collector.GetSecretDigests(toUnstructured(secret))
+		Expect(err).To(BeNil())
+		Expect(hashedDigests).ToNot(Equal(fullDigests))
+	})
+
+	It("HashSecret removes last-applied-configuration and managedFields", func() {
+		password := string(secret.Data["password"])
+		secret.Annotations = map[string]string{
+			corev1.LastAppliedConfigAnnotation: fmt.Sprintf(`{"apiVersion":"v1","kind":"Secret","stringData":{"password":%q}}`,
+				password),
+			"owner": randomString(),
+		}
+		secret.ManagedFields = []metav1.ManagedFieldsEntry{
+			{Manager: "kubectl-client-side-apply", Operation: metav1.ManagedFieldsOperationUpdate},
+		}
+
+		hashedSecret := secret.DeepCopy()
+		collector.HashSecret(hashedSecret)
+		Expect(hashedSecret.Annotations).ToNot(HaveKey(corev1.LastAppliedConfigAnnotation))
+		Expect(hashedSecret.Annotations).To(HaveKeyWithValue("owner", secret.Annotations["owner"]))
+		Expect(hashedSecret.ManagedFields).To(BeNil())
+
+		data, err := yaml.Marshal(hashedSecret)
+		Expect(err).To(BeNil())
+		Expect(string(data)).ToNot(ContainSubstring(password))
+	})
+})
+
+func toUnstructured(secret *corev1.Secret) *unstructured.Unstructured {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(secret)
+	Expect(err).To(BeNil())
+	return &unstructured.Unstructured{Object: content}
+}
@@ -0,0 +1,204 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+)
+
+// stagingPrefix is prepended to the name of a collection while it is being written.
+// Staging folders live next to collections, so publishing one is a rename within the
+// same directory.
+const stagingPrefix = ".staging-"
+
+const (
+	// publishMarkerSuffix is appended to the name of a staging folder to name the file
+	// recording the collection the staging folder is being published to
+	publishMarkerSuffix = ".publish"
+
+	// maxPublishAttempts is the number of names, one per second from the time the
+	// collection started, PublishCollection tries
+	maxPublishAttempts = 60
+)
+
+func isStagingFolder(name string) bool {
+	return strings.HasPrefix(name, stagingPrefix)
+}
+
+// getStagingTime returns the time the collection written in staging folder name started
+func getStagingTime(name string) (time.Time, error) {
+	value := strings.TrimPrefix(name, stagingPrefix)
+	if len(value) < len(timeFormat) {
+		return time.Time{}, fmt.Errorf("malformed staging folder name %s", name)
+	}
+
+	return time.Parse(timeFormat, value[:len(timeFormat)])
+}
+
+// GetStagingFolderPath returns the path, relative to the storage root, of the folder where
+// resources collected at time t are written. Once all resources are written, PublishCollection
+// moves it to its final folder.
+// Staging folder names are unique, so collections started within the same second, by this or
+// any other process, never share a staging folder.
+func (d *Collector) GetStagingFolderPath(requestorName string, collectionType CollectionType, t time.Time,
+) (string, error) {
+
+	id, err := getRandomID()
+	if err != nil {
+		return "", err
+	}
+
+	artifactFolder := getArtifactFolderName(requestorName, collectionType)
+	return path.Join(artifactFolder, fmt.Sprintf("%s%s-%s", stagingPrefix, t.Format(timeFormat), id)), nil
+}
+
+// PublishCollection moves the collection written in stagingFolder to its final folder, named
+// after the time the collection started. If a collection with such name already exists (two
+// collections started within the same second), the first free name in the following seconds
+// is used. An existing collection is never replaced.
+// Returns the name of the collection.
+func (d *Collector) PublishCollection(storage Storage, stagingFolder string) (string, error) {
+	name := path.Base(stagingFolder)
+	if !isStagingFolder(name) {
+		return "", fmt.Errorf("%s is not a staging folder", stagingFolder)
+	}
+
+	t, err := getStagingTime(name)
+	if err != nil {
+		return "", err
+	}
+
+	marker := stagingFolder + publishMarkerSuffix
+	defer func() {
+		_ = storage.RemoveAll(marker)
+	}()
+
+	for i := 0; i < maxPublishAttempts; i++ {
+		collection := t.Add(time.Duration(i) * time.Second).Format(timeFormat)
+		folder := path.Join(path.Dir(stagingFolder), collection)
+
+		// Rename is not atomic on all storages. Record where the collection is published, so
+		// CleanupStagingFolders removes what was copied if this process stops while publishing.
+		if err := storage.WriteFile(marker, []byte(collection)); err != nil {
+			return "", err
+		}
+
+		err = storage.Rename(stagingFolder, folder)
+		if err == nil {
+			return collection, nil
+		}
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+
+		// Remove what a non atomic rename might have copied
+		_ = storage.RemoveAll(folder)
+		return "", err
+	}
+
+	return "", &fs.PathError{Op: "publish", Path: stagingFolder, Err: fs.ErrExist}
+}
+
+// CleanupStagingFolders removes the staging folders left behind by collections for requestorName
+// which never completed. If the collection was being published when the process stopped (storages
+// without atomic rename), what was copied to the collection folder is removed as well. Any other
+// collection is left untouched.
+// Must not be called while a collection for requestorName is in progress.
+// Returns the name of the removed staging folders.
+func (d *Collector) CleanupStagingFolders(storage Storage, requestorName string, collectionType CollectionType,
+	logger logr.Logger) ([]string, error) {
+
+	artifactFolder := getArtifactFolderName(requestorName, collectionType)
+
+	entries, err := storage.ReadDir(artifactFolder)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	removed := make([]string, 0)
+	for i := range entries {
+		if !isStagingFolder(entries[i].Name) {
+			continue
+		}
+
+		stagingFolder := path.Join(artifactFolder, entries[i].Name)
+		if !entries[i].IsDir {
+			if err := removeStalePublishMarker(storage, stagingFolder); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		logger.V(logs.LogDebug).Info(fmt.Sprintf("removing staging folder %s", entries[i].Name))
+		if err := removePartiallyPublished(storage, stagingFolder); err != nil {
+			return nil, err
+		}
+		if err := storage.RemoveAll(stagingFolder); err != nil {
+			return nil, err
+		}
+		removed = append(removed, entries[i].Name)
+	}
+
+	return removed, nil
+}
+
+// removePartiallyPublished removes the collection stagingFolder was being published to, if any
+func removePartiallyPublished(storage Storage, stagingFolder string) error {
+	marker := stagingFolder + publishMarkerSuffix
+	data, err := storage.ReadFile(marker)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	collection := string(data)
+	if _, err := time.Parse(timeFormat, collection); err == nil {
+		if err := storage.RemoveAll(path.Join(path.Dir(stagingFolder), collection)); err != nil {
+			return err
+		}
+	}
+
+	return storage.RemoveAll(marker)
+}
+
+// removeStalePublishMarker removes marker, a publish marker, if its staging folder was published
+func removeStalePublishMarker(storage Storage, marker string) error {
+	if !strings.HasSuffix(marker, publishMarkerSuffix) {
+		return nil
+	}
+
+	exist, err := storage.Exists(strings.TrimSuffix(marker, publishMarkerSuffix))
+	if err != nil || exist {
+		return err
+	}
+
+	return storage.RemoveAll(marker)
+}
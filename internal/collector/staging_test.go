@@ -0,0 +1,168 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector_test
+
+import (
+	"os"
+	"path"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/klog/v2/textlogger"
+
+	"github.com/projectsveltos/sveltosctl/internal/collector"
+)
+
+var _ = Describe("Staging", func() {
+	var root string
+	var storage collector.Storage
+	var snapshotName string
+
+	BeforeEach(func() {
+		var err error
+		root, err = os.MkdirTemp("", randomString())
+		Expect(err).To(BeNil())
+
+		storage = collector.NewFilesystemStorage(root)
+		snapshotName = randomString()
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(root)
+	})
+
+	It("collections are listed only once published", func() {
+		d := collector.GetClient()
+		logger := textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1)))
+
+		now := time.Now()
+		published := now.Add(-time.Hour)
+		Expect(storage.WriteFile(path.Join(d.GetFolderPath(snapshotName, collector.Snapshot, published),
+			"ClusterProfile", randomString()+".yaml"), []byte(randomString()))).To(Succeed())
+
+		stagingFolder, err := d.GetStagingFolderPath(snapshotName, collector.Snapshot, now)
+		Expect(err).To(BeNil())
+		Expect(storage.WriteFile(path.Join(stagingFolder, "ClusterProfile", randomString()+".yaml"),
+			[]byte(randomString()))).To(Succeed())
+
+		collections, err := d.ListCollections(storage, snapshotName, collector.Snapshot, logger)
+		Expect(err).To(BeNil())
+		Expect(collections).To(ConsistOf(published.Format(collector.TimeFormat)))
+
+		collection, err := d.PublishCollection(storage, stagingFolder)
+		Expect(err).To(BeNil())
+		Expect(collection).To(Equal(now.Format(collector.TimeFormat)))
+
+		collections, err = d.ListCollections(storage, snapshotName, collector.Snapshot, logger)
+		Expect(err).To(BeNil())
+		Expect(collections).To(ConsistOf(published.Format(collector.TimeFormat), collection))
+
+		entries, err := storage.ReadDir(collector.GetArtifactFolderName(snapshotName, collector.Snapshot))
+		Expect(err).To(BeNil())
+		Expect(entries).To(HaveLen(2))
+	})
+
+	It("collections started within the same second are published under different names", func() {
+		d := collector.GetClient()
+
+		now := time.Now()
+		existingFolder := d.GetFolderPath(snapshotName, collector.Snapshot, now)
+		existingFile := path.Join(existingFolder, "ClusterProfile", randomString()+".yaml")
+		existingContent := []byte(randomString())
+		Expect(storage.WriteFile(existingFile, existingContent)).To(Succeed())
+
+		stagingFolders := make([]string, 2)
+		for i := range stagingFolders {
+			var err error
+			stagingFolders[i], err = d.GetStagingFolderPath(snapshotName, collector.Snapshot, now)
+			Expect(err).To(BeNil())
+			Expect(storage.WriteFile(path.Join(stagingFolders[i], "ClusterProfile", randomString()+".yaml"),
+				[]byte(randomString()))).To(Succeed())
+		}
+		Expect(stagingFolders[0]).ToNot(Equal(stagingFolders[1]))
+
+		// An existing collection is never replaced: first free name is used
+		for i := range stagingFolders {
+			collection, err := d.PublishCollection(storage, stagingFolders[i])
+			Expect(err).To(BeNil())
+			Expect(collection).To(Equal(now.Add(time.Duration(i+1) * time.Second).Format(collector.TimeFormat)))
+		}
+
+		data, err := storage.ReadFile(existingFile)
+		Expect(err).To(BeNil())
+		Expect(data).To(Equal(existingContent))
+		entries, err := storage.ReadDir(existingFolder)
+		Expect(err).To(BeNil())
+		Expect(entries).To(HaveLen(1))
+	})
+
+	It("CleanupStagingFolders removes staging folders and what they partially published", func() {
+		d := collector.GetClient()
+		logger := textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1)))
+
+		removed, err := d.CleanupStagingFolders(storage, snapshotName, collector.Snapshot, logger)
+		Expect(err).To(BeNil())
+		Expect(removed).To(BeEmpty())
+
+		now := time.Now()
+		// Collection published by another process, started within the same second as
+		// the interrupted collections
+		complete := now
+		Expect(storage.WriteFile(path.Join(d.GetFolderPath(snapshotName, collector.Snapshot, complete),
+			"ClusterProfile", randomString()+".yaml"), []byte(randomString()))).To(Succeed())
+
+		// Collection interrupted before being published
+		interrupted, err := d.GetStagingFolderPath(snapshotName, collector.Snapshot, now)
+		Expect(err).To(BeNil())
+		Expect(storage.WriteFile(path.Join(interrupted, "ClusterProfile", randomString()+".yaml"),
+			[]byte(randomString()))).To(Succeed())
+
+		// Collection interrupted while being published: content is in both folders
+		publishing, err := d.GetStagingFolderPath(snapshotName, collector.Snapshot, now)
+		Expect(err).To(BeNil())
+		partial := now.Add(time.Second).Format(collector.TimeFormat)
+		for _, folder := range []string{publishing,
+			path.Join(collector.GetArtifactFolderName(snapshotName, collector.Snapshot), partial)} {
+
+			Expect(storage.WriteFile(path.Join(folder, "ClusterProfile", randomString()+".yaml"),
+				[]byte(randomString()))).To(Succeed())
+		}
+		Expect(storage.WriteFile(publishing+collector.PublishMarkerSuffix, []byte(partial))).To(Succeed())
+
+		// Publish marker left behind by a collection which was published
+		published, err := d.GetStagingFolderPath(snapshotName, collector.Snapshot, now)
+		Expect(err).To(BeNil())
+		Expect(storage.WriteFile(published+collector.PublishMarkerSuffix,
+			[]byte(complete.Format(collector.TimeFormat)))).To(Succeed())
+
+		collections, err := d.ListCollections(storage, snapshotName, collector.Snapshot, logger)
+		Expect(err).To(BeNil())
+		Expect(collections).To(ConsistOf(complete.Format(collector.TimeFormat), partial))
+
+		removed, err = d.CleanupStagingFolders(storage, snapshotName, collector.Snapshot, logger)
+		Expect(err).To(BeNil())
+		Expect(removed).To(ConsistOf(path.Base(interrupted), path.Base(publishing)))
+
+		entries, err := storage.ReadDir(collector.GetArtifactFolderName(snapshotName, collector.Snapshot))
+		Expect(err).To(BeNil())
+		Expect(entries).To(Equal([]collector.StorageEntry{
+			{Name: complete.Format(collector.TimeFormat), IsDir: true},
+		}))
+	})
+})
@@ -0,0 +1,132 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	utilsv1beta1 "github.com/projectsveltos/sveltosctl/api/v1beta1"
+)
+
+// StorageEntry represents an entry (file or directory) in a Storage
+type StorageEntry struct {
+	Name  string
+	IsDir bool
+}
+
+// Storage is where collections are persisted.
+// Keys are slash separated paths, relative to the storage root.
+// Directories are implicit: a directory exists as long as it contains at least one file.
+// When key does not exist, ReadFile and ReadDir return an error wrapping fs.ErrNotExist.
+type Storage interface {
+	// WriteFile stores data at key. Any existing content is replaced.
+	WriteFile(key string, data []byte) error
+
+	// ReadFile returns the content stored at key
+	ReadFile(key string) ([]byte, error)
+
+	// ReadDir returns all entries directly contained in directory key, sorted by name
+	ReadDir(key string) ([]StorageEntry, error)
+
+	// Exists returns true if key is either a file or a directory
+	Exists(key string) (bool, error)
+
+	// RemoveAll removes key and, if key is a directory, everything it contains.
+	// It returns nil if key does not exist.
+	RemoveAll(key string) error
+
+	// Rename moves key (a file or a directory along with everything it contains) to newKey.
+	// It fails, wrapping fs.ErrExist, if newKey already exists.
+	Rename(key, newKey string) error
+}
+
+// NewStorage returns the Storage rooted at root for the passed backend.
+// If backend is nil, a filesystem Storage is returned.
+// If encryption is set, Secrets are encrypted with the keys contained in the encryption Secret.
+// Returned Storage reads packed collections (see PackCollection).
+func NewStorage(ctx context.Context, c client.Reader, root string,
+	backend *utilsv1beta1.StorageBackend, encryption *utilsv1beta1.SnapshotEncryption) (Storage, error) {
+
+	storage, err := newBackendStorage(ctx, c, root, backend)
+	if err != nil {
+		return nil, err
+	}
+
+	if encryption != nil {
+		keys, err := getEncryptionKeys(ctx, c, encryption)
+		if err != nil {
+			return nil, err
+		}
+		storage = NewEncryptedStorage(storage, keys)
+	}
+
+	return NewContentAddressedStorage(storage), nil
+}
+
+func newBackendStorage(ctx context.Context, c client.Reader, root string,
+	backend *utilsv1beta1.StorageBackend) (Storage, error) {
+
+	if backend == nil || backend.Type == "" ||
+		backend.Type == utilsv1beta1.StorageBackendTypeFilesystem {
+
+		return NewFilesystemStorage(root), nil
+	}
+
+	if backend.Type != utilsv1beta1.StorageBackendTypeS3 {
+		return nil, fmt.Errorf("unsupported storage backend type %q", backend.Type)
+	}
+
+	if backend.S3 == nil {
+		return nil, fmt.Errorf("storage backend type is %s but s3 section is not set",
+			utilsv1beta1.StorageBackendTypeS3)
+	}
+
+	secretRef := backend.S3.CredentialsSecretRef
+	secret := &corev1.Secret{}
+	err := c.Get(ctx, types.NamespacedName{Namespace: secretRef.Namespace, Name: secretRef.Name}, secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credentials Secret %s/%s: %w",
+			secretRef.Namespace, secretRef.Name, err)
+	}
+
+	accessKeyID, ok := secret.Data[utilsv1beta1.S3AccessKeyIDKey]
+	if !ok {
+		return nil, fmt.Errorf("credentials Secret %s/%s does not contain key %s",
+			secretRef.Namespace, secretRef.Name, utilsv1beta1.S3AccessKeyIDKey)
+	}
+	secretAccessKey, ok := secret.Data[utilsv1beta1.S3SecretAccessKeyKey]
+	if !ok {
+		return nil, fmt.Errorf("credentials Secret %s/%s does not contain key %s",
+			secretRef.Namespace, secretRef.Name, utilsv1beta1.S3SecretAccessKeyKey)
+	}
+
+	return NewS3Storage(&S3Options{
+		Endpoint:        backend.S3.Endpoint,
+		Bucket:          backend.S3.Bucket,
+		Region:          backend.S3.Region,
+		Insecure:        backend.S3.Insecure,
+		AccessKeyID:     string(accessKeyID),
+		SecretAccessKey: string(secretAccessKey),
+		SessionToken:    string(secret.Data[utilsv1beta1.S3SessionTokenKey]),
+		Prefix:          root,
+	})
+}
@@ -0,0 +1,504 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/yaml"
+
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+)
+
+const (
+	// objectsFolder is the folder, next to the collections of a requestor, where objects
+	// of packed collections are stored. Each object is stored once per content, in
+	// <kind>/<sha256 of content>.yaml, so it is shared by all collections containing it.
+	objectsFolder = ".objects"
+
+	// collectionKeyDepth is the number of elements of the key of a collection folder:
+	// <collection type>/<requestor>/<collection>
+	collectionKeyDepth = 3
+)
+
+// contentAddressedStorage reads packed collections. A packed collection only contains
+// its manifest (along with metadata, annotations and signature): any object listed in the
+// manifest is read from the objects folder.
+// Collections which are not packed (being collected or stored before packing was
+// introduced) are read as they are, so both layouts can coexist.
+// Objects of packed collections are read only.
+type contentAddressedStorage struct {
+	Storage
+
+	mu sync.Mutex
+	// manifests contains, per collection folder, the digest of each file listed in
+	// the collection manifest. Value is nil for collections without manifest.
+	manifests map[string]map[string]string
+}
+
+// NewContentAddressedStorage returns a Storage able to pack collections stored in storage
+// and to read packed collections
+func NewContentAddressedStorage(storage Storage) Storage {
+	return &contentAddressedStorage{Storage: storage, manifests: make(map[string]map[string]string)}
+}
+
+// splitCollectionKey returns, if key is within a collection folder, such folder and the
+// path of key relative to it
+func splitCollectionKey(key string) (folder, relativePath string, ok bool) {
+	elements := strings.Split(path.Clean(key), "/")
+	if len(elements) < collectionKeyDepth || elements[collectionKeyDepth-1] == objectsFolder {
+		return "", "", false
+	}
+
+	return path.Join(elements[:collectionKeyDepth]...), path.Join(elements[collectionKeyDepth:]...), true
+}
+
+// getObjectKey returns the key of the object storing the file at relativePath, with
+// given digest, of the collection in folder. Object name is the digest without its algorithm.
+// Objects keep kind in their path, so Secrets are still encrypted when stored as objects.
+func getObjectKey(folder, relativePath, digest string) string {
+	kind := path.Base(path.Dir(relativePath))
+	_, name, _ := strings.Cut(digest, ":")
+	return path.Join(path.Dir(folder), objectsFolder, kind, name+".yaml")
+}
+
+// getSampleManifest returns the manifest of the collection in folder, nil if there is none
+func getSampleManifest(storage Storage, folder string) (*SampleManifest, error) {
+	data, err := storage.ReadFile(path.Join(folder, sampleManifestFile))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	manifest := &SampleManifest{}
+	if err := yaml.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest in %s: %w", folder, err)
+	}
+
+	return manifest, nil
+}
+
+func (s *contentAddressedStorage) getManifestFiles(folder string) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if files, ok := s.manifests[folder]; ok {
+		return files, nil
+	}
+
+	manifest, err := getSampleManifest(s.Storage, folder)
+	if err != nil {
+		return nil, err
+	}
+
+	var files map[string]string
+	if manifest != nil {
+		files = make(map[string]string, len(manifest.Files))
+		for i := range manifest.Files {
+			files[manifest.Files[i].Path] = manifest.Files[i].Digest
+		}
+	}
+
+	s.manifests[folder] = files
+	return files, nil
+}
+
+// forget drops cached manifests. Called on any modification.
+func (s *contentAddressedStorage) forget() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.manifests = make(map[string]map[string]string)
+}
+
+func (s *contentAddressedStorage) WriteFile(key string, data []byte) error {
+	s.forget()
+	return s.Storage.WriteFile(key, data)
+}
+
+func (s *contentAddressedStorage) RemoveAll(key string) error {
+	s.forget()
+	return s.Storage.RemoveAll(key)
+}
+
+func (s *contentAddressedStorage) Rename(key, newKey string) error {
+	s.forget()
+	return s.Storage.Rename(key, newKey)
+}
+
+func (s *contentAddressedStorage) ReadFile(key string) ([]byte, error) {
+	data, err := s.Storage.ReadFile(key)
+	if err == nil || !errors.Is(err, fs.ErrNotExist) {
+		return data, err
+	}
+
+	folder, relativePath, ok := splitCollectionKey(key)
+	if !ok || relativePath == "" {
+		return nil, err
+	}
+
+	files, manifestErr := s.getManifestFiles(folder)
+	if manifestErr != nil {
+		return nil, manifestErr
+	}
+
+	digest, ok := files[relativePath]
+	if !ok {
+		return nil, err
+	}
+
+	return s.Storage.ReadFile(getObjectKey(folder, relativePath, digest))
+}
+
+func (s *contentAddressedStorage) ReadDir(key string) ([]StorageEntry, error) {
+	entries, err := s.Storage.ReadDir(key)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	folder, relativePath, ok := splitCollectionKey(key)
+	if !ok {
+		return entries, err
+	}
+
+	files, manifestErr := s.getManifestFiles(folder)
+	if manifestErr != nil {
+		return nil, manifestErr
+	}
+
+	packed := getManifestEntries(files, relativePath)
+	if len(packed) == 0 {
+		return entries, err
+	}
+
+	names := make(map[string]bool, len(entries))
+	for i := range entries {
+		names[entries[i].Name] = true
+	}
+	for i := range packed {
+		if !names[packed[i].Name] {
+			names[packed[i].Name] = true
+			entries = append(entries, packed[i])
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+
+	return entries, nil
+}
+
+func (s *contentAddressedStorage) Exists(key string) (bool, error) {
+	exist, err := s.Storage.Exists(key)
+	if err != nil || exist {
+		return exist, err
+	}
+
+	folder, relativePath, ok := splitCollectionKey(key)
+	if !ok || relativePath == "" {
+		return false, nil
+	}
+
+	files, err := s.getManifestFiles(folder)
+	if err != nil {
+		return false, err
+	}
+
+	if _, ok := files[relativePath]; ok {
+		return true, nil
+	}
+	prefix := relativePath + "/"
+	for p := range files {
+		if strings.HasPrefix(p, prefix) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// getManifestEntries returns the entries directly contained in directory dir (relative
+// to the collection folder) according to the manifest files
+func getManifestEntries(files map[string]string, dir string) []StorageEntry {
+	prefix := ""
+	if dir != "" {
+		prefix = dir + "/"
+	}
+
+	names := make(map[string]bool)
+	entries := make([]StorageEntry, 0)
+	for p := range files {
+		if !strings.HasPrefix(p, prefix) {
+			continue
+		}
+		name, _, isDir := strings.Cut(strings.TrimPrefix(p, prefix), "/")
+		if names[name] {
+			continue
+		}
+		names[name] = true
+		entries = append(entries, StorageEntry{Name: name, IsDir: isDir})
+	}
+
+	return entries
+}
+
+func getContentAddressedStorage(storage Storage) (*contentAddressedStorage, error) {
+	s, ok := storage.(*contentAddressedStorage)
+	if !ok {
+		return nil, errors.New("storage is not content addressed")
+	}
+	return s, nil
+}
+
+// isCollectionPacked returns true if the collection in folder has a manifest and
+// all its objects are in the objects folder
+func (s *contentAddressedStorage) isCollectionPacked(folder string) (bool, error) {
+	files, err := s.getManifestFiles(folder)
+	if err != nil || files == nil {
+		return false, err
+	}
+
+	// Objects are always stored in subdirectories (namespace and/or kind)
+	entries, err := s.Storage.ReadDir(folder)
+	if err != nil {
+		return false, err
+	}
+	for i := range entries {
+		if entries[i].IsDir {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// PackCollection moves every object of the collection in folder to the objects folder,
+// shared by all collections of the same requestor, where identical objects are stored once.
+// The collection keeps its manifest, which is used to locate its objects.
+// The collection must have a manifest matching its content. Caller must hold a pack lease
+// (see AcquirePackLease) until the collection is published.
+func (d *Collector) PackCollection(storage Storage, folder string) error {
+	s, err := getContentAddressedStorage(storage)
+	if err != nil {
+		return err
+	}
+
+	expected, err := getSampleManifest(s.Storage, folder)
+	if err != nil {
+		return err
+	}
+	if expected == nil {
+		return fmt.Errorf("collection %s has no manifest", folder)
+	}
+
+	// Content is read through s, so a collection partially packed by a previous attempt
+	// is still complete
+	current, err := buildSampleManifest(s, folder, expected)
+	if err != nil {
+		return err
+	}
+	if problems := compareManifests(expected, current); len(problems) > 0 {
+		return fmt.Errorf("collection %s does not match its manifest: %s", folder, strings.Join(problems, "; "))
+	}
+
+	for i := range current.Files {
+		f := &current.Files[i]
+		// Sample metadata stays in the collection folder
+		if !isObjectFile(f.Path) {
+			continue
+		}
+		objectKey := getObjectKey(folder, f.Path, f.Digest)
+		exist, err := s.Storage.Exists(objectKey)
+		if err != nil {
+			return err
+		}
+		if exist {
+			continue
+		}
+
+		data, err := s.ReadFile(path.Join(folder, f.Path))
+		if err != nil {
+			return err
+		}
+		if err := s.Storage.WriteFile(objectKey, data); err != nil {
+			return err
+		}
+	}
+
+	entries, err := s.Storage.ReadDir(folder)
+	if err != nil {
+		return err
+	}
+	for i := range entries {
+		if entries[i].IsDir {
+			if err := s.RemoveAll(path.Join(folder, entries[i].Name)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// MigrateCollections packs every collection for requestorName still stored as a directory
+// of objects. Collections without manifest get one first, signed with key if set.
+// If dryRun is set, nothing is modified.
+// Returns the collections migrated (or which would be migrated).
+func (d *Collector) MigrateCollections(storage Storage, requestorName string, collectionType CollectionType,
+	key ed25519.PrivateKey, dryRun bool, logger logr.Logger) ([]string, error) {
+
+	s, err := getContentAddressedStorage(storage)
+	if err != nil {
+		return nil, err
+	}
+
+	collections, err := listCollectionsForRequestor(s, requestorName, collectionType, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	if !dryRun {
+		release, err := d.AcquirePackLease(s, requestorName, collectionType, logger)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+	}
+
+	artifactFolder := getArtifactFolderName(requestorName, collectionType)
+	migrated := make([]string, 0)
+	for i := range collections {
+		folder := path.Join(artifactFolder, collections[i])
+		packed, err := s.isCollectionPacked(folder)
+		if err != nil {
+			return nil, err
+		}
+		if packed {
+			continue
+		}
+
+		migrated = append(migrated, collections[i])
+		if dryRun {
+			continue
+		}
+
+		logger.V(logs.LogDebug).Info(fmt.Sprintf("packing collection %s", collections[i]))
+		manifest, err := getSampleManifest(s.Storage, folder)
+		if err != nil {
+			return nil, err
+		}
+		if manifest == nil {
+			if err := d.WriteSampleManifest(s, folder, key); err != nil {
+				return nil, err
+			}
+		}
+		if err := d.PackCollection(s, folder); err != nil {
+			return nil, err
+		}
+	}
+
+	return migrated, nil
+}
+
+// CollectGarbage removes, from the objects folder of requestorName, every object which
+// is not referenced by any collection anymore (including collections being collected).
+// Nothing is removed while a collection for requestorName is being packed (see AcquirePackLease),
+// by this or any other process.
+// Returns the number of removed objects.
+func (d *Collector) CollectGarbage(storage Storage, requestorName string, collectionType CollectionType,
+	logger logr.Logger) (int, error) {
+
+	artifactFolder := getArtifactFolderName(requestorName, collectionType)
+	objects := path.Join(artifactFolder, objectsFolder)
+
+	exist, err := storage.Exists(objects)
+	if err != nil || !exist {
+		return 0, err
+	}
+
+	release, acquired, err := acquireGCLease(storage, requestorName, collectionType, logger)
+	if err != nil {
+		return 0, err
+	}
+	if !acquired {
+		logger.V(logs.LogDebug).Info("a collection is being packed. Skipping garbage collection")
+		return 0, nil
+	}
+	defer release()
+
+	entries, err := storage.ReadDir(artifactFolder)
+	if err != nil {
+		return 0, err
+	}
+
+	referenced := make(map[string]bool)
+	for i := range entries {
+		if !entries[i].IsDir || entries[i].Name == objectsFolder {
+			continue
+		}
+		folder := path.Join(artifactFolder, entries[i].Name)
+		manifest, err := getSampleManifest(storage, folder)
+		if err != nil {
+			return 0, err
+		}
+		if manifest == nil {
+			continue
+		}
+		for j := range manifest.Files {
+			referenced[getObjectKey(folder, manifest.Files[j].Path, manifest.Files[j].Digest)] = true
+		}
+	}
+
+	kinds, err := storage.ReadDir(objects)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for i := range kinds {
+		if !kinds[i].IsDir {
+			continue
+		}
+		files, err := storage.ReadDir(path.Join(objects, kinds[i].Name))
+		if err != nil {
+			return removed, err
+		}
+		for j := range files {
+			key := path.Join(objects, kinds[i].Name, files[j].Name)
+			if referenced[key] {
+				continue
+			}
+			logger.V(logs.LogDebug).Info(fmt.Sprintf("removing unreferenced object %s", key))
+			if err := storage.RemoveAll(key); err != nil {
+				return removed, err
+			}
+			removed++
+		}
+	}
+
+	return removed, nil
+}
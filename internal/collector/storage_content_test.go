@@ -0,0 +1,316 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector_test
+
+import (
+	"os"
+	"path"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	configv1beta1 "github.com/projectsveltos/addon-controller/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2/textlogger"
+
+	utilsv1beta1 "github.com/projectsveltos/sveltosctl/api/v1beta1"
+	"github.com/projectsveltos/sveltosctl/internal/collector"
+)
+
+var _ = Describe("Content addressed storage", func() {
+	var root string
+	var plainStorage collector.Storage
+	var snapshotName string
+	var configMap *corev1.ConfigMap
+
+	BeforeEach(func() {
+		var err error
+		root, err = os.MkdirTemp("", randomString())
+		Expect(err).To(BeNil())
+
+		plainStorage = collector.NewFilesystemStorage(root)
+		snapshotName = randomString()
+
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: randomString(), Name: randomString()},
+			Data:       map[string]string{"key": randomString()},
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(root)
+	})
+
+	// dumpSample stores, in a new sample, configMap and a ClusterProfile unique to the sample
+	dumpSample := func(storage collector.Storage, t time.Time) string {
+		logger := textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1)))
+		folder := collector.GetClient().GetFolderPath(snapshotName, collector.Snapshot, t)
+		Expect(collector.GetClient().DumpObject(storage, configMap.DeepCopy(), folder, logger)).To(Succeed())
+		clusterProfile := &configv1beta1.ClusterProfile{ObjectMeta: metav1.ObjectMeta{Name: randomString()}}
+		Expect(collector.GetClient().DumpObject(storage, clusterProfile, folder, logger)).To(Succeed())
+		return folder
+	}
+
+	countObjects := func(kind string) int {
+		entries, err := plainStorage.ReadDir(path.Join("snapshot", snapshotName, ".objects", kind))
+		if err != nil {
+			return 0
+		}
+		return len(entries)
+	}
+
+	It("PackCollection stores identical objects once and packed collections are read as before", func() {
+		d := collector.GetClient()
+		logger := textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1)))
+		storage := collector.NewContentAddressedStorage(plainStorage)
+
+		now := time.Now()
+		folders := []string{dumpSample(storage, now.Add(-time.Hour)), dumpSample(storage, now)}
+
+		// Collections without manifest cannot be packed
+		Expect(d.PackCollection(storage, folders[0])).ToNot(Succeed())
+		// Only content addressed storages can pack collections
+		Expect(d.WriteSampleManifest(storage, folders[0], nil)).To(Succeed())
+		Expect(d.PackCollection(plainStorage, folders[0])).ToNot(Succeed())
+
+		for i := range folders {
+			Expect(d.WriteSampleManifest(storage, folders[i], nil)).To(Succeed())
+			Expect(d.PackCollection(storage, folders[i])).To(Succeed())
+
+			// Collection only contains manifest and metadata
+			entries, err := plainStorage.ReadDir(folders[i])
+			Expect(err).To(BeNil())
+			for j := range entries {
+				Expect(entries[j].IsDir).To(BeFalse())
+			}
+		}
+
+		Expect(countObjects("ConfigMap")).To(Equal(1))
+		Expect(countObjects(configv1beta1.ClusterProfileKind)).To(Equal(2))
+
+		collections, err := d.ListCollections(storage, snapshotName, collector.Snapshot, logger)
+		Expect(err).To(BeNil())
+		Expect(collections).To(HaveLen(len(folders)))
+
+		for i := range folders {
+			_, err = d.VerifySample(storage, folders[i], nil)
+			Expect(err).To(BeNil())
+
+			configMaps, err := d.GetNamespacedResources(storage, folders[i], "ConfigMap", logger)
+			Expect(err).To(BeNil())
+			Expect(configMaps).To(HaveKey(configMap.Namespace))
+			Expect(configMaps[configMap.Namespace]).To(HaveLen(1))
+			Expect(configMaps[configMap.Namespace][0].GetName()).To(Equal(configMap.Name))
+
+			clusterProfiles, err := d.GetClusterResources(storage, folders[i], configv1beta1.ClusterProfileKind, logger)
+			Expect(err).To(BeNil())
+			Expect(clusterProfiles).To(HaveLen(1))
+
+			objects, err := d.GetSampleObjects(storage, folders[i], logger)
+			Expect(err).To(BeNil())
+			Expect(objects).To(HaveLen(2))
+
+			exist, err := storage.Exists(path.Join(folders[i], configMap.Namespace, "ConfigMap", configMap.Name+".yaml"))
+			Expect(err).To(BeNil())
+			Expect(exist).To(BeTrue())
+		}
+	})
+
+	It("CollectGarbage removes objects referenced by no collection", func() {
+		d := collector.GetClient()
+		logger := textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1)))
+		storage := collector.NewContentAddressedStorage(plainStorage)
+
+		removed, err := d.CollectGarbage(storage, snapshotName, collector.Snapshot, logger)
+		Expect(err).To(BeNil())
+		Expect(removed).To(BeZero())
+
+		now := time.Now()
+		folders := []string{dumpSample(storage, now.Add(-time.Hour)), dumpSample(storage, now)}
+		for i := range folders {
+			Expect(d.WriteSampleManifest(storage, folders[i], nil)).To(Succeed())
+			Expect(d.PackCollection(storage, folders[i])).To(Succeed())
+		}
+
+		removed, err = d.CollectGarbage(storage, snapshotName, collector.Snapshot, logger)
+		Expect(err).To(BeNil())
+		Expect(removed).To(BeZero())
+
+		hourly := int32(1)
+		pruned, err := d.PruneCollections(storage, snapshotName, collector.Snapshot,
+			&utilsv1beta1.RetentionPolicy{Hourly: &hourly}, now, false, logger)
+		Expect(err).To(BeNil())
+		Expect(pruned).To(ConsistOf(path.Base(folders[0])))
+
+		// ConfigMap is still referenced by the remaining sample
+		removed, err = d.CollectGarbage(storage, snapshotName, collector.Snapshot, logger)
+		Expect(err).To(BeNil())
+		Expect(removed).To(Equal(1))
+		Expect(countObjects("ConfigMap")).To(Equal(1))
+		Expect(countObjects(configv1beta1.ClusterProfileKind)).To(Equal(1))
+
+		_, err = d.VerifySample(storage, folders[1], nil)
+		Expect(err).To(BeNil())
+	})
+
+	It("CollectGarbage does not remove objects while a collection is being packed", func() {
+		d := collector.GetClient()
+		logger := textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1)))
+		storage := collector.NewContentAddressedStorage(plainStorage)
+
+		now := time.Now()
+		old := dumpSample(storage, now.Add(-time.Hour))
+		Expect(d.WriteSampleManifest(storage, old, nil)).To(Succeed())
+		Expect(d.PackCollection(storage, old)).To(Succeed())
+
+		// New sample, sharing the ConfigMap with old, starts being collected
+		release, err := d.AcquirePackLease(storage, snapshotName, collector.Snapshot, logger)
+		Expect(err).To(BeNil())
+		folder := dumpSample(storage, now)
+
+		// Old sample is removed (by retention) before new sample is packed, so the ConfigMap
+		// object is referenced by no manifest. Garbage collection must not remove it, as
+		// packing new sample relies on it.
+		Expect(storage.RemoveAll(old)).To(Succeed())
+		removed, err := d.CollectGarbage(storage, snapshotName, collector.Snapshot, logger)
+		Expect(err).To(BeNil())
+		Expect(removed).To(BeZero())
+		Expect(countObjects("ConfigMap")).To(Equal(1))
+
+		Expect(d.WriteSampleManifest(storage, folder, nil)).To(Succeed())
+		Expect(d.PackCollection(storage, folder)).To(Succeed())
+		release()
+
+		// Only the ClusterProfile of old sample is not referenced anymore
+		removed, err = d.CollectGarbage(storage, snapshotName, collector.Snapshot, logger)
+		Expect(err).To(BeNil())
+		Expect(removed).To(Equal(1))
+		Expect(countObjects("ConfigMap")).To(Equal(1))
+
+		_, err = d.VerifySample(storage, folder, nil)
+		Expect(err).To(BeNil())
+	})
+
+	It("AcquirePackLease waits for garbage collection to complete", func() {
+		d := collector.GetClient()
+		logger := textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1)))
+		storage := collector.NewContentAddressedStorage(plainStorage)
+
+		releaseGC, acquired, err := collector.AcquireGCLease(storage, snapshotName, collector.Snapshot, logger)
+		Expect(err).To(BeNil())
+		Expect(acquired).To(BeTrue())
+
+		packing := make(chan func())
+		go func() {
+			defer GinkgoRecover()
+			release, err := d.AcquirePackLease(storage, snapshotName, collector.Snapshot, logger)
+			Expect(err).To(BeNil())
+			packing <- release
+		}()
+
+		Consistently(packing, 2*time.Second, 100*time.Millisecond).ShouldNot(Receive())
+		releaseGC()
+
+		var releasePack func()
+		Eventually(packing, 5*time.Second, 100*time.Millisecond).Should(Receive(&releasePack))
+
+		// Garbage collection does not start while a collection is being packed
+		_, acquired, err = collector.AcquireGCLease(storage, snapshotName, collector.Snapshot, logger)
+		Expect(err).To(BeNil())
+		Expect(acquired).To(BeFalse())
+
+		releasePack()
+		releaseGC, acquired, err = collector.AcquireGCLease(storage, snapshotName, collector.Snapshot, logger)
+		Expect(err).To(BeNil())
+		Expect(acquired).To(BeTrue())
+		releaseGC()
+	})
+
+	It("MigrateCollections packs collections stored as a directory of objects", func() {
+		d := collector.GetClient()
+		logger := textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1)))
+		storage := collector.NewContentAddressedStorage(plainStorage)
+
+		// Collection taken before manifests were introduced
+		legacy := dumpSample(plainStorage, time.Now())
+
+		migrated, err := d.MigrateCollections(storage, snapshotName, collector.Snapshot, nil, true, logger)
+		Expect(err).To(BeNil())
+		Expect(migrated).To(ConsistOf(path.Base(legacy)))
+		Expect(countObjects("ConfigMap")).To(BeZero())
+
+		migrated, err = d.MigrateCollections(storage, snapshotName, collector.Snapshot, nil, false, logger)
+		Expect(err).To(BeNil())
+		Expect(migrated).To(ConsistOf(path.Base(legacy)))
+		Expect(countObjects("ConfigMap")).To(Equal(1))
+
+		manifest, err := d.VerifySample(storage, legacy, nil)
+		Expect(err).To(BeNil())
+		Expect(manifest.Counts).To(Equal(map[string]int{"ConfigMap": 1, configv1beta1.ClusterProfileKind: 1}))
+
+		// Nothing left to migrate
+		migrated, err = d.MigrateCollections(storage, snapshotName, collector.Snapshot, nil, false, logger)
+		Expect(err).To(BeNil())
+		Expect(migrated).To(BeEmpty())
+	})
+
+	It("Secrets stored as objects are encrypted and re-encrypted", func() {
+		d := collector.GetClient()
+		logger := textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1)))
+
+		oldKey := generateKey()
+		oldKeys, err := collector.NewEncryptionKeys(map[string][]byte{utilsv1beta1.EncryptionKeyKey: oldKey})
+		Expect(err).To(BeNil())
+		storage := collector.NewContentAddressedStorage(collector.NewEncryptedStorage(plainStorage, oldKeys))
+
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: randomString(), Name: randomString()},
+			Data:       map[string][]byte{"password": []byte(randomString())},
+		}
+		folder := collector.GetClient().GetFolderPath(snapshotName, collector.Snapshot, time.Now())
+		Expect(d.DumpObject(storage, secret.DeepCopy(), folder, logger)).To(Succeed())
+		Expect(d.WriteSampleManifest(storage, folder, nil)).To(Succeed())
+		Expect(d.PackCollection(storage, folder)).To(Succeed())
+
+		objects, err := plainStorage.ReadDir(path.Join("snapshot", snapshotName, ".objects", "Secret"))
+		Expect(err).To(BeNil())
+		Expect(objects).To(HaveLen(1))
+		raw, err := plainStorage.ReadFile(path.Join("snapshot", snapshotName, ".objects", "Secret", objects[0].Name))
+		Expect(err).To(BeNil())
+		Expect(string(raw)).ToNot(ContainSubstring(secret.Name))
+
+		secretFile := path.Join(folder, secret.Namespace, "Secret", secret.Name+".yaml")
+		data, err := storage.ReadFile(secretFile)
+		Expect(err).To(BeNil())
+		Expect(string(data)).To(ContainSubstring(secret.Name))
+
+		newKeys, err := collector.NewEncryptionKeys(map[string][]byte{
+			utilsv1beta1.EncryptionKeyKey: generateKey(),
+			"previous":                    oldKey,
+		})
+		Expect(err).To(BeNil())
+		storage = collector.NewContentAddressedStorage(collector.NewEncryptedStorage(plainStorage, newKeys))
+		count, err := d.ReencryptCollections(storage, snapshotName, collector.Snapshot, logger)
+		Expect(err).To(BeNil())
+		Expect(count).To(Equal(1))
+
+		_, err = d.VerifySample(storage, folder, nil)
+		Expect(err).To(BeNil())
+	})
+})
@@ -0,0 +1,494 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+	utilsv1beta1 "github.com/projectsveltos/sveltosctl/api/v1beta1"
+)
+
+const (
+	// encryptionCipher is the only cipher currently used to encrypt Secrets
+	encryptionCipher = "AES-256-GCM"
+
+	// encryptionKeySize is the size in bytes of an AES-256 key
+	encryptionKeySize = 32
+
+	// encryptedFileHeader is the beginning of every encrypted file. A plain Secret
+	// starts with apiVersion, so the two are never mistaken.
+	encryptedFileHeader = "encryptedSecret:"
+
+	secretKind = "Secret"
+
+	// digestKeyFile is the file, next to the collections of a requestor, storing the key of
+	// Secret digests. It is encrypted, and re-encrypted when the encryption key rotates,
+	// so Secret digests do not depend on the encryption key in use.
+	digestKeyFile = ".digest-key"
+
+	// digestKeySize is the size in bytes of the key of Secret digests
+	digestKeySize = 32
+
+	// keyedDigestPrefix is the prefix of Secret digests computed with the digest key
+	keyedDigestPrefix = "hmac-sha256:"
+)
+
+// encryptedFile is the content stored in place of a Secret when encryption is enabled
+type encryptedFile struct {
+	EncryptedSecret encryptedPayload `json:"encryptedSecret"`
+}
+
+type encryptedPayload struct {
+	// Cipher used to encrypt Data
+	Cipher string `json:"cipher"`
+
+	// KeyID identifies the key used to encrypt Data
+	KeyID string `json:"keyID"`
+
+	// Data contains the nonce followed by the encrypted Secret
+	Data []byte `json:"data"`
+}
+
+// EncryptionKeys contains the keys used to encrypt and decrypt Secrets
+type EncryptionKeys struct {
+	// activeKeyID is the ID of the key used to encrypt
+	activeKeyID string
+
+	// keys contains all keys, by ID. Any of them can be used to decrypt.
+	keys map[string]cipher.AEAD
+}
+
+// NewEncryptionKeys returns the EncryptionKeys built from data, the Data section of
+// the encryption Secret. Entry EncryptionKeyKey is the key used to encrypt. Any other
+// entry is a previous key only used to decrypt.
+func NewEncryptionKeys(data map[string][]byte) (*EncryptionKeys, error) {
+	activeKey, ok := data[utilsv1beta1.EncryptionKeyKey]
+	if !ok {
+		return nil, fmt.Errorf("encryption Secret does not contain key %s", utilsv1beta1.EncryptionKeyKey)
+	}
+
+	keys := &EncryptionKeys{keys: make(map[string]cipher.AEAD)}
+	for name := range data {
+		if len(data[name]) != encryptionKeySize {
+			return nil, fmt.Errorf("encryption key %s must be %d bytes long", name, encryptionKeySize)
+		}
+		block, err := aes.NewCipher(data[name])
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		keys.keys[getEncryptionKeyID(data[name])] = aead
+	}
+	keys.activeKeyID = getEncryptionKeyID(activeKey)
+
+	return keys, nil
+}
+
+// getEncryptionKeyID returns an identifier for key which does not reveal it
+func getEncryptionKeyID(key []byte) string {
+	const idLength = 16
+	h := sha256.Sum256(key)
+	return hex.EncodeToString(h[:])[:idLength]
+}
+
+// getEncryptionKeys fetches the encryption Secret referenced by encryption
+func getEncryptionKeys(ctx context.Context, c client.Reader, encryption *utilsv1beta1.SnapshotEncryption,
+) (*EncryptionKeys, error) {
+
+	secretRef := encryption.KeySecretRef
+	secret := &corev1.Secret{}
+	err := c.Get(ctx, types.NamespacedName{Namespace: secretRef.Namespace, Name: secretRef.Name}, secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get encryption Secret %s/%s: %w",
+			secretRef.Namespace, secretRef.Name, err)
+	}
+
+	keys, err := NewEncryptionKeys(secret.Data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption Secret %s/%s: %w",
+			secretRef.Namespace, secretRef.Name, err)
+	}
+
+	return keys, nil
+}
+
+// encryptedStorage is a Storage encrypting Secrets before storing them
+// in the underlying Storage and decrypting them when read.
+// Any other file is stored as it is.
+type encryptedStorage struct {
+	Storage
+	keys *EncryptionKeys
+
+	mu sync.Mutex
+	// digestKeys contains, per artifact folder, the key of Secret digests
+	digestKeys map[string][]byte
+}
+
+// NewEncryptedStorage returns a Storage which encrypts Secrets with keys before
+// storing them in storage
+func NewEncryptedStorage(storage Storage, keys *EncryptionKeys) Storage {
+	return &encryptedStorage{Storage: storage, keys: keys, digestKeys: make(map[string][]byte)}
+}
+
+// isSecretFile returns true if key is where a Secret is stored: <...>/<namespace>/Secret/<name>.yaml
+func isSecretFile(key string) bool {
+	return path.Base(path.Dir(key)) == secretKind && strings.HasSuffix(key, ".yaml")
+}
+
+// getAdditionalData returns the data authenticated along with a Secret.
+// It is the namespace/Secret/name part of the key, so an encrypted Secret cannot be
+// swapped with another one, while samples can still be moved within the storage.
+func getAdditionalData(key string) []byte {
+	dir, name := path.Split(key)
+	namespace := path.Base(path.Dir(path.Clean(dir)))
+	return []byte(path.Join(namespace, secretKind, name))
+}
+
+func isEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(encryptedFileHeader))
+}
+
+func (s *encryptedStorage) WriteFile(key string, data []byte) error {
+	if !isSecretFile(key) {
+		return s.Storage.WriteFile(key, data)
+	}
+
+	encrypted, err := s.encrypt(key, data)
+	if err != nil {
+		return err
+	}
+
+	return s.Storage.WriteFile(key, encrypted)
+}
+
+func (s *encryptedStorage) ReadFile(key string) ([]byte, error) {
+	data, err := s.Storage.ReadFile(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Secrets stored before encryption was enabled are in clear text
+	if !isSecretFile(key) || !isEncrypted(data) {
+		return data, nil
+	}
+
+	return s.decrypt(key, data)
+}
+
+func (s *encryptedStorage) encrypt(key string, data []byte) ([]byte, error) {
+	aead := s.keys.keys[s.keys.activeKeyID]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	file := &encryptedFile{
+		EncryptedSecret: encryptedPayload{
+			Cipher: encryptionCipher,
+			KeyID:  s.keys.activeKeyID,
+			Data:   aead.Seal(nonce, nonce, data, getAdditionalData(key)),
+		},
+	}
+
+	return yaml.Marshal(file)
+}
+
+func (s *encryptedStorage) decrypt(key string, data []byte) ([]byte, error) {
+	file := &encryptedFile{}
+	if err := yaml.Unmarshal(data, file); err != nil {
+		return nil, fmt.Errorf("failed to parse encrypted Secret %s: %w", key, err)
+	}
+
+	payload := &file.EncryptedSecret
+	if payload.Cipher != encryptionCipher {
+		return nil, fmt.Errorf("encrypted Secret %s uses unsupported cipher %q", key, payload.Cipher)
+	}
+
+	aead, ok := s.keys.keys[payload.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("encrypted Secret %s was encrypted with key %s which is not in the encryption Secret",
+			key, payload.KeyID)
+	}
+
+	if len(payload.Data) < aead.NonceSize() {
+		return nil, fmt.Errorf("encrypted Secret %s is corrupted", key)
+	}
+	nonce, ciphertext := payload.Data[:aead.NonceSize()], payload.Data[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, getAdditionalData(key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt Secret %s: %w", key, err)
+	}
+
+	return plaintext, nil
+}
+
+// getSecretDigest returns the digest of data, a Secret stored in a collection of artifactFolder.
+// Digests are stored in clear text (in manifests and as object names). A plain SHA-256 would
+// let anyone with access to the storage confirm a guessed Secret value, so the digest is an
+// HMAC-SHA256 with a key only readable with the encryption key.
+func (s *encryptedStorage) getSecretDigest(artifactFolder string, data []byte) (string, error) {
+	key, err := s.getDigestKey(artifactFolder)
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return keyedDigestPrefix + hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// getDigestKey returns the key of Secret digests for the collections in artifactFolder.
+// Key is created the first time it is needed.
+func (s *encryptedStorage) getDigestKey(artifactFolder string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key, ok := s.digestKeys[artifactFolder]; ok {
+		return key, nil
+	}
+
+	keyPath := path.Join(artifactFolder, digestKeyFile)
+	key, err := s.readDigestKey(keyPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		key, err = s.createDigestKey(keyPath)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.digestKeys[artifactFolder] = key
+	return key, nil
+}
+
+func (s *encryptedStorage) readDigestKey(keyPath string) ([]byte, error) {
+	data, err := s.Storage.ReadFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.decrypt(keyPath, data)
+}
+
+// createDigestKey stores a new random key at keyPath. If another process stored one
+// in the meantime, that one is returned instead.
+func (s *encryptedStorage) createDigestKey(keyPath string) ([]byte, error) {
+	key := make([]byte, digestKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	encrypted, err := s.encrypt(keyPath, key)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := getRandomID()
+	if err != nil {
+		return nil, err
+	}
+
+	// Key is written aside then renamed, which never replaces an existing key
+	tmpPath := fmt.Sprintf("%s-%s", keyPath, id)
+	if err := s.Storage.WriteFile(tmpPath, encrypted); err != nil {
+		return nil, err
+	}
+
+	err = s.Storage.Rename(tmpPath, keyPath)
+	if err == nil {
+		return key, nil
+	}
+
+	_ = s.Storage.RemoveAll(tmpPath)
+	if errors.Is(err, fs.ErrExist) {
+		return s.readDigestKey(keyPath)
+	}
+	return nil, err
+}
+
+// reencryptDigestKey encrypts, with the active key, the key of Secret digests for the
+// collections in artifactFolder, if any
+func (s *encryptedStorage) reencryptDigestKey(artifactFolder string) error {
+	keyPath := path.Join(artifactFolder, digestKeyFile)
+	exist, err := s.Exists(keyPath)
+	if err != nil || !exist {
+		return err
+	}
+
+	reencrypt, err := s.needsReencryption(keyPath)
+	if err != nil || !reencrypt {
+		return err
+	}
+
+	key, err := s.readDigestKey(keyPath)
+	if err != nil {
+		return err
+	}
+
+	encrypted, err := s.encrypt(keyPath, key)
+	if err != nil {
+		return err
+	}
+
+	return s.Storage.WriteFile(keyPath, encrypted)
+}
+
+// needsReencryption returns true if the Secret stored at key is either in clear text
+// or encrypted with a key other than the active one
+func (s *encryptedStorage) needsReencryption(key string) (bool, error) {
+	data, err := s.Storage.ReadFile(key)
+	if err != nil {
+		return false, err
+	}
+
+	if !isEncrypted(data) {
+		return true, nil
+	}
+
+	file := &encryptedFile{}
+	if err := yaml.Unmarshal(data, file); err != nil {
+		return false, fmt.Errorf("failed to parse encrypted Secret %s: %w", key, err)
+	}
+
+	return file.EncryptedSecret.KeyID != s.keys.activeKeyID, nil
+}
+
+// ReencryptCollections encrypts, with the active key, every Secret in every collection
+// for requestorName which is either in clear text or encrypted with a previous key.
+// Returns the number of re-encrypted Secrets.
+func (d *Collector) ReencryptCollections(storage Storage, requestorName string, collectionType CollectionType,
+	logger logr.Logger) (int, error) {
+
+	s, ok := getEncryptedStorage(storage)
+	if !ok {
+		return 0, errors.New("encryption is not configured")
+	}
+
+	collections, err := listCollectionsForRequestor(storage, requestorName, collectionType, logger)
+	if err != nil {
+		return 0, err
+	}
+
+	artifactFolder := getArtifactFolderName(requestorName, collectionType)
+
+	// Secret digests of existing collections keep being computed with the same key
+	if err := s.reencryptDigestKey(artifactFolder); err != nil {
+		return 0, err
+	}
+
+	folders := make([]string, len(collections))
+	for i := range collections {
+		folders[i] = path.Join(artifactFolder, collections[i])
+	}
+
+	// Secrets of packed collections are stored in the objects folder
+	objects := path.Join(artifactFolder, objectsFolder)
+	exist, err := s.Exists(objects)
+	if err != nil {
+		return 0, err
+	}
+	if exist {
+		folders = append(folders, objects)
+	}
+
+	count := 0
+	for i := range folders {
+		n, err := s.reencryptFolder(folders[i], logger)
+		count += n
+		if err != nil {
+			return count, err
+		}
+	}
+
+	return count, nil
+}
+
+// getEncryptedStorage returns the encrypted storage storage is, or is built on
+func getEncryptedStorage(storage Storage) (*encryptedStorage, bool) {
+	if s, ok := storage.(*contentAddressedStorage); ok {
+		storage = s.Storage
+	}
+
+	s, ok := storage.(*encryptedStorage)
+	return s, ok
+}
+
+func (s *encryptedStorage) reencryptFolder(folder string, logger logr.Logger) (int, error) {
+	entries, err := s.ReadDir(folder)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := range entries {
+		key := path.Join(folder, entries[i].Name)
+		if entries[i].IsDir {
+			n, err := s.reencryptFolder(key, logger)
+			count += n
+			if err != nil {
+				return count, err
+			}
+			continue
+		}
+
+		if !isSecretFile(key) {
+			continue
+		}
+
+		reencrypt, err := s.needsReencryption(key)
+		if err != nil {
+			return count, err
+		}
+		if !reencrypt {
+			continue
+		}
+
+		logger.V(logs.LogDebug).Info(fmt.Sprintf("re-encrypting %s", key))
+		data, err := s.ReadFile(key)
+		if err != nil {
+			return count, err
+		}
+		if err := s.WriteFile(key, data); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, nil
+}
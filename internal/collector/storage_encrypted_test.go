@@ -0,0 +1,254 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/v2/textlogger"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	utilsv1beta1 "github.com/projectsveltos/sveltosctl/api/v1beta1"
+	"github.com/projectsveltos/sveltosctl/internal/collector"
+)
+
+var _ = Describe("Encrypted storage", func() {
+	var root string
+	var secret *corev1.Secret
+
+	BeforeEach(func() {
+		var err error
+		root, err = os.MkdirTemp("", randomString())
+		Expect(err).To(BeNil())
+
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: randomString(),
+				Name:      randomString(),
+			},
+			Data: map[string][]byte{
+				"password": []byte(randomString()),
+			},
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(root)
+	})
+
+	It("encrypts Secrets and transparently decrypts them", func() {
+		keys, err := collector.NewEncryptionKeys(map[string][]byte{utilsv1beta1.EncryptionKeyKey: generateKey()})
+		Expect(err).To(BeNil())
+
+		plainStorage := collector.NewFilesystemStorage(root)
+		storage := collector.NewEncryptedStorage(plainStorage, keys)
+
+		folder := path.Join("snapshot", randomString(), time.Now().Format(collector.TimeFormat))
+		logger := textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1)))
+		Expect(collector.GetClient().DumpObject(storage, secret.DeepCopy(), folder, logger)).To(Succeed())
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: secret.Namespace, Name: randomString()},
+			Data:       map[string]string{"key": randomString()},
+		}
+		Expect(collector.GetClient().DumpObject(storage, configMap.DeepCopy(), folder, logger)).To(Succeed())
+
+		secretFile := path.Join(folder, secret.Namespace, "Secret", secret.Name+".yaml")
+		raw, err := plainStorage.ReadFile(secretFile)
+		Expect(err).To(BeNil())
+		Expect(string(raw)).To(ContainSubstring("AES-256-GCM"))
+		Expect(string(raw)).ToNot(ContainSubstring(secret.Name))
+
+		// Other resources are stored as they are
+		raw, err = plainStorage.ReadFile(path.Join(folder, secret.Namespace, "ConfigMap", configMap.Name+".yaml"))
+		Expect(err).To(BeNil())
+		Expect(string(raw)).To(ContainSubstring(configMap.Data["key"]))
+
+		secrets, err := collector.GetClient().GetNamespacedResources(storage, folder, "Secret", logger)
+		Expect(err).To(BeNil())
+		Expect(secrets).To(HaveKey(secret.Namespace))
+		Expect(secrets[secret.Namespace]).To(HaveLen(1))
+		Expect(secrets[secret.Namespace][0].GetName()).To(Equal(secret.Name))
+
+		// An encrypted Secret cannot be passed off as another one
+		otherFile := path.Join(folder, secret.Namespace, "Secret", randomString()+".yaml")
+		encrypted, err := plainStorage.ReadFile(secretFile)
+		Expect(err).To(BeNil())
+		Expect(plainStorage.WriteFile(otherFile, encrypted)).To(Succeed())
+		_, err = storage.ReadFile(otherFile)
+		Expect(err).ToNot(BeNil())
+
+		// Secrets stored before encryption was enabled are still readable
+		plainSecret := secret.DeepCopy()
+		plainSecret.Name = randomString()
+		Expect(collector.GetClient().DumpObject(plainStorage, plainSecret, folder, logger)).To(Succeed())
+		_, err = storage.ReadFile(path.Join(folder, plainSecret.Namespace, "Secret", plainSecret.Name+".yaml"))
+		Expect(err).To(BeNil())
+	})
+
+	It("NewEncryptionKeys validates keys", func() {
+		_, err := collector.NewEncryptionKeys(map[string][]byte{randomString(): generateKey()})
+		Expect(err).ToNot(BeNil())
+		Expect(err.Error()).To(ContainSubstring(utilsv1beta1.EncryptionKeyKey))
+
+		_, err = collector.NewEncryptionKeys(map[string][]byte{utilsv1beta1.EncryptionKeyKey: []byte(randomString())})
+		Expect(err).ToNot(BeNil())
+	})
+
+	It("ReencryptCollections re-encrypts Secrets with the active key", func() {
+		oldKey := generateKey()
+		oldKeys, err := collector.NewEncryptionKeys(map[string][]byte{utilsv1beta1.EncryptionKeyKey: oldKey})
+		Expect(err).To(BeNil())
+
+		plainStorage := collector.NewFilesystemStorage(root)
+		snapshotName := randomString()
+		logger := textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1)))
+
+		// One sample encrypted with the old key, one stored before encryption was enabled
+		encryptedFolder := path.Join("snapshot", snapshotName, time.Now().Add(-time.Hour).Format(collector.TimeFormat))
+		Expect(collector.GetClient().DumpObject(collector.NewEncryptedStorage(plainStorage, oldKeys),
+			secret.DeepCopy(), encryptedFolder, logger)).To(Succeed())
+		plainFolder := path.Join("snapshot", snapshotName, time.Now().Format(collector.TimeFormat))
+		Expect(collector.GetClient().DumpObject(plainStorage, secret.DeepCopy(), plainFolder, logger)).To(Succeed())
+
+		_, err = collector.GetClient().ReencryptCollections(plainStorage, snapshotName, collector.Snapshot, logger)
+		Expect(err).ToNot(BeNil())
+
+		newKeys, err := collector.NewEncryptionKeys(map[string][]byte{
+			utilsv1beta1.EncryptionKeyKey: generateKey(),
+			"previous":                    oldKey,
+		})
+		Expect(err).To(BeNil())
+		storage := collector.NewEncryptedStorage(plainStorage, newKeys)
+
+		count, err := collector.GetClient().ReencryptCollections(storage, snapshotName, collector.Snapshot, logger)
+		Expect(err).To(BeNil())
+		Expect(count).To(Equal(2))
+
+		// Nothing left to re-encrypt
+		count, err = collector.GetClient().ReencryptCollections(storage, snapshotName, collector.Snapshot, logger)
+		Expect(err).To(BeNil())
+		Expect(count).To(BeZero())
+
+		// Old key alone cannot decrypt anymore
+		oldOnlyKeys, err := collector.NewEncryptionKeys(map[string][]byte{utilsv1beta1.EncryptionKeyKey: oldKey})
+		Expect(err).To(BeNil())
+		for _, folder := range []string{encryptedFolder, plainFolder} {
+			secretFile := path.Join(folder, secret.Namespace, "Secret", secret.Name+".yaml")
+			_, err = storage.ReadFile(secretFile)
+			Expect(err).To(BeNil())
+			_, err = collector.NewEncryptedStorage(plainStorage, oldOnlyKeys).ReadFile(secretFile)
+			Expect(err).ToNot(BeNil())
+		}
+	})
+
+	It("Secret digests do not reveal Secret content", func() {
+		oldKey := generateKey()
+		oldKeys, err := collector.NewEncryptionKeys(map[string][]byte{utilsv1beta1.EncryptionKeyKey: oldKey})
+		Expect(err).To(BeNil())
+
+		plainStorage := collector.NewFilesystemStorage(root)
+		storage := collector.NewContentAddressedStorage(collector.NewEncryptedStorage(plainStorage, oldKeys))
+
+		snapshotName := randomString()
+		folder := path.Join("snapshot", snapshotName, time.Now().Format(collector.TimeFormat))
+		logger := textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1)))
+		d := collector.GetClient()
+		Expect(d.DumpObject(storage, secret.DeepCopy(), folder, logger)).To(Succeed())
+		Expect(d.WriteSampleManifest(storage, folder, nil)).To(Succeed())
+
+		secretPath := path.Join(secret.Namespace, "Secret", secret.Name+".yaml")
+		content, err := storage.ReadFile(path.Join(folder, secretPath))
+		Expect(err).To(BeNil())
+		plainDigest := sha256.Sum256(content)
+
+		raw, err := plainStorage.ReadFile(path.Join(folder, "sample-manifest.yaml"))
+		Expect(err).To(BeNil())
+		Expect(string(raw)).To(ContainSubstring("hmac-sha256:"))
+		Expect(string(raw)).ToNot(ContainSubstring(hex.EncodeToString(plainDigest[:])))
+
+		Expect(d.PackCollection(storage, folder)).To(Succeed())
+		objects, err := plainStorage.ReadDir(path.Join("snapshot", snapshotName, ".objects", "Secret"))
+		Expect(err).To(BeNil())
+		Expect(objects).To(HaveLen(1))
+		Expect(objects[0].Name).ToNot(ContainSubstring(hex.EncodeToString(plainDigest[:])))
+
+		_, err = d.VerifySample(storage, folder, nil)
+		Expect(err).To(BeNil())
+
+		// Digests still match once Secrets are re-encrypted and the old key is removed
+		newKey := generateKey()
+		newKeys, err := collector.NewEncryptionKeys(map[string][]byte{
+			utilsv1beta1.EncryptionKeyKey: newKey,
+			"previous":                    oldKey,
+		})
+		Expect(err).To(BeNil())
+		_, err = d.ReencryptCollections(collector.NewContentAddressedStorage(
+			collector.NewEncryptedStorage(plainStorage, newKeys)), snapshotName, collector.Snapshot, logger)
+		Expect(err).To(BeNil())
+
+		newOnlyKeys, err := collector.NewEncryptionKeys(map[string][]byte{utilsv1beta1.EncryptionKeyKey: newKey})
+		Expect(err).To(BeNil())
+		_, err = d.VerifySample(collector.NewContentAddressedStorage(
+			collector.NewEncryptedStorage(plainStorage, newOnlyKeys)), folder, nil)
+		Expect(err).To(BeNil())
+	})
+
+	It("NewStorage fails when encryption Secret is missing", func() {
+		scheme := runtime.NewScheme()
+		Expect(corev1.AddToScheme(scheme)).To(Succeed())
+
+		encryption := &utilsv1beta1.SnapshotEncryption{
+			KeySecretRef: corev1.SecretReference{Namespace: randomString(), Name: randomString()},
+		}
+
+		c := fake.NewClientBuilder().WithScheme(scheme).Build()
+		_, err := collector.NewStorage(context.TODO(), c, root, nil, encryption)
+		Expect(err).ToNot(BeNil())
+
+		keySecret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: encryption.KeySecretRef.Namespace,
+				Name:      encryption.KeySecretRef.Name,
+			},
+			Data: map[string][]byte{utilsv1beta1.EncryptionKeyKey: generateKey()},
+		}
+		c = fake.NewClientBuilder().WithScheme(scheme).WithObjects(keySecret).Build()
+		storage, err := collector.NewStorage(context.TODO(), c, root, nil, encryption)
+		Expect(err).To(BeNil())
+		Expect(storage).ToNot(BeNil())
+	})
+})
+
+func generateKey() []byte {
+	const keySize = 32
+	key := make([]byte, keySize)
+	_, err := rand.Read(key)
+	Expect(err).To(BeNil())
+	return key
+}
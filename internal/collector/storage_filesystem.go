@@ -0,0 +1,99 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// filesystemStorage stores collections in a local directory
+type filesystemStorage struct {
+	root string
+}
+
+// NewFilesystemStorage returns a Storage storing collections in the root directory
+func NewFilesystemStorage(root string) Storage {
+	return &filesystemStorage{root: root}
+}
+
+func (s *filesystemStorage) path(key string) string {
+	return filepath.Join(s.root, filepath.FromSlash(key))
+}
+
+func (s *filesystemStorage) WriteFile(key string, data []byte) error {
+	p := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(p), permission0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(p, data, permission0644)
+}
+
+func (s *filesystemStorage) ReadFile(key string) ([]byte, error) {
+	return os.ReadFile(s.path(key))
+}
+
+func (s *filesystemStorage) ReadDir(key string) ([]StorageEntry, error) {
+	files, err := os.ReadDir(s.path(key))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]StorageEntry, len(files))
+	for i := range files {
+		entries[i] = StorageEntry{Name: files[i].Name(), IsDir: files[i].IsDir()}
+	}
+
+	return entries, nil
+}
+
+func (s *filesystemStorage) Exists(key string) (bool, error) {
+	_, err := os.Stat(s.path(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s *filesystemStorage) RemoveAll(key string) error {
+	return os.RemoveAll(s.path(key))
+}
+
+// Rename relies on os.Rename, so within the same filesystem a directory is
+// moved atomically
+func (s *filesystemStorage) Rename(key, newKey string) error {
+	exist, err := s.Exists(newKey)
+	if err != nil {
+		return err
+	}
+	if exist {
+		return &fs.PathError{Op: "rename", Path: newKey, Err: fs.ErrExist}
+	}
+
+	p := s.path(newKey)
+	if err := os.MkdirAll(filepath.Dir(p), permission0755); err != nil {
+		return err
+	}
+
+	return os.Rename(s.path(key), p)
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// memoryStorage keeps collections in memory. It is used for collections which
+// are never persisted, like the live configuration compared against a sample.
+type memoryStorage struct {
+	mu    sync.RWMutex
+	files map[string][]byte
+}
+
+// NewMemoryStorage returns an empty Storage keeping everything in memory
+func NewMemoryStorage() Storage {
+	return &memoryStorage{files: make(map[string][]byte)}
+}
+
+func cleanKey(key string) string {
+	return strings.Trim(path.Clean("/"+key), "/")
+}
+
+// isInDir returns true if key is contained, at any depth, in directory dir
+func isInDir(key, dir string) bool {
+	return dir == "" || strings.HasPrefix(key, dir+"/")
+}
+
+func (s *memoryStorage) WriteFile(key string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.files[cleanKey(key)] = append([]byte(nil), data...)
+	return nil
+}
+
+func (s *memoryStorage) ReadFile(key string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, ok := s.files[cleanKey(key)]
+	if !ok {
+		return nil, &fs.PathError{Op: "read", Path: key, Err: fs.ErrNotExist}
+	}
+
+	return append([]byte(nil), data...), nil
+}
+
+func (s *memoryStorage) ReadDir(key string) ([]StorageEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	dir := cleanKey(key)
+	entries := make(map[string]bool)
+	for k := range s.files {
+		if !isInDir(k, dir) {
+			continue
+		}
+		relative := strings.TrimPrefix(strings.TrimPrefix(k, dir), "/")
+		name, _, isDir := strings.Cut(relative, "/")
+		entries[name] = entries[name] || isDir
+	}
+
+	if len(entries) == 0 {
+		return nil, &fs.PathError{Op: "readdir", Path: key, Err: fs.ErrNotExist}
+	}
+
+	result := make([]StorageEntry, 0, len(entries))
+	for name := range entries {
+		result = append(result, StorageEntry{Name: name, IsDir: entries[name]})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+
+	return result, nil
+}
+
+func (s *memoryStorage) Exists(key string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	k := cleanKey(key)
+	if _, ok := s.files[k]; ok {
+		return true, nil
+	}
+	for file := range s.files {
+		if isInDir(file, k) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (s *memoryStorage) RemoveAll(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := cleanKey(key)
+	for file := range s.files {
+		if file == k || isInDir(file, k) {
+			delete(s.files, file)
+		}
+	}
+
+	return nil
+}
+
+func (s *memoryStorage) Rename(key, newKey string) error {
+	exist, err := s.Exists(newKey)
+	if err != nil {
+		return err
+	}
+	if exist {
+		return &fs.PathError{Op: "rename", Path: newKey, Err: fs.ErrExist}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	from := cleanKey(key)
+	to := cleanKey(newKey)
+	moved := make(map[string][]byte)
+	for file := range s.files {
+		if file == from {
+			moved[to] = s.files[file]
+		} else if isInDir(file, from) {
+			moved[path.Join(to, strings.TrimPrefix(file, from+"/"))] = s.files[file]
+		}
+	}
+	if len(moved) == 0 {
+		return &fs.PathError{Op: "rename", Path: key, Err: fs.ErrNotExist}
+	}
+
+	for file := range s.files {
+		if file == from || isInDir(file, from) {
+			delete(s.files, file)
+		}
+	}
+	for file := range moved {
+		s.files[file] = moved[file]
+	}
+
+	return nil
+}
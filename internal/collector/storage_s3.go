@@ -0,0 +1,271 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+const (
+	// s3OperationTimeout is the maximum time a single operation against the object store can take
+	s3OperationTimeout = 2 * time.Minute
+
+	noSuchKey = "NoSuchKey"
+)
+
+// S3Options contains the configuration to access an S3-compatible object store
+type S3Options struct {
+	// Endpoint in the form host[:port]
+	Endpoint string
+	// Bucket where collections are stored
+	Bucket string
+	// Region of the bucket. Optional.
+	Region string
+	// Insecure, when set, uses plain HTTP
+	Insecure bool
+
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+
+	// Prefix is prepended to every key
+	Prefix string
+}
+
+// s3Storage stores collections in an S3-compatible object store.
+// Directories are emulated using "/" as delimiter in object keys.
+type s3Storage struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+// NewS3Storage returns a Storage storing collections in an S3-compatible object store
+func NewS3Storage(options *S3Options) (Storage, error) {
+	if options.Endpoint == "" {
+		return nil, fmt.Errorf("s3 endpoint must be set")
+	}
+	if options.Bucket == "" {
+		return nil, fmt.Errorf("s3 bucket must be set")
+	}
+
+	c, err := minio.New(options.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(options.AccessKeyID, options.SecretAccessKey, options.SessionToken),
+		Secure: !options.Insecure,
+		Region: options.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3Storage{
+		client: c,
+		bucket: options.Bucket,
+		prefix: strings.Trim(options.Prefix, "/"),
+	}, nil
+}
+
+// objectName returns the name of the object corresponding to key
+func (s *s3Storage) objectName(key string) string {
+	return strings.Trim(path.Join(s.prefix, key), "/")
+}
+
+// dirPrefix returns the prefix shared by all objects contained in directory key
+func (s *s3Storage) dirPrefix(key string) string {
+	name := s.objectName(key)
+	if name == "" {
+		return ""
+	}
+	return name + "/"
+}
+
+func (s *s3Storage) WriteFile(key string, data []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s3OperationTimeout)
+	defer cancel()
+
+	_, err := s.client.PutObject(ctx, s.bucket, s.objectName(key), bytes.NewReader(data), int64(len(data)),
+		minio.PutObjectOptions{ContentType: "application/octet-stream"})
+	return err
+}
+
+func (s *s3Storage) ReadFile(key string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s3OperationTimeout)
+	defer cancel()
+
+	object, err := s.client.GetObject(ctx, s.bucket, s.objectName(key), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, s.toPathError("open", key, err)
+	}
+	defer object.Close()
+
+	data, err := io.ReadAll(object)
+	if err != nil {
+		return nil, s.toPathError("open", key, err)
+	}
+
+	return data, nil
+}
+
+func (s *s3Storage) ReadDir(key string) ([]StorageEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s3OperationTimeout)
+	defer cancel()
+
+	prefix := s.dirPrefix(key)
+	entries := make([]StorageEntry, 0)
+	for object := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: prefix}) {
+		if object.Err != nil {
+			return nil, object.Err
+		}
+
+		name := strings.TrimPrefix(object.Key, prefix)
+		isDir := strings.HasSuffix(name, "/")
+		name = strings.TrimSuffix(name, "/")
+		if name == "" {
+			continue
+		}
+		entries = append(entries, StorageEntry{Name: name, IsDir: isDir})
+	}
+
+	if len(entries) == 0 {
+		return nil, &fs.PathError{Op: "readdir", Path: key, Err: fs.ErrNotExist}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+
+	return entries, nil
+}
+
+func (s *s3Storage) Exists(key string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s3OperationTimeout)
+	defer cancel()
+
+	_, err := s.client.StatObject(ctx, s.bucket, s.objectName(key), minio.StatObjectOptions{})
+	if err == nil {
+		return true, nil
+	}
+	if minio.ToErrorResponse(err).Code != noSuchKey {
+		return false, err
+	}
+
+	// key is not an object. Verify whether it is a directory
+	for object := range s.client.ListObjects(ctx, s.bucket,
+		minio.ListObjectsOptions{Prefix: s.dirPrefix(key), MaxKeys: 1}) {
+
+		if object.Err != nil {
+			return false, object.Err
+		}
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// RemoveAll removes key and every object below it. An empty key is rejected,
+// as its prefix would match every object in the bucket.
+func (s *s3Storage) RemoveAll(key string) error {
+	if strings.Trim(path.Clean(key), "/.") == "" {
+		return &fs.PathError{Op: "removeall", Path: key, Err: fs.ErrInvalid}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s3OperationTimeout)
+	defer cancel()
+
+	err := s.client.RemoveObject(ctx, s.bucket, s.objectName(key), minio.RemoveObjectOptions{})
+	if err != nil && minio.ToErrorResponse(err).Code != noSuchKey {
+		return err
+	}
+
+	objects := s.client.ListObjects(ctx, s.bucket,
+		minio.ListObjectsOptions{Prefix: s.dirPrefix(key), Recursive: true})
+	for removeErr := range s.client.RemoveObjects(ctx, s.bucket, objects, minio.RemoveObjectsOptions{}) {
+		if removeErr.Err != nil {
+			return removeErr.Err
+		}
+	}
+
+	return nil
+}
+
+// Rename copies every object to its new key, then removes the original objects.
+// Object stores have no rename, so this is not atomic: until original objects are
+// removed, content is available under both key and newKey.
+func (s *s3Storage) Rename(key, newKey string) error {
+	exist, err := s.Exists(newKey)
+	if err != nil {
+		return err
+	}
+	if exist {
+		return &fs.PathError{Op: "rename", Path: newKey, Err: fs.ErrExist}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s3OperationTimeout)
+	defer cancel()
+
+	objects := make(map[string]string)
+	_, err = s.client.StatObject(ctx, s.bucket, s.objectName(key), minio.StatObjectOptions{})
+	switch {
+	case err == nil:
+		objects[s.objectName(key)] = s.objectName(newKey)
+	case minio.ToErrorResponse(err).Code != noSuchKey:
+		return err
+	}
+
+	prefix := s.dirPrefix(key)
+	for object := range s.client.ListObjects(ctx, s.bucket,
+		minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+
+		if object.Err != nil {
+			return object.Err
+		}
+		objects[object.Key] = s.dirPrefix(newKey) + strings.TrimPrefix(object.Key, prefix)
+	}
+
+	if len(objects) == 0 {
+		return &fs.PathError{Op: "rename", Path: key, Err: fs.ErrNotExist}
+	}
+
+	for src, dst := range objects {
+		_, err = s.client.CopyObject(ctx, minio.CopyDestOptions{Bucket: s.bucket, Object: dst},
+			minio.CopySrcOptions{Bucket: s.bucket, Object: src})
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.RemoveAll(key)
+}
+
+// toPathError converts a missing object error to an error wrapping fs.ErrNotExist
+func (s *s3Storage) toPathError(op, key string, err error) error {
+	if minio.ToErrorResponse(err).Code == noSuchKey {
+		return &fs.PathError{Op: op, Path: key, Err: fs.ErrNotExist}
+	}
+	return err
+}
@@ -0,0 +1,243 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector_test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	utilsv1beta1 "github.com/projectsveltos/sveltosctl/api/v1beta1"
+	"github.com/projectsveltos/sveltosctl/internal/collector"
+)
+
+// S3 tests run only when an S3-compatible object store (for instance MinIO) is available.
+// Following environment variables must be set:
+// - S3_TEST_ENDPOINT (for instance localhost:9000)
+// - S3_TEST_BUCKET (bucket must exist)
+// - S3_TEST_ACCESS_KEY
+// - S3_TEST_SECRET_KEY
+const (
+	s3EndpointEnv  = "S3_TEST_ENDPOINT"
+	s3BucketEnv    = "S3_TEST_BUCKET"
+	s3AccessKeyEnv = "S3_TEST_ACCESS_KEY"
+	s3SecretKeyEnv = "S3_TEST_SECRET_KEY"
+)
+
+var _ = Describe("Storage", func() {
+	It("filesystem storage stores, lists and removes entries", func() {
+		root, err := os.MkdirTemp("", randomString())
+		Expect(err).To(BeNil())
+		defer os.RemoveAll(root)
+
+		verifyStorage(collector.NewFilesystemStorage(root))
+	})
+
+	It("memory storage stores, lists and removes entries", func() {
+		verifyStorage(collector.NewMemoryStorage())
+	})
+
+	It("s3 storage stores, lists and removes entries", func() {
+		options := getS3TestOptions()
+		options.Prefix = randomString()
+
+		storage, err := collector.NewS3Storage(options)
+		Expect(err).To(BeNil())
+		defer func() {
+			rootOptions := *options
+			rootOptions.Prefix = ""
+			root, err := collector.NewS3Storage(&rootOptions)
+			Expect(err).To(BeNil())
+			Expect(root.RemoveAll(options.Prefix)).To(Succeed())
+		}()
+
+		verifyStorage(storage)
+	})
+
+	It("s3 storage refuses to remove the whole bucket", func() {
+		// No request is sent: endpoint does not need to exist
+		storage, err := collector.NewS3Storage(&collector.S3Options{
+			Endpoint: "localhost:9000",
+			Bucket:   randomString(),
+		})
+		Expect(err).To(BeNil())
+
+		for _, key := range []string{"", "/", ".", "./"} {
+			err = storage.RemoveAll(key)
+			Expect(err).ToNot(BeNil())
+			Expect(errors.Is(err, fs.ErrInvalid)).To(BeTrue())
+		}
+	})
+
+	It("NewStorage returns filesystem storage when backend is not set", func() {
+		c := fake.NewClientBuilder().WithScheme(runtime.NewScheme()).Build()
+
+		storage, err := collector.NewStorage(context.TODO(), c, randomString(), nil, nil)
+		Expect(err).To(BeNil())
+		Expect(storage).ToNot(BeNil())
+
+		storage, err = collector.NewStorage(context.TODO(), c, randomString(),
+			&utilsv1beta1.StorageBackend{Type: utilsv1beta1.StorageBackendTypeFilesystem}, nil)
+		Expect(err).To(BeNil())
+		Expect(storage).ToNot(BeNil())
+	})
+
+	It("NewStorage fails when S3 credentials Secret is missing or incomplete", func() {
+		scheme := runtime.NewScheme()
+		Expect(corev1.AddToScheme(scheme)).To(Succeed())
+
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: randomString(),
+				Name:      randomString(),
+			},
+			Data: map[string][]byte{
+				utilsv1beta1.S3AccessKeyIDKey: []byte(randomString()),
+			},
+		}
+
+		backend := &utilsv1beta1.StorageBackend{
+			Type: utilsv1beta1.StorageBackendTypeS3,
+			S3: &utilsv1beta1.S3Storage{
+				Endpoint: "localhost:9000",
+				Bucket:   randomString(),
+				CredentialsSecretRef: corev1.SecretReference{
+					Namespace: secret.Namespace,
+					Name:      secret.Name,
+				},
+			},
+		}
+
+		c := fake.NewClientBuilder().WithScheme(scheme).Build()
+		_, err := collector.NewStorage(context.TODO(), c, randomString(), backend, nil)
+		Expect(err).ToNot(BeNil())
+
+		c = fake.NewClientBuilder().WithScheme(scheme).WithObjects(secret).Build()
+		_, err = collector.NewStorage(context.TODO(), c, randomString(), backend, nil)
+		Expect(err).ToNot(BeNil())
+		Expect(err.Error()).To(ContainSubstring(utilsv1beta1.S3SecretAccessKeyKey))
+
+		secret.Data[utilsv1beta1.S3SecretAccessKeyKey] = []byte(randomString())
+		c = fake.NewClientBuilder().WithScheme(scheme).WithObjects(secret).Build()
+		storage, err := collector.NewStorage(context.TODO(), c, randomString(), backend, nil)
+		Expect(err).To(BeNil())
+		Expect(storage).ToNot(BeNil())
+	})
+})
+
+func getS3TestOptions() *collector.S3Options {
+	options := &collector.S3Options{
+		Endpoint:        os.Getenv(s3EndpointEnv),
+		Bucket:          os.Getenv(s3BucketEnv),
+		AccessKeyID:     os.Getenv(s3AccessKeyEnv),
+		SecretAccessKey: os.Getenv(s3SecretKeyEnv),
+		Insecure:        true,
+	}
+
+	if options.Endpoint == "" || options.Bucket == "" {
+		Skip("S3 endpoint and bucket are not set")
+	}
+
+	return options
+}
+
+func verifyStorage(storage collector.Storage) {
+	const (
+		first  = "snapshot/instance/2024-01-01:10:00:00/default/ConfigMap/first.yaml"
+		second = "snapshot/instance/2024-01-01:10:00:00/default/ConfigMap/second.yaml"
+		third  = "snapshot/instance/2024-01-02:10:00:00/ClusterProfile/third.yaml"
+	)
+
+	By("writing files")
+	for _, key := range []string{first, second, third} {
+		Expect(storage.WriteFile(key, []byte(key))).To(Succeed())
+	}
+
+	By("reading files")
+	content, err := storage.ReadFile(second)
+	Expect(err).To(BeNil())
+	Expect(string(content)).To(Equal(second))
+
+	_, err = storage.ReadFile("snapshot/instance/missing.yaml")
+	Expect(errors.Is(err, fs.ErrNotExist)).To(BeTrue())
+
+	By("listing directories")
+	entries, err := storage.ReadDir("snapshot/instance")
+	Expect(err).To(BeNil())
+	Expect(entries).To(Equal([]collector.StorageEntry{
+		{Name: "2024-01-01:10:00:00", IsDir: true},
+		{Name: "2024-01-02:10:00:00", IsDir: true},
+	}))
+
+	entries, err = storage.ReadDir("snapshot/instance/2024-01-01:10:00:00/default/ConfigMap")
+	Expect(err).To(BeNil())
+	Expect(entries).To(Equal([]collector.StorageEntry{
+		{Name: "first.yaml", IsDir: false},
+		{Name: "second.yaml", IsDir: false},
+	}))
+
+	_, err = storage.ReadDir("snapshot/missing")
+	Expect(errors.Is(err, fs.ErrNotExist)).To(BeTrue())
+
+	By("verifying existence")
+	for _, key := range []string{"snapshot/instance", first} {
+		exist, err := storage.Exists(key)
+		Expect(err).To(BeNil())
+		Expect(exist).To(BeTrue())
+	}
+
+	exist, err := storage.Exists("snapshot/missing")
+	Expect(err).To(BeNil())
+	Expect(exist).To(BeFalse())
+
+	By("removing a directory")
+	Expect(storage.RemoveAll("snapshot/instance/2024-01-01:10:00:00")).To(Succeed())
+	exist, err = storage.Exists(first)
+	Expect(err).To(BeNil())
+	Expect(exist).To(BeFalse())
+
+	entries, err = storage.ReadDir("snapshot/instance")
+	Expect(err).To(BeNil())
+	Expect(entries).To(Equal([]collector.StorageEntry{
+		{Name: "2024-01-02:10:00:00", IsDir: true},
+	}))
+
+	Expect(storage.RemoveAll("snapshot/missing")).To(Succeed())
+
+	By("renaming a directory")
+	Expect(storage.Rename("snapshot/instance/2024-01-02:10:00:00", "snapshot/instance/2024-01-03:10:00:00")).
+		To(Succeed())
+	content, err = storage.ReadFile("snapshot/instance/2024-01-03:10:00:00/ClusterProfile/third.yaml")
+	Expect(err).To(BeNil())
+	Expect(string(content)).To(Equal(third))
+	exist, err = storage.Exists(third)
+	Expect(err).To(BeNil())
+	Expect(exist).To(BeFalse())
+
+	Expect(storage.WriteFile(third, []byte(third))).To(Succeed())
+	err = storage.Rename("snapshot/instance/2024-01-02:10:00:00", "snapshot/instance/2024-01-03:10:00:00")
+	Expect(errors.Is(err, fs.ErrExist)).To(BeTrue())
+}
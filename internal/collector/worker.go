@@ -19,9 +19,9 @@ package collector
 import (
 	"context"
 	"fmt"
-	"os"
-	"path/filepath"
+	"path"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
@@ -104,8 +104,8 @@ func processRequests(ctx context.Context, collector *Collector, i int, logger lo
 				l.V(logs.LogDebug).Info("take from jobQueue")
 				// Add to inProgress
 				l.V(logs.LogDebug).Info("add to inProgress")
-				collector.inProgress = append(collector.inProgress, params.requestorName)
 				key := getKey(params.requestorName, params.collectionType)
+				collector.inProgress = append(collector.inProgress, key)
 				// If present remove from dirty
 				for i := range collector.dirty {
 					if collector.dirty[i] == key {
@@ -132,9 +132,11 @@ func storeResult(collector *Collector, requestorName string, collectionType Coll
 
 	collector.mu.Lock()
 
+	key := getKey(requestorName, collectionType)
+
 	// Remove from inProgress
 	for i := range collector.inProgress {
-		if collector.inProgress[i] != requestorName {
+		if collector.inProgress[i] != key {
 			continue
 		}
 		logger.V(logs.LogDebug).Info("remove from inProgress")
@@ -149,9 +151,8 @@ func storeResult(collector *Collector, requestorName string, collectionType Coll
 	} else {
 		l.V(logs.LogInfo).Info("added to result")
 	}
-	collector.results[requestorName] = err
+	collector.results[key] = err
 
-	key := getKey(requestorName, collectionType)
 	// if key is in dirty, remove from there and push to jobQueue
 	for i := range collector.dirty {
 		if collector.dirty[i] != key {
@@ -166,7 +167,7 @@ func storeResult(collector *Collector, requestorName string, collectionType Coll
 		l.V(logs.LogDebug).Info("remove from dirty")
 		collector.dirty = removeFromSlice(collector.dirty, i)
 		l.V(logs.LogDebug).Info("remove result")
-		delete(collector.results, requestorName)
+		delete(collector.results, key)
 		break
 	}
 
@@ -256,11 +257,11 @@ func addTypeInformationToObject(obj client.Object) error {
 	return nil
 }
 
-func getArtifactFolderName(storage, requestorName string, collectionType CollectionType) string {
-	return filepath.Join(storage, collectionType.string(), requestorName)
+func getArtifactFolderName(requestorName string, collectionType CollectionType) string {
+	return path.Join(collectionType.string(), requestorName)
 }
 
-func cleanOldCollections(storage, requestorName string, collectionType CollectionType,
+func cleanOldCollections(storage Storage, requestorName string, collectionType CollectionType,
 	limit int32, logger logr.Logger) error {
 
 	results, err := listCollectionsForRequestor(storage, requestorName, collectionType, logger)
@@ -270,11 +271,20 @@ func cleanOldCollections(storage, requestorName string, collectionType Collectio
 
 	timeSlice := make([]time.Time, 0)
 
+	artifactFolder := getArtifactFolderName(requestorName, collectionType)
 	for i := range results {
 		t, err := time.Parse(timeFormat, results[i])
 		if err != nil {
 			continue
 		}
+		// Pinned collections are never removed and do not count against limit
+		pinned, err := isCollectionPinned(storage, path.Join(artifactFolder, results[i]))
+		if err != nil {
+			return err
+		}
+		if pinned {
+			continue
+		}
 		timeSlice = append(timeSlice, t)
 	}
 
@@ -283,10 +293,9 @@ func cleanOldCollections(storage, requestorName string, collectionType Collectio
 	})
 
 	// Remove oldest directories
-	artifactFolder := getArtifactFolderName(storage, requestorName, collectionType)
 	for i := 0; i < len(timeSlice)-int(limit); i++ {
 		dirName := timeSlice[i].Format(timeFormat)
-		err := os.RemoveAll(filepath.Join(artifactFolder, dirName))
+		err := storage.RemoveAll(path.Join(artifactFolder, dirName))
 		if err != nil {
 			return err
 		}
@@ -294,23 +303,36 @@ func cleanOldCollections(storage, requestorName string, collectionType Collectio
 	return nil
 }
 
-func listCollectionsForRequestor(storage, requestorName string, collectionType CollectionType,
+func listCollectionsForRequestor(storage Storage, requestorName string, collectionType CollectionType,
 	logger logr.Logger) ([]string, error) {
 
-	artifactFolder := getArtifactFolderName(storage, requestorName, collectionType)
+	artifactFolder := getArtifactFolderName(requestorName, collectionType)
 
 	logger.V(logs.LogDebug).Info(fmt.Sprintf("getting content for directory: %s", artifactFolder))
 
-	files, err := os.ReadDir(artifactFolder)
+	files, err := storage.ReadDir(artifactFolder)
 	if err != nil {
 		return nil, err
 	}
 
+	// A collection whose staging folder still exists is incomplete
+	staging := make(map[string]bool)
+	for i := range files {
+		if files[i].IsDir && isStagingFolder(files[i].Name) {
+			staging[strings.TrimPrefix(files[i].Name, stagingPrefix)] = true
+		}
+	}
+
 	results := make([]string, 0)
 	for i := range files {
-		if files[i].IsDir() {
-			results = append(results, files[i].Name())
+		if !files[i].IsDir || isStagingFolder(files[i].Name) || files[i].Name == objectsFolder {
+			continue
+		}
+		if staging[files[i].Name] {
+			logger.V(logs.LogDebug).Info(fmt.Sprintf("ignoring incomplete collection %s", files[i].Name))
+			continue
 		}
+		results = append(results, files[i].Name)
 	}
 
 	return results, nil
@@ -0,0 +1,62 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands
+
+import (
+	"time"
+
+	"github.com/projectsveltos/sveltosctl/internal/collector"
+)
+
+var (
+	ProcessSnapshotTrigger  = processSnapshotTrigger
+	CollectSnapshotOnDemand = collectSnapshotOnDemand
+	CollectSnapshot         = collectSnapshot
+	CleanupStagingSamples   = cleanupStagingSamples
+	CollectLiveSample       = collectLiveSample
+
+	UpdateSnapshotRollbackStatus = updateSnapshotRollbackStatus
+
+	ParseSince = parseSince
+)
+
+var (
+	ProcessSnapshotChanges     = processSnapshotChanges
+	GetSnapshotsForChange      = getSnapshotsForChange
+	GetCollectSnapshotOnChange = getCollectSnapshotOnChange
+	IsResourceVersionChanged   = isResourceVersionChanged
+
+	IsReferencedByProfile         = isReferencedByProfile
+	IndexClusterProfileReferences = indexClusterProfileReferences
+	IndexProfileReferences        = indexProfileReferences
+)
+
+const (
+	ProfileReferencesIndex = profileReferencesIndex
+)
+
+func RecordSnapshotChange(snapshotName string, change collector.SampleChange, now time.Time) {
+	snapshotChanges.recordChange(snapshotName, change, now)
+}
+
+func ForgetSnapshotChanges(snapshotName string) {
+	snapshotChanges.forget(snapshotName)
+}
+
+func GetSnapshotLastChange(snapshotName string) (time.Time, bool) {
+	return snapshotChanges.getLastChange(snapshotName)
+}
@@ -88,6 +88,11 @@ func watchResources(ctx context.Context, logger logr.Logger) error {
 		logger.Error(err, "failed to start snapshot reconciler")
 	}
 
+	err = startSnapshotRollbackReconciler(ctx, mgr, logger)
+	if err != nil {
+		logger.Error(err, "failed to start snapshotrollback reconciler")
+	}
+
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
 		logger.Error(err, "unable to continue running manager")
 		return err
@@ -97,6 +102,9 @@ func watchResources(ctx context.Context, logger logr.Logger) error {
 }
 
 func startSnapshotReconciler(ctx context.Context, mgr manager.Manager, logger logr.Logger) error {
+	// No collection is in progress yet. So any staging folder is left over from a previous run.
+	cleanupStagingSamples(ctx, logger)
+
 	// Create an un-managed controller
 	c, err := controller.NewUnmanaged("snapshot-watcher", controller.Options{
 		Reconciler:              reconcile.Func(SnapshotReconciler),
@@ -119,6 +127,10 @@ func startSnapshotReconciler(ctx context.Context, mgr manager.Manager, logger lo
 		return err
 	}
 
+	if err := watchSnapshotChanges(ctx, mgr, c, logger); err != nil {
+		return err
+	}
+
 	// Start controller in a goroutine so not to block.
 	go func() {
 		// Start controller. This will block until the context is
@@ -259,7 +271,7 @@ func isCollectionInProgress(lastRunStatus *utilsv1beta1.CollectionStatus) bool {
 }
 
 func removeQueuedJobsAndFinalizer(c *collector.Collector, instance client.Object, collectionType collector.CollectionType,
-	storage, finalizer string, logger logr.Logger) error {
+	storage collector.Storage, finalizer string, logger logr.Logger) error {
 
 	err := c.CleanupEntries(storage, instance.GetName(), collectionType)
 	if err != nil {
@@ -275,7 +287,7 @@ func removeQueuedJobsAndFinalizer(c *collector.Collector, instance client.Object
 }
 
 func reconcileDelete(ctx context.Context, instance client.Object, collectionType collector.CollectionType,
-	storage, finalizer string, logger logr.Logger) (reconcile.Result, error) {
+	storage collector.Storage, finalizer string, logger logr.Logger) (reconcile.Result, error) {
 
 	logger.V(logs.LogInfo).Info("reconcileDelete")
 
@@ -41,6 +41,9 @@ func Show(ctx context.Context, args []string, logger logr.Logger) error {
     dryrun        Displays information on ClusterProfiles in DryRun mode. It displays what changes would
                   take effect if a ClusterProfile were to be moved out of DryRun mode.
     admin-rbac    Displays information about RBACs assigned to admins in each managed cluster.
+    deployment-status
+                  Displays, for each cluster and ClusterProfile/Profile, the status of each feature
+                  (Resources, Helm, Kustomize) along with failure messages.
 
 Options:
   -h --help       Show this screen.
@@ -82,6 +85,8 @@ See 'sveltosctl show <subcommand> --help' to read about a specific subcommand.
 			err = show.Usage(ctx, arguments, logger)
 		case "admin-rbac":
 			err = show.AdminPermissions(ctx, arguments, logger)
+		case "deployment-status":
+			err = show.DeploymentStatus(ctx, arguments, logger)
 		default:
 			//nolint: forbidigo // print doc
 			fmt.Println(doc)
@@ -21,6 +21,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/docopt/docopt-go"
@@ -29,6 +30,7 @@ import (
 
 	configv1beta1 "github.com/projectsveltos/addon-controller/api/v1beta1"
 	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+	"github.com/projectsveltos/sveltosctl/internal/output"
 	"github.com/projectsveltos/sveltosctl/internal/utils"
 )
 
@@ -54,24 +56,69 @@ var (
 	}
 )
 
-func displayAddOns(ctx context.Context, passedNamespace, passedCluster, passedProfile string,
-	logger logr.Logger) error {
+// AddOn represents a Kubernetes resource or helm release deployed in a cluster.
+// It is the item type of show addons structured output.
+type AddOn struct {
+	// Cluster is the cluster in the form namespace/name
+	Cluster string `json:"cluster"`
+	// Kind is either "helm chart" or the resource group:kind
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Version is the helm chart version. N/A for resources.
+	Version     string `json:"version"`
+	LastApplied string `json:"lastApplied"`
+	// Profiles lists all ClusterProfiles/Profiles causing this add-on to be deployed
+	Profiles []string `json:"profiles"`
+	// Source is the helm repository URL for helm charts and the referenced
+	// ConfigMap/Secret for resources. Displayed only in wide format.
+	Source string `json:"source,omitempty"`
+}
 
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"CLUSTER", "RESOURCE TYPE", "NAMESPACE", "NAME", "VERSION", "TIME", "PROFILES"})
+func displayAddOns(ctx context.Context, passedNamespace, passedCluster, passedProfile string,
+	format output.Format, logger logr.Logger) error {
 
+	addOns := make([]AddOn, 0)
 	if err := displayAddOnsInNamespaces(ctx, passedNamespace, passedCluster,
-		passedProfile, table, logger); err != nil {
+		passedProfile, &addOns, logger); err != nil {
 		return err
 	}
 
+	sort.SliceStable(addOns, func(i, j int) bool {
+		return addOnKey(&addOns[i]) < addOnKey(&addOns[j])
+	})
+
+	if format.IsStructured() {
+		return output.PrintList(os.Stdout, format, "AddOnList", addOns)
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	header := []string{"CLUSTER", "RESOURCE TYPE", "NAMESPACE", "NAME", "VERSION", "TIME", "PROFILES"}
+	if format.IsWide() {
+		header = append(header, "SOURCE")
+	}
+	table.SetHeader(header)
+
+	for i := range addOns {
+		a := &addOns[i]
+		row := genAddOnsRow(a.Cluster, a.Kind, a.Namespace, a.Name, a.Version, a.LastApplied, a.Profiles)
+		if format.IsWide() {
+			row = append(row, a.Source)
+		}
+		table.Append(row)
+	}
+
 	table.Render()
 
 	return nil
 }
 
+func addOnKey(a *AddOn) string {
+	return fmt.Sprintf("%s:%s:%s:%s", a.Cluster, a.Kind, a.Namespace, a.Name)
+}
+
 func displayAddOnsInNamespaces(ctx context.Context, passedNamespace, passedCluster, passedProfile string,
-	table *tablewriter.Table, logger logr.Logger) error {
+	addOns *[]AddOn, logger logr.Logger) error {
 
 	instance := utils.GetAccessInstance()
 
@@ -85,7 +132,7 @@ func displayAddOnsInNamespaces(ctx context.Context, passedNamespace, passedClust
 		if doConsiderNamespace(ns, passedNamespace) {
 			logger.V(logs.LogDebug).Info(fmt.Sprintf("Considering namespace: %s", ns.Name))
 			err = displayAddOnsInNamespace(ctx, ns.Name, passedCluster, passedProfile,
-				table, logger)
+				addOns, logger)
 			if err != nil {
 				return err
 			}
@@ -96,7 +143,7 @@ func displayAddOnsInNamespaces(ctx context.Context, passedNamespace, passedClust
 }
 
 func displayAddOnsInNamespace(ctx context.Context, namespace, passedCluster, passedProfile string,
-	table *tablewriter.Table, logger logr.Logger) error {
+	addOns *[]AddOn, logger logr.Logger) error {
 
 	instance := utils.GetAccessInstance()
 
@@ -111,7 +158,7 @@ func displayAddOnsInNamespace(ctx context.Context, namespace, passedCluster, pas
 		cc := &clusterConfigurations.Items[i]
 		if doConsiderClusterConfiguration(cc, passedCluster) {
 			logger.V(logs.LogDebug).Info(fmt.Sprintf("Considering ClusterConfiguration: %s", cc.Name))
-			displayAddOnsForCluster(cc, passedProfile, table, logger)
+			displayAddOnsForCluster(cc, passedProfile, addOns, logger)
 		}
 	}
 
@@ -119,7 +166,7 @@ func displayAddOnsInNamespace(ctx context.Context, namespace, passedCluster, pas
 }
 
 func displayAddOnsForCluster(clusterConfiguration *configv1beta1.ClusterConfiguration, passedProfile string,
-	table *tablewriter.Table, logger logr.Logger) {
+	addOns *[]AddOn, logger logr.Logger) {
 
 	instance := utils.GetAccessInstance()
 	helmCharts := instance.GetHelmReleases(clusterConfiguration, logger)
@@ -132,17 +179,33 @@ func displayAddOnsForCluster(clusterConfiguration *configv1beta1.ClusterConfigur
 	clusterInfo := fmt.Sprintf("%s/%s", clusterConfiguration.Namespace, clusterName)
 	for chart := range helmCharts {
 		if doConsiderProfile(helmCharts[chart], passedProfile) {
-			table.Append(genAddOnsRow(clusterInfo, "helm chart", chart.Namespace, chart.ReleaseName, chart.ChartVersion,
-				chart.LastAppliedTime.String(), helmCharts[chart]))
+			*addOns = append(*addOns, AddOn{
+				Cluster:     clusterInfo,
+				Kind:        "helm chart",
+				Namespace:   chart.Namespace,
+				Name:        chart.ReleaseName,
+				Version:     chart.ChartVersion,
+				LastApplied: chart.LastAppliedTime.String(),
+				Profiles:    helmCharts[chart],
+				Source:      chart.RepoURL,
+			})
 		}
 	}
 
 	resources := instance.GetResources(clusterConfiguration, logger)
 	for resource := range resources {
 		if doConsiderProfile(resources[resource], passedProfile) {
-			table.Append(genAddOnsRow(clusterInfo, fmt.Sprintf("%s:%s", resource.Group, resource.Kind),
-				resource.Namespace, resource.Name, "N/A",
-				resource.LastAppliedTime.String(), resources[resource]))
+			*addOns = append(*addOns, AddOn{
+				Cluster:     clusterInfo,
+				Kind:        fmt.Sprintf("%s:%s", resource.Group, resource.Kind),
+				Namespace:   resource.Namespace,
+				Name:        resource.Name,
+				Version:     "N/A",
+				LastApplied: resource.LastAppliedTime.String(),
+				Profiles:    resources[resource],
+				Source: fmt.Sprintf("%s:%s/%s", resource.Owner.Kind,
+					resource.Owner.Namespace, resource.Owner.Name),
+			})
 		}
 	}
 }
@@ -150,7 +213,7 @@ func displayAddOnsForCluster(clusterConfiguration *configv1beta1.ClusterConfigur
 // AddOns displays information about Kubernetes AddOns deployed in clusters
 func AddOns(ctx context.Context, args []string, logger logr.Logger) error {
 	doc := `Usage:
-  sveltosctl show addons [options] [--namespace=<name>] [--cluster=<name>] [--profile=<name>] [--verbose]
+  sveltosctl show addons [options] [--namespace=<name>] [--cluster=<name>] [--profile=<name>] [--output=<format>] [--verbose]
 
      --namespace=<name>      Show Kubernetes addons deployed in clusters in this namespace.
                              If not specified all namespaces are considered.
@@ -161,6 +224,7 @@ func AddOns(ctx context.Context, args []string, logger logr.Logger) error {
 
 Options:
   -h --help                  Show this screen.
+  -o --output=<format>       Output format: table, wide, json or yaml. Defaults to global --output, or table.
      --verbose               Verbose mode. Print each step.  
 
 Description:
@@ -203,5 +267,10 @@ Description:
 		profile = passedProfile.(string)
 	}
 
-	return displayAddOns(ctx, namespace, cluster, profile, logger)
+	format, err := parseOutputFormat(parsedArgs)
+	if err != nil {
+		return err
+	}
+
+	return displayAddOns(ctx, namespace, cluster, profile, format, logger)
 }
@@ -19,6 +19,7 @@ package show_test
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -35,6 +36,7 @@ import (
 
 	configv1beta1 "github.com/projectsveltos/addon-controller/api/v1beta1"
 	"github.com/projectsveltos/sveltosctl/internal/commands/show"
+	"github.com/projectsveltos/sveltosctl/internal/output"
 	"github.com/projectsveltos/sveltosctl/internal/utils"
 )
 
@@ -83,7 +85,7 @@ var _ = Describe("AddOnss", func() {
 		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initObjects...).Build()
 
 		utils.InitalizeManagementClusterAcces(scheme, nil, nil, c)
-		err = show.DisplayAddOns(context.TODO(), "", "", "",
+		err = show.DisplayAddOns(context.TODO(), "", "", "", output.Table,
 			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
 		Expect(err).To(BeNil())
 
@@ -134,7 +136,7 @@ var _ = Describe("AddOnss", func() {
 		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initObjects...).Build()
 
 		utils.InitalizeManagementClusterAcces(scheme, nil, nil, c)
-		err = show.DisplayAddOns(context.TODO(), "", "", "",
+		err = show.DisplayAddOns(context.TODO(), "", "", "", output.Table,
 			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
 		Expect(err).To(BeNil())
 
@@ -160,6 +162,61 @@ var _ = Describe("AddOnss", func() {
 
 		os.Stdout = old
 	})
+
+	It("show addons with json output displays a versioned list", func() {
+		clusterProfileName := randomString()
+		charts := []configv1beta1.Chart{
+			*generateChart(), *generateChart(),
+		}
+		clusterConfiguration = addDeployedHelmCharts(clusterConfiguration, clusterProfileName, charts)
+
+		old := os.Stdout // keep backup of the real stdout
+		r, w, _ := os.Pipe()
+		os.Stdout = w
+
+		initObjects := []client.Object{ns, clusterConfiguration}
+
+		scheme, err := utils.GetScheme()
+		Expect(err).To(BeNil())
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initObjects...).Build()
+
+		utils.InitalizeManagementClusterAcces(scheme, nil, nil, c)
+		err = show.DisplayAddOns(context.TODO(), "", "", "", output.JSON,
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
+		Expect(err).To(BeNil())
+
+		w.Close()
+		var buf bytes.Buffer
+		_, err = io.Copy(&buf, r)
+		Expect(err).To(BeNil())
+		os.Stdout = old
+
+		var list struct {
+			APIVersion string       `json:"apiVersion"`
+			Kind       string       `json:"kind"`
+			Items      []show.AddOn `json:"items"`
+		}
+		Expect(json.Unmarshal(buf.Bytes(), &list)).To(Succeed())
+		Expect(list.APIVersion).To(Equal(output.APIVersion))
+		Expect(list.Kind).To(Equal("AddOnList"))
+		Expect(len(list.Items)).To(Equal(len(charts)))
+
+		clusterInfo := fmt.Sprintf("%s/%s", clusterConfiguration.Namespace, clusterConfiguration.Name)
+		for i := range charts {
+			found := false
+			for j := range list.Items {
+				if list.Items[j].Name == charts[i].ReleaseName &&
+					list.Items[j].Namespace == charts[i].Namespace {
+
+					Expect(list.Items[j].Cluster).To(Equal(clusterInfo))
+					Expect(list.Items[j].Version).To(Equal(charts[i].ChartVersion))
+					Expect(list.Items[j].Profiles).To(ContainElement(ContainSubstring(clusterProfileName)))
+					found = true
+				}
+			}
+			Expect(found).To(BeTrue())
+		}
+	})
 })
 
 func verifyCharts(lines []string, clusterInfo, clusterProfileName string,
@@ -35,31 +35,42 @@ import (
 	libsveltosv1beta1 "github.com/projectsveltos/libsveltos/api/v1beta1"
 	"github.com/projectsveltos/libsveltos/lib/k8s_utils"
 	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+	"github.com/projectsveltos/sveltosctl/internal/output"
 	"github.com/projectsveltos/sveltosctl/internal/utils"
 )
 
-var (
-	// resourceKind indentifies the type of resource (ClusterProfile, ConfigMap, Secret)
-	// resourceNamespace and resourceName is the kubernetes resource namespace/name
-	// clusters is the list of clusters where resource content is deployed
-	genAdminRbac = func(clusterKind, clusterNamespace, clusterName, serviceAccountNamespace, serviceAccountName,
-		namespace, apigroups, resources, resourceNames, verbs string,
-	) []string {
-		return []string{
-			fmt.Sprintf("%s:%s/%s", clusterKind, clusterNamespace, clusterName),
-			fmt.Sprintf("%s/%s", serviceAccountNamespace, serviceAccountName),
-			namespace,
-			apigroups,
-			resources,
-			resources,
-			verbs,
-		}
+// AdminRbacEntry represents a policy rule granted to an admin in a managed cluster.
+// It is the item type of show admin-rbac structured output.
+type AdminRbacEntry struct {
+	// Cluster is the cluster in the form kind:namespace/name
+	Cluster string `json:"cluster"`
+	// Admin is the ServiceAccount in the form namespace/name
+	Admin string `json:"admin"`
+	// Namespace is the namespace the rule applies to. "*" for ClusterRoles
+	Namespace     string   `json:"namespace"`
+	APIGroups     []string `json:"apiGroups"`
+	Resources     []string `json:"resources"`
+	ResourceNames []string `json:"resourceNames,omitempty"`
+	Verbs         []string `json:"verbs"`
+}
+
+func newAdminRbacEntry(clusterKind, clusterNamespace, clusterName, serviceAccountNamespace, serviceAccountName,
+	namespace string, rule *rbacv1.PolicyRule) AdminRbacEntry {
+
+	return AdminRbacEntry{
+		Cluster:       fmt.Sprintf("%s:%s/%s", clusterKind, clusterNamespace, clusterName),
+		Admin:         fmt.Sprintf("%s/%s", serviceAccountNamespace, serviceAccountName),
+		Namespace:     namespace,
+		APIGroups:     rule.APIGroups,
+		Resources:     rule.Resources,
+		ResourceNames: rule.ResourceNames,
+		Verbs:         rule.Verbs,
 	}
-)
+}
 
 func displayAdminRbacs(ctx context.Context,
 	passedNamespace, passedCluster, passedServiceAccountNamespace, passedServiceAccountName string,
-	logger logr.Logger) error {
+	format output.Format, logger logr.Logger) error {
 
 	// Collect all RoleRequest
 	instance := utils.GetAccessInstance()
@@ -72,8 +83,7 @@ func displayAdminRbacs(ctx context.Context,
 
 	logger.V(logs.LogDebug).Info(fmt.Sprintf("found %d roleRequests", len(roleRequests.Items)))
 
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"CLUSTER", "ADMIN", "NAMESPACE", "API GROUPS", "RESOURCES", "RESOURCE NAMES", "VERBS"})
+	entries := make([]AdminRbacEntry, 0)
 
 	// Build a map: key is the cluster, value is the slices of rolerequests matching that cluster
 	clusterMap := createRoleRequestsPerClusterMap(roleRequests, logger)
@@ -82,12 +92,23 @@ func displayAdminRbacs(ctx context.Context,
 		l := logger.WithValues("cluster", fmt.Sprintf("%s:%s/%s", k.Kind, k.Namespace, k.Name))
 		l.V(logs.LogDebug).Info("considering cluster")
 		err = parseCluster(ctx, &k, clusterMap[k], passedNamespace, passedCluster, passedServiceAccountNamespace,
-			passedServiceAccountName, table, l)
+			passedServiceAccountName, &entries, l)
 		if err != nil {
 			return err
 		}
 	}
 
+	if format.IsStructured() {
+		return output.PrintList(os.Stdout, format, "AdminRbacList", entries)
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"CLUSTER", "ADMIN", "NAMESPACE", "API GROUPS", "RESOURCES", "RESOURCE NAMES", "VERBS"})
+	for i := range entries {
+		e := &entries[i]
+		table.Append([]string{e.Cluster, e.Admin, e.Namespace, strings.Join(e.APIGroups, ","),
+			strings.Join(e.Resources, ","), strings.Join(e.ResourceNames, ","), strings.Join(e.Verbs, ",")})
+	}
 	table.Render()
 
 	return nil
@@ -124,7 +145,7 @@ func parseMatchihgCluster(rr *libsveltosv1beta1.RoleRequest,
 func parseCluster(ctx context.Context, cluster *corev1.ObjectReference,
 	roleRequests []*libsveltosv1beta1.RoleRequest,
 	passedNamespace, passedCluster, passedServiceAccountNamespace, passedServiceAccountName string,
-	table *tablewriter.Table, logger logr.Logger) error {
+	entries *[]AdminRbacEntry, logger logr.Logger) error {
 
 	if passedNamespace == "" || passedNamespace == cluster.Namespace {
 		if passedCluster == "" || passedCluster == cluster.Name {
@@ -132,7 +153,7 @@ func parseCluster(ctx context.Context, cluster *corev1.ObjectReference,
 			for i := range roleRequests {
 				if err := parseRoleRequest(ctx, roleRequests[i], cluster.Namespace,
 					cluster.Name, cluster.Kind, passedServiceAccountNamespace, passedServiceAccountName,
-					table, logger); err != nil {
+					entries, logger); err != nil {
 					return err
 				}
 			}
@@ -162,7 +183,7 @@ func shouldParseRoleRequest(roleRequest *libsveltosv1beta1.RoleRequest,
 
 func parseRoleRequest(ctx context.Context, roleRequest *libsveltosv1beta1.RoleRequest,
 	clusterNamespace, clusterName, clusterKind, passedServiceAccountNamespace, passedServiceAccountName string,
-	table *tablewriter.Table, logger logr.Logger) error {
+	entries *[]AdminRbacEntry, logger logr.Logger) error {
 
 	logger = logger.WithValues("admin", fmt.Sprintf("%s/%s",
 		roleRequest.Spec.ServiceAccountNamespace, roleRequest.Spec.ServiceAccountName))
@@ -172,7 +193,7 @@ func parseRoleRequest(ctx context.Context, roleRequest *libsveltosv1beta1.RoleRe
 		for i := range roleRequest.Spec.RoleRefs {
 			if err := parseReferencedResource(ctx, clusterNamespace, clusterName, clusterKind,
 				roleRequest.Spec.ServiceAccountNamespace, roleRequest.Spec.ServiceAccountName,
-				roleRequest.Spec.RoleRefs[i], table, logger); err != nil {
+				roleRequest.Spec.RoleRefs[i], entries, logger); err != nil {
 				return err
 			}
 		}
@@ -183,7 +204,7 @@ func parseRoleRequest(ctx context.Context, roleRequest *libsveltosv1beta1.RoleRe
 
 func parseReferencedResource(ctx context.Context,
 	clusterNamespace, clusterName, clusterKind, serviceAccountNamespace, serviceAccountName string,
-	resource libsveltosv1beta1.PolicyRef, table *tablewriter.Table, logger logr.Logger) error {
+	resource libsveltosv1beta1.PolicyRef, entries *[]AdminRbacEntry, logger logr.Logger) error {
 
 	// fetch resource
 	content, err := collectResourceContent(ctx, resource, logger)
@@ -194,13 +215,13 @@ func parseReferencedResource(ctx context.Context,
 	for i := range content {
 		if content[i].GroupVersionKind().Kind == "Role" {
 			err = processRole(content[i], clusterNamespace, clusterName, clusterKind,
-				serviceAccountNamespace, serviceAccountName, table, logger)
+				serviceAccountNamespace, serviceAccountName, entries, logger)
 			if err != nil {
 				return err
 			}
 		} else if content[i].GroupVersionKind().Kind == "ClusterRole" {
 			err = processClusterRole(content[i], clusterNamespace, clusterName, clusterKind,
-				serviceAccountNamespace, serviceAccountName, table, logger)
+				serviceAccountNamespace, serviceAccountName, entries, logger)
 			if err != nil {
 				return err
 			}
@@ -214,7 +235,7 @@ func parseReferencedResource(ctx context.Context,
 
 func processRole(u *unstructured.Unstructured,
 	clusterNamespace, clusterName, clusterKind, serviceAccountNamespace, serviceAccountName string,
-	table *tablewriter.Table, logger logr.Logger) error {
+	entries *[]AdminRbacEntry, logger logr.Logger) error {
 
 	role := &rbacv1.Role{}
 	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.UnstructuredContent(), role); err != nil {
@@ -224,15 +245,8 @@ func processRole(u *unstructured.Unstructured,
 	logger.V(logs.LogDebug).Info("process role")
 
 	for i := range role.Rules {
-		rule := role.Rules[i]
-		resourceNames := ""
-		if rule.ResourceNames != nil {
-			resourceNames = strings.Join(rule.ResourceNames, ",")
-		}
-
-		table.Append(genAdminRbac(clusterKind, clusterNamespace, clusterName, serviceAccountNamespace,
-			serviceAccountName, role.Namespace, strings.Join(rule.APIGroups, ","), strings.Join(rule.Resources, ","),
-			resourceNames, strings.Join(rule.Verbs, ",")))
+		*entries = append(*entries, newAdminRbacEntry(clusterKind, clusterNamespace, clusterName,
+			serviceAccountNamespace, serviceAccountName, role.Namespace, &role.Rules[i]))
 	}
 
 	return nil
@@ -240,7 +254,7 @@ func processRole(u *unstructured.Unstructured,
 
 func processClusterRole(u *unstructured.Unstructured,
 	clusterNamespace, clusterName, clusterKind, serviceAccountNamespace, serviceAccountName string,
-	table *tablewriter.Table, logger logr.Logger) error {
+	entries *[]AdminRbacEntry, logger logr.Logger) error {
 
 	clusterRole := &rbacv1.ClusterRole{}
 	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.UnstructuredContent(), clusterRole); err != nil {
@@ -250,15 +264,8 @@ func processClusterRole(u *unstructured.Unstructured,
 	logger.V(logs.LogDebug).Info("process role")
 
 	for i := range clusterRole.Rules {
-		rule := clusterRole.Rules[i]
-		resourceNames := ""
-		if rule.ResourceNames != nil {
-			resourceNames = strings.Join(rule.ResourceNames, ",")
-		}
-
-		table.Append(genAdminRbac(clusterKind, clusterNamespace, clusterName, serviceAccountNamespace, serviceAccountName,
-			"*", strings.Join(rule.APIGroups, ","), strings.Join(rule.Resources, ","),
-			resourceNames, strings.Join(rule.Verbs, ",")))
+		*entries = append(*entries, newAdminRbacEntry(clusterKind, clusterNamespace, clusterName,
+			serviceAccountNamespace, serviceAccountName, "*", &clusterRole.Rules[i]))
 	}
 
 	return nil
@@ -326,7 +333,7 @@ func collectContent(data map[string]string, logger logr.Logger) ([]*unstructured
 // AdminPermissions displays information about permissions each admin has in each managed cluster
 func AdminPermissions(ctx context.Context, args []string, logger logr.Logger) error {
 	doc := `Usage:
-  sveltosctl show admin-rbac [options] [--namespace=<name>] [--cluster=<name>] [--serviceAccountName=<name>] [--serviceAccountNamespace=<name>] [--verbose]
+  sveltosctl show admin-rbac [options] [--namespace=<name>] [--cluster=<name>] [--serviceAccountName=<name>] [--serviceAccountNamespace=<name>] [--output=<format>] [--verbose]
 
      --serviceAccountName=<name>            Show permissions for this ServiceAccount.
                                             If not specified all admins are considered.
@@ -339,6 +346,7 @@ func AdminPermissions(ctx context.Context, args []string, logger logr.Logger) er
 
 Options:
   -h --help                  Show this screen.
+  -o --output=<format>       Output format: table, wide, json or yaml. Defaults to global --output, or table.
      --verbose               Verbose mode. Print each step.  
 
 Description:
@@ -386,5 +394,10 @@ Description:
 		saNamespace = passedSaNamespace.(string)
 	}
 
-	return displayAdminRbacs(ctx, namespace, cluster, saNamespace, saName, logger)
+	format, err := parseOutputFormat(parsedArgs)
+	if err != nil {
+		return err
+	}
+
+	return displayAdminRbacs(ctx, namespace, cluster, saNamespace, saName, format, logger)
 }
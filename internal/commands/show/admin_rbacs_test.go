@@ -35,6 +35,7 @@ import (
 
 	libsveltosv1beta1 "github.com/projectsveltos/libsveltos/api/v1beta1"
 	"github.com/projectsveltos/sveltosctl/internal/commands/show"
+	"github.com/projectsveltos/sveltosctl/internal/output"
 	"github.com/projectsveltos/sveltosctl/internal/utils"
 )
 
@@ -105,7 +106,7 @@ var _ = Describe("Admin RBACs", func() {
 		os.Stdout = w
 
 		utils.InitalizeManagementClusterAcces(scheme, nil, nil, c)
-		err = show.DisplayAdminRbacs(context.TODO(), "", "", "", "",
+		err = show.DisplayAdminRbacs(context.TODO(), "", "", "", "", output.Table,
 			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
 		Expect(err).To(BeNil())
 
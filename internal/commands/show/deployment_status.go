@@ -0,0 +1,277 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package show
+
+import (
+	"context"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docopt/docopt-go"
+	"github.com/go-logr/logr"
+	"github.com/olekukonko/tablewriter"
+
+	configv1beta1 "github.com/projectsveltos/addon-controller/api/v1beta1"
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+	"github.com/projectsveltos/sveltosctl/internal/output"
+	"github.com/projectsveltos/sveltosctl/internal/utils"
+)
+
+const (
+	// shortHashLength is the number of hash characters displayed in table format
+	shortHashLength = 12
+)
+
+var (
+	// cluster represents the cluster => namespace/name
+	// profile is the ClusterProfile/Profile in the form kind/name
+	// feature is the feature (Resources, Helm, Kustomize)
+	// status, hash, lastApplied and failureMessage are the feature deployment status
+	genDeploymentStatusRow = func(cluster, profile, feature, status, hash, lastApplied, failureMessage string,
+	) []string {
+
+		return []string{
+			cluster,
+			profile,
+			feature,
+			status,
+			hash,
+			lastApplied,
+			failureMessage,
+		}
+	}
+)
+
+// DeploymentStatusEntry represents the deployment status of a feature in a cluster
+// for a ClusterProfile/Profile.
+// It is the item type of show deployment-status structured output.
+type DeploymentStatusEntry struct {
+	// Cluster is the cluster in the form namespace/name
+	Cluster string `json:"cluster"`
+	// Profile is the ClusterProfile/Profile in the form kind/name
+	Profile string `json:"profile"`
+	// Feature is one of Resources, Helm or Kustomize
+	Feature string `json:"feature"`
+	// Status is the feature status (Provisioned, Provisioning, Failed, FailedNonRetriable, etc.)
+	Status string `json:"status"`
+	// Hash is the hex encoded hash of the feature configuration last deployed
+	Hash        string `json:"hash,omitempty"`
+	LastApplied string `json:"lastApplied,omitempty"`
+	// FailureReason and FailureMessage are set only if deployment failed
+	FailureReason       string `json:"failureReason,omitempty"`
+	FailureMessage      string `json:"failureMessage,omitempty"`
+	ConsecutiveFailures uint   `json:"consecutiveFailures,omitempty"`
+}
+
+func displayDeploymentStatus(ctx context.Context, passedNamespace, passedCluster, passedProfile string,
+	format output.Format, logger logr.Logger) error {
+
+	instance := utils.GetAccessInstance()
+
+	clusterSummaries, err := instance.ListClusterSummaries(ctx, passedNamespace, logger)
+	if err != nil {
+		return err
+	}
+
+	entries := make([]DeploymentStatusEntry, 0)
+	for i := range clusterSummaries.Items {
+		cs := &clusterSummaries.Items[i]
+		if passedCluster != "" && cs.Spec.ClusterName != passedCluster {
+			continue
+		}
+
+		profile := getClusterSummaryProfile(cs)
+		if passedProfile != "" && profile != passedProfile {
+			continue
+		}
+
+		logger.V(logs.LogDebug).Info(fmt.Sprintf("Considering ClusterSummary: %s/%s", cs.Namespace, cs.Name))
+		entries = append(entries, getDeploymentStatusEntries(cs, profile)...)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return deploymentStatusKey(&entries[i]) < deploymentStatusKey(&entries[j])
+	})
+
+	if format.IsStructured() {
+		return output.PrintList(os.Stdout, format, "DeploymentStatusList", entries)
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	header := []string{"CLUSTER", "PROFILE", "FEATURE", "STATUS", "HASH", "LAST APPLIED", "FAILURE MESSAGE"}
+	if format.IsWide() {
+		header = append(header, "FAILURE REASON", "CONSECUTIVE FAILURES")
+	}
+	table.SetHeader(header)
+
+	for i := range entries {
+		displayDeploymentStatusEntry(&entries[i], format, table)
+	}
+
+	table.Render()
+
+	return nil
+}
+
+func displayDeploymentStatusEntry(entry *DeploymentStatusEntry, format output.Format, table *tablewriter.Table) {
+	hash := entry.Hash
+	if !format.IsWide() && len(hash) > shortHashLength {
+		hash = hash[:shortHashLength]
+	}
+
+	data := genDeploymentStatusRow(entry.Cluster, entry.Profile, entry.Feature, entry.Status, hash,
+		entry.LastApplied, entry.FailureMessage)
+	if format.IsWide() {
+		data = append(data, entry.FailureReason, strconv.FormatUint(uint64(entry.ConsecutiveFailures), 10))
+	}
+
+	if isFeatureFailed(entry.Status) {
+		colors := make([]tablewriter.Colors, len(data))
+		for i := range colors {
+			colors[i] = tablewriter.Colors{}
+		}
+		colors[3] = tablewriter.Colors{tablewriter.Bold, tablewriter.BgRedColor}
+		colors[6] = tablewriter.Colors{tablewriter.Bold, tablewriter.BgRedColor}
+		table.Rich(data, colors)
+		return
+	}
+
+	table.Append(data)
+}
+
+func getDeploymentStatusEntries(clusterSummary *configv1beta1.ClusterSummary, profile string,
+) []DeploymentStatusEntry {
+
+	cluster := fmt.Sprintf("%s/%s", clusterSummary.Spec.ClusterNamespace, clusterSummary.Spec.ClusterName)
+
+	entries := make([]DeploymentStatusEntry, len(clusterSummary.Status.FeatureSummaries))
+	for i := range clusterSummary.Status.FeatureSummaries {
+		fs := &clusterSummary.Status.FeatureSummaries[i]
+		entries[i] = DeploymentStatusEntry{
+			Cluster:             cluster,
+			Profile:             profile,
+			Feature:             string(fs.FeatureID),
+			Status:              string(fs.Status),
+			Hash:                hex.EncodeToString(fs.Hash),
+			ConsecutiveFailures: fs.ConsecutiveFailures,
+		}
+		if fs.LastAppliedTime != nil {
+			entries[i].LastApplied = fs.LastAppliedTime.String()
+		}
+		if fs.FailureReason != nil {
+			entries[i].FailureReason = *fs.FailureReason
+		}
+		if fs.FailureMessage != nil {
+			entries[i].FailureMessage = *fs.FailureMessage
+		}
+	}
+
+	return entries
+}
+
+// getClusterSummaryProfile returns the ClusterProfile/Profile owning the ClusterSummary
+// in the form kind/name
+func getClusterSummaryProfile(clusterSummary *configv1beta1.ClusterSummary) string {
+	for i := range clusterSummary.OwnerReferences {
+		ref := &clusterSummary.OwnerReferences[i]
+		if ref.Kind == configv1beta1.ClusterProfileKind || ref.Kind == configv1beta1.ProfileKind {
+			return fmt.Sprintf("%s/%s", ref.Kind, ref.Name)
+		}
+	}
+
+	return ""
+}
+
+func isFeatureFailed(status string) bool {
+	return status == string(configv1beta1.FeatureStatusFailed) ||
+		status == string(configv1beta1.FeatureStatusFailedNonRetriable)
+}
+
+func deploymentStatusKey(e *DeploymentStatusEntry) string {
+	return fmt.Sprintf("%s:%s:%s", e.Cluster, e.Profile, e.Feature)
+}
+
+// DeploymentStatus displays, for each cluster and ClusterProfile/Profile, the deployment status of each feature
+func DeploymentStatus(ctx context.Context, args []string, logger logr.Logger) error {
+	doc := `Usage:
+  sveltosctl show deployment-status [options] [--namespace=<name>] [--cluster=<name>] [--profile=<name>] [--output=<format>] [--verbose]
+
+     --namespace=<name>      Show deployment status in clusters in this namespace.
+                             If not specified all namespaces are considered.
+     --cluster=<name>        Show deployment status in cluster with name.
+                             If not specified all cluster names are considered.
+     --profile=<kind/name>   Show deployment status for this clusterprofile/profile.
+                             If not specified all clusterprofiles/profiles are considered.
+
+Options:
+  -h --help                  Show this screen.
+  -o --output=<format>       Output format: table, wide, json or yaml. Defaults to global --output, or table.
+     --verbose               Verbose mode. Print each step.
+
+Description:
+  The show deployment-status command shows, for each cluster and clusterprofile/profile, the status of
+  each feature (Resources, Helm, Kustomize) along with failure message, hash and last time it was applied.
+`
+	parsedArgs, err := docopt.ParseArgs(doc, nil, "1.0")
+	if err != nil {
+		logger.V(logs.LogInfo).Error(err, "failed to parse args")
+		return fmt.Errorf(
+			"invalid option: 'sveltosctl %s'. Use flag '--help' to read about a specific subcommand. Error: %w",
+			strings.Join(args, " "),
+			err,
+		)
+	}
+	if len(parsedArgs) == 0 {
+		return nil
+	}
+
+	_ = flag.Lookup("v").Value.Set(fmt.Sprint(logs.LogInfo))
+	verbose := parsedArgs["--verbose"].(bool)
+	if verbose {
+		err = flag.Lookup("v").Value.Set(fmt.Sprint(logs.LogDebug))
+		if err != nil {
+			return err
+		}
+	}
+
+	namespace := ""
+	if passedNamespace := parsedArgs["--namespace"]; passedNamespace != nil {
+		namespace = passedNamespace.(string)
+	}
+
+	cluster := ""
+	if passedCluster := parsedArgs["--cluster"]; passedCluster != nil {
+		cluster = passedCluster.(string)
+	}
+
+	profile := ""
+	if passedProfile := parsedArgs["--profile"]; passedProfile != nil {
+		profile = passedProfile.(string)
+	}
+
+	format, err := parseOutputFormat(parsedArgs)
+	if err != nil {
+		return err
+	}
+
+	return displayDeploymentStatus(ctx, namespace, cluster, profile, format, logger)
+}
@@ -0,0 +1,185 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package show_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2/textlogger"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	configv1beta1 "github.com/projectsveltos/addon-controller/api/v1beta1"
+	"github.com/projectsveltos/sveltosctl/internal/commands/show"
+	"github.com/projectsveltos/sveltosctl/internal/output"
+	"github.com/projectsveltos/sveltosctl/internal/utils"
+)
+
+var _ = Describe("DeploymentStatus", func() {
+	var failedSummary *configv1beta1.ClusterSummary
+	var provisionedSummary *configv1beta1.ClusterSummary
+	var failureMessage string
+
+	BeforeEach(func() {
+		failureMessage = randomString()
+
+		failedSummary = generateClusterSummary(configv1beta1.ClusterProfileKind, randomString())
+		failedSummary.Status.FeatureSummaries = []configv1beta1.FeatureSummary{
+			{
+				FeatureID:      configv1beta1.FeatureHelm,
+				Status:         configv1beta1.FeatureStatusFailed,
+				Hash:           []byte(randomString()),
+				FailureMessage: &failureMessage,
+			},
+			{
+				FeatureID: configv1beta1.FeatureResources,
+				Status:    configv1beta1.FeatureStatusProvisioned,
+			},
+		}
+
+		provisionedSummary = generateClusterSummary(configv1beta1.ProfileKind, randomString())
+		provisionedSummary.Status.FeatureSummaries = []configv1beta1.FeatureSummary{
+			{
+				FeatureID: configv1beta1.FeatureKustomize,
+				Status:    configv1beta1.FeatureStatusProvisioning,
+			},
+		}
+	})
+
+	It("show deployment-status displays status of each feature", func() {
+		old := os.Stdout // keep backup of the real stdout
+		r, w, _ := os.Pipe()
+		os.Stdout = w
+
+		initObjects := []client.Object{failedSummary, provisionedSummary}
+
+		scheme, err := utils.GetScheme()
+		Expect(err).To(BeNil())
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initObjects...).Build()
+
+		utils.InitalizeManagementClusterAcces(scheme, nil, nil, c)
+		err = show.DisplayDeploymentStatus(context.TODO(), "", "", "", output.Table,
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
+		Expect(err).To(BeNil())
+
+		w.Close()
+		var buf bytes.Buffer
+		_, err = io.Copy(&buf, r)
+		Expect(err).To(BeNil())
+		os.Stdout = old
+
+		/*
+			// This is an example of how the table needs to look like
+			+-------------------+---------------------------+---------+---------+--------------+-------------------------------+-----------------+
+			|      CLUSTER      |          PROFILE          | FEATURE | STATUS  |     HASH     |         LAST APPLIED          | FAILURE MESSAGE |
+			+-------------------+---------------------------+---------+---------+--------------+-------------------------------+-----------------+
+			| default/cluster-1 | ClusterProfile/kyverno    | Helm    | Failed  | 6b7976657a31 | 2024-09-30 11:48:45 -0700 PDT | chart not found |
+			+-------------------+---------------------------+---------+---------+--------------+-------------------------------+-----------------+
+		*/
+
+		foundFailed, foundProvisioned, foundProvisioning := false, false, false
+		lines := strings.Split(buf.String(), "\n")
+		for i := range lines {
+			switch {
+			case strings.Contains(lines[i], failedSummary.Spec.ClusterName) &&
+				strings.Contains(lines[i], string(configv1beta1.FeatureHelm)) &&
+				strings.Contains(lines[i], string(configv1beta1.FeatureStatusFailed)) &&
+				strings.Contains(lines[i], failureMessage):
+				foundFailed = true
+			case strings.Contains(lines[i], failedSummary.Spec.ClusterName) &&
+				strings.Contains(lines[i], string(configv1beta1.FeatureResources)) &&
+				strings.Contains(lines[i], string(configv1beta1.FeatureStatusProvisioned)):
+				foundProvisioned = true
+			case strings.Contains(lines[i], provisionedSummary.Spec.ClusterName) &&
+				strings.Contains(lines[i], string(configv1beta1.FeatureKustomize)) &&
+				strings.Contains(lines[i], string(configv1beta1.FeatureStatusProvisioning)):
+				foundProvisioning = true
+			}
+		}
+		Expect(foundFailed).To(BeTrue())
+		Expect(foundProvisioned).To(BeTrue())
+		Expect(foundProvisioning).To(BeTrue())
+	})
+
+	It("show deployment-status filters by cluster and profile", func() {
+		old := os.Stdout // keep backup of the real stdout
+		r, w, _ := os.Pipe()
+		os.Stdout = w
+
+		initObjects := []client.Object{failedSummary, provisionedSummary}
+
+		scheme, err := utils.GetScheme()
+		Expect(err).To(BeNil())
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initObjects...).Build()
+
+		profile := configv1beta1.ProfileKind + "/" + provisionedSummary.OwnerReferences[0].Name
+
+		utils.InitalizeManagementClusterAcces(scheme, nil, nil, c)
+		err = show.DisplayDeploymentStatus(context.TODO(), provisionedSummary.Namespace,
+			provisionedSummary.Spec.ClusterName, profile, output.JSON,
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
+		Expect(err).To(BeNil())
+
+		w.Close()
+		var buf bytes.Buffer
+		_, err = io.Copy(&buf, r)
+		Expect(err).To(BeNil())
+		os.Stdout = old
+
+		var list struct {
+			Kind  string                       `json:"kind"`
+			Items []show.DeploymentStatusEntry `json:"items"`
+		}
+		Expect(json.Unmarshal(buf.Bytes(), &list)).To(Succeed())
+		Expect(list.Kind).To(Equal("DeploymentStatusList"))
+		Expect(list.Items).To(HaveLen(1))
+		Expect(list.Items[0].Profile).To(Equal(profile))
+		Expect(list.Items[0].Feature).To(Equal(string(configv1beta1.FeatureKustomize)))
+		Expect(list.Items[0].Status).To(Equal(string(configv1beta1.FeatureStatusProvisioning)))
+	})
+})
+
+func generateClusterSummary(profileKind, profileName string) *configv1beta1.ClusterSummary {
+	namespace := randomString()
+	return &configv1beta1.ClusterSummary{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      randomString(),
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: configv1beta1.GroupVersion.String(),
+					Kind:       profileKind,
+					Name:       profileName,
+					UID:        "uid",
+				},
+			},
+		},
+		Spec: configv1beta1.ClusterSummarySpec{
+			ClusterNamespace: namespace,
+			ClusterName:      randomString(),
+		},
+	}
+}
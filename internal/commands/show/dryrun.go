@@ -33,9 +33,14 @@ import (
 
 	configv1beta1 "github.com/projectsveltos/addon-controller/api/v1beta1"
 	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+	"github.com/projectsveltos/sveltosctl/internal/output"
 	"github.com/projectsveltos/sveltosctl/internal/utils"
 )
 
+const (
+	helmReleaseType = "helm release"
+)
+
 var (
 	// cluster represents the cluster => namespace/name
 	// resourceNamespace and resourceName is the kubernetes resource/helm release namespace/name
@@ -56,26 +61,83 @@ var (
 	}
 )
 
+// DryRunEntry represents a change that would take effect in a cluster if a ClusterProfile/Profile
+// were moved out of DryRun mode. It is the item type of show dryrun structured output.
+type DryRunEntry struct {
+	// Cluster is the cluster in the form namespace/name
+	Cluster string `json:"cluster"`
+	// Kind is either "helm release" or the resource group:kind
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Version is the helm chart version or the resource version
+	Version string `json:"version,omitempty"`
+	Action  string `json:"action"`
+	// Message contains, when available, the full diff
+	Message string `json:"message,omitempty"`
+	Profile string `json:"profile"`
+}
+
 func displayDryRun(ctx context.Context, passedNamespace, passedCluster, passedProfile string,
-	rawDiff bool, logger logr.Logger) error {
+	rawDiff bool, format output.Format, logger logr.Logger) error {
 
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"CLUSTER", "RESOURCE TYPE", "NAMESPACE", "NAME", "ACTION", "MESSAGE", "PROFILE"})
+	if format.IsStructured() {
+		// Full diffs are part of each entry
+		rawDiff = false
+	}
 
+	entries := make([]DryRunEntry, 0)
 	if err := displayDryRunInNamespaces(ctx, passedNamespace, passedCluster,
-		passedProfile, table, rawDiff, logger); err != nil {
+		passedProfile, &entries, rawDiff, logger); err != nil {
 		return err
 	}
 
-	if !rawDiff {
-		table.Render()
+	if format.IsStructured() {
+		return output.PrintList(os.Stdout, format, "DryRunList", entries)
 	}
 
+	if rawDiff {
+		return nil
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	header := []string{"CLUSTER", "RESOURCE TYPE", "NAMESPACE", "NAME", "ACTION", "MESSAGE", "PROFILE"}
+	if format.IsWide() {
+		header = append(header, "VERSION")
+	}
+	table.SetHeader(header)
+
+	for i := range entries {
+		e := &entries[i]
+		row := genDryRunRow(e.Cluster, e.Kind, e.Namespace, e.Name, e.Action, getDryRunTableMessage(e), e.Profile)
+		if format.IsWide() {
+			row = append(row, e.Version)
+		}
+		table.Append(row)
+	}
+
+	table.Render()
+
 	return nil
 }
 
+// getDryRunTableMessage returns the message to display in the table. Full diffs are
+// replaced by an hint to use --raw-diff.
+func getDryRunTableMessage(entry *DryRunEntry) string {
+	if entry.Kind == helmReleaseType {
+		if entry.Action == string(configv1beta1.UpdateHelmValuesAction) {
+			return "use --raw-diff to see full diff for helm values"
+		}
+		return entry.Message
+	}
+	if entry.Action == string(configv1beta1.UpdateResourceAction) {
+		return "use --raw-diff to see full diff"
+	}
+	return entry.Message
+}
+
 func displayDryRunInNamespaces(ctx context.Context, passedNamespace, passedCluster, passedProfile string,
-	table *tablewriter.Table, rawDiff bool, logger logr.Logger) error {
+	entries *[]DryRunEntry, rawDiff bool, logger logr.Logger) error {
 
 	instance := utils.GetAccessInstance()
 
@@ -89,7 +151,7 @@ func displayDryRunInNamespaces(ctx context.Context, passedNamespace, passedClust
 		if doConsiderNamespace(ns, passedNamespace) {
 			logger.V(logs.LogDebug).Info(fmt.Sprintf("Considering namespace: %s", ns.Name))
 			err = displayDryRunInNamespace(ctx, ns.Name, passedCluster, passedProfile,
-				table, rawDiff, logger)
+				entries, rawDiff, logger)
 			if err != nil {
 				return err
 			}
@@ -100,7 +162,7 @@ func displayDryRunInNamespaces(ctx context.Context, passedNamespace, passedClust
 }
 
 func displayDryRunInNamespace(ctx context.Context, namespace, passedCluster, passedProfile string,
-	table *tablewriter.Table, rawDiff bool, logger logr.Logger) error {
+	entries *[]DryRunEntry, rawDiff bool, logger logr.Logger) error {
 
 	instance := utils.GetAccessInstance()
 
@@ -134,7 +196,7 @@ func displayDryRunInNamespace(ctx context.Context, namespace, passedCluster, pas
 			doConsiderProfile([]string{profileName}, passedProfile) {
 
 			logger.V(logs.LogDebug).Info(fmt.Sprintf("Considering ClusterReport: %s", cr.Name))
-			err = displayDryRunForCluster(cr, profileName, table, rawDiff)
+			err = displayDryRunForCluster(cr, profileName, entries, rawDiff)
 			if err != nil {
 				return err
 			}
@@ -145,7 +207,7 @@ func displayDryRunInNamespace(ctx context.Context, namespace, passedCluster, pas
 }
 
 func displayDryRunForCluster(clusterReport *configv1beta1.ClusterReport, profileName string,
-	table *tablewriter.Table, rawDiff bool) error {
+	entries *[]DryRunEntry, rawDiff bool) error {
 
 	clusterInfo := fmt.Sprintf("%s/%s", clusterReport.Spec.ClusterNamespace, clusterReport.Spec.ClusterName)
 	profileOwner, err := getProfileOwnerReference(clusterReport)
@@ -153,61 +215,50 @@ func displayDryRunForCluster(clusterReport *configv1beta1.ClusterReport, profile
 		return err
 	}
 
-	updateMessage := "use --raw-diff to see full diff for helm values"
-	for i := range clusterReport.Status.ReleaseReports {
-		report := &clusterReport.Status.ReleaseReports[i]
-		message := report.Message
-		if report.Action == string(configv1beta1.UpdateHelmValuesAction) {
-			message = updateMessage
-		}
-		table.Append(genDryRunRow(clusterInfo, "helm release", report.ReleaseNamespace, report.ReleaseName,
-			report.Action, message, profileName))
-		if rawDiff {
-			if rawDiff && report.Message != "" && report.Action == string(configv1beta1.UpdateHelmValuesAction) {
-				//nolint: forbidigo // print diff
-				fmt.Printf("Profile: %s:%s Cluster: %s/%s\n%s\n", profileOwner.Kind, profileOwner.Name,
-					clusterReport.Spec.ClusterNamespace, clusterReport.Spec.ClusterName, report.Message)
-			}
+	// Only diffs for updateAction (helm values update for releases, resource update for resources) are printed
+	printRawDiff := func(action, message, updateAction string) {
+		if rawDiff && message != "" && action == updateAction {
+			//nolint: forbidigo // print diff
+			fmt.Printf("Profile: %s:%s Cluster: %s/%s\n%s\n", profileOwner.Kind, profileOwner.Name,
+				clusterReport.Spec.ClusterNamespace, clusterReport.Spec.ClusterName, message)
 		}
 	}
 
-	updateMessage = "use --raw-diff to see full diff"
-	for i := range clusterReport.Status.ResourceReports {
-		report := &clusterReport.Status.ResourceReports[i]
-		groupKind := fmt.Sprintf("%s:%s", report.Resource.Group, report.Resource.Kind)
-		message := report.Message
-		if report.Action == string(configv1beta1.UpdateResourceAction) {
-			message = updateMessage
-		}
-		table.Append(genDryRunRow(clusterInfo, groupKind, report.Resource.Namespace, report.Resource.Name,
-			report.Action, message, profileName))
-		if rawDiff {
-			if rawDiff && report.Message != "" && report.Action == string(configv1beta1.UpdateResourceAction) {
-				//nolint: forbidigo // print diff
-				fmt.Printf("Profile: %s:%s Cluster: %s/%s\n%s\n", profileOwner.Kind, profileOwner.Name,
-					clusterReport.Spec.ClusterNamespace, clusterReport.Spec.ClusterName, report.Message)
-			}
-		}
+	for i := range clusterReport.Status.ReleaseReports {
+		report := &clusterReport.Status.ReleaseReports[i]
+		*entries = append(*entries, DryRunEntry{
+			Cluster:   clusterInfo,
+			Kind:      helmReleaseType,
+			Namespace: report.ReleaseNamespace,
+			Name:      report.ReleaseName,
+			Version:   report.ChartVersion,
+			Action:    report.Action,
+			Message:   report.Message,
+			Profile:   profileName,
+		})
+		printRawDiff(report.Action, report.Message, string(configv1beta1.UpdateHelmValuesAction))
 	}
 
-	for i := range clusterReport.Status.KustomizeResourceReports {
-		report := &clusterReport.Status.KustomizeResourceReports[i]
-		groupKind := fmt.Sprintf("%s:%s", report.Resource.Group, report.Resource.Kind)
-		message := report.Message
-		if report.Action == string(configv1beta1.UpdateResourceAction) {
-			message = updateMessage
-		}
-		table.Append(genDryRunRow(clusterInfo, groupKind, report.Resource.Namespace, report.Resource.Name,
-			report.Action, message, profileName))
-		if rawDiff {
-			if rawDiff && report.Message != "" && report.Action == string(configv1beta1.UpdateResourceAction) {
-				//nolint: forbidigo // print diff
-				fmt.Printf("Profile: %s:%s Cluster: %s/%s\n%s\n", profileOwner.Kind, profileOwner.Name,
-					clusterReport.Spec.ClusterNamespace, clusterReport.Spec.ClusterName, report.Message)
-			}
+	addResourceReports := func(reports []configv1beta1.ResourceReport) {
+		for i := range reports {
+			report := &reports[i]
+			*entries = append(*entries, DryRunEntry{
+				Cluster:   clusterInfo,
+				Kind:      fmt.Sprintf("%s:%s", report.Resource.Group, report.Resource.Kind),
+				Namespace: report.Resource.Namespace,
+				Name:      report.Resource.Name,
+				Version:   report.Resource.Version,
+				Action:    report.Action,
+				Message:   report.Message,
+				Profile:   profileName,
+			})
+			printRawDiff(report.Action, report.Message, string(configv1beta1.UpdateResourceAction))
 		}
 	}
 
+	addResourceReports(clusterReport.Status.ResourceReports)
+	addResourceReports(clusterReport.Status.KustomizeResourceReports)
+
 	return nil
 }
 
@@ -215,7 +266,7 @@ func displayDryRunForCluster(clusterReport *configv1beta1.ClusterReport, profile
 // to a ClusterProfile currently in DryRun mode,
 func DryRun(ctx context.Context, args []string, logger logr.Logger) error {
 	doc := `Usage:
-  sveltosctl show dryrun [options] [--namespace=<name>] [--cluster=<name>] [--profile=<name>] [--raw-diff] [--verbose]
+  sveltosctl show dryrun [options] [--namespace=<name>] [--cluster=<name>] [--profile=<name>] [--raw-diff] [--output=<format>] [--verbose]
 
      --namespace=<name>      Show which Kubernetes addons would change in clusters in this namespace.
                              If not specified all namespaces are considered.
@@ -227,6 +278,8 @@ func DryRun(ctx context.Context, args []string, logger logr.Logger) error {
 
 Options:
   -h --help                  Show this screen.
+  -o --output=<format>       Output format: table, wide, json or yaml. Defaults to global --output, or table.
+                             With json and yaml, full diffs are included in each entry message.
      --verbose               Verbose mode. Print each step.  
 
 Description:
@@ -271,7 +324,12 @@ Description:
 
 	rawDiff := parsedArgs["--raw-diff"].(bool)
 
-	return displayDryRun(ctx, namespace, cluster, profile, rawDiff, logger)
+	format, err := parseOutputFormat(parsedArgs)
+	if err != nil {
+		return err
+	}
+
+	return displayDryRun(ctx, namespace, cluster, profile, rawDiff, format, logger)
 }
 
 // getProfileOwnerReference returns the ClusterProfile/Profile owning a given ClusterReport
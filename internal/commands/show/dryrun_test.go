@@ -35,6 +35,7 @@ import (
 
 	configv1beta1 "github.com/projectsveltos/addon-controller/api/v1beta1"
 	"github.com/projectsveltos/sveltosctl/internal/commands/show"
+	"github.com/projectsveltos/sveltosctl/internal/output"
 	"github.com/projectsveltos/sveltosctl/internal/utils"
 )
 
@@ -127,7 +128,7 @@ var _ = Describe("DryRun", func() {
 		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initObjects...).Build()
 
 		utils.InitalizeManagementClusterAcces(scheme, nil, nil, c)
-		err = show.DisplayDryRun(context.TODO(), "", "", "", false,
+		err = show.DisplayDryRun(context.TODO(), "", "", "", false, output.Table,
 			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
 		Expect(err).To(BeNil())
 
@@ -237,7 +238,7 @@ var _ = Describe("DryRun", func() {
 		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initObjects...).Build()
 
 		utils.InitalizeManagementClusterAcces(scheme, nil, nil, c)
-		err = show.DisplayDryRun(context.TODO(), "", "", "", false,
+		err = show.DisplayDryRun(context.TODO(), "", "", "", false, output.Table,
 			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
 		Expect(err).To(BeNil())
 
@@ -263,6 +264,22 @@ var _ = Describe("DryRun", func() {
 
 		os.Stdout = old
 	})
+
+	It("getDryRunTableMessage hides only the full diffs of updates", func() {
+		message := randomString()
+
+		entry := &show.DryRunEntry{Kind: "helm release", Action: string(configv1beta1.UpdateHelmValuesAction), Message: message}
+		Expect(show.GetDryRunTableMessage(entry)).To(Equal("use --raw-diff to see full diff for helm values"))
+
+		entry = &show.DryRunEntry{Kind: "helm release", Action: string(configv1beta1.UpdateResourceAction), Message: message}
+		Expect(show.GetDryRunTableMessage(entry)).To(Equal(message))
+
+		entry = &show.DryRunEntry{Kind: "apps:Deployment", Action: string(configv1beta1.UpdateResourceAction), Message: message}
+		Expect(show.GetDryRunTableMessage(entry)).To(Equal("use --raw-diff to see full diff"))
+
+		entry = &show.DryRunEntry{Kind: "apps:Deployment", Action: string(configv1beta1.UpdateHelmValuesAction), Message: message}
+		Expect(show.GetDryRunTableMessage(entry)).To(Equal(message))
+	})
 })
 
 func verifyReleaseReports(lines []string, clusterInfo, clusterProfileName string,
@@ -17,9 +17,12 @@ limitations under the License.
 package show
 
 var (
-	DisplayAddOns     = displayAddOns
-	DisplayDryRun     = displayDryRun
-	ShowUsage         = showUsage
-	DisplayAdminRbacs = displayAdminRbacs
-	DisplayResources  = displayResources
+	DisplayAddOns         = displayAddOns
+	DisplayDryRun         = displayDryRun
+	GetDryRunTableMessage = getDryRunTableMessage
+	ShowUsage             = showUsage
+	DisplayAdminRbacs     = displayAdminRbacs
+	DisplayResources      = displayResources
+
+	DisplayDeploymentStatus = displayDeploymentStatus
 )
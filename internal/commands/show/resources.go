@@ -32,6 +32,7 @@ import (
 	libsveltosv1beta1 "github.com/projectsveltos/libsveltos/api/v1beta1"
 	"github.com/projectsveltos/libsveltos/lib/k8s_utils"
 	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+	"github.com/projectsveltos/sveltosctl/internal/output"
 	"github.com/projectsveltos/sveltosctl/internal/utils"
 )
 
@@ -50,38 +51,67 @@ var (
 	}
 )
 
+// ResourceEntry represents a resource collected from a managed cluster.
+// It is the item type of show resources structured output.
+type ResourceEntry struct {
+	// Cluster is the cluster in the form namespace/name
+	Cluster string `json:"cluster"`
+	// Kind is the resource GroupVersionKind
+	Kind         string `json:"kind"`
+	Namespace    string `json:"namespace"`
+	Name         string `json:"name"`
+	HealthStatus string `json:"healthStatus"`
+	Message      string `json:"message,omitempty"`
+	// Object is the full resource. Set only when --full is passed.
+	Object map[string]interface{} `json:"object,omitempty"`
+}
+
 func displayResources(ctx context.Context,
 	passedClusterNamespace, passedCluster, passedGroup, passedKind, passedNamespace string,
-	full bool, logger logr.Logger) error {
+	full bool, format output.Format, logger logr.Logger) error {
+
+	entries := make([]ResourceEntry, 0)
+	if err := displayResourcesInNamespaces(ctx, passedClusterNamespace, passedCluster,
+		passedGroup, passedKind, passedNamespace, full && !format.IsStructured(), format.IsStructured() && full,
+		&entries, logger); err != nil {
+		return err
+	}
+
+	if format.IsStructured() {
+		return output.PrintList(os.Stdout, format, "ResourceList", entries)
+	}
+
+	if full {
+		return nil
+	}
 
 	table := tablewriter.NewWriter(os.Stdout)
 	table.SetBorder(true)
 
-	if !full {
-		table.SetHeader([]string{"CLUSTER", "GVK", "NAMESPACE", "NAME", "MESSAGE"})
-		table.SetAutoMergeCellsByColumnIndex([]int{0, 1})
-		table.SetColumnColor(tablewriter.Colors{tablewriter.Bold, tablewriter.FgBlackColor},
-			tablewriter.Colors{tablewriter.Bold, tablewriter.FgBlackColor},
-			tablewriter.Colors{tablewriter.Bold, tablewriter.FgBlackColor},
-			tablewriter.Colors{tablewriter.Bold, tablewriter.FgBlackColor},
-			tablewriter.Colors{tablewriter.Bold, tablewriter.FgBlackColor})
+	header := []string{"CLUSTER", "GVK", "NAMESPACE", "NAME", "MESSAGE"}
+	if format.IsWide() {
+		header = append(header, "HEALTH")
 	}
-
-	if err := displayResourcesInNamespaces(ctx, passedClusterNamespace, passedCluster,
-		passedGroup, passedKind, passedNamespace, full, table, logger); err != nil {
-		return err
+	colors := make([]tablewriter.Colors, len(header))
+	for i := range colors {
+		colors[i] = tablewriter.Colors{tablewriter.Bold, tablewriter.FgBlackColor}
 	}
+	table.SetHeader(header)
+	table.SetAutoMergeCellsByColumnIndex([]int{0, 1})
+	table.SetColumnColor(colors...)
 
-	if !full {
-		table.Render()
+	for i := range entries {
+		displayResource(&entries[i], format, table)
 	}
 
+	table.Render()
+
 	return nil
 }
 
 func displayResourcesInNamespaces(ctx context.Context,
 	passedClusterNamespace, passedCluster, passedGroup, passedKind, passedNamespace string,
-	full bool, table *tablewriter.Table, logger logr.Logger) error {
+	print, includeObject bool, entries *[]ResourceEntry, logger logr.Logger) error {
 
 	instance := utils.GetAccessInstance()
 
@@ -98,7 +128,7 @@ func displayResourcesInNamespaces(ctx context.Context,
 		logger.V(logs.LogDebug).Info(fmt.Sprintf("Considering healthCheckReport: %s/%s",
 			hcr.Namespace, hcr.Name))
 		err = displayResourcesInReport(hcr, passedGroup, passedKind, passedNamespace,
-			full, table, logger)
+			print, includeObject, entries, logger)
 		if err != nil {
 			return err
 		}
@@ -107,9 +137,12 @@ func displayResourcesInNamespaces(ctx context.Context,
 	return nil
 }
 
+// displayResourcesInReport walks all resources in the HealthCheckReport. If print is set,
+// each resource is printed. Otherwise an entry is added for each resource (including the
+// full resource if includeObject is set).
 func displayResourcesInReport(healthCheckReport *libsveltosv1beta1.HealthCheckReport,
-	passedGroup, passedKind, passedNamespace string, full bool,
-	table *tablewriter.Table, logger logr.Logger) error {
+	passedGroup, passedKind, passedNamespace string, print, includeObject bool,
+	entries *[]ResourceEntry, logger logr.Logger) error {
 
 	logger = logger.WithValues("healtcheckreport", fmt.Sprintf("%s/%s",
 		healthCheckReport.Namespace, healthCheckReport.Name))
@@ -118,40 +151,56 @@ func displayResourcesInReport(healthCheckReport *libsveltosv1beta1.HealthCheckRe
 		resourceStatus := &healthCheckReport.Spec.ResourceStatuses[i]
 		if doConsiderResourceStatus(resourceStatus, passedGroup, passedKind, passedNamespace) {
 			logger.V(logs.LogDebug).Info("Considering resources in healthCheckReport")
-			if full {
+			if print {
 				err := printResource(resourceStatus, healthCheckReport.Spec.ClusterNamespace,
 					healthCheckReport.Spec.ClusterName, logger)
 				if err != nil {
 					return err
 				}
-			} else {
-				displayResource(resourceStatus, healthCheckReport.Spec.ClusterNamespace,
-					healthCheckReport.Spec.ClusterName, table)
+				continue
+			}
+
+			entry := ResourceEntry{
+				Cluster: fmt.Sprintf("%s/%s", healthCheckReport.Spec.ClusterNamespace,
+					healthCheckReport.Spec.ClusterName),
+				Kind:         resourceStatus.ObjectRef.GroupVersionKind().String(),
+				Namespace:    resourceStatus.ObjectRef.Namespace,
+				Name:         resourceStatus.ObjectRef.Name,
+				HealthStatus: string(resourceStatus.HealthStatus),
+				Message:      resourceStatus.Message,
 			}
+			if includeObject && resourceStatus.Resource != nil {
+				resource, err := k8s_utils.GetUnstructured(resourceStatus.Resource)
+				if err != nil {
+					return err
+				}
+				entry.Object = resource.UnstructuredContent()
+			}
+			*entries = append(*entries, entry)
 		}
 	}
 
 	return nil
 }
 
-func displayResource(resourceStatus *libsveltosv1beta1.ResourceStatus,
-	clusterNamespace, clusterName string, table *tablewriter.Table) {
-
-	clusterInfo := fmt.Sprintf("%s/%s", clusterNamespace, clusterName)
-	gvk := resourceStatus.ObjectRef.GroupVersionKind().String()
-	resourceNamespace := resourceStatus.ObjectRef.Namespace
-	resourceName := resourceStatus.ObjectRef.Name
-	message := resourceStatus.Message
+func displayResource(entry *ResourceEntry, format output.Format, table *tablewriter.Table) {
+	data := genResourceRow(entry.Cluster, entry.Kind, entry.Namespace, entry.Name, entry.Message)
+	if format.IsWide() {
+		data = append(data, entry.HealthStatus)
+	}
 
-	if resourceStatus.HealthStatus != libsveltosv1beta1.HealthStatusHealthy {
-		data := []string{clusterInfo, gvk, resourceNamespace, resourceName, message}
-		table.Rich(data, []tablewriter.Colors{{tablewriter.Bold, tablewriter.FgBlackColor},
+	if entry.HealthStatus != string(libsveltosv1beta1.HealthStatusHealthy) {
+		colors := []tablewriter.Colors{{tablewriter.Bold, tablewriter.FgBlackColor},
 			{tablewriter.Bold, tablewriter.FgBlackColor}, {tablewriter.Bold, tablewriter.BgRedColor},
-			{tablewriter.Bold, tablewriter.BgRedColor}, {tablewriter.Bold, tablewriter.FgBlackColor}})
+			{tablewriter.Bold, tablewriter.BgRedColor}, {tablewriter.Bold, tablewriter.FgBlackColor}}
+		if format.IsWide() {
+			colors = append(colors, tablewriter.Colors{tablewriter.Bold, tablewriter.BgRedColor})
+		}
+		table.Rich(data, colors)
 		return
 	}
 
-	table.Append(genResourceRow(clusterInfo, gvk, resourceNamespace, resourceName, message))
+	table.Append(data)
 }
 
 func printResource(resourceStatus *libsveltosv1beta1.ResourceStatus,
@@ -223,7 +272,7 @@ func doConsiderResourceStatus(resourceStatus *libsveltosv1beta1.ResourceStatus,
 func Resources(ctx context.Context, args []string, logger logr.Logger) error {
 	doc := `Usage:
   sveltosctl show resources [options] [--group=<group>] [--kind=<kind>] [--namespace=<namespace>] 
-  [--cluster-namespace=<name>] [--cluster=<name>] [--full] [--verbose]
+  [--cluster-namespace=<name>] [--cluster=<name>] [--full] [--output=<format>] [--verbose]
 
      --group=<group>              Show Kubernetes resources deployed in clusters matching this group.
                                   If not specified all groups are considered.
@@ -239,6 +288,8 @@ func Resources(ctx context.Context, args []string, logger logr.Logger) error {
 
 Options:
   -h --help                  Show this screen.
+  -o --output=<format>       Output format: table, wide, json or yaml. Defaults to global --output, or table.
+                             With json and yaml, --full adds the full resource to each entry.
      --verbose               Verbose mode. Print each step.  
 
 Description:
@@ -293,6 +344,11 @@ Description:
 		namespace = passedNamespace.(string)
 	}
 
+	format, err := parseOutputFormat(parsedArgs)
+	if err != nil {
+		return err
+	}
+
 	return displayResources(ctx, clusterNamespace, cluster,
-		group, kind, namespace, full, logger)
+		group, kind, namespace, full, format, logger)
 }
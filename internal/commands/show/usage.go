@@ -21,6 +21,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/docopt/docopt-go"
@@ -31,6 +32,7 @@ import (
 	configv1beta1 "github.com/projectsveltos/addon-controller/api/v1beta1"
 	libsveltosv1beta1 "github.com/projectsveltos/libsveltos/api/v1beta1"
 	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+	"github.com/projectsveltos/sveltosctl/internal/output"
 	"github.com/projectsveltos/sveltosctl/internal/utils"
 )
 
@@ -49,31 +51,61 @@ var (
 	}
 )
 
-func showUsage(ctx context.Context, kind, passedNamespace, passedName string, logger logr.Logger) error {
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"RESOURCE KIND", "RESOURCE NAMESPACE", "RESOURCE NAME", "CLUSTERS"})
+// UsageEntry lists the clusters where a resource is currently deployed.
+// It is the item type of show usage structured output.
+type UsageEntry struct {
+	Kind      string   `json:"kind"`
+	Namespace string   `json:"namespace,omitempty"`
+	Name      string   `json:"name"`
+	Clusters  []string `json:"clusters"`
+}
+
+func showUsage(ctx context.Context, kind, passedNamespace, passedName string, format output.Format,
+	logger logr.Logger) error {
+
+	entries := make([]UsageEntry, 0)
 
 	if kind == "" || kind == configv1beta1.ClusterProfileKind {
-		if err := showUsageForClusterProfiles(ctx, passedName, table, logger); err != nil {
+		if err := showUsageForClusterProfiles(ctx, passedName, &entries, logger); err != nil {
 			return err
 		}
 	}
 	if kind == "" || kind == configv1beta1.ProfileKind {
-		if err := showUsageForProfiles(ctx, passedName, table, logger); err != nil {
+		if err := showUsageForProfiles(ctx, passedName, &entries, logger); err != nil {
 			return err
 		}
 	}
 	if kind == "" || kind == string(libsveltosv1beta1.ConfigMapReferencedResourceKind) {
-		if err := showUsageForConfigMaps(ctx, passedNamespace, passedName, table, logger); err != nil {
+		if err := showUsageForConfigMaps(ctx, passedNamespace, passedName, &entries, logger); err != nil {
 			return err
 		}
 	}
 	if kind == "" || kind == string(libsveltosv1beta1.SecretReferencedResourceKind) {
-		if err := showUsageForSecrets(ctx, passedNamespace, passedName, table, logger); err != nil {
+		if err := showUsageForSecrets(ctx, passedNamespace, passedName, &entries, logger); err != nil {
 			return err
 		}
 	}
 
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Kind != entries[j].Kind {
+			return entries[i].Kind < entries[j].Kind
+		}
+		if entries[i].Namespace != entries[j].Namespace {
+			return entries[i].Namespace < entries[j].Namespace
+		}
+		return entries[i].Name < entries[j].Name
+	})
+
+	if format.IsStructured() {
+		return output.PrintList(os.Stdout, format, "UsageList", entries)
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"RESOURCE KIND", "RESOURCE NAMESPACE", "RESOURCE NAME", "CLUSTERS"})
+	for i := range entries {
+		e := &entries[i]
+		table.Append(genUsageRow(e.Kind, e.Namespace, e.Name, e.Clusters))
+	}
 	table.Render()
 
 	return nil
@@ -88,7 +120,7 @@ func getMatchingClusters(matchingClusterRefs []corev1.ObjectReference) []string
 	return clusters
 }
 
-func showUsageForClusterProfiles(ctx context.Context, passedName string, table *tablewriter.Table, logger logr.Logger) error {
+func showUsageForClusterProfiles(ctx context.Context, passedName string, entries *[]UsageEntry, logger logr.Logger) error {
 	instance := utils.GetAccessInstance()
 
 	cps, err := instance.ListClusterProfiles(ctx, logger)
@@ -99,24 +131,25 @@ func showUsageForClusterProfiles(ctx context.Context, passedName string, table *
 	for i := range cps.Items {
 		cp := &cps.Items[i]
 		if passedName == "" || cp.Name == passedName {
-			showUsageForClusterProfile(cp, table, logger)
+			showUsageForClusterProfile(cp, entries, logger)
 		}
 	}
 
 	return nil
 }
 
-func showUsageForClusterProfile(clusterProfile *configv1beta1.ClusterProfile, table *tablewriter.Table,
+func showUsageForClusterProfile(clusterProfile *configv1beta1.ClusterProfile, entries *[]UsageEntry,
 	logger logr.Logger) {
 
 	logger.V(logs.LogDebug).Info(fmt.Sprintf("Considering ClusterProfile %s", clusterProfile.Name))
 
 	clusters := getMatchingClusters(clusterProfile.Status.MatchingClusterRefs)
 
-	table.Append(genUsageRow(configv1beta1.ClusterProfileKind, "", clusterProfile.Name, clusters))
+	*entries = append(*entries, UsageEntry{Kind: configv1beta1.ClusterProfileKind,
+		Name: clusterProfile.Name, Clusters: clusters})
 }
 
-func showUsageForProfiles(ctx context.Context, passedName string, table *tablewriter.Table, logger logr.Logger) error {
+func showUsageForProfiles(ctx context.Context, passedName string, entries *[]UsageEntry, logger logr.Logger) error {
 	instance := utils.GetAccessInstance()
 
 	ps, err := instance.ListProfiles(ctx, logger)
@@ -127,25 +160,26 @@ func showUsageForProfiles(ctx context.Context, passedName string, table *tablewr
 	for i := range ps.Items {
 		p := &ps.Items[i]
 		if passedName == "" || p.Name == passedName {
-			showUsageForProfile(p, table, logger)
+			showUsageForProfile(p, entries, logger)
 		}
 	}
 
 	return nil
 }
 
-func showUsageForProfile(profile *configv1beta1.Profile, table *tablewriter.Table,
+func showUsageForProfile(profile *configv1beta1.Profile, entries *[]UsageEntry,
 	logger logr.Logger) {
 
 	logger.V(logs.LogDebug).Info(fmt.Sprintf("Considering Profile %s", profile.Name))
 
 	clusters := getMatchingClusters(profile.Status.MatchingClusterRefs)
 
-	table.Append(genUsageRow(configv1beta1.ProfileKind, "", profile.Name, clusters))
+	*entries = append(*entries, UsageEntry{Kind: configv1beta1.ProfileKind,
+		Name: profile.Name, Clusters: clusters})
 }
 
 func showUsageForConfigMaps(ctx context.Context, passedNamespace, passedName string,
-	table *tablewriter.Table, logger logr.Logger) error {
+	entries *[]UsageEntry, logger logr.Logger) error {
 
 	instance := utils.GetAccessInstance()
 	result := make(map[configv1beta1.PolicyRef][]string)
@@ -175,15 +209,15 @@ func showUsageForConfigMaps(ctx context.Context, passedNamespace, passedName str
 	}
 
 	for pr := range result {
-		table.Append(genUsageRow(string(libsveltosv1beta1.ConfigMapReferencedResourceKind),
-			pr.Namespace, pr.Name, result[pr]))
+		*entries = append(*entries, UsageEntry{Kind: string(libsveltosv1beta1.ConfigMapReferencedResourceKind),
+			Namespace: pr.Namespace, Name: pr.Name, Clusters: result[pr]})
 	}
 
 	return nil
 }
 
 func showUsageForSecrets(ctx context.Context, passedNamespace, passedName string,
-	table *tablewriter.Table, logger logr.Logger) error {
+	entries *[]UsageEntry, logger logr.Logger) error {
 
 	instance := utils.GetAccessInstance()
 	result := make(map[configv1beta1.PolicyRef][]string)
@@ -213,8 +247,8 @@ func showUsageForSecrets(ctx context.Context, passedNamespace, passedName string
 	}
 
 	for pr := range result {
-		table.Append(genUsageRow(string(libsveltosv1beta1.SecretReferencedResourceKind),
-			pr.Namespace, pr.Name, result[pr]))
+		*entries = append(*entries, UsageEntry{Kind: string(libsveltosv1beta1.SecretReferencedResourceKind),
+			Namespace: pr.Namespace, Name: pr.Name, Clusters: result[pr]})
 	}
 
 	return nil
@@ -291,7 +325,7 @@ func shouldAddPolicyRef(passedNamespace, passedName string, pr *configv1beta1.Po
 // Usage displays CAPI cluster where policies (ClusterProfiles and referenced ConfigMaps/Secrets) are deployed
 func Usage(ctx context.Context, args []string, logger logr.Logger) error {
 	doc := `Usage:
-  sveltosctl show usage [options] [--kind=<name>] [--namespace=<resourceNamespace>] [--name=<resourceName>] [--verbose]
+  sveltosctl show usage [options] [--kind=<name>] [--namespace=<resourceNamespace>] [--name=<resourceName>] [--output=<format>] [--verbose]
 
      --kind=<name>                    Show usage information for resources of this Kind only.
                                       If not specified, ClusterProfile/Profile and referenced ConfigMap and Secret are considered.
@@ -302,6 +336,7 @@ func Usage(ctx context.Context, args []string, logger logr.Logger) error {
 
 Options:
   -h --help                  Show this screen.
+  -o --output=<format>       Output format: table, wide, json or yaml. Defaults to global --output, or table.
      --verbose               Verbose mode. Print each step.  
 
 Description:
@@ -357,5 +392,10 @@ Description:
 		}
 	}
 
-	return showUsage(ctx, kind, namespace, name, logger)
+	format, err := parseOutputFormat(parsedArgs)
+	if err != nil {
+		return err
+	}
+
+	return showUsage(ctx, kind, namespace, name, format, logger)
 }
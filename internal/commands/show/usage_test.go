@@ -18,10 +18,13 @@ package show_test
 
 import (
 	"bytes"
+	"cmp"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"slices"
 	"strings"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -36,6 +39,7 @@ import (
 	configv1beta1 "github.com/projectsveltos/addon-controller/api/v1beta1"
 	libsveltosv1beta1 "github.com/projectsveltos/libsveltos/api/v1beta1"
 	"github.com/projectsveltos/sveltosctl/internal/commands/show"
+	"github.com/projectsveltos/sveltosctl/internal/output"
 	"github.com/projectsveltos/sveltosctl/internal/utils"
 )
 
@@ -79,7 +83,7 @@ var _ = Describe("Usage", func() {
 		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initObjects...).Build()
 
 		utils.InitalizeManagementClusterAcces(scheme, nil, nil, c)
-		err = show.ShowUsage(context.TODO(), "", "", "",
+		err = show.ShowUsage(context.TODO(), "", "", "", output.Table,
 			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
 		Expect(err).To(BeNil())
 
@@ -109,6 +113,52 @@ var _ = Describe("Usage", func() {
 			secret.Namespace, secret.Name, &clusterProfile2.Status.MatchingClusterRefs[0])
 		os.Stdout = old
 	})
+
+	It("showUsage lists resources sorted by kind, namespace and name", func() {
+		namespace := randomString()
+		policyRefs := make([]configv1beta1.PolicyRef, 0)
+		for i := 0; i < 5; i++ {
+			policyRefs = append(policyRefs,
+				configv1beta1.PolicyRef{Namespace: namespace, Name: randomString(),
+					Kind: string(libsveltosv1beta1.ConfigMapReferencedResourceKind)},
+				configv1beta1.PolicyRef{Namespace: randomString(), Name: randomString(),
+					Kind: string(libsveltosv1beta1.SecretReferencedResourceKind)})
+		}
+
+		clusterProfile1 := generateClusterProfile()
+		clusterProfile1.Spec.PolicyRefs = policyRefs
+		clusterProfile2 := generateClusterProfile()
+
+		old := os.Stdout // keep backup of the real stdout
+		r, w, _ := os.Pipe()
+		os.Stdout = w
+
+		initObjects := []client.Object{clusterProfile1, clusterProfile2}
+		scheme, err := utils.GetScheme()
+		Expect(err).To(BeNil())
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initObjects...).Build()
+
+		utils.InitalizeManagementClusterAcces(scheme, nil, nil, c)
+		err = show.ShowUsage(context.TODO(), "", "", "", output.JSON,
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
+		Expect(err).To(BeNil())
+
+		w.Close()
+		var buf bytes.Buffer
+		_, err = io.Copy(&buf, r)
+		Expect(err).To(BeNil())
+		os.Stdout = old
+
+		var list struct {
+			Items []show.UsageEntry `json:"items"`
+		}
+		Expect(json.Unmarshal(buf.Bytes(), &list)).To(Succeed())
+		Expect(list.Items).To(HaveLen(len(policyRefs) + 2))
+		Expect(slices.IsSortedFunc(list.Items, func(a, b show.UsageEntry) int {
+			return cmp.Or(cmp.Compare(a.Kind, b.Kind), cmp.Compare(a.Namespace, b.Namespace),
+				cmp.Compare(a.Name, b.Name))
+		})).To(BeTrue())
+	})
 })
 
 func verifyClusterProfileUsage(lines []string, clusterProfile *configv1beta1.ClusterProfile) {
@@ -20,8 +20,18 @@ import (
 	corev1 "k8s.io/api/core/v1"
 
 	configv1beta1 "github.com/projectsveltos/addon-controller/api/v1beta1"
+	"github.com/projectsveltos/sveltosctl/internal/output"
 )
 
+// parseOutputFormat returns the output format requested with --output. If not passed,
+// the format set with the global --output flag is returned.
+func parseOutputFormat(parsedArgs map[string]interface{}) (output.Format, error) {
+	if passedFormat := parsedArgs["--output"]; passedFormat != nil {
+		return output.ParseFormat(passedFormat.(string))
+	}
+	return output.DefaultFormat(), nil
+}
+
 func doConsiderNamespace(ns *corev1.Namespace, passedNamespace string) bool {
 	if passedNamespace == "" {
 		return true
@@ -36,8 +36,17 @@ func Snapshot(ctx context.Context, args []string, logger logr.Logger) error {
   sveltosctl snapshot [options] <subcommand> [<args>...]
 
     list          Displays all available collected snapshots.
-    diff          Displays diff between two collected snapshots.
+    diff          Displays diff between two collected snapshots, or a snapshot and the live configuration.
+    history       Displays the samples in which an object, or one of its fields, changed.
     rollback      Rollback to any previous configuration snapshot.
+    undo          Restores the configuration in place before the last rollback.
+    take          Collects a new snapshot immediately, outside the schedule.
+    rotate-key    Re-encrypts Secrets in all collected snapshots with the current key.
+    prune         Removes collected snapshots not kept by the retention policy.
+    annotate      Sets a note on a collected snapshot and pins/unpins it.
+    verify        Verifies a collected snapshot against its manifest.
+    export        Writes the objects of a collected snapshot to a local directory.
+    migrate       Moves collected snapshots to the deduplicated storage layout.
     reconciler    Starts a snapshot reconciler.
 
 Options:
@@ -79,9 +88,27 @@ See 'sveltosctl snapshot <subcommand> --help' to read about a specific subcomman
 		case "list":
 			err = snapshot.List(ctx, arguments, logger)
 		case "diff":
-			err = snapshot.Diff(ctx, arguments, logger)
+			err = snapshot.Diff(ctx, arguments, collectLiveSample, logger)
+		case "history":
+			err = snapshot.History(ctx, arguments, logger)
 		case "rollback":
-			err = snapshot.Rollback(ctx, arguments, logger)
+			err = snapshot.Rollback(ctx, arguments, takeSample, logger)
+		case "undo":
+			err = snapshot.Undo(ctx, arguments, takeSample, logger)
+		case "take":
+			err = snapshot.Take(ctx, arguments, logger)
+		case "rotate-key":
+			err = snapshot.RotateKey(ctx, arguments, logger)
+		case "prune":
+			err = snapshot.Prune(ctx, arguments, logger)
+		case "annotate":
+			err = snapshot.Annotate(ctx, arguments, logger)
+		case "verify":
+			err = snapshot.Verify(ctx, arguments, logger)
+		case "export":
+			err = snapshot.Export(ctx, arguments, logger)
+		case "migrate":
+			err = snapshot.Migrate(ctx, arguments, logger)
 		default:
 			//nolint: forbidigo // print doc
 			fmt.Println(doc)
@@ -0,0 +1,159 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/docopt/docopt-go"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2/textlogger"
+
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+	utilsv1beta1 "github.com/projectsveltos/sveltosctl/api/v1beta1"
+	"github.com/projectsveltos/sveltosctl/internal/collector"
+	"github.com/projectsveltos/sveltosctl/internal/utils"
+)
+
+// annotateSample sets note and pin state of sample, a sample of Snapshot snapshotName.
+// A nil note or pinned leaves the current value unchanged.
+func annotateSample(ctx context.Context, snapshotName, sample string, note *string, pinned *bool,
+	logger logr.Logger) (*collector.SampleAnnotations, error) {
+
+	logger.V(logs.LogDebug).Info(fmt.Sprintf("Getting Snapshot %s", snapshotName))
+
+	snapshotInstance := &utilsv1beta1.Snapshot{}
+	err := utils.GetAccessInstance().GetResource(ctx, types.NamespacedName{Name: snapshotName}, snapshotInstance)
+	if err != nil {
+		return nil, err
+	}
+
+	storage, err := getSnapshotStorage(ctx, snapshotInstance)
+	if err != nil {
+		return nil, err
+	}
+
+	collectorClient := collector.GetClient()
+	artifactFolder, err := collectorClient.GetFolder(storage, snapshotName, collector.Snapshot, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	folder := path.Join(*artifactFolder, sample)
+	exist, err := storage.Exists(folder)
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, fmt.Errorf("sample %s not found for snapshot %s", sample, snapshotName)
+	}
+
+	annotations, err := collectorClient.GetSampleAnnotations(storage, folder)
+	if err != nil {
+		return nil, err
+	}
+
+	if note != nil {
+		annotations.Note = *note
+	}
+	if pinned != nil {
+		annotations.Pinned = *pinned
+	}
+
+	logger.V(logs.LogDebug).Info(fmt.Sprintf("Updating annotations of sample %s", sample))
+	return annotations, collectorClient.WriteSampleAnnotations(storage, folder, annotations)
+}
+
+// Annotate sets a note on a sample and pins/unpins it
+func Annotate(ctx context.Context, args []string, logger logr.Logger) error {
+	doc := `Usage:
+	sveltosctl snapshot annotate [options] --snapshot=<name> --sample=<name> [--note=<text>] [--pin | --unpin] [--verbose]
+
+     --snapshot=<name>      Name of the Snapshot instance
+     --sample=<name>        Name of the sample to annotate
+     --note=<text>          Free text describing the sample. An empty text removes the note.
+
+Options:
+  -h --help                  Show this screen.
+     --pin                   Pins the sample. Pinned samples are never removed by retention.
+     --unpin                 Unpins the sample.
+     --verbose               Verbose mode. Print each step.
+
+Description:
+  The snapshot annotate command stores a note and/or the pin state in the sample annotations.
+  Both are displayed by snapshot list. Annotations are not part of the sample manifest.
+`
+
+	parsedArgs, err := docopt.ParseArgs(doc, nil, "1.0")
+	if err != nil {
+		logger.V(logs.LogDebug).Error(err, "failed to parse args")
+		return fmt.Errorf(
+			"invalid option: 'sveltosctl %s'. Use flag '--help' to read about a specific subcommand. Error: %w",
+			strings.Join(args, " "),
+			err,
+		)
+	}
+
+	_ = flag.Lookup("v").Value.Set(fmt.Sprint(logs.LogInfo))
+	verbose := parsedArgs["--verbose"].(bool)
+	if verbose {
+		err = flag.Lookup("v").Value.Set(fmt.Sprint(logs.LogDebug))
+		if err != nil {
+			return err
+		}
+	}
+
+	logger = textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1)))
+
+	snapshostName := parsedArgs["--snapshot"].(string)
+	sample := parsedArgs["--sample"].(string)
+
+	var note *string
+	if passedNote := parsedArgs["--note"]; passedNote != nil {
+		n := passedNote.(string)
+		note = &n
+	}
+
+	var pinned *bool
+	if parsedArgs["--pin"].(bool) {
+		pin := true
+		pinned = &pin
+	} else if parsedArgs["--unpin"].(bool) {
+		pin := false
+		pinned = &pin
+	}
+
+	if note == nil && pinned == nil {
+		return errors.New("at least one of --note, --pin and --unpin must be set")
+	}
+
+	annotations, err := annotateSample(ctx, snapshostName, sample, note, pinned, logger)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stdout, "Sample %s of snapshot %s annotated (pinned: %t, note: %q)\n",
+		sample, snapshostName, annotations.Pinned, annotations.Note)
+	return nil
+}
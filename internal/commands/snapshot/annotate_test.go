@@ -0,0 +1,122 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/klog/v2/textlogger"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	utilsv1beta1 "github.com/projectsveltos/sveltosctl/api/v1beta1"
+	"github.com/projectsveltos/sveltosctl/internal/commands/snapshot"
+	"github.com/projectsveltos/sveltosctl/internal/utils"
+)
+
+var _ = Describe("Snapshot Annotate", func() {
+	var snapshotInstance *utilsv1beta1.Snapshot
+	var samples []string
+
+	BeforeEach(func() {
+		snapshotInstance, samples = createSnapshotWithSamples(24*time.Hour, 0)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(snapshotInstance.Spec.Storage)
+	})
+
+	It("annotateSample stores note and pin state, honored by retention and displayed by list", func() {
+		initObjects := []client.Object{snapshotInstance}
+		scheme, err := utils.GetScheme()
+		Expect(err).To(BeNil())
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initObjects...).Build()
+		utils.InitalizeManagementClusterAcces(scheme, nil, nil, c)
+
+		logger := textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1)))
+
+		note := "before " + randomString() + " upgrade"
+		pinned := true
+		annotations, err := snapshot.AnnotateSample(context.TODO(), snapshotInstance.Name, samples[0], &note, &pinned, logger)
+		Expect(err).To(BeNil())
+		Expect(annotations.Note).To(Equal(note))
+		Expect(annotations.Pinned).To(BeTrue())
+		// Sample metadata, listed in the sample manifest, is left untouched
+		_, err = os.Stat(path.Join(snapshotInstance.Spec.Storage, "snapshot", snapshotInstance.Name, samples[0],
+			"sample-metadata.yaml"))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+
+		// Pinned sample is not removed by retention
+		pruned, err := snapshot.PruneSamples(context.TODO(), snapshotInstance.Name, true, logger)
+		Expect(err).To(BeNil())
+		Expect(pruned).To(BeEmpty())
+
+		old := os.Stdout
+		r, w, _ := os.Pipe()
+		os.Stdout = w
+		err = snapshot.ListSnapshots(context.TODO(), snapshotInstance.Name, logger)
+		w.Close()
+		os.Stdout = old
+		Expect(err).To(BeNil())
+		var buf bytes.Buffer
+		_, err = io.Copy(&buf, r)
+		Expect(err).To(BeNil())
+
+		found := false
+		for _, line := range strings.Split(buf.String(), "\n") {
+			if strings.Contains(line, samples[0]) {
+				Expect(line).To(ContainSubstring(note))
+				Expect(line).To(ContainSubstring("yes"))
+				found = true
+			}
+		}
+		Expect(found).To(BeTrue())
+
+		// Note is kept when only pin state changes
+		pinned = false
+		annotations, err = snapshot.AnnotateSample(context.TODO(), snapshotInstance.Name, samples[0], nil, &pinned, logger)
+		Expect(err).To(BeNil())
+		Expect(annotations.Note).To(Equal(note))
+		Expect(annotations.Pinned).To(BeFalse())
+
+		pruned, err = snapshot.PruneSamples(context.TODO(), snapshotInstance.Name, true, logger)
+		Expect(err).To(BeNil())
+		Expect(pruned).To(ConsistOf(samples[0]))
+	})
+
+	It("annotateSample fails when sample does not exist", func() {
+		initObjects := []client.Object{snapshotInstance}
+		scheme, err := utils.GetScheme()
+		Expect(err).To(BeNil())
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initObjects...).Build()
+		utils.InitalizeManagementClusterAcces(scheme, nil, nil, c)
+
+		note := randomString()
+		_, err = snapshot.AnnotateSample(context.TODO(), snapshotInstance.Name, randomString(), &note, nil,
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
+		Expect(err).ToNot(BeNil())
+	})
+})
@@ -22,7 +22,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
-	"path/filepath"
+	"path"
 	"reflect"
 	"strings"
 
@@ -43,6 +43,7 @@ import (
 	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
 	utilsv1beta1 "github.com/projectsveltos/sveltosctl/api/v1beta1"
 	"github.com/projectsveltos/sveltosctl/internal/collector"
+	"github.com/projectsveltos/sveltosctl/internal/output"
 	"github.com/projectsveltos/sveltosctl/internal/utils"
 )
 
@@ -71,6 +72,22 @@ var (
 	}
 )
 
+// DiffOptions contains the options of snapshot diff
+type DiffOptions struct {
+	// Namespace restricts differences to clusters and namespaced objects in this namespace
+	Namespace string
+	// Cluster restricts deployed features differences to clusters with this name
+	Cluster string
+	// Kinds, when not empty, restricts differences to these kinds
+	Kinds []string
+	// RawDiff, when set, displays the content diff of resources instead of tables
+	RawDiff bool
+	// Format is the output format: table, json, yaml or patch
+	Format output.Format
+	// IgnoreRules contains the fields which are not compared. If nil, only built-in rules apply.
+	IgnoreRules *IgnoreRules
+}
+
 func doConsiderNamespace(currentNamespace, passedNamespace string) bool {
 	if passedNamespace == "" {
 		return true
@@ -91,8 +108,8 @@ func doConsiderCluster(currentCluster, passedCluster string) bool {
 // differences include:
 // - list of helm chart (configured, upgraded, removed)
 // - list of kubernetes resources (configured, upgraded, removed)
-func listSnapshotDiffs(ctx context.Context, snapshotName, fromSample, toSample,
-	passedNamespace, passedCluster string, rawDiff bool,
+// - field level changes of every collected object
+func listSnapshotDiffs(ctx context.Context, snapshotName, fromSample, toSample string, options *DiffOptions,
 	logger logr.Logger) error {
 
 	logger.V(logs.LogDebug).Info(fmt.Sprintf("finding diff between %s and %s", fromSample, toSample))
@@ -105,59 +122,324 @@ func listSnapshotDiffs(ctx context.Context, snapshotName, fromSample, toSample,
 		return err
 	}
 
+	storage, err := getSnapshotStorage(ctx, snapshotInstance)
+	if err != nil {
+		return err
+	}
+
 	snapshotClient := collector.GetClient()
-	artifactFolder, err := snapshotClient.GetFolder(snapshotInstance.Spec.Storage, snapshotInstance.Name,
+	artifactFolder, err := snapshotClient.GetFolder(storage, snapshotInstance.Name,
 		collector.Snapshot, logger)
 	if err != nil {
 		return err
 	}
 
-	fromFolder := filepath.Join(*artifactFolder, fromSample)
+	fromFolder := path.Join(*artifactFolder, fromSample)
 	// Get the two directories containing the collected snaphosts
-	_, err = os.Stat(fromFolder)
-	if os.IsNotExist(err) {
-		logger.V(logs.LogDebug).Info(fmt.Sprintf("Folder %s does not exist for snapshot instance: %s",
-			fromSample, snapshotName))
+	err = verifySampleExists(storage, fromFolder, snapshotName, logger)
+	if err != nil {
 		return err
 	}
 
-	toFolder := filepath.Join(*artifactFolder, toSample)
+	toFolder := path.Join(*artifactFolder, toSample)
 	// Get the two directories containing the collected snaphosts
-	_, err = os.Stat(toFolder)
-	if os.IsNotExist(err) {
-		logger.V(logs.LogDebug).Info(fmt.Sprintf("Folder %s does not exist for snapshot instance: %s",
-			toSample, snapshotName))
+	err = verifySampleExists(storage, toFolder, snapshotName, logger)
+	if err != nil {
 		return err
 	}
 
-	err = listClusterResourcesDiff(fromFolder, toFolder, passedNamespace, passedCluster, rawDiff, logger)
+	return listFolderDiffs(storage, fromFolder, toFolder, options, logger)
+}
+
+// listFolderDiffs lists all differences between the samples stored in fromFolder and toFolder
+// in the format requested by options
+func listFolderDiffs(storage collector.Storage, fromFolder, toFolder string, options *DiffOptions,
+	logger logr.Logger) error {
+
+	scopes, err := getSampleScopes(storage, fromFolder, toFolder)
+	if err != nil {
+		return err
+	}
+
+	switch options.Format {
+	case output.JSON, output.YAML:
+		changes, err := getSampleChanges(storage, fromFolder, toFolder, options, scopes, logger)
+		if err != nil {
+			return err
+		}
+		return output.PrintList(os.Stdout, options.Format, "SnapshotDiffList", changes)
+	case output.Patch:
+		return writeSamplePatch(os.Stdout, storage, fromFolder, toFolder, options, scopes, logger)
+	default:
+		return listFolderDiffsInTables(storage, fromFolder, toFolder, options, scopes, logger)
+	}
+}
+
+// listFolderDiffsInTables displays the differences between the samples stored in fromFolder and toFolder
+// as tables (or, with RawDiff, as textual diffs)
+func listFolderDiffsInTables(storage collector.Storage, fromFolder, toFolder string, options *DiffOptions,
+	scopes []*collector.SampleScope, logger logr.Logger) error {
+
+	if isKindSelected(options.Kinds, configv1beta1.ClusterConfigurationKind) {
+		err := listClusterResourcesDiff(storage, fromFolder, toFolder, options.Namespace, options.Cluster, scopes,
+			options.RawDiff, logger)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Classifiers, RoleRequests and Secrets are reported along with every other object below.
+	// Only their textual diffs are displayed here.
+	if options.RawDiff {
+		for _, kind := range []string{libsveltosv1beta1.ClassifierKind, libsveltosv1beta1.RoleRequestKind} {
+			if isKindSelected(options.Kinds, kind) {
+				err := listDiff(storage, fromFolder, toFolder, kind, scopes, options.IgnoreRules, true, logger)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		if isKindSelected(options.Kinds, string(libsveltosv1beta1.SecretReferencedResourceKind)) {
+			err := listSecretDiff(storage, fromFolder, toFolder, options.Namespace, scopes, true, logger)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	objectDiffs, err := getObjectDiffs(storage, fromFolder, toFolder, options, scopes, logger)
 	if err != nil {
 		return err
 	}
 
-	err = listDiff(fromFolder, toFolder, libsveltosv1beta1.ClassifierKind, rawDiff, logger)
+	if len(objectDiffs) > 0 {
+		printObjectDiffs(os.Stdout, objectDiffs)
+	} else {
+		//nolint: forbidigo // indicating no diff
+		fmt.Println("no changes detected in objects")
+	}
+
+	return nil
+}
+
+// getSampleScopes returns the scopes of the samples stored in folders
+func getSampleScopes(storage collector.Storage, folders ...string) ([]*collector.SampleScope, error) {
+	scopes := make([]*collector.SampleScope, len(folders))
+	for i := range folders {
+		metadata, err := collector.GetClient().GetSampleMetadata(storage, folders[i])
+		if err != nil {
+			return nil, err
+		}
+		scopes[i] = metadata.Scope
+	}
+
+	return scopes, nil
+}
+
+// isKindInScopes returns true if kind is collected in all samples.
+// Otherwise, instances of kind cannot be compared.
+func isKindInScopes(scopes []*collector.SampleScope, kind string) bool {
+	for i := range scopes {
+		if !scopes[i].IsKindInScope(kind) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isObjectInScopes returns true if object, an instance of kind, is in scope of all samples.
+// An object out of scope of a sample is absent on purpose and must be reported
+// neither as added nor as removed.
+func isObjectInScopes(scopes []*collector.SampleScope, kind string, object *unstructured.Unstructured,
+) (bool, error) {
+
+	for i := range scopes {
+		inScope, err := scopes[i].IsObjectInScope(kind, object)
+		if err != nil || !inScope {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// filterObjectsInScopes removes from objects the instances of kind not in scope of all samples
+func filterObjectsInScopes(scopes []*collector.SampleScope, kind string,
+	objects map[string]*unstructured.Unstructured) error {
+
+	for name := range objects {
+		inScope, err := isObjectInScopes(scopes, kind, objects[name])
+		if err != nil {
+			return err
+		}
+		if !inScope {
+			delete(objects, name)
+		}
+	}
+
+	return nil
+}
+
+// isReferencedObjectInScopes returns true if object, a referenced resource of kind, is in
+// scope of all samples
+func isReferencedObjectInScopes(scopes []*collector.SampleScope, kind string, object *unstructured.Unstructured,
+) bool {
+
+	for i := range scopes {
+		if !scopes[i].IsReferencedObjectInScope(kind, object) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isNamespaceInScopes returns true if namespace is in scope of all samples
+func isNamespaceInScopes(scopes []*collector.SampleScope, namespace string) bool {
+	for i := range scopes {
+		if !scopes[i].IsNamespaceInScope(namespace) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func printKindNotInScopes(kind string) {
+	//nolint: forbidigo // indicating kind cannot be compared
+	fmt.Printf("%s not collected in both samples. Skipping.\n", kind)
+}
+
+// listSecretDiff lists Secrets added, removed or modified between two samples.
+// Secrets are compared by digests, so Secrets stored hashed are compared as well and
+// Secret content is never displayed.
+func listSecretDiff(storage collector.Storage, fromFolder, toFolder, passedNamespace string,
+	scopes []*collector.SampleScope, rawDiff bool, logger logr.Logger) error {
+
+	secretKind := string(libsveltosv1beta1.SecretReferencedResourceKind)
+	for i := range scopes {
+		if !scopes[i].IsReferencedKindInScope(secretKind) {
+			printKindNotInScopes(secretKind)
+			return nil
+		}
+	}
+
+	fromMap, err := getSecretDigestsInSample(storage, fromFolder, passedNamespace, scopes, logger)
 	if err != nil {
 		return err
 	}
 
-	err = listDiff(fromFolder, toFolder, libsveltosv1beta1.RoleRequestKind, rawDiff, logger)
+	toMap, err := getSecretDigestsInSample(storage, toFolder, passedNamespace, scopes, logger)
 	if err != nil {
 		return err
 	}
 
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"SECRET", "ACTION"})
+
+	for name := range toMap {
+		fromDigests, ok := fromMap[name]
+		if !ok {
+			table.Append(genDiffRow(name, "added"))
+			continue
+		}
+		if reflect.DeepEqual(fromDigests, toMap[name]) {
+			continue
+		}
+		table.Append(genDiffRow(name, "modified"))
+		if rawDiff {
+			err = showSecretDigestDiff(name, fromDigests, toMap[name])
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for name := range fromMap {
+		if _, ok := toMap[name]; !ok {
+			table.Append(genDiffRow(name, "removed"))
+		}
+	}
+
+	if !rawDiff {
+		if table.NumLines() > 0 {
+			table.Render()
+		}
+	}
+
 	return nil
 }
 
-func listDiff(fromFolder, toFolder, kind string, rawDiff bool, logger logr.Logger) error {
+// getSecretDigestsInSample returns, for each Secret (namespace/name) in the sample stored in
+// folder, the digests of its Data
+func getSecretDigestsInSample(storage collector.Storage, folder, passedNamespace string,
+	scopes []*collector.SampleScope, logger logr.Logger) (map[string]map[string]string, error) {
+
+	secretKind := string(libsveltosv1beta1.SecretReferencedResourceKind)
+	secretMap, err := collector.GetClient().GetNamespacedResources(storage, folder, secretKind, logger)
+	if err != nil {
+		logger.V(logs.LogDebug).Info(fmt.Sprintf("failed to collect Secrets from folder %s", folder))
+		return nil, err
+	}
+
+	result := make(map[string]map[string]string)
+	for ns := range secretMap {
+		if !doConsiderNamespace(ns, passedNamespace) {
+			continue
+		}
+		for i := range secretMap[ns] {
+			secret := secretMap[ns][i]
+			if !isReferencedObjectInScopes(scopes, secretKind, secret) {
+				continue
+			}
+			digests, err := collector.GetSecretDigests(secret)
+			if err != nil {
+				return nil, err
+			}
+			result[fmt.Sprintf("%s/%s", secret.GetNamespace(), secret.GetName())] = digests
+		}
+	}
+
+	return result, nil
+}
+
+func showSecretDigestDiff(name string, from, to map[string]string) error {
+	fromJSON, err := json.MarshalIndent(from, "", "  ")
+	if err != nil {
+		return err
+	}
+	toJSON, err := json.MarshalIndent(to, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	objectInfo := fmt.Sprintf("Secret %s", name)
+	edits := myers.ComputeEdits(span.URIFromPath(objectInfo), string(fromJSON), string(toJSON))
+	//nolint: forbidigo // print diff
+	fmt.Println(fmt.Sprint(gotextdiff.ToUnified(objectInfo, objectInfo, string(fromJSON), edits)))
+
+	return nil
+}
+
+func listDiff(storage collector.Storage, fromFolder, toFolder, kind string, scopes []*collector.SampleScope,
+	rules *IgnoreRules, rawDiff bool, logger logr.Logger) error {
+
+	if !isKindInScopes(scopes, kind) {
+		printKindNotInScopes(kind)
+		return nil
+	}
+
 	snapshotClient := collector.GetClient()
-	froms, err := snapshotClient.GetClusterResources(fromFolder, kind, logger)
+	froms, err := snapshotClient.GetClusterResources(storage, fromFolder, kind, logger)
 	if err != nil {
 		logger.V(logs.LogDebug).Info(fmt.Sprintf("failed to collect %ss from folder %s", kind, fromFolder))
 		return err
 	}
 	logger.V(logs.LogDebug).Info(fmt.Sprintf("found %d %ss in folder %s", len(froms), kind, fromFolder))
 
-	tos, err := snapshotClient.GetClusterResources(toFolder, kind, logger)
+	tos, err := snapshotClient.GetClusterResources(storage, toFolder, kind, logger)
 	if err != nil {
 		logger.V(logs.LogDebug).Info(fmt.Sprintf("failed to collect %ss from folder %s", kind, toFolder))
 		return err
@@ -176,7 +458,14 @@ func listDiff(fromFolder, toFolder, kind string, rawDiff bool, logger logr.Logge
 		toMap[cl.GetName()] = cl
 	}
 
-	err = showDiff(fromMap, toMap, kind, rawDiff, logger)
+	if err := filterObjectsInScopes(scopes, kind, fromMap); err != nil {
+		return err
+	}
+	if err := filterObjectsInScopes(scopes, kind, toMap); err != nil {
+		return err
+	}
+
+	err = showDiff(fromMap, toMap, kind, rules, rawDiff, logger)
 	if err != nil {
 		return err
 	}
@@ -184,8 +473,8 @@ func listDiff(fromFolder, toFolder, kind string, rawDiff bool, logger logr.Logge
 	return nil
 }
 
-func showDiff(fromMap, toMap map[string]*unstructured.Unstructured, kind string, rawDiff bool,
-	logger logr.Logger) error {
+func showDiff(fromMap, toMap map[string]*unstructured.Unstructured, kind string, rules *IgnoreRules,
+	rawDiff bool, logger logr.Logger) error {
 
 	table := tablewriter.NewWriter(os.Stdout)
 	table.SetHeader([]string{strings.ToUpper(kind), "ACTION"})
@@ -196,7 +485,7 @@ func showDiff(fromMap, toMap map[string]*unstructured.Unstructured, kind string,
 			table.Append(genDiffRow(clName, "added"))
 			continue
 		}
-		hasDiff, err := hasResourceDiff(v, toMap[clName], rawDiff, logger)
+		hasDiff, err := hasResourceDiff(v, toMap[clName], rules, rawDiff, logger)
 		if err != nil {
 			return err
 		}
@@ -221,14 +510,19 @@ func showDiff(fromMap, toMap map[string]*unstructured.Unstructured, kind string,
 	return nil
 }
 
-func listClusterResourcesDiff(fromFolder, toFolder, passedNamespace, passedCluster string, rawDiff bool,
-	logger logr.Logger) error {
+func listClusterResourcesDiff(storage collector.Storage, fromFolder, toFolder, passedNamespace, passedCluster string,
+	scopes []*collector.SampleScope, rawDiff bool, logger logr.Logger) error {
+
+	if !isKindInScopes(scopes, configv1beta1.ClusterConfigurationKind) {
+		printKindNotInScopes(configv1beta1.ClusterConfigurationKind)
+		return nil
+	}
 
 	table := tablewriter.NewWriter(os.Stdout)
 	table.SetHeader([]string{"CLUSTER", "RESOURCE TYPE", "NAMESPACE", "NAME", "ACTION", "MESSAGE"})
 
-	err := listSnapshotDiffsBewteenSamples(fromFolder, toFolder, passedNamespace, passedCluster, rawDiff,
-		table, logger)
+	err := listSnapshotDiffsBewteenSamples(storage, fromFolder, toFolder, passedNamespace, passedCluster, scopes,
+		rawDiff, table, logger)
 	if err != nil {
 		return err
 	}
@@ -245,47 +539,73 @@ func listClusterResourcesDiff(fromFolder, toFolder, passedNamespace, passedClust
 	return nil
 }
 
-func listSnapshotDiffsBewteenSamples(fromFolder, toFolder, passedNamespace, passedCluster string,
-	rawDiff bool, table *tablewriter.Table, logger logr.Logger) error {
+func listSnapshotDiffsBewteenSamples(storage collector.Storage, fromFolder, toFolder, passedNamespace, passedCluster string,
+	scopes []*collector.SampleScope, rawDiff bool, table *tablewriter.Table, logger logr.Logger) error {
+
+	fromClusterConfigurationMap, toClusterConfigurationMap, err := getClusterConfigurationMaps(storage,
+		fromFolder, toFolder, scopes, logger)
+	if err != nil {
+		return err
+	}
+
+	err = listFeatureDiff(storage, fromFolder, toFolder, fromClusterConfigurationMap, toClusterConfigurationMap,
+		passedNamespace, passedCluster, rawDiff, table, logger)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// getClusterConfigurationMaps returns, per namespace, the ClusterConfigurations stored in the samples in
+// fromFolder and toFolder. ClusterConfigurations in namespaces out of scope of any sample are not returned.
+func getClusterConfigurationMaps(storage collector.Storage, fromFolder, toFolder string,
+	scopes []*collector.SampleScope, logger logr.Logger) (fromClusterConfigurationMap,
+	toClusterConfigurationMap map[string][]*unstructured.Unstructured, err error) {
 
 	// Following maps contain per Cluster corresponding ClusterConfiguration at the time snapshot was taken
 	// There is one ClusterConfigurations per Cluster
 	snapshotClient := collector.GetClient()
-	fromClusterConfigurationMap, err := snapshotClient.GetNamespacedResources(fromFolder,
+	fromClusterConfigurationMap, err = snapshotClient.GetNamespacedResources(storage, fromFolder,
 		configv1beta1.ClusterConfigurationKind, logger)
 	if err != nil {
 		logger.V(logs.LogDebug).Info(fmt.Sprintf("failed to collect ClusterConfigurations from folder %s", fromFolder))
-		return err
+		return nil, nil, err
 	}
 	logger.V(logs.LogDebug).Info(fmt.Sprintf("found %d namespaces with at least one ClusterConfiguration in folder %s",
 		len(fromClusterConfigurationMap), fromFolder))
 
-	toClusterConfigurationMap, err := snapshotClient.GetNamespacedResources(toFolder,
+	toClusterConfigurationMap, err = snapshotClient.GetNamespacedResources(storage, toFolder,
 		configv1beta1.ClusterConfigurationKind, logger)
 	if err != nil {
 		logger.V(logs.LogDebug).Info(fmt.Sprintf("failed to collect ClusterConfigurations from folder %s", toFolder))
-		return err
+		return nil, nil, err
 	}
 	logger.V(logs.LogDebug).Info(fmt.Sprintf("found %d namespaces with at least one ClusterConfiguration in folder %s",
 		len(toClusterConfigurationMap), fromFolder))
 
-	err = listFeatureDiff(fromFolder, toFolder, fromClusterConfigurationMap, toClusterConfigurationMap,
-		passedNamespace, passedCluster, rawDiff, table, logger)
-	if err != nil {
-		return err
+	// ClusterConfigurations in namespaces out of scope of any sample cannot be compared
+	for _, clusterConfigurationMap := range []map[string][]*unstructured.Unstructured{
+		fromClusterConfigurationMap, toClusterConfigurationMap} {
+
+		for ns := range clusterConfigurationMap {
+			if !isNamespaceInScopes(scopes, ns) {
+				delete(clusterConfigurationMap, ns)
+			}
+		}
 	}
 
-	return nil
+	return fromClusterConfigurationMap, toClusterConfigurationMap, nil
 }
 
-func listFeatureDiff(fromFolder, toFolder string,
+func listFeatureDiff(storage collector.Storage, fromFolder, toFolder string,
 	fromClusterConfigurationMap, toClusterConfigurationMap map[string][]*unstructured.Unstructured,
 	passedNamespace, passedCluster string, rawDiff bool, table *tablewriter.Table, logger logr.Logger) error {
 
 	for k := range toClusterConfigurationMap {
 		if doConsiderNamespace(k, passedNamespace) {
 			logger.V(logs.LogDebug).Info(fmt.Sprintf("finding feature diff for clusters in namespace %s", k))
-			err := listFeatureDiffInNamespace(fromFolder, toFolder, k, fromClusterConfigurationMap, toClusterConfigurationMap,
+			err := listFeatureDiffInNamespace(storage, fromFolder, toFolder, k, fromClusterConfigurationMap, toClusterConfigurationMap,
 				passedCluster, rawDiff, table, logger)
 			if err != nil {
 				return err
@@ -299,7 +619,7 @@ func listFeatureDiff(fromFolder, toFolder string,
 			if _, ok := toClusterConfigurationMap[k]; ok {
 				continue
 			}
-			err := listFeatureDiffInNamespace(fromFolder, toFolder, k, fromClusterConfigurationMap, toClusterConfigurationMap,
+			err := listFeatureDiffInNamespace(storage, fromFolder, toFolder, k, fromClusterConfigurationMap, toClusterConfigurationMap,
 				passedCluster, rawDiff, table, logger)
 			if err != nil {
 				return err
@@ -310,7 +630,7 @@ func listFeatureDiff(fromFolder, toFolder string,
 	return nil
 }
 
-func listFeatureDiffInNamespace(fromFolder, toFolder, namespace string,
+func listFeatureDiffInNamespace(storage collector.Storage, fromFolder, toFolder, namespace string,
 	fromClusterConfigurationMap, toClusterConfigurationMap map[string][]*unstructured.Unstructured,
 	passedCluster string, rawDiff bool, table *tablewriter.Table, logger logr.Logger) error {
 
@@ -329,7 +649,7 @@ func listFeatureDiffInNamespace(fromFolder, toFolder, namespace string,
 	logger.V(logs.LogDebug).Info(fmt.Sprintf("got %d ClusterConfigurations in namespace %s in from folder", len(fromClusterConfigurations), namespace))
 
 	logger.V(logs.LogDebug).Info(fmt.Sprintf("finding diff for ClusterConfigurations in namespace %s", namespace))
-	err = listDiffInClusterConfigurations(fromFolder, toFolder, fromClusterConfigurations, toClusterConfigurations, rawDiff, table, logger)
+	err = listDiffInClusterConfigurations(storage, fromFolder, toFolder, fromClusterConfigurations, toClusterConfigurations, rawDiff, table, logger)
 	if err != nil {
 		return err
 	}
@@ -362,7 +682,7 @@ func getClusterConfigurationsInNamespace(namespace, passedCluster string, cluste
 	return results, nil
 }
 
-func listDiffInClusterConfigurations(fromFolder, toFolder string, fromClusterConfigurations, toClusterConfigurations []*configv1beta1.ClusterConfiguration,
+func listDiffInClusterConfigurations(storage collector.Storage, fromFolder, toFolder string, fromClusterConfigurations, toClusterConfigurations []*configv1beta1.ClusterConfiguration,
 	rawDiff bool, table *tablewriter.Table, logger logr.Logger) error {
 
 	// Create maps
@@ -379,7 +699,7 @@ func listDiffInClusterConfigurations(fromFolder, toFolder string, fromClusterCon
 	}
 
 	for to := range toClusterConfigurationMaps {
-		if err := listClusterConfigurationDiff(fromFolder, toFolder, fromClusterConfigurationMaps[to],
+		if err := listClusterConfigurationDiff(storage, fromFolder, toFolder, fromClusterConfigurationMaps[to],
 			toClusterConfigurationMaps[to], rawDiff, table, logger); err != nil {
 			return err
 		}
@@ -387,7 +707,7 @@ func listDiffInClusterConfigurations(fromFolder, toFolder string, fromClusterCon
 
 	for from := range fromClusterConfigurationMaps {
 		if _, ok := toClusterConfigurationMaps[from]; !ok {
-			if err := listClusterConfigurationDiff(fromFolder, toFolder, fromClusterConfigurationMaps[from],
+			if err := listClusterConfigurationDiff(storage, fromFolder, toFolder, fromClusterConfigurationMaps[from],
 				toClusterConfigurationMaps[from], rawDiff, table, logger); err != nil {
 				return err
 			}
@@ -397,40 +717,17 @@ func listDiffInClusterConfigurations(fromFolder, toFolder string, fromClusterCon
 	return nil
 }
 
-func listClusterConfigurationDiff(fromFolder, toFolder string, fromClusterConfiguration, toClusterConfiguration *configv1beta1.ClusterConfiguration,
+func listClusterConfigurationDiff(storage collector.Storage, fromFolder, toFolder string, fromClusterConfiguration, toClusterConfiguration *configv1beta1.ClusterConfiguration,
 	rawDiff bool, table *tablewriter.Table, logger logr.Logger) error {
 
-	toCharts := make([]configv1beta1.Chart, 0)
-	toResources := make([]configv1beta1.Resource, 0)
-	if toClusterConfiguration != nil {
-		for i := range toClusterConfiguration.Status.ClusterProfileResources {
-			cpr := &toClusterConfiguration.Status.ClusterProfileResources[i]
-			toCharts, toResources = appendChartsAndResourcesForClusterProfiles(cpr, toCharts, toResources)
-		}
-		for i := range toClusterConfiguration.Status.ProfileResources {
-			pr := &toClusterConfiguration.Status.ProfileResources[i]
-			toCharts, toResources = appendChartsAndResourcesForProfiles(pr, toCharts, toResources)
-		}
-	}
-
-	fromCharts := make([]configv1beta1.Chart, 0)
-	fromResources := make([]configv1beta1.Resource, 0)
-	if fromClusterConfiguration != nil {
-		for i := range fromClusterConfiguration.Status.ClusterProfileResources {
-			cpr := &fromClusterConfiguration.Status.ClusterProfileResources[i]
-			fromCharts, fromResources = appendChartsAndResourcesForClusterProfiles(cpr, fromCharts, fromResources)
-		}
-		for i := range fromClusterConfiguration.Status.ProfileResources {
-			pr := &fromClusterConfiguration.Status.ProfileResources[i]
-			fromCharts, fromResources = appendChartsAndResourcesForProfiles(pr, fromCharts, fromResources)
-		}
-	}
+	toCharts, toResources := getChartsAndResources(toClusterConfiguration)
+	fromCharts, fromResources := getChartsAndResources(fromClusterConfiguration)
 
 	// Evaluate the diff
 	chartAdded, chartModified, chartDeleted, modifiedChartMessage :=
 		chartDifference(fromCharts, toCharts)
 	resourceAdded, resourceModified, resourceDeleted, err :=
-		resourceDifference(fromFolder, toFolder, fromResources, toResources, rawDiff, logger)
+		resourceDifference(storage, fromFolder, toFolder, fromResources, toResources, rawDiff, logger)
 	if err != nil {
 		return err
 	}
@@ -446,18 +743,42 @@ func listClusterConfigurationDiff(fromFolder, toFolder string, fromClusterConfig
 	return nil
 }
 
-func addChartEntry(fromClusterConfiguration, toClusterConfiguration *configv1beta1.ClusterConfiguration,
-	charts []*configv1beta1.Chart, action string, message map[configv1beta1.Chart]string, table *tablewriter.Table) {
+// getChartsAndResources returns the helm charts and resources deployed, according to clusterConfiguration,
+// in a cluster. Returns empty lists if clusterConfiguration is nil.
+func getChartsAndResources(clusterConfiguration *configv1beta1.ClusterConfiguration,
+) ([]configv1beta1.Chart, []configv1beta1.Resource) {
+
+	charts := make([]configv1beta1.Chart, 0)
+	resources := make([]configv1beta1.Resource, 0)
+	if clusterConfiguration == nil {
+		return charts, resources
+	}
+
+	for i := range clusterConfiguration.Status.ClusterProfileResources {
+		cpr := &clusterConfiguration.Status.ClusterProfileResources[i]
+		charts, resources = appendChartsAndResourcesForClusterProfiles(cpr, charts, resources)
+	}
+	for i := range clusterConfiguration.Status.ProfileResources {
+		pr := &clusterConfiguration.Status.ProfileResources[i]
+		charts, resources = appendChartsAndResourcesForProfiles(pr, charts, resources)
+	}
+
+	return charts, resources
+}
 
+// clusterInfo returns namespace/name of the cluster either ClusterConfiguration is for
+func clusterInfo(fromClusterConfiguration, toClusterConfiguration *configv1beta1.ClusterConfiguration) string {
 	instance := utils.GetAccessInstance()
-	clusterInfo := func(fromClusterConfiguration, toClusterConfiguration *configv1beta1.ClusterConfiguration) string {
-		if toClusterConfiguration != nil {
-			clusterName := instance.GetClusterNameFromClusterConfiguration(toClusterConfiguration)
-			return fmt.Sprintf("%s/%s", toClusterConfiguration.Namespace, clusterName)
-		}
-		clusterName := instance.GetClusterNameFromClusterConfiguration(fromClusterConfiguration)
-		return fmt.Sprintf("%s/%s", fromClusterConfiguration.Namespace, clusterName)
+	if toClusterConfiguration != nil {
+		clusterName := instance.GetClusterNameFromClusterConfiguration(toClusterConfiguration)
+		return fmt.Sprintf("%s/%s", toClusterConfiguration.Namespace, clusterName)
 	}
+	clusterName := instance.GetClusterNameFromClusterConfiguration(fromClusterConfiguration)
+	return fmt.Sprintf("%s/%s", fromClusterConfiguration.Namespace, clusterName)
+}
+
+func addChartEntry(fromClusterConfiguration, toClusterConfiguration *configv1beta1.ClusterConfiguration,
+	charts []*configv1beta1.Chart, action string, message map[configv1beta1.Chart]string, table *tablewriter.Table) {
 
 	for i := range charts {
 		msg := ""
@@ -538,7 +859,7 @@ func chartDifference(from, to []configv1beta1.Chart) (added, modified, deleted [
 }
 
 // resourceDifference returns differences between from and to
-func resourceDifference(fromFolder, toFolder string, from, to []configv1beta1.Resource, rawDiff bool,
+func resourceDifference(storage collector.Storage, fromFolder, toFolder string, from, to []configv1beta1.Resource, rawDiff bool,
 	logger logr.Logger) (added, modified, deleted []*configv1beta1.Resource, err error) {
 
 	resourceInfo := func(resource *configv1beta1.Resource) string {
@@ -569,7 +890,7 @@ func resourceDifference(fromFolder, toFolder string, from, to []configv1beta1.Re
 			addedResources = append(addedResources, toResourceMap[k])
 		} else if !reflect.DeepEqual(*v, *(toResourceMap[k])) {
 			var diff bool
-			diff, err = hasDiff(fromFolder, toFolder, v, toResourceMap[k], rawDiff)
+			diff, err = hasDiff(storage, fromFolder, toFolder, v, toResourceMap[k], rawDiff)
 			if err != nil {
 				return nil, nil, nil, err
 			}
@@ -622,13 +943,28 @@ func appendChartsAndResourcesForProfiles(pr *configv1beta1.ProfileResource, char
 }
 
 // hasDiff returns true if any diff exist
-func hasDiff(fromFolder, toFolder string, from, to *configv1beta1.Resource, rawDiff bool) (bool, error) {
-	fromResource, err := getResourceFromResourceOwner(fromFolder, from)
+func hasDiff(storage collector.Storage, fromFolder, toFolder string, from, to *configv1beta1.Resource,
+	rawDiff bool) (bool, error) {
+
+	fromOwner, err := getOwnerStoredInFull(storage, fromFolder, from)
+	if err != nil {
+		return false, err
+	}
+	toOwner, err := getOwnerStoredInFull(storage, toFolder, to)
 	if err != nil {
 		return false, err
 	}
+	if !fromOwner || !toOwner {
+		// Resource content is not available. Best that can be done is comparing owners' digests
+		return hasOwnerDigestDiff(storage, fromFolder, toFolder, from, to, rawDiff)
+	}
 
-	toResource, err := getResourceFromResourceOwner(toFolder, to)
+	fromResource, err := getResourceFromResourceOwner(storage, fromFolder, from)
+	if err != nil {
+		return false, err
+	}
+
+	toResource, err := getResourceFromResourceOwner(storage, toFolder, to)
 	if err != nil {
 		return false, err
 	}
@@ -653,14 +989,17 @@ func hasDiff(fromFolder, toFolder string, from, to *configv1beta1.Resource, rawD
 	return diff != "", nil
 }
 
-// hasResourceDiff returns true if any diff exist between two unstructured spec
-func hasResourceDiff(from, to *unstructured.Unstructured, rawDiff bool, logger logr.Logger) (bool, error) {
+// hasResourceDiff returns true if any diff, not ignored by rules, exist between two unstructured spec
+func hasResourceDiff(from, to *unstructured.Unstructured, rules *IgnoreRules, rawDiff bool,
+	logger logr.Logger) (bool, error) {
+
 	var fromContent = from.UnstructuredContent()
 
 	var toContent = to.UnstructuredContent()
 
 	const spec = "spec"
-	if !reflect.DeepEqual(fromContent[spec], toContent[spec]) {
+	changes := rules.filterChanges(to.GetKind(), diffFields(spec, fromContent[spec], toContent[spec]))
+	if len(changes) > 0 {
 		objectInfo := fmt.Sprintf("%s %s", from.GroupVersionKind().Kind, from.GetName())
 
 		fromJSON, err := json.MarshalIndent(fromContent[spec], "", "  ")
@@ -692,10 +1031,12 @@ func hasResourceDiff(from, to *unstructured.Unstructured, rawDiff bool, logger l
 	return false, nil
 }
 
-func getResourceFromResourceOwner(folder string, resource *configv1beta1.Resource) (string, error) {
+func getResourceFromResourceOwner(storage collector.Storage, folder string, resource *configv1beta1.Resource,
+) (string, error) {
+
 	ownerPath := buildOwnerPath(folder, resource)
 
-	owner, err := getResourceOwner(ownerPath)
+	owner, err := getResourceOwner(storage, ownerPath)
 	if err != nil {
 		return "", err
 	}
@@ -745,15 +1086,81 @@ func getResourceFromResourceOwner(folder string, resource *configv1beta1.Resourc
 		resource.Kind, resource.Namespace, resource.Name, ownerPath)
 }
 
+// getOwnerStoredInFull returns true if the ConfigMap/Secret containing resource is in
+// the sample stored in folder with its actual content. Because of the Snapshot secret policies,
+// a Secret might not be stored at all or be stored hashed.
+func getOwnerStoredInFull(storage collector.Storage, folder string, resource *configv1beta1.Resource,
+) (bool, error) {
+
+	ownerPath := buildOwnerPath(folder, resource)
+	exist, err := storage.Exists(ownerPath)
+	if err != nil || !exist {
+		return false, err
+	}
+
+	owner, err := getResourceOwner(storage, ownerPath)
+	if err != nil {
+		return false, err
+	}
+
+	return owner.GetKind() != "Secret" || collector.IsSecretStoredInFull(owner), nil
+}
+
+// hasOwnerDigestDiff returns true if the Secrets containing from and to have different content.
+// If any of the two Secrets was not stored, no diff can be detected.
+func hasOwnerDigestDiff(storage collector.Storage, fromFolder, toFolder string, from, to *configv1beta1.Resource,
+	rawDiff bool) (bool, error) {
+
+	fromDigests, err := getOwnerDigests(storage, fromFolder, from)
+	if err != nil || fromDigests == nil {
+		return false, err
+	}
+	toDigests, err := getOwnerDigests(storage, toFolder, to)
+	if err != nil || toDigests == nil {
+		return false, err
+	}
+
+	if reflect.DeepEqual(fromDigests, toDigests) {
+		return false, nil
+	}
+
+	if rawDiff {
+		//nolint: forbidigo // print diff
+		fmt.Printf("%s/%s %s/%s: content not available, Secret %s/%s was not stored in full. Digests differ.\n",
+			from.Group, from.Kind, from.Namespace, from.Name, from.Owner.Namespace, from.Owner.Name)
+	}
+
+	return true, nil
+}
+
+// getOwnerDigests returns the digests of the Secret containing resource.
+// Returns nil if such Secret is not in the sample.
+func getOwnerDigests(storage collector.Storage, folder string, resource *configv1beta1.Resource,
+) (map[string]string, error) {
+
+	ownerPath := buildOwnerPath(folder, resource)
+	exist, err := storage.Exists(ownerPath)
+	if err != nil || !exist {
+		return nil, err
+	}
+
+	owner, err := getResourceOwner(storage, ownerPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return collector.GetSecretDigests(owner)
+}
+
 func buildOwnerPath(folder string, resource *configv1beta1.Resource) string {
-	return filepath.Join(folder,
+	return path.Join(folder,
 		resource.Owner.Namespace,
 		resource.Owner.Kind,
 		fmt.Sprintf("%s.yaml", resource.Owner.Name))
 }
 
-func getResourceOwner(ownerFile string) (*unstructured.Unstructured, error) {
-	content, err := os.ReadFile(ownerFile)
+func getResourceOwner(storage collector.Storage, ownerFile string) (*unstructured.Unstructured, error) {
+	content, err := storage.ReadFile(ownerFile)
 	if err != nil {
 		return nil, err
 	}
@@ -767,28 +1174,52 @@ func getResourceOwner(ownerFile string) (*unstructured.Unstructured, error) {
 	return u, nil
 }
 
-// Diff lists differences between two snapshots
-func Diff(ctx context.Context, args []string, logger logr.Logger) error {
+// Diff lists differences between two snapshots, or between a snapshot and the live configuration.
+// collectLive is used to collect the live configuration.
+func Diff(ctx context.Context, args []string, collectLive LiveSampleCollector, logger logr.Logger) error {
 	doc := `Usage:
-	sveltosctl snapshot diff [options] --snapshot=<name> --from-sample=<name> --to-sample=<name> [--namespace=<name>] [--raw-diff] [--cluster=<name>] [--verbose]
+	sveltosctl snapshot diff [options] --snapshot=<name> --from-sample=<name> (--to-sample=<name> | --to-live) [--namespace=<name>] [--raw-diff] [--cluster=<name>] [--kind=<name>] [--ignore-fields=<list>] [--ignore-file=<path>] [--output=<format>] [--verbose]
 
      --snapshot=<name>      Name of the Snapshot instance
      --from-sample=<name>   Name of the directory containing this sample.
                             Use sveltosctl snapshot list to see all collected snapshosts.
      --to-sample=<name>     Name of the directory containing this sample.
                             Use sveltosctl snapshot list to see all collected snapshosts.
+     --to-live              Compare with the live configuration instead of a collected sample.
+                            The live configuration is collected in memory, as a sample would be, and never stored.
      --namespace=<name>     Show features differences for clusters in this namespace.
                             If not specified all namespaces are considered.
      --cluster=<name>       Show features differences for clusters with name.
                             If not specified all cluster names are considered.
+     --kind=<name>          Show differences only for this kind (for instance ClusterProfile).
+                            A comma separated list of kinds can be passed. If not specified all kinds are considered.
+     --ignore-fields=<list>  Comma separated list of JSONPath-style field paths which are not compared, for instance
+                            metadata.labels["example.com/build"]. Prefix a path with kind and colon to ignore it only
+                            for that kind, for instance ConfigMap:metadata.annotations.
+     --ignore-file=<path>   YAML file containing ignore rules. Each rule lists the fields not compared, for every kind
+                            or for a single kind.
      --raw-diff             With this flag, for each referenced ConfigMap/Secret, diff will be displayed.
+                            Only valid with table output.
 
 Options:
   -h --help                  Show this screen.
+  -o --output=<format>       Output format: table, json, yaml or patch. Defaults to global --output, or table.
+                             json and yaml print the list of changes. patch prints a unified diff of the
+                             sample files, which can be applied with git apply to the from-sample exported
+                             with snapshot export.
      --verbose               Verbose mode. Print each step.  
 
 Description:
-  The snapshot diff command list differences in deployed features in sample-two having sample-one as starting point
+  The snapshot diff command list differences in deployed features in sample-two having sample-one as starting point.
+  With --to-live, it lists what changed in the management cluster since sample-one was taken.
+  For every collected object (ClusterProfiles, Profiles, Clusters, Classifiers, EventTriggers, referenced
+  ConfigMaps/Secrets, ...) the changed fields are listed as well, so the cause of a deployment change is shown
+  along with its effect. Status and fields set by the API server or kubectl (resourceVersion, uid, generation,
+  creationTimestamp, managedFields and the last-applied-configuration annotation) are not compared, unless
+  skipDefaults is set in the ignore file. Objects whose only changes are ignored fields are not reported.
+  Secrets are compared by digests, so Secrets stored hashed are compared as well. Secret values are never displayed.
+  Resources out of the scope of either sample (see Snapshot includeKinds, excludeKinds, namespaceSelector
+  and profileSelector) are not compared.
 `
 
 	parsedArgs, err := docopt.ParseArgs(doc, nil, "1.0")
@@ -813,7 +1244,6 @@ Description:
 	logger = textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1)))
 
 	snapshostName := parsedArgs["--snapshot"].(string)
-	toSample := parsedArgs["--to-sample"].(string)
 	fromSample := parsedArgs["--from-sample"].(string)
 
 	namespace := ""
@@ -826,7 +1256,42 @@ Description:
 		cluster = passedCluster.(string)
 	}
 
+	var kinds []string
+	if passedKind := parsedArgs["--kind"]; passedKind != nil {
+		kinds = strings.Split(passedKind.(string), ",")
+	}
+
 	rawDiff := parsedArgs["--raw-diff"].(bool)
 
-	return listSnapshotDiffs(ctx, snapshostName, fromSample, toSample, namespace, cluster, rawDiff, logger)
+	format := getDefaultFormat()
+	if passedFormat := parsedArgs["--output"]; passedFormat != nil {
+		format, err = parseDiffFormat(passedFormat.(string))
+		if err != nil {
+			return err
+		}
+	}
+	if rawDiff && format != output.Table {
+		return fmt.Errorf("--raw-diff can only be used with %s output", output.Table)
+	}
+
+	ignoreRules, err := getIgnoreRules(parsedArgs)
+	if err != nil {
+		return err
+	}
+
+	options := &DiffOptions{
+		Namespace:   namespace,
+		Cluster:     cluster,
+		Kinds:       kinds,
+		RawDiff:     rawDiff,
+		Format:      format,
+		IgnoreRules: ignoreRules,
+	}
+
+	if parsedArgs["--to-live"].(bool) {
+		return listSnapshotDiffsToLive(ctx, snapshostName, fromSample, options, collectLive, logger)
+	}
+
+	toSample := parsedArgs["--to-sample"].(string)
+	return listSnapshotDiffs(ctx, snapshostName, fromSample, toSample, options, logger)
 }
@@ -0,0 +1,121 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/types"
+
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+	utilsv1beta1 "github.com/projectsveltos/sveltosctl/api/v1beta1"
+	"github.com/projectsveltos/sveltosctl/internal/collector"
+	"github.com/projectsveltos/sveltosctl/internal/utils"
+)
+
+const (
+	// liveSampleName is the name of the (never stored) sample containing the live configuration.
+	// Sample names are timestamps, so it never conflicts with an actual sample.
+	liveSampleName = "live"
+)
+
+// LiveSampleCollector dumps, in folder of storage, the live configuration as a sample
+// of Snapshot snapshotName would contain it
+type LiveSampleCollector func(ctx context.Context, snapshotName string, storage collector.Storage,
+	folder string, logger logr.Logger) error
+
+// overlayStorage serves every key in folder from overlay and any other key from the
+// underlying Storage. It allows comparing a stored sample with one kept in memory.
+type overlayStorage struct {
+	collector.Storage
+	folder  string
+	overlay collector.Storage
+}
+
+func (s *overlayStorage) get(key string) collector.Storage {
+	key = strings.Trim(path.Clean("/"+key), "/")
+	if key == s.folder || strings.HasPrefix(key, s.folder+"/") {
+		return s.overlay
+	}
+	return s.Storage
+}
+
+func (s *overlayStorage) WriteFile(key string, data []byte) error {
+	return s.get(key).WriteFile(key, data)
+}
+
+func (s *overlayStorage) ReadFile(key string) ([]byte, error) {
+	return s.get(key).ReadFile(key)
+}
+
+func (s *overlayStorage) ReadDir(key string) ([]collector.StorageEntry, error) {
+	return s.get(key).ReadDir(key)
+}
+
+func (s *overlayStorage) Exists(key string) (bool, error) {
+	return s.get(key).Exists(key)
+}
+
+func (s *overlayStorage) RemoveAll(key string) error {
+	return s.get(key).RemoveAll(key)
+}
+
+// listSnapshotDiffsToLive lists all differences in the live configuration from fromSample.
+// The live configuration is collected in memory, as a sample would be, and never stored.
+func listSnapshotDiffsToLive(ctx context.Context, snapshotName, fromSample string, options *DiffOptions,
+	collectLive LiveSampleCollector, logger logr.Logger) error {
+
+	logger.V(logs.LogDebug).Info(fmt.Sprintf("finding diff between %s and live configuration", fromSample))
+
+	instance := utils.GetAccessInstance()
+	snapshotInstance := &utilsv1beta1.Snapshot{}
+	err := instance.GetResource(ctx, types.NamespacedName{Name: snapshotName}, snapshotInstance)
+	if err != nil {
+		return err
+	}
+
+	storage, err := getSnapshotStorage(ctx, snapshotInstance)
+	if err != nil {
+		return err
+	}
+
+	artifactFolder, err := collector.GetClient().GetFolder(storage, snapshotInstance.Name,
+		collector.Snapshot, logger)
+	if err != nil {
+		return err
+	}
+
+	fromFolder := path.Join(*artifactFolder, fromSample)
+	err = verifySampleExists(storage, fromFolder, snapshotName, logger)
+	if err != nil {
+		return err
+	}
+
+	liveFolder := path.Join(*artifactFolder, liveSampleName)
+	liveStorage := collector.NewMemoryStorage()
+	err = collectLive(ctx, snapshotName, liveStorage, liveFolder, logger)
+	if err != nil {
+		return err
+	}
+
+	storage = &overlayStorage{Storage: storage, folder: liveFolder, overlay: liveStorage}
+	return listFolderDiffs(storage, fromFolder, liveFolder, options, logger)
+}
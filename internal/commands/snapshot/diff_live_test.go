@@ -0,0 +1,130 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2/textlogger"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	libsveltosv1beta1 "github.com/projectsveltos/libsveltos/api/v1beta1"
+	utilsv1beta1 "github.com/projectsveltos/sveltosctl/api/v1beta1"
+	"github.com/projectsveltos/sveltosctl/internal/collector"
+	"github.com/projectsveltos/sveltosctl/internal/commands/snapshot"
+	"github.com/projectsveltos/sveltosctl/internal/utils"
+)
+
+var _ = Describe("Snapshot Diff To Live", func() {
+	It("listSnapshotDiffsToLive compares a sample with the live configuration collected in memory", func() {
+		storageDir, err := os.MkdirTemp("", randomString())
+		Expect(err).To(BeNil())
+		defer os.RemoveAll(storageDir)
+
+		snapshotInstance := &utilsv1beta1.Snapshot{
+			ObjectMeta: metav1.ObjectMeta{Name: randomString()},
+			Spec:       utilsv1beta1.SnapshotSpec{Storage: storageDir},
+		}
+		Expect(os.MkdirAll(filepath.Join(storageDir, "snapshot", snapshotInstance.Name), os.ModePerm)).To(Succeed())
+
+		newClassifier := func() *libsveltosv1beta1.Classifier {
+			classifier := &libsveltosv1beta1.Classifier{
+				ObjectMeta: metav1.ObjectMeta{Name: randomString()},
+				Spec: libsveltosv1beta1.ClassifierSpec{
+					ClassifierLabels: []libsveltosv1beta1.ClassifierLabel{
+						{Key: randomString(), Value: randomString()},
+					},
+				},
+			}
+			Expect(addTypeInformationToObject(classifier)).To(Succeed())
+			return classifier
+		}
+
+		removed := newClassifier()
+		unchanged := newClassifier()
+		sample := createSnapshotDirectoryWithObjects(snapshotInstance.Name, storageDir,
+			[]client.Object{removed, unchanged})
+
+		added := newClassifier()
+		collectLive := func(ctx context.Context, snapshotName string, storage collector.Storage,
+			folder string, logger logr.Logger) error {
+
+			Expect(snapshotName).To(Equal(snapshotInstance.Name))
+			for _, classifier := range []client.Object{unchanged, added} {
+				if err := collector.GetClient().DumpObject(storage, classifier, folder, logger); err != nil {
+					return err
+				}
+			}
+			return collector.GetClient().WriteSampleMetadata(storage, folder,
+				&collector.SampleMetadata{Trigger: collector.SampleTriggerLive})
+		}
+
+		scheme, err := utils.GetScheme()
+		Expect(err).To(BeNil())
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(snapshotInstance).Build()
+		utils.InitalizeManagementClusterAcces(scheme, nil, nil, c)
+
+		old := os.Stdout // keep backup of the real stdout
+		r, w, _ := os.Pipe()
+		os.Stdout = w
+
+		err = snapshot.ListSnapshotDiffsToLive(context.TODO(), snapshotInstance.Name, sample, &snapshot.DiffOptions{},
+			collectLive, textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
+
+		w.Close()
+		os.Stdout = old
+		Expect(err).To(BeNil())
+
+		var buf bytes.Buffer
+		_, err = io.Copy(&buf, r)
+		Expect(err).To(BeNil())
+
+		actions := make(map[string]string)
+		lines := strings.Split(buf.String(), "\n")
+		for i := range lines {
+			for _, name := range []string{removed.Name, unchanged.Name, added.Name} {
+				if strings.Contains(lines[i], name) {
+					actions[name] = lines[i]
+				}
+			}
+		}
+		Expect(actions).To(HaveLen(2))
+		Expect(actions[added.Name]).To(ContainSubstring("added"))
+		Expect(actions[removed.Name]).To(ContainSubstring("removed"))
+
+		// Live configuration is never stored
+		entries, err := os.ReadDir(filepath.Join(storageDir, "snapshot", snapshotInstance.Name))
+		Expect(err).To(BeNil())
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].Name()).To(Equal(sample))
+
+		// Only existing samples can be compared with the live configuration
+		Expect(snapshot.ListSnapshotDiffsToLive(context.TODO(), snapshotInstance.Name, randomString(), &snapshot.DiffOptions{},
+			collectLive, textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).ToNot(Succeed())
+	})
+})
@@ -0,0 +1,389 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/hexops/gotextdiff"
+	"github.com/hexops/gotextdiff/myers"
+	"github.com/hexops/gotextdiff/span"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+
+	configv1beta1 "github.com/projectsveltos/addon-controller/api/v1beta1"
+	libsveltosv1beta1 "github.com/projectsveltos/libsveltos/api/v1beta1"
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+	"github.com/projectsveltos/sveltosctl/internal/collector"
+	"github.com/projectsveltos/sveltosctl/internal/output"
+)
+
+const (
+	helmReleaseKind = "helm release"
+)
+
+// Change represents an object, or a helm release/resource deployed in a cluster, added, removed
+// or modified between two samples.
+// It is the item type of snapshot diff structured output.
+type Change struct {
+	// Cluster is, for helm releases and resources deployed in a cluster, the cluster in the
+	// form namespace/name. Empty for objects.
+	Cluster string `json:"cluster,omitempty"`
+	// Kind is the object kind, "helm release" or the deployed resource group/kind
+	Kind      string           `json:"kind"`
+	Namespace string           `json:"namespace,omitempty"`
+	Name      string           `json:"name"`
+	Action    ObjectDiffAction `json:"action"`
+	// FromVersion and ToVersion are the helm chart versions. N/A for objects and resources.
+	FromVersion string `json:"fromVersion,omitempty"`
+	ToVersion   string `json:"toVersion,omitempty"`
+	// Fields contains the field level changes of a modified object
+	Fields []FieldChange `json:"fields,omitempty"`
+}
+
+// parseDiffFormat validates value and returns the corresponding Format.
+// Besides table, json and yaml, snapshot diff supports patch.
+func parseDiffFormat(value string) (output.Format, error) {
+	switch f := output.Format(strings.ToLower(value)); f {
+	case "":
+		return output.Table, nil
+	case output.Table, output.JSON, output.YAML, output.Patch:
+		return f, nil
+	default:
+		return "", fmt.Errorf("invalid output format %q. Possible values are: %s, %s, %s, %s",
+			value, output.Table, output.JSON, output.YAML, output.Patch)
+	}
+}
+
+// getDefaultFormat returns the format set with the global --output flag. Snapshot
+// commands have no additional columns, so wide is the same as table.
+func getDefaultFormat() output.Format {
+	if format := output.DefaultFormat(); format != output.Wide {
+		return format
+	}
+	return output.Table
+}
+
+// getSampleChanges returns all changes between the samples stored in fromFolder and toFolder:
+// helm releases and resources deployed in clusters first, then objects.
+func getSampleChanges(storage collector.Storage, fromFolder, toFolder string, options *DiffOptions,
+	scopes []*collector.SampleScope, logger logr.Logger) ([]Change, error) {
+
+	changes := make([]Change, 0)
+	if isKindSelected(options.Kinds, configv1beta1.ClusterConfigurationKind) {
+		clusterChanges, err := getClusterChanges(storage, fromFolder, toFolder, options, scopes, logger)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, clusterChanges...)
+	}
+
+	objectDiffs, err := getObjectDiffs(storage, fromFolder, toFolder, options, scopes, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range objectDiffs {
+		changes = append(changes, Change{
+			Kind:      objectDiffs[i].Kind,
+			Namespace: objectDiffs[i].Namespace,
+			Name:      objectDiffs[i].Name,
+			Action:    objectDiffs[i].Action,
+			Fields:    objectDiffs[i].Changes,
+		})
+	}
+
+	return changes, nil
+}
+
+// getClusterChanges returns, sorted by cluster, kind, namespace and name, the helm releases and
+// resources deployed in clusters which were added, removed or modified between the two samples
+func getClusterChanges(storage collector.Storage, fromFolder, toFolder string, options *DiffOptions,
+	scopes []*collector.SampleScope, logger logr.Logger) ([]Change, error) {
+
+	fromClusterConfigurationMap, toClusterConfigurationMap, err := getClusterConfigurationMaps(storage,
+		fromFolder, toFolder, scopes, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	namespaces := make(map[string]bool)
+	for _, clusterConfigurationMap := range []map[string][]*unstructured.Unstructured{
+		fromClusterConfigurationMap, toClusterConfigurationMap} {
+
+		for ns := range clusterConfigurationMap {
+			if doConsiderNamespace(ns, options.Namespace) {
+				namespaces[ns] = true
+			}
+		}
+	}
+
+	changes := make([]Change, 0)
+	for ns := range namespaces {
+		fromClusterConfigurations, err := getClusterConfigurationsInNamespace(ns, options.Cluster,
+			fromClusterConfigurationMap, logger)
+		if err != nil {
+			return nil, err
+		}
+		toClusterConfigurations, err := getClusterConfigurationsInNamespace(ns, options.Cluster,
+			toClusterConfigurationMap, logger)
+		if err != nil {
+			return nil, err
+		}
+
+		pairs := make(map[string][2]*configv1beta1.ClusterConfiguration)
+		for i := range fromClusterConfigurations {
+			pair := pairs[fromClusterConfigurations[i].Name]
+			pair[0] = fromClusterConfigurations[i]
+			pairs[fromClusterConfigurations[i].Name] = pair
+		}
+		for i := range toClusterConfigurations {
+			pair := pairs[toClusterConfigurations[i].Name]
+			pair[1] = toClusterConfigurations[i]
+			pairs[toClusterConfigurations[i].Name] = pair
+		}
+
+		for _, pair := range pairs {
+			clusterConfigurationChanges, err := getClusterConfigurationChanges(storage, fromFolder, toFolder,
+				pair[0], pair[1], logger)
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, clusterConfigurationChanges...)
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Cluster+"|"+getObjectDiffKey(changes[i].Kind, changes[i].Namespace, changes[i].Name) <
+			changes[j].Cluster+"|"+getObjectDiffKey(changes[j].Kind, changes[j].Namespace, changes[j].Name)
+	})
+
+	return changes, nil
+}
+
+// getClusterConfigurationChanges returns the helm releases and resources added, removed or modified
+// in a cluster. Either ClusterConfiguration can be nil.
+func getClusterConfigurationChanges(storage collector.Storage, fromFolder, toFolder string,
+	fromClusterConfiguration, toClusterConfiguration *configv1beta1.ClusterConfiguration,
+	logger logr.Logger) ([]Change, error) {
+
+	toCharts, toResources := getChartsAndResources(toClusterConfiguration)
+	fromCharts, fromResources := getChartsAndResources(fromClusterConfiguration)
+
+	chartAdded, chartModified, chartDeleted, _ := chartDifference(fromCharts, toCharts)
+	resourceAdded, resourceModified, resourceDeleted, err :=
+		resourceDifference(storage, fromFolder, toFolder, fromResources, toResources, false, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	fromVersions := make(map[string]string, len(fromCharts))
+	for i := range fromCharts {
+		fromVersions[getObjectDiffKey(helmReleaseKind, fromCharts[i].Namespace, fromCharts[i].ReleaseName)] =
+			fromCharts[i].ChartVersion
+	}
+
+	cluster := clusterInfo(fromClusterConfiguration, toClusterConfiguration)
+	changes := make([]Change, 0)
+	addCharts := func(charts []*configv1beta1.Chart, action ObjectDiffAction) {
+		for i := range charts {
+			change := Change{Cluster: cluster, Kind: helmReleaseKind, Namespace: charts[i].Namespace,
+				Name: charts[i].ReleaseName, Action: action}
+			if action == ObjectDiffRemoved {
+				change.FromVersion = charts[i].ChartVersion
+			} else {
+				change.FromVersion = fromVersions[getObjectDiffKey(helmReleaseKind, change.Namespace, change.Name)]
+				change.ToVersion = charts[i].ChartVersion
+			}
+			changes = append(changes, change)
+		}
+	}
+	addResources := func(resources []*configv1beta1.Resource, action ObjectDiffAction) {
+		for i := range resources {
+			changes = append(changes, Change{Cluster: cluster,
+				Kind:      fmt.Sprintf("%s/%s", resources[i].Group, resources[i].Kind),
+				Namespace: resources[i].Namespace, Name: resources[i].Name, Action: action})
+		}
+	}
+
+	addCharts(chartAdded, ObjectDiffAdded)
+	addCharts(chartModified, ObjectDiffModified)
+	addCharts(chartDeleted, ObjectDiffRemoved)
+	addResources(resourceAdded, ObjectDiffAdded)
+	addResources(resourceModified, ObjectDiffModified)
+	addResources(resourceDeleted, ObjectDiffRemoved)
+
+	return changes, nil
+}
+
+// getSampleFilePath returns the path, relative to the sample folder, of the file containing object
+func getSampleFilePath(object *unstructured.Unstructured) string {
+	return path.Join(object.GetNamespace(), object.GetKind(), object.GetName()+".yaml")
+}
+
+// getPatchContent returns the content of the file containing object in the sample stored in folder.
+// Secrets stored in full are returned hashed, so secret values are never part of a patch.
+func getPatchContent(storage collector.Storage, folder string, object *unstructured.Unstructured,
+) (string, error) {
+
+	if object == nil {
+		return "", nil
+	}
+
+	if object.GetKind() == string(libsveltosv1beta1.SecretReferencedResourceKind) &&
+		collector.IsSecretStoredInFull(object) {
+
+		secret := &corev1.Secret{}
+		err := runtime.DefaultUnstructuredConverter.FromUnstructured(object.UnstructuredContent(), secret)
+		if err != nil {
+			return "", err
+		}
+		collector.HashSecret(secret)
+		data, err := yaml.Marshal(secret)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+
+	data, err := storage.ReadFile(path.Join(folder, getSampleFilePath(object)))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// writeSamplePatch writes to w, as a git style unified diff series, the changes turning the files of
+// the sample stored in fromFolder into the files of the sample stored in toFolder. Paths are relative
+// to the sample folder, so the patch can be applied, with git apply, to the from sample written by
+// writeSampleTree.
+// Files whose only changes are fields ignored by options.IgnoreRules are left out.
+func writeSamplePatch(w io.Writer, storage collector.Storage, fromFolder, toFolder string, options *DiffOptions,
+	scopes []*collector.SampleScope, logger logr.Logger) error {
+
+	fromMap, err := getSampleObjectMap(storage, fromFolder, options.Kinds, options.Namespace, scopes, logger)
+	if err != nil {
+		return err
+	}
+	toMap, err := getSampleObjectMap(storage, toFolder, options.Kinds, options.Namespace, scopes, logger)
+	if err != nil {
+		return err
+	}
+
+	type filePair struct {
+		from *unstructured.Unstructured
+		to   *unstructured.Unstructured
+	}
+	files := make(map[string]*filePair)
+	for _, from := range fromMap {
+		files[getSampleFilePath(from)] = &filePair{from: from}
+	}
+	for _, to := range toMap {
+		filePath := getSampleFilePath(to)
+		if pair, ok := files[filePath]; ok {
+			pair.to = to
+			continue
+		}
+		files[filePath] = &filePair{to: to}
+	}
+
+	filePaths := make([]string, 0, len(files))
+	for filePath := range files {
+		filePaths = append(filePaths, filePath)
+	}
+	sort.Strings(filePaths)
+
+	for _, filePath := range filePaths {
+		fromContent, err := getPatchContent(storage, fromFolder, files[filePath].from)
+		if err != nil {
+			return err
+		}
+		toContent, err := getPatchContent(storage, toFolder, files[filePath].to)
+		if err != nil {
+			return err
+		}
+		if fromContent == toContent {
+			continue
+		}
+		if from, to := files[filePath].from, files[filePath].to; from != nil && to != nil &&
+			len(getFieldChanges(from, to, options.IgnoreRules)) == 0 {
+			// Only ignored fields changed
+			continue
+		}
+
+		logger.V(logs.LogDebug).Info(fmt.Sprintf("writing patch for %s", filePath))
+		err = writeFilePatch(w, filePath, fromContent, toContent, files[filePath].from == nil,
+			files[filePath].to == nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeFilePatch writes to w the git style diff turning fromContent into toContent
+func writeFilePatch(w io.Writer, filePath, fromContent, toContent string, isNew, isDeleted bool) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "diff --git a/%s b/%s\n", filePath, filePath)
+	switch {
+	case isNew:
+		fmt.Fprintf(&sb, "new file mode 100644\n--- /dev/null\n+++ b/%s\n", filePath)
+		writeHunk(&sb, "+", toContent, func(count string) string { return fmt.Sprintf("@@ -0,0 +%s @@", count) })
+	case isDeleted:
+		fmt.Fprintf(&sb, "deleted file mode 100644\n--- a/%s\n+++ /dev/null\n", filePath)
+		writeHunk(&sb, "-", fromContent, func(count string) string { return fmt.Sprintf("@@ -%s +0,0 @@", count) })
+	default:
+		// gotextdiff only produces correct hunk headers when both files have content,
+		// which is why new and deleted files are handled above
+		edits := myers.ComputeEdits(span.URIFromPath(filePath), fromContent, toContent)
+		fmt.Fprint(&sb, gotextdiff.ToUnified("a/"+filePath, "b/"+filePath, fromContent, edits))
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// writeHunk writes a single hunk adding (prefix "+") or removing (prefix "-") every line of content
+func writeHunk(sb *strings.Builder, prefix, content string, header func(count string) string) {
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return
+	}
+
+	count := "1"
+	if len(lines) > 1 {
+		count = fmt.Sprintf("1,%d", len(lines))
+	}
+	sb.WriteString(header(count) + "\n")
+	for i := range lines {
+		sb.WriteString(prefix + lines[i])
+	}
+	if !strings.HasSuffix(content, "\n") {
+		sb.WriteString("\n\\ No newline at end of file\n")
+	}
+}
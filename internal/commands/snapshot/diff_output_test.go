@@ -0,0 +1,185 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2/textlogger"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	configv1beta1 "github.com/projectsveltos/addon-controller/api/v1beta1"
+	libsveltosv1beta1 "github.com/projectsveltos/libsveltos/api/v1beta1"
+	"github.com/projectsveltos/sveltosctl/internal/collector"
+	"github.com/projectsveltos/sveltosctl/internal/commands/snapshot"
+)
+
+var _ = Describe("Snapshot Diff Output", func() {
+	var storageDir string
+	var storage collector.Storage
+
+	BeforeEach(func() {
+		var err error
+		storageDir, err = os.MkdirTemp("", randomString())
+		Expect(err).To(BeNil())
+		storage = collector.NewFilesystemStorage(storageDir)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(storageDir)
+	})
+
+	dumpSample := func(folder string, objects ...client.Object) {
+		for i := range objects {
+			Expect(collector.GetClient().DumpObject(storage, objects[i], folder,
+				textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
+		}
+	}
+
+	newClusterProfile := func() *configv1beta1.ClusterProfile {
+		return &configv1beta1.ClusterProfile{
+			ObjectMeta: metav1.ObjectMeta{Name: randomString()},
+			Spec: configv1beta1.Spec{
+				ClusterSelector: libsveltosv1beta1.Selector{
+					LabelSelector: metav1.LabelSelector{MatchLabels: map[string]string{"env": "qa"}},
+				},
+			},
+		}
+	}
+
+	It("getSampleChanges returns deployed helm releases and objects changes", func() {
+		clusterConfiguration := generateClusterConfiguration()
+		clusterProfile := newClusterProfile()
+
+		fromFolder := path.Join("snapshot", randomString(), "from")
+		dumpSample(fromFolder, clusterConfiguration.DeepCopy(), clusterProfile.DeepCopy())
+
+		charts := clusterConfiguration.Status.ClusterProfileResources[0].Features[0].Charts
+		modifiedChart := charts[0].DeepCopy()
+		removedChart := charts[1].DeepCopy()
+		clusterConfiguration.Status.ClusterProfileResources[0].Features[0].Charts = []configv1beta1.Chart{charts[0]}
+		clusterConfiguration.Status.ClusterProfileResources[0].Features[0].Charts[0].ChartVersion = "v2.0.0"
+		clusterProfile.Spec.ClusterSelector.MatchLabels["env"] = "production"
+
+		toFolder := path.Join("snapshot", randomString(), "to")
+		dumpSample(toFolder, clusterConfiguration, clusterProfile)
+
+		cluster := fmt.Sprintf("%s/%s", clusterConfiguration.Namespace, clusterConfiguration.Name)
+		changes, err := snapshot.GetSampleChanges(storage, fromFolder, toFolder, &snapshot.DiffOptions{},
+			[]*collector.SampleScope{nil, nil}, textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
+		Expect(err).To(BeNil())
+		Expect(changes).To(ConsistOf(
+			snapshot.Change{Cluster: cluster, Kind: "helm release", Namespace: modifiedChart.Namespace,
+				Name: modifiedChart.ReleaseName, Action: snapshot.ObjectDiffModified,
+				FromVersion: modifiedChart.ChartVersion, ToVersion: "v2.0.0"},
+			snapshot.Change{Cluster: cluster, Kind: "helm release", Namespace: removedChart.Namespace,
+				Name: removedChart.ReleaseName, Action: snapshot.ObjectDiffRemoved,
+				FromVersion: removedChart.ChartVersion},
+			snapshot.Change{Kind: configv1beta1.ClusterProfileKind, Name: clusterProfile.Name,
+				Action: snapshot.ObjectDiffModified, Fields: []snapshot.FieldChange{
+					{Path: "spec.clusterSelector.matchLabels.env", From: "qa", To: "production"},
+				}},
+		))
+
+		// Changes in clusters are only listed when ClusterConfigurations are selected
+		changes, err = snapshot.GetSampleChanges(storage, fromFolder, toFolder,
+			&snapshot.DiffOptions{Kinds: []string{configv1beta1.ClusterProfileKind}},
+			[]*collector.SampleScope{nil, nil}, textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
+		Expect(err).To(BeNil())
+		Expect(changes).To(HaveLen(1))
+		Expect(changes[0].Kind).To(Equal(configv1beta1.ClusterProfileKind))
+	})
+
+	It("writeSamplePatch writes a patch which can be applied to the exported from sample", func() {
+		if _, err := exec.LookPath("git"); err != nil {
+			Skip("git is not available")
+		}
+
+		namespace := randomString()
+		clusterProfile := newClusterProfile()
+		password := randomString()
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: randomString()},
+			Data:       map[string][]byte{"password": []byte(password)},
+		}
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: randomString()},
+			Data:       map[string]string{"key": randomString()},
+		}
+
+		fromFolder := path.Join("snapshot", randomString(), "from")
+		dumpSample(fromFolder, clusterProfile.DeepCopy(), secret.DeepCopy(), configMap.DeepCopy())
+
+		clusterProfile.Spec.ClusterSelector.MatchLabels["env"] = "production"
+		secret.Data["password"] = []byte(randomString())
+		roleRequest := &libsveltosv1beta1.RoleRequest{ObjectMeta: metav1.ObjectMeta{Name: randomString()}}
+
+		toFolder := path.Join("snapshot", randomString(), "to")
+		dumpSample(toFolder, clusterProfile, secret, roleRequest)
+
+		var patch bytes.Buffer
+		Expect(snapshot.WriteSamplePatch(&patch, storage, fromFolder, toFolder, &snapshot.DiffOptions{},
+			[]*collector.SampleScope{nil, nil},
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
+
+		// Secret values are never part of the patch
+		Expect(patch.String()).ToNot(ContainSubstring(base64.StdEncoding.EncodeToString([]byte(password))))
+		Expect(patch.String()).To(ContainSubstring(path.Join(namespace, "Secret", secret.Name+".yaml")))
+
+		// Apply the patch to the exported from sample
+		treeDir, err := os.MkdirTemp("", randomString())
+		Expect(err).To(BeNil())
+		defer os.RemoveAll(treeDir)
+		count, err := snapshot.WriteSampleTree(storage, fromFolder, treeDir,
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
+		Expect(err).To(BeNil())
+		Expect(count).To(Equal(3))
+
+		patchFile := filepath.Join(treeDir, "sample.patch")
+		Expect(os.WriteFile(patchFile, patch.Bytes(), 0600)).To(Succeed())
+		for _, args := range [][]string{{"apply", "--check", patchFile}, {"apply", patchFile}} {
+			cmd := exec.Command("git", args...)
+			cmd.Dir = treeDir
+			out, err := cmd.CombinedOutput()
+			Expect(err).To(BeNil(), string(out))
+		}
+
+		for _, file := range []string{
+			filepath.Join("ClusterProfile", clusterProfile.Name+".yaml"),
+			filepath.Join("RoleRequest", roleRequest.Name+".yaml"),
+		} {
+			expected, err := os.ReadFile(filepath.Join(storageDir, toFolder, file))
+			Expect(err).To(BeNil())
+			applied, err := os.ReadFile(filepath.Join(treeDir, file))
+			Expect(err).To(BeNil())
+			Expect(applied).To(Equal(expected))
+		}
+		_, err = os.Stat(filepath.Join(treeDir, namespace, "ConfigMap", configMap.Name+".yaml"))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+})
@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"time"
@@ -100,7 +101,7 @@ var _ = Describe("Snapshot Diff", func() {
 		collector.InitializeClient(context.TODO(),
 			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))), c, 10)
 
-		err = snapshot.ListSnapshotDiffs(context.TODO(), snapshotInstance.Name, timeOne, timeTwo, "", "", false,
+		err = snapshot.ListSnapshotDiffs(context.TODO(), snapshotInstance.Name, timeOne, timeTwo, &snapshot.DiffOptions{},
 			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
 		Expect(err).To(BeNil())
 
@@ -239,14 +240,15 @@ var _ = Describe("Snapshot Diff", func() {
 		collector.InitializeClient(context.TODO(),
 			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))), c, 10)
 
+		storage := collector.NewFilesystemStorage(snapshotInstance.Spec.Storage)
 		snapshotClient := collector.GetClient()
-		artifactFolder, err := snapshotClient.GetFolder(snapshotInstance.Spec.Storage, snapshotInstance.Name,
+		artifactFolder, err := snapshotClient.GetFolder(storage, snapshotInstance.Name,
 			collector.Snapshot, textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
 		Expect(err).To(BeNil())
-		fromFolder := filepath.Join(*artifactFolder, timeOne)
-		toFolder := filepath.Join(*artifactFolder, timeTwo)
+		fromFolder := path.Join(*artifactFolder, timeOne)
+		toFolder := path.Join(*artifactFolder, timeTwo)
 
-		err = snapshot.ListDiff(fromFolder, toFolder, libsveltosv1beta1.ClassifierKind, false,
+		err = snapshot.ListDiff(storage, fromFolder, toFolder, libsveltosv1beta1.ClassifierKind, nil, nil, false,
 			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
 		Expect(err).To(BeNil())
 
@@ -290,7 +292,7 @@ var _ = Describe("Snapshot Diff", func() {
 		r, w, _ = os.Pipe()
 		os.Stdout = w
 
-		err = snapshot.ListDiff(fromFolder, toFolder, libsveltosv1beta1.RoleRequestKind, false,
+		err = snapshot.ListDiff(storage, fromFolder, toFolder, libsveltosv1beta1.RoleRequestKind, nil, nil, false,
 			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
 		Expect(err).To(BeNil())
 
@@ -332,7 +334,7 @@ var _ = Describe("Snapshot Diff", func() {
 			},
 		}
 		table := tablewriter.NewWriter(os.Stdout)
-		Expect(snapshot.ListDiffInClusterConfigurations("", "",
+		Expect(snapshot.ListDiffInClusterConfigurations(localStorage, "", "",
 			[]*configv1beta1.ClusterConfiguration{oldClusterConfiguration},
 			[]*configv1beta1.ClusterConfiguration{newClusterConfiguration},
 			false, table, textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
@@ -358,7 +360,7 @@ var _ = Describe("Snapshot Diff", func() {
 			},
 		}
 		table := tablewriter.NewWriter(os.Stdout)
-		Expect(snapshot.ListClusterConfigurationDiff("", "", oldClusterConfiguration,
+		Expect(snapshot.ListClusterConfigurationDiff(localStorage, "", "", oldClusterConfiguration,
 			newClusterConfiguration, false, table,
 			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
 
@@ -401,7 +403,7 @@ var _ = Describe("Snapshot Diff", func() {
 		newResources := []configv1beta1.Resource{*generateResource(), *generateResource()}
 
 		added, modified, deleted, err :=
-			snapshot.ResourceDifference("", "", oldResources, newResources, false,
+			snapshot.ResourceDifference(localStorage, "", "", oldResources, newResources, false,
 				textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
 		Expect(err).To(BeNil())
 		Expect(len(added)).To(Equal(2))
@@ -425,7 +427,7 @@ var _ = Describe("Snapshot Diff", func() {
 		Expect(err).To(BeNil())
 		clusterRole := getClusterRole()
 		oldConfigMap := createConfigMapWithPolicy(namespace, name, render.AsCode(clusterRole))
-		Expect(collectorClient.DumpObject(oldConfigMap, oldFolder,
+		Expect(collectorClient.DumpObject(localStorage, oldConfigMap, oldFolder,
 			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
 
 		newFolder, err := os.MkdirTemp("", randomString())
@@ -434,7 +436,7 @@ var _ = Describe("Snapshot Diff", func() {
 			{Verbs: []string{"create", "get"}, APIGroups: []string{"cert-manager.io"}, Resources: []string{"certificaterequests"}},
 		}
 		newConfigMap := createConfigMapWithPolicy(namespace, name, render.AsCode(clusterRole))
-		Expect(collectorClient.DumpObject(newConfigMap, newFolder,
+		Expect(collectorClient.DumpObject(localStorage, newConfigMap, newFolder,
 			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
 
 		oldResource := &configv1beta1.Resource{
@@ -452,7 +454,7 @@ var _ = Describe("Snapshot Diff", func() {
 		newResource.LastAppliedTime = &metav1.Time{Time: time.Now().Add(time.Second * time.Duration(2))}
 
 		added, modified, deleted, err :=
-			snapshot.ResourceDifference(oldFolder, newFolder, []configv1beta1.Resource{*oldResource},
+			snapshot.ResourceDifference(localStorage, oldFolder, newFolder, []configv1beta1.Resource{*oldResource},
 				[]configv1beta1.Resource{newResource}, false,
 				textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
 		Expect(err).To(BeNil())
@@ -533,6 +535,110 @@ var _ = Describe("Snapshot Diff", func() {
 		Expect(len(resources)).To(Equal(resourceNumber))
 	})
 
+	It("listSecretDiff detects modified Secrets from digests", func() {
+		folder, err := os.MkdirTemp("", randomString())
+		Expect(err).To(BeNil())
+		defer os.RemoveAll(folder)
+
+		fromFolder := filepath.Join(folder, randomString())
+		toFolder := filepath.Join(folder, randomString())
+
+		unchanged := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: randomString(), Name: randomString()},
+			Data:       map[string][]byte{"key": []byte(randomString())},
+		}
+		modified := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: randomString(), Name: randomString()},
+			Data:       map[string][]byte{"key": []byte(randomString())},
+		}
+		removed := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: randomString(), Name: randomString()},
+			Data:       map[string][]byte{"key": []byte(randomString())},
+		}
+
+		// Unchanged Secret is stored in full in one sample and hashed in the other
+		logger := textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1)))
+		collectorClient := collector.GetClient()
+		Expect(collectorClient.DumpObject(localStorage, unchanged.DeepCopy(), fromFolder, logger)).To(Succeed())
+		hashed := unchanged.DeepCopy()
+		collector.HashSecret(hashed)
+		Expect(collectorClient.DumpObject(localStorage, hashed, toFolder, logger)).To(Succeed())
+
+		hashed = modified.DeepCopy()
+		collector.HashSecret(hashed)
+		Expect(collectorClient.DumpObject(localStorage, hashed, fromFolder, logger)).To(Succeed())
+		modified.Data["key"] = []byte(randomString())
+		hashed = modified.DeepCopy()
+		collector.HashSecret(hashed)
+		Expect(collectorClient.DumpObject(localStorage, hashed, toFolder, logger)).To(Succeed())
+
+		Expect(collectorClient.DumpObject(localStorage, removed.DeepCopy(), fromFolder, logger)).To(Succeed())
+
+		old := os.Stdout // keep backup of the real stdout
+		r, w, _ := os.Pipe()
+		os.Stdout = w
+
+		err = snapshot.ListSecretDiff(localStorage, fromFolder, toFolder, "", nil, false, logger)
+		Expect(err).To(BeNil())
+
+		w.Close()
+		var buf bytes.Buffer
+		_, err = io.Copy(&buf, r)
+		Expect(err).To(BeNil())
+		os.Stdout = old
+
+		Expect(buf.String()).ToNot(ContainSubstring(unchanged.Name))
+		Expect(buf.String()).To(MatchRegexp(fmt.Sprintf("%s/%s.*modified", modified.Namespace, modified.Name)))
+		Expect(buf.String()).To(MatchRegexp(fmt.Sprintf("%s/%s.*removed", removed.Namespace, removed.Name)))
+		Expect(buf.String()).ToNot(ContainSubstring(string(removed.Data["key"])))
+	})
+
+	It("listSecretDiff does not report Secrets out of the sample scope as removed", func() {
+		folder, err := os.MkdirTemp("", randomString())
+		Expect(err).To(BeNil())
+		defer os.RemoveAll(folder)
+
+		fromFolder := filepath.Join(folder, randomString())
+		toFolder := filepath.Join(folder, randomString())
+
+		inScope := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: randomString(), Name: randomString()},
+			Data:       map[string][]byte{"key": []byte(randomString())},
+		}
+		outOfScope := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: randomString(), Name: randomString()},
+			Data:       map[string][]byte{"key": []byte(randomString())},
+		}
+
+		// Both Secrets are in the first sample. Second sample only contains namespace of inScope.
+		logger := textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1)))
+		collectorClient := collector.GetClient()
+		Expect(collectorClient.DumpObject(localStorage, inScope.DeepCopy(), fromFolder, logger)).To(Succeed())
+		Expect(collectorClient.DumpObject(localStorage, outOfScope.DeepCopy(), fromFolder, logger)).To(Succeed())
+		Expect(os.MkdirAll(toFolder, os.ModePerm)).To(Succeed())
+
+		scopes := []*collector.SampleScope{
+			nil,
+			{NamespaceSelector: &metav1.LabelSelector{}, Namespaces: []string{inScope.Namespace}},
+		}
+
+		old := os.Stdout // keep backup of the real stdout
+		r, w, _ := os.Pipe()
+		os.Stdout = w
+
+		err = snapshot.ListSecretDiff(localStorage, fromFolder, toFolder, "", scopes, false, logger)
+		Expect(err).To(BeNil())
+
+		w.Close()
+		var buf bytes.Buffer
+		_, err = io.Copy(&buf, r)
+		Expect(err).To(BeNil())
+		os.Stdout = old
+
+		Expect(buf.String()).To(MatchRegexp(fmt.Sprintf("%s/%s.*removed", inScope.Namespace, inScope.Name)))
+		Expect(buf.String()).ToNot(ContainSubstring(outOfScope.Name))
+	})
+
 	It("getResourceFromResourceOwner returns the resource contained in the Owner", func() {
 		folder, err := os.MkdirTemp("", randomString())
 		Expect(err).To(BeNil())
@@ -551,7 +657,7 @@ var _ = Describe("Snapshot Diff", func() {
 		name := randomString()
 		namespace := randomString()
 		configMap := createConfigMapWithPolicy(namespace, name, render.AsCode(clusterRole))
-		Expect(collectorClient.DumpObject(configMap, folder,
+		Expect(collectorClient.DumpObject(localStorage, configMap, folder,
 			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
 		By(fmt.Sprintf("Dumped ConfigMap %s/%s", configMap.Namespace, configMap.Name))
 
@@ -568,7 +674,7 @@ var _ = Describe("Snapshot Diff", func() {
 			},
 		}
 
-		policy, err := snapshot.GetResourceFromResourceOwner(folder, resource)
+		policy, err := snapshot.GetResourceFromResourceOwner(localStorage, folder, resource)
 		Expect(err).To(BeNil())
 		Expect(policy).To(ContainSubstring(clusterRole.Name))
 		Expect(policy).To(ContainSubstring(clusterRoleGroup))
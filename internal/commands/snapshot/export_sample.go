@@ -0,0 +1,178 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/docopt/docopt-go"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2/textlogger"
+
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+	utilsv1beta1 "github.com/projectsveltos/sveltosctl/api/v1beta1"
+	"github.com/projectsveltos/sveltosctl/internal/collector"
+	"github.com/projectsveltos/sveltosctl/internal/utils"
+)
+
+const (
+	permission0600 = 0600
+	permission0755 = 0755
+)
+
+// verifyExportDir returns an error if dir exists and is not an empty directory
+func verifyExportDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if len(entries) > 0 {
+		return fmt.Errorf("directory %s is not empty", dir)
+	}
+
+	return nil
+}
+
+// writeSampleTree writes, in dir, one file per object of the sample stored in folder.
+// Files have the same path and content as in a snapshot diff patch, so such patch applies
+// to dir. Returns the number of files written.
+func writeSampleTree(storage collector.Storage, folder, dir string, logger logr.Logger) (int, error) {
+	objects, err := collector.GetClient().GetSampleObjects(storage, folder, logger)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range objects {
+		content, err := getPatchContent(storage, folder, objects[i])
+		if err != nil {
+			return 0, err
+		}
+
+		filePath := filepath.Join(dir, filepath.FromSlash(getSampleFilePath(objects[i])))
+		logger.V(logs.LogDebug).Info(fmt.Sprintf("writing %s", filePath))
+		if err := os.MkdirAll(filepath.Dir(filePath), permission0755); err != nil {
+			return 0, err
+		}
+		if err := os.WriteFile(filePath, []byte(content), permission0600); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(objects), nil
+}
+
+// exportSample writes, in dir, the objects of sample, a sample of Snapshot snapshotName.
+// Returns the number of files written.
+func exportSample(ctx context.Context, snapshotName, sample, dir string, logger logr.Logger) (int, error) {
+	logger.V(logs.LogDebug).Info(fmt.Sprintf("Getting Snapshot %s", snapshotName))
+
+	snapshotInstance := &utilsv1beta1.Snapshot{}
+	err := utils.GetAccessInstance().GetResource(ctx, types.NamespacedName{Name: snapshotName}, snapshotInstance)
+	if err != nil {
+		return 0, err
+	}
+
+	storage, err := getSnapshotStorage(ctx, snapshotInstance)
+	if err != nil {
+		return 0, err
+	}
+
+	artifactFolder, err := collector.GetClient().GetFolder(storage, snapshotName, collector.Snapshot, logger)
+	if err != nil {
+		return 0, err
+	}
+
+	folder := path.Join(*artifactFolder, sample)
+	err = verifySampleExists(storage, folder, snapshotName, logger)
+	if err != nil {
+		return 0, err
+	}
+
+	err = verifyExportDir(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	return writeSampleTree(storage, folder, dir, logger)
+}
+
+// Export writes the objects of a sample to a local directory
+func Export(ctx context.Context, args []string, logger logr.Logger) error {
+	doc := `Usage:
+	sveltosctl snapshot export [options] --snapshot=<name> --sample=<name> --dir=<path> [--verbose]
+
+     --snapshot=<name>      Name of the Snapshot instance
+     --sample=<name>        Name of the sample to export
+     --dir=<path>           Local directory where objects are written. It must not exist or be empty.
+
+Options:
+  -h --help                  Show this screen.
+     --verbose               Verbose mode. Print each step.
+
+Description:
+  The snapshot export command writes every object of the sample to a local directory, one file
+  per object in <namespace>/<kind>/<name>.yaml (<kind>/<name>.yaml for cluster wide objects).
+  Secrets are written hashed, as with the hashed Secret policy, so Secret values are never written.
+  Files are the ones snapshot diff -o patch compares, so a patch from this sample applies
+  to the exported directory with git apply.
+`
+
+	parsedArgs, err := docopt.ParseArgs(doc, nil, "1.0")
+	if err != nil {
+		logger.V(logs.LogDebug).Error(err, "failed to parse args")
+		return fmt.Errorf(
+			"invalid option: 'sveltosctl %s'. Use flag '--help' to read about a specific subcommand. Error: %w",
+			strings.Join(args, " "),
+			err,
+		)
+	}
+
+	_ = flag.Lookup("v").Value.Set(fmt.Sprint(logs.LogInfo))
+	verbose := parsedArgs["--verbose"].(bool)
+	if verbose {
+		err = flag.Lookup("v").Value.Set(fmt.Sprint(logs.LogDebug))
+		if err != nil {
+			return err
+		}
+	}
+
+	logger = textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1)))
+
+	snapshostName := parsedArgs["--snapshot"].(string)
+	sample := parsedArgs["--sample"].(string)
+	dir := parsedArgs["--dir"].(string)
+
+	count, err := exportSample(ctx, snapshostName, sample, dir, logger)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stdout, "Exported %d objects of sample %s of snapshot %s to %s\n", count, sample, snapshostName, dir)
+	return nil
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot_test
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	configv1beta1 "github.com/projectsveltos/addon-controller/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2/textlogger"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	utilsv1beta1 "github.com/projectsveltos/sveltosctl/api/v1beta1"
+	"github.com/projectsveltos/sveltosctl/internal/collector"
+	"github.com/projectsveltos/sveltosctl/internal/commands/snapshot"
+	"github.com/projectsveltos/sveltosctl/internal/utils"
+)
+
+var _ = Describe("Snapshot Export", func() {
+	var snapshotInstance *utilsv1beta1.Snapshot
+	var clusterProfile *configv1beta1.ClusterProfile
+	var secret *corev1.Secret
+	var sample string
+
+	BeforeEach(func() {
+		storageDir, err := os.MkdirTemp("", randomString())
+		Expect(err).To(BeNil())
+
+		snapshotInstance = &utilsv1beta1.Snapshot{
+			ObjectMeta: metav1.ObjectMeta{Name: randomString()},
+			Spec: utilsv1beta1.SnapshotSpec{
+				Storage: storageDir,
+			},
+		}
+
+		clusterProfile = &configv1beta1.ClusterProfile{
+			ObjectMeta: metav1.ObjectMeta{Name: randomString()},
+		}
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: randomString(), Name: randomString()},
+			Data:       map[string][]byte{"password": []byte(randomString())},
+		}
+
+		// Packed sample: objects are only stored in the objects folder
+		storage := collector.NewContentAddressedStorage(collector.NewFilesystemStorage(storageDir))
+		sample = time.Now().Add(-time.Hour).Format(timeFormat)
+		folder := path.Join("snapshot", snapshotInstance.Name, sample)
+		logger := textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1)))
+		for _, object := range []client.Object{clusterProfile.DeepCopy(), secret.DeepCopy()} {
+			Expect(collector.GetClient().DumpObject(storage, object, folder, logger)).To(Succeed())
+		}
+		Expect(collector.GetClient().WriteSampleManifest(storage, folder, nil)).To(Succeed())
+		Expect(collector.GetClient().PackCollection(storage, folder)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(snapshotInstance.Spec.Storage)
+	})
+
+	It("exportSample writes the objects of a packed sample, Secrets hashed", func() {
+		initObjects := []client.Object{snapshotInstance}
+		scheme, err := utils.GetScheme()
+		Expect(err).To(BeNil())
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initObjects...).Build()
+		utils.InitalizeManagementClusterAcces(scheme, nil, nil, c)
+
+		logger := textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1)))
+
+		dir, err := os.MkdirTemp("", randomString())
+		Expect(err).To(BeNil())
+		defer os.RemoveAll(dir)
+
+		count, err := snapshot.ExportSample(context.TODO(), snapshotInstance.Name, sample, dir, logger)
+		Expect(err).To(BeNil())
+		Expect(count).To(Equal(2))
+
+		_, err = os.Stat(filepath.Join(dir, configv1beta1.ClusterProfileKind, clusterProfile.Name+".yaml"))
+		Expect(err).To(BeNil())
+
+		data, err := os.ReadFile(filepath.Join(dir, secret.Namespace, "Secret", secret.Name+".yaml"))
+		Expect(err).To(BeNil())
+		u, err := collector.GetClient().GetUnstructured(data)
+		Expect(err).To(BeNil())
+		Expect(collector.IsSecretStoredInFull(u)).To(BeFalse())
+
+		// Directory is not empty anymore
+		_, err = snapshot.ExportSample(context.TODO(), snapshotInstance.Name, sample, dir, logger)
+		Expect(err).ToNot(BeNil())
+		Expect(err.Error()).To(ContainSubstring("not empty"))
+
+		_, err = snapshot.ExportSample(context.TODO(), snapshotInstance.Name, randomString(),
+			filepath.Join(dir, randomString()), logger)
+		Expect(err).ToNot(BeNil())
+	})
+})
@@ -17,8 +17,9 @@ limitations under the License.
 package snapshot
 
 var (
-	ListSnapshots     = listSnapshots
-	ListSnapshotDiffs = listSnapshotDiffs
+	ListSnapshots           = listSnapshots
+	ListSnapshotDiffs       = listSnapshotDiffs
+	ListSnapshotDiffsToLive = listSnapshotDiffsToLive
 
 	ListDiffInClusterConfigurations            = listDiffInClusterConfigurations
 	ListClusterConfigurationDiff               = listClusterConfigurationDiff
@@ -28,13 +29,66 @@ var (
 	AddResourceEntry                           = addResourceEntry
 	AppendChartsAndResourcesForClusterProfiles = appendChartsAndResourcesForClusterProfiles
 	ListDiff                                   = listDiff
+	ListSecretDiff                             = listSecretDiff
+	GetObjectDiffs                             = getObjectDiffs
+	ListFolderDiffsInTables                    = listFolderDiffsInTables
+	GetSampleChanges                           = getSampleChanges
+	WriteSamplePatch                           = writeSamplePatch
+	WriteSampleTree                            = writeSampleTree
+	ExportSample                               = exportSample
 
 	GetAndRollbackConfigMaps        = getAndRollbackConfigMaps
 	GetAndRollbackProfiles          = getAndRollbackProfiles
+	GetAndRollbackReferenced        = getAndRollbackReferencedResources
 	RollbackConfigMaps              = rollbackConfigMaps
 	RollbackSecrets                 = rollbackSecrets
+	PrintSkippedEntries             = printSkippedEntries
 	RollbackClusters                = rollbackClusters
 	RollbackClusterProfile          = rollbackClusterProfile
 	RollbackConfigurationToSnapshot = rollbackConfigurationToSnapshot
 	GetResourceFromResourceOwner    = getResourceFromResourceOwner
+
+	NewRollbackPlan        = newRollbackPlan
+	GetProfileMatchChanges = getProfileMatchChanges
+	DiffFields             = diffFields
+
+	GetPruneCandidates = getPruneCandidates
+	PruneResources     = pruneResources
+	ConfirmPrune       = confirmPrune
+
+	GetLatestPreRollbackSample = getLatestPreRollbackSample
+	UndoRollback               = undoRollback
+
+	RequestSample = requestSample
+
+	RotateKeyForSnapshot = rotateKey
+
+	PruneSamples = pruneSamples
+
+	AnnotateSample = annotateSample
+
+	VerifySample = verifySample
+
+	MigrateSamples = migrateSamples
+
+	GetObjectHistory = getObjectHistory
 )
+
+var (
+	ParseIgnoreFields = parseIgnoreFields
+	LoadIgnoreRules   = loadIgnoreRules
+)
+
+// NewRollbackPlanWithIgnoreRules returns a rollback plan which does not compare fields ignored by rules
+func NewRollbackPlanWithIgnoreRules(rules *IgnoreRules) *rollbackPlan {
+	plan := newRollbackPlan()
+	plan.ignore = rules
+	return plan
+}
+
+// NewAppliedRollbackPlan returns a rollback plan whose entries are applied as well
+func NewAppliedRollbackPlan() *rollbackPlan {
+	plan := newRollbackPlan()
+	plan.apply = true
+	return plan
+}
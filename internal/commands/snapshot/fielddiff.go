@@ -0,0 +1,150 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	// absentValue is displayed when a field is not set
+	absentValue = "<none>"
+)
+
+// FieldChange represents a change of a single field of an object.
+// From is nil when field is added, To is nil when field is removed.
+type FieldChange struct {
+	// Path is the field path, for instance spec.helmCharts[0].chartVersion
+	Path string      `json:"path"`
+	From interface{} `json:"from,omitempty"`
+	To   interface{} `json:"to,omitempty"`
+}
+
+// String returns a one line representation of the change
+func (f *FieldChange) String() string {
+	return fmt.Sprintf("%s: %s -> %s", f.Path, formatFieldValue(f.From), formatFieldValue(f.To))
+}
+
+// Values returns From and To formatted as in String. A value not set is returned as
+// an empty string.
+func (f *FieldChange) Values() (from, to string) {
+	if f.From != nil {
+		from = formatFieldValue(f.From)
+	}
+	if f.To != nil {
+		to = formatFieldValue(f.To)
+	}
+	return from, to
+}
+
+// diffFields compares from and to, which are unstructured content (maps, slices and
+// scalar values), and returns the list of leaf fields which differ, sorted by path.
+func diffFields(path string, from, to interface{}) []FieldChange {
+	if reflect.DeepEqual(from, to) {
+		return nil
+	}
+
+	fromMap, fromIsMap := from.(map[string]interface{})
+	toMap, toIsMap := to.(map[string]interface{})
+	if fromIsMap && toIsMap {
+		return diffMapFields(path, fromMap, toMap)
+	}
+
+	fromSlice, fromIsSlice := from.([]interface{})
+	toSlice, toIsSlice := to.([]interface{})
+	if fromIsSlice && toIsSlice {
+		return diffSliceFields(path, fromSlice, toSlice)
+	}
+
+	return []FieldChange{{Path: path, From: from, To: to}}
+}
+
+func diffMapFields(path string, from, to map[string]interface{}) []FieldChange {
+	keys := make(map[string]bool)
+	for k := range from {
+		keys[k] = true
+	}
+	for k := range to {
+		keys[k] = true
+	}
+
+	sortedKeys := make([]string, 0, len(keys))
+	for k := range keys {
+		sortedKeys = append(sortedKeys, k)
+	}
+	sort.Strings(sortedKeys)
+
+	changes := make([]FieldChange, 0)
+	for _, k := range sortedKeys {
+		changes = append(changes, diffFields(joinFieldPath(path, k), from[k], to[k])...)
+	}
+
+	return changes
+}
+
+func diffSliceFields(path string, from, to []interface{}) []FieldChange {
+	length := len(from)
+	if len(to) > length {
+		length = len(to)
+	}
+
+	changes := make([]FieldChange, 0)
+	for i := 0; i < length; i++ {
+		var fromValue, toValue interface{}
+		if i < len(from) {
+			fromValue = from[i]
+		}
+		if i < len(to) {
+			toValue = to[i]
+		}
+		changes = append(changes, diffFields(fmt.Sprintf("%s[%d]", path, i), fromValue, toValue)...)
+	}
+
+	return changes
+}
+
+// joinFieldPath appends key to path. Keys containing dots (like most label keys)
+// are quoted so path stays unambiguous.
+func joinFieldPath(path, key string) string {
+	if strings.ContainsAny(key, ".[]") {
+		return fmt.Sprintf("%s[%q]", path, key)
+	}
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func formatFieldValue(value interface{}) string {
+	if value == nil {
+		return absentValue
+	}
+
+	if s, ok := value.(string); ok {
+		return s
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(data)
+}
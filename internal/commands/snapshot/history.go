@@ -0,0 +1,352 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docopt/docopt-go"
+	"github.com/go-logr/logr"
+	"github.com/olekukonko/tablewriter"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2/textlogger"
+
+	libsveltosv1beta1 "github.com/projectsveltos/libsveltos/api/v1beta1"
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+	utilsv1beta1 "github.com/projectsveltos/sveltosctl/api/v1beta1"
+	"github.com/projectsveltos/sveltosctl/internal/collector"
+	"github.com/projectsveltos/sveltosctl/internal/output"
+	"github.com/projectsveltos/sveltosctl/internal/utils"
+)
+
+// HistoryOptions identifies the object, and optionally the field, whose history is displayed
+type HistoryOptions struct {
+	// Kind is the object kind. It is matched case insensitively.
+	Kind      string
+	Namespace string
+	Name      string
+	// Field, when set, restricts history to changes of this field (and fields below it)
+	Field string
+	// IgnoreRules contains the fields which are not compared. If nil, only built-in rules apply.
+	IgnoreRules *IgnoreRules
+}
+
+// HistoryEntry is a sample in which the object appeared, changed or was deleted.
+// It is the item type of snapshot history structured output.
+type HistoryEntry struct {
+	Sample string           `json:"sample"`
+	Action ObjectDiffAction `json:"action"`
+	// Changes contains the field level changes from the previous sample containing the object.
+	// When a field is passed, it contains its value when object appears.
+	Changes []FieldChange `json:"changes,omitempty"`
+}
+
+// getSampleObject returns the object of given kind (case insensitive), namespace and name stored
+// in the sample in folder. Returns nil if sample does not contain it.
+func getSampleObject(storage collector.Storage, folder, kind, namespace, name string,
+) (*unstructured.Unstructured, error) {
+
+	kindsFolder := path.Join(folder, namespace)
+	exist, err := storage.Exists(kindsFolder)
+	if err != nil || !exist {
+		return nil, err
+	}
+
+	entries, err := storage.ReadDir(kindsFolder)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range entries {
+		if !entries[i].IsDir || !strings.EqualFold(entries[i].Name, kind) {
+			continue
+		}
+
+		objectFile := path.Join(kindsFolder, entries[i].Name, name+".yaml")
+		exist, err = storage.Exists(objectFile)
+		if err != nil || !exist {
+			return nil, err
+		}
+		data, err := storage.ReadFile(objectFile)
+		if err != nil {
+			return nil, err
+		}
+		return collector.GetClient().GetUnstructured(data)
+	}
+
+	return nil, nil
+}
+
+// getFieldValue returns the value of the field identified by segments in content.
+// Returns nil if field is not set.
+func getFieldValue(content interface{}, segments []string) interface{} {
+	value := content
+	for _, segment := range segments {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			value = v[segment]
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil
+			}
+			value = v[index]
+		default:
+			return nil
+		}
+	}
+
+	return value
+}
+
+// getHistoryChanges returns the changes, not ignored, between from and to. If a field is
+// passed, only changes of that field are returned.
+func getHistoryChanges(from, to *unstructured.Unstructured, field string, segments []string,
+	rules *IgnoreRules) ([]FieldChange, error) {
+
+	if field == "" {
+		return getObjectChanges(from, to, rules)
+	}
+
+	changes := rules.filterChanges(to.GetKind(),
+		diffFields(field, getFieldValue(from.Object, segments), getFieldValue(to.Object, segments)))
+	if to.GetKind() == string(libsveltosv1beta1.SecretReferencedResourceKind) {
+		redactSecretChanges(changes)
+	}
+	return changes, nil
+}
+
+// getObjectHistory walks samples, from the oldest to the most recent, and returns the samples
+// in which the object appeared, changed or was deleted. Samples whose scope does not include
+// the object say nothing about it and are skipped.
+func getObjectHistory(storage collector.Storage, artifactFolder string, samples []string,
+	options *HistoryOptions, logger logr.Logger) ([]HistoryEntry, error) {
+
+	field := strings.TrimPrefix(strings.TrimPrefix(options.Field, "$"), ".")
+	var segments []string
+	if field != "" {
+		var err error
+		segments, err = parseFieldPath(field)
+		if err != nil {
+			return nil, err
+		}
+		for i := range segments {
+			if segments[i] == "*" {
+				return nil, fmt.Errorf("invalid field %q: wildcards are not supported", options.Field)
+			}
+		}
+	}
+
+	// Sample names are times formatted from the most to the least significant unit,
+	// so sorting names sorts samples by time.
+	sorted := append([]string{}, samples...)
+	sort.Strings(sorted)
+
+	entries := make([]HistoryEntry, 0)
+	var previous *unstructured.Unstructured
+	for _, sample := range sorted {
+		folder := path.Join(artifactFolder, sample)
+		current, err := getSampleObject(storage, folder, options.Kind, options.Namespace, options.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case current == nil && previous == nil:
+			continue
+		case current == nil:
+			scopes, err := getSampleScopes(storage, folder)
+			if err != nil {
+				return nil, err
+			}
+			inScope, err := isSampleObjectInScopes(scopes, previous)
+			if err != nil {
+				return nil, err
+			}
+			if !inScope {
+				logger.V(logs.LogDebug).Info(fmt.Sprintf("object is out of scope of sample %s", sample))
+				continue
+			}
+			entries = append(entries, HistoryEntry{Sample: sample, Action: ObjectDiffRemoved})
+		case previous == nil:
+			entry := HistoryEntry{Sample: sample, Action: ObjectDiffAdded}
+			if value := getFieldValue(current.Object, segments); field != "" && value != nil {
+				entry.Changes = []FieldChange{{Path: field, To: value}}
+				if current.GetKind() == string(libsveltosv1beta1.SecretReferencedResourceKind) {
+					redactSecretChanges(entry.Changes)
+				}
+			}
+			entries = append(entries, entry)
+		default:
+			changes, err := getHistoryChanges(previous, current, field, segments, options.IgnoreRules)
+			if err != nil {
+				return nil, err
+			}
+			if len(changes) > 0 {
+				entries = append(entries, HistoryEntry{Sample: sample, Action: ObjectDiffModified, Changes: changes})
+			}
+		}
+
+		previous = current
+	}
+
+	return entries, nil
+}
+
+// printHistory displays, for each sample, the action and the field level changes
+func printHistory(w io.Writer, entries []HistoryEntry) {
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"SAMPLE", "ACTION", "CHANGES"})
+	table.SetAutoWrapText(false)
+	for i := range entries {
+		changes := make([]string, len(entries[i].Changes))
+		for j := range entries[i].Changes {
+			changes[j] = entries[i].Changes[j].String()
+		}
+		table.Append([]string{entries[i].Sample, string(entries[i].Action), strings.Join(changes, "\n")})
+	}
+	table.Render()
+}
+
+func showHistory(ctx context.Context, snapshotName string, options *HistoryOptions, format output.Format,
+	logger logr.Logger) error {
+
+	instance := utils.GetAccessInstance()
+	snapshotInstance := &utilsv1beta1.Snapshot{}
+	err := instance.GetResource(ctx, types.NamespacedName{Name: snapshotName}, snapshotInstance)
+	if err != nil {
+		return err
+	}
+
+	storage, err := getSnapshotStorage(ctx, snapshotInstance)
+	if err != nil {
+		return err
+	}
+
+	snapshotClient := collector.GetClient()
+	artifactFolder, err := snapshotClient.GetFolder(storage, snapshotInstance.Name, collector.Snapshot, logger)
+	if err != nil {
+		return err
+	}
+	samples, err := snapshotClient.ListCollections(storage, snapshotInstance.Name, collector.Snapshot, logger)
+	if err != nil {
+		return err
+	}
+
+	entries, err := getObjectHistory(storage, *artifactFolder, samples, options, logger)
+	if err != nil {
+		return err
+	}
+
+	if format.IsStructured() {
+		return output.PrintList(os.Stdout, format, "SnapshotHistoryList", entries)
+	}
+
+	if len(entries) == 0 {
+		//nolint: forbidigo // indicating object was never collected
+		fmt.Printf("%s %s not found in any sample\n", options.Kind,
+			types.NamespacedName{Namespace: options.Namespace, Name: options.Name})
+		return nil
+	}
+
+	printHistory(os.Stdout, entries)
+	return nil
+}
+
+// History displays when an object, or one of its fields, changed across all samples of a snapshot
+func History(ctx context.Context, args []string, logger logr.Logger) error {
+	doc := `Usage:
+	sveltosctl snapshot history [options] --snapshot=<name> --kind=<kind> --name=<name> [--field=<path>] [--ignore-fields=<list>] [--ignore-file=<path>] [--output=<format>] [--verbose]
+
+     --snapshot=<name>      Name of the Snapshot instance
+     --kind=<kind>          Kind of the object, for instance ClusterProfile or ConfigMap.
+     --name=<name>          Name of the object, in the form namespace/name for namespaced objects.
+     --field=<path>         Show only changes of this field, for instance spec.clusterSelector.
+     --ignore-fields=<list>  Comma separated list of field paths which are not compared (see sveltosctl snapshot diff).
+     --ignore-file=<path>   YAML file containing ignore rules (see sveltosctl snapshot diff).
+
+Options:
+  -h --help                  Show this screen.
+  -o --output=<format>       Output format: table, json or yaml. Defaults to global --output, or table.
+     --verbose               Verbose mode. Print each step.
+
+Description:
+  The snapshot history command walks all samples of a snapshot, from the oldest to the most recent,
+  and lists each sample where the object (or the field) changed, with the changed fields.
+  Samples where the object appeared and where it was deleted are listed as well.
+  Samples whose scope (see Snapshot includeKinds, excludeKinds, namespaceSelector and profileSelector)
+  does not include the object are skipped. Secret values are never displayed.
+`
+
+	parsedArgs, err := docopt.ParseArgs(doc, nil, "1.0")
+	if err != nil {
+		logger.V(logs.LogDebug).Error(err, "failed to parse args")
+		return fmt.Errorf(
+			"invalid option: 'sveltosctl %s'. Use flag '--help' to read about a specific subcommand. Error: %w",
+			strings.Join(args, " "),
+			err,
+		)
+	}
+
+	_ = flag.Lookup("v").Value.Set(fmt.Sprint(logs.LogInfo))
+	verbose := parsedArgs["--verbose"].(bool)
+	if verbose {
+		err = flag.Lookup("v").Value.Set(fmt.Sprint(logs.LogDebug))
+		if err != nil {
+			return err
+		}
+	}
+
+	logger = textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1)))
+
+	format := getDefaultFormat()
+	if passedFormat := parsedArgs["--output"]; passedFormat != nil {
+		format, err = output.ParseFormat(passedFormat.(string))
+		if err != nil {
+			return err
+		}
+	}
+
+	ignoreRules, err := getIgnoreRules(parsedArgs)
+	if err != nil {
+		return err
+	}
+
+	options := &HistoryOptions{
+		Kind:        parsedArgs["--kind"].(string),
+		Name:        parsedArgs["--name"].(string),
+		IgnoreRules: ignoreRules,
+	}
+	if namespace, name, ok := strings.Cut(options.Name, "/"); ok {
+		options.Namespace = namespace
+		options.Name = name
+	}
+	if field := parsedArgs["--field"]; field != nil {
+		options.Field = field.(string)
+	}
+
+	return showHistory(ctx, parsedArgs["--snapshot"].(string), options, format, logger)
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot_test
+
+import (
+	"os"
+	"path"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2/textlogger"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	configv1beta1 "github.com/projectsveltos/addon-controller/api/v1beta1"
+	libsveltosv1beta1 "github.com/projectsveltos/libsveltos/api/v1beta1"
+	"github.com/projectsveltos/sveltosctl/internal/collector"
+	"github.com/projectsveltos/sveltosctl/internal/commands/snapshot"
+)
+
+var _ = Describe("Snapshot History", func() {
+	var storageDir string
+	var storage collector.Storage
+	var artifactFolder string
+
+	BeforeEach(func() {
+		var err error
+		storageDir, err = os.MkdirTemp("", randomString())
+		Expect(err).To(BeNil())
+		storage = collector.NewFilesystemStorage(storageDir)
+		artifactFolder = path.Join("snapshot", randomString())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(storageDir)
+	})
+
+	dumpSample := func(sample string, objects ...client.Object) {
+		folder := path.Join(artifactFolder, sample)
+		Expect(collector.GetClient().WriteSampleMetadata(storage, folder,
+			&collector.SampleMetadata{Trigger: collector.SampleTriggerOnDemand})).To(Succeed())
+		for i := range objects {
+			Expect(collector.GetClient().DumpObject(storage, objects[i], folder,
+				textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
+		}
+	}
+
+	It("getObjectHistory reports when object appeared, changed and was deleted", func() {
+		clusterProfile := &configv1beta1.ClusterProfile{
+			ObjectMeta: metav1.ObjectMeta{Name: randomString()},
+			Spec: configv1beta1.Spec{
+				ClusterSelector: libsveltosv1beta1.Selector{
+					LabelSelector: metav1.LabelSelector{MatchLabels: map[string]string{"env": "qa"}},
+				},
+			},
+		}
+
+		// Samples are passed out of order to verify they are sorted by time
+		samples := []string{"2024-03-01:10:00:00", "2024-01-01:10:00:00", "2024-04-01:10:00:00",
+			"2024-02-01:10:00:00", "2024-05-01:10:00:00"}
+		dumpSample("2024-01-01:10:00:00")
+		dumpSample("2024-02-01:10:00:00", clusterProfile.DeepCopy())
+		clusterProfile.Spec.SyncMode = configv1beta1.SyncModeContinuous
+		dumpSample("2024-03-01:10:00:00", clusterProfile.DeepCopy())
+		clusterProfile.Spec.ClusterSelector.MatchLabels["env"] = "production"
+		dumpSample("2024-04-01:10:00:00", clusterProfile.DeepCopy())
+		dumpSample("2024-05-01:10:00:00")
+
+		logger := textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1)))
+		entries, err := snapshot.GetObjectHistory(storage, artifactFolder, samples,
+			&snapshot.HistoryOptions{Kind: "clusterprofile", Name: clusterProfile.Name}, logger)
+		Expect(err).To(BeNil())
+		Expect(entries).To(HaveLen(4))
+		Expect(entries[0].Sample).To(Equal("2024-02-01:10:00:00"))
+		Expect(entries[0].Action).To(Equal(snapshot.ObjectDiffAdded))
+		Expect(entries[1].Sample).To(Equal("2024-03-01:10:00:00"))
+		Expect(entries[1].Action).To(Equal(snapshot.ObjectDiffModified))
+		Expect(entries[1].Changes).To(ConsistOf(
+			snapshot.FieldChange{Path: "spec.syncMode", To: string(configv1beta1.SyncModeContinuous)}))
+		Expect(entries[2].Sample).To(Equal("2024-04-01:10:00:00"))
+		Expect(entries[2].Changes).To(ConsistOf(
+			snapshot.FieldChange{Path: "spec.clusterSelector.matchLabels.env", From: "qa", To: "production"}))
+		Expect(entries[3].Sample).To(Equal("2024-05-01:10:00:00"))
+		Expect(entries[3].Action).To(Equal(snapshot.ObjectDiffRemoved))
+
+		// Only samples where field changed are reported
+		entries, err = snapshot.GetObjectHistory(storage, artifactFolder, samples,
+			&snapshot.HistoryOptions{Kind: configv1beta1.ClusterProfileKind, Name: clusterProfile.Name,
+				Field: "spec.clusterSelector"}, logger)
+		Expect(err).To(BeNil())
+		Expect(entries).To(HaveLen(3))
+		Expect(entries[0].Action).To(Equal(snapshot.ObjectDiffAdded))
+		Expect(entries[0].Changes).To(HaveLen(1))
+		Expect(entries[0].Changes[0].Path).To(Equal("spec.clusterSelector"))
+		Expect(entries[1].Sample).To(Equal("2024-04-01:10:00:00"))
+		Expect(entries[1].Changes).To(HaveLen(1))
+		Expect(entries[2].Action).To(Equal(snapshot.ObjectDiffRemoved))
+
+		// Ignored fields are not reported
+		entries, err = snapshot.GetObjectHistory(storage, artifactFolder, samples,
+			&snapshot.HistoryOptions{Kind: configv1beta1.ClusterProfileKind, Name: clusterProfile.Name,
+				IgnoreRules: &snapshot.IgnoreRules{Rules: []snapshot.IgnoreRule{{Fields: []string{"spec.syncMode"}}}}},
+			logger)
+		Expect(err).To(BeNil())
+		Expect(entries).To(HaveLen(3))
+
+		_, err = snapshot.GetObjectHistory(storage, artifactFolder, samples,
+			&snapshot.HistoryOptions{Kind: configv1beta1.ClusterProfileKind, Name: clusterProfile.Name,
+				Field: "spec.helmCharts[*]"}, logger)
+		Expect(err).ToNot(BeNil())
+	})
+
+	It("getObjectHistory skips samples whose scope does not include the object", func() {
+		cluster := &libsveltosv1beta1.SveltosCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: randomString(), Name: randomString(),
+				Labels: map[string]string{"env": "qa"},
+			},
+		}
+
+		dumpSample("2024-01-01:10:00:00", cluster.DeepCopy())
+		folder := path.Join(artifactFolder, "2024-02-01:10:00:00")
+		Expect(collector.GetClient().WriteSampleMetadata(storage, folder,
+			&collector.SampleMetadata{Trigger: collector.SampleTriggerOnDemand,
+				Scope: &collector.SampleScope{ExcludeKinds: []string{libsveltosv1beta1.SveltosClusterKind}}})).To(Succeed())
+		cluster.Labels["env"] = "production"
+		dumpSample("2024-03-01:10:00:00", cluster.DeepCopy())
+
+		samples := []string{"2024-01-01:10:00:00", "2024-02-01:10:00:00", "2024-03-01:10:00:00"}
+		entries, err := snapshot.GetObjectHistory(storage, artifactFolder, samples,
+			&snapshot.HistoryOptions{Kind: libsveltosv1beta1.SveltosClusterKind, Namespace: cluster.Namespace,
+				Name: cluster.Name}, textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
+		Expect(err).To(BeNil())
+		Expect(entries).To(HaveLen(2))
+		Expect(entries[0].Action).To(Equal(snapshot.ObjectDiffAdded))
+		Expect(entries[1].Sample).To(Equal("2024-03-01:10:00:00"))
+		Expect(entries[1].Action).To(Equal(snapshot.ObjectDiffModified))
+		Expect(entries[1].Changes).To(ConsistOf(
+			snapshot.FieldChange{Path: "metadata.labels.env", From: "qa", To: "production"}))
+	})
+})
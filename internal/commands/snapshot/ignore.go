@@ -0,0 +1,305 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/docopt/docopt-go"
+	"sigs.k8s.io/yaml"
+)
+
+// IgnoreRule lists fields which are never compared
+type IgnoreRule struct {
+	// Kind restricts the rule to objects of this kind. If empty, rule applies to every object.
+	Kind string `json:"kind,omitempty"`
+
+	// Fields are JSONPath-style field paths, for instance metadata.annotations["example.com/owner"]
+	// or spec.helmCharts[*].values. Fields below a listed field are ignored as well.
+	Fields []string `json:"fields"`
+}
+
+// IgnoreRules contains the fields which snapshot diff and rollback do not compare.
+// A nil IgnoreRules applies the built-in rules only.
+type IgnoreRules struct {
+	// SkipDefaults, when set, causes the built-in rules not to be applied
+	SkipDefaults bool `json:"skipDefaults,omitempty"`
+
+	Rules []IgnoreRule `json:"rules,omitempty"`
+}
+
+// defaultIgnoreRules are always applied unless SkipDefaults is set.
+// Status and fields set by the API server or by kubectl change even when configuration
+// does not. Changes reflected by status are reported from ClusterConfigurations.
+var defaultIgnoreRules = []IgnoreRule{
+	{
+		Fields: []string{
+			"status",
+			"metadata.resourceVersion",
+			"metadata.uid",
+			"metadata.generation",
+			"metadata.creationTimestamp",
+			"metadata.managedFields",
+			`metadata.annotations["kubectl.kubernetes.io/last-applied-configuration"]`,
+		},
+	},
+}
+
+// getRules returns all the rules to apply, built-in ones included
+func (r *IgnoreRules) getRules() []IgnoreRule {
+	if r == nil {
+		return defaultIgnoreRules
+	}
+	if r.SkipDefaults {
+		return r.Rules
+	}
+	return append(append([]IgnoreRule{}, defaultIgnoreRules...), r.Rules...)
+}
+
+// isIgnored returns true if field path of an object of given kind matches any rule
+func (r *IgnoreRules) isIgnored(kind, path string) bool {
+	field, err := parseFieldPath(path)
+	if err != nil {
+		return false
+	}
+
+	rules := r.getRules()
+	for i := range rules {
+		if rules[i].Kind != "" && !strings.EqualFold(rules[i].Kind, kind) {
+			continue
+		}
+		for j := range rules[i].Fields {
+			// Rules are validated when loaded
+			ignored, _ := parseFieldPath(rules[i].Fields[j])
+			if isFieldPathPrefix(ignored, field) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// filterChanges returns the changes, of an object of given kind, which are not ignored
+func (r *IgnoreRules) filterChanges(kind string, changes []FieldChange) []FieldChange {
+	result := make([]FieldChange, 0, len(changes))
+	for i := range changes {
+		if !r.isIgnored(kind, changes[i].Path) {
+			result = append(result, changes[i])
+		}
+	}
+
+	return result
+}
+
+// validate returns an error if any rule contains an invalid field path
+func (r *IgnoreRules) validate() error {
+	for i := range r.Rules {
+		for j := range r.Rules[i].Fields {
+			if _, err := parseFieldPath(r.Rules[i].Fields[j]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// isFieldPathPrefix returns true if field is, or is below, ignored. A "*" segment in
+// ignored matches any key or index.
+func isFieldPathPrefix(ignored, field []string) bool {
+	if len(ignored) == 0 || len(ignored) > len(field) {
+		return false
+	}
+
+	for i := range ignored {
+		if ignored[i] != "*" && ignored[i] != field[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// parseFieldPath splits a JSONPath-style field path in its segments. Both field paths reported
+// in FieldChange (spec.helmCharts[0].chartVersion, metadata.labels["example.com/env"]) and
+// paths starting with $ or with a dot are accepted.
+func parseFieldPath(path string) ([]string, error) {
+	rest := strings.TrimPrefix(strings.TrimSpace(path), "$")
+	segments := make([]string, 0)
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			if rest == "" || rest[0] == '.' || rest[0] == '[' {
+				return nil, fmt.Errorf("invalid field path %q: empty field name", path)
+			}
+		case '[':
+			segment, length, err := parseBracketSegment(rest)
+			if err != nil {
+				return nil, fmt.Errorf("invalid field path %q: %w", path, err)
+			}
+			segments = append(segments, segment)
+			rest = rest[length:]
+		default:
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			segments = append(segments, rest[:end])
+			rest = rest[end:]
+		}
+	}
+
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("invalid field path %q: empty path", path)
+	}
+	return segments, nil
+}
+
+// parseBracketSegment parses the segment at the beginning of value, which starts with [.
+// Returns the segment and the number of characters it takes.
+func parseBracketSegment(value string) (segment string, length int, err error) {
+	if len(value) > 1 && (value[1] == '"' || value[1] == '\'') {
+		quote := value[1]
+		end := 2
+		for end < len(value) && value[end] != quote {
+			if value[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end+1 >= len(value) || value[end+1] != ']' {
+			return "", 0, fmt.Errorf("unterminated quoted key")
+		}
+		quoted := value[1 : end+1]
+		if quote == '"' {
+			segment, err = strconv.Unquote(quoted)
+			if err != nil {
+				return "", 0, err
+			}
+		} else {
+			segment = quoted[1 : len(quoted)-1]
+		}
+		return segment, end + 2, nil
+	}
+
+	end := strings.Index(value, "]")
+	if end < 0 {
+		return "", 0, fmt.Errorf("missing ]")
+	}
+	segment = strings.TrimSpace(value[1:end])
+	if segment == "" {
+		return "", 0, fmt.Errorf("empty index")
+	}
+	return segment, end + 1, nil
+}
+
+// parseIgnoreFields parses a comma separated list of field paths. Each path can be
+// prefixed by kind and colon (for instance ConfigMap:metadata.labels) to be ignored
+// only for objects of that kind.
+func parseIgnoreFields(value string) ([]IgnoreRule, error) {
+	rules := make([]IgnoreRule, 0)
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		rule := IgnoreRule{}
+		if i := strings.Index(field, ":"); i > 0 && !strings.ContainsAny(field[:i], ".[$") {
+			rule.Kind = field[:i]
+			field = field[i+1:]
+		}
+		if _, err := parseFieldPath(field); err != nil {
+			return nil, err
+		}
+		rule.Fields = []string{field}
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// loadIgnoreRules reads the ignore rules from the YAML (or JSON) file
+func loadIgnoreRules(file string) (*IgnoreRules, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := &IgnoreRules{}
+	err = yaml.UnmarshalStrict(data, rules)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ignore rules file %s: %w", file, err)
+	}
+
+	if err := rules.validate(); err != nil {
+		return nil, fmt.Errorf("invalid ignore rules file %s: %w", file, err)
+	}
+
+	return rules, nil
+}
+
+// NewIgnoreRules returns the ignore rules built from fields, field paths in the same
+// format as --ignore-fields. Returns nil, so only built-in rules are applied, if fields
+// is empty and skipDefaults is not set.
+func NewIgnoreRules(fields []string, skipDefaults bool) (*IgnoreRules, error) {
+	if len(fields) == 0 && !skipDefaults {
+		return nil, nil
+	}
+
+	rules := &IgnoreRules{SkipDefaults: skipDefaults}
+	for i := range fields {
+		fieldRules, err := parseIgnoreFields(fields[i])
+		if err != nil {
+			return nil, err
+		}
+		rules.Rules = append(rules.Rules, fieldRules...)
+	}
+
+	return rules, nil
+}
+
+// getIgnoreRules returns the ignore rules passed with --ignore-file and --ignore-fields.
+// Returns nil, so only built-in rules are applied, if neither is passed.
+func getIgnoreRules(parsedArgs docopt.Opts) (*IgnoreRules, error) {
+	var rules *IgnoreRules
+	if file := parsedArgs["--ignore-file"]; file != nil {
+		var err error
+		rules, err = loadIgnoreRules(file.(string))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if fields := parsedArgs["--ignore-fields"]; fields != nil {
+		fieldRules, err := parseIgnoreFields(fields.(string))
+		if err != nil {
+			return nil, err
+		}
+		if rules == nil {
+			rules = &IgnoreRules{}
+		}
+		rules.Rules = append(rules.Rules, fieldRules...)
+	}
+
+	return rules, nil
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot_test
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2/textlogger"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	configv1beta1 "github.com/projectsveltos/addon-controller/api/v1beta1"
+	libsveltosv1beta1 "github.com/projectsveltos/libsveltos/api/v1beta1"
+	"github.com/projectsveltos/sveltosctl/internal/collector"
+	"github.com/projectsveltos/sveltosctl/internal/commands/snapshot"
+	"github.com/projectsveltos/sveltosctl/internal/utils"
+)
+
+const (
+	ignoreRulesFile = `rules:
+- kind: ClusterProfile
+  fields:
+  - spec.clusterSelector.matchLabels
+- fields:
+  - $.metadata.annotations["example.com/build"]
+`
+)
+
+var _ = Describe("Snapshot Ignore Rules", func() {
+	var storageDir string
+	var storage collector.Storage
+
+	BeforeEach(func() {
+		var err error
+		storageDir, err = os.MkdirTemp("", randomString())
+		Expect(err).To(BeNil())
+		storage = collector.NewFilesystemStorage(storageDir)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(storageDir)
+	})
+
+	dumpSample := func(folder string, objects ...client.Object) {
+		for i := range objects {
+			Expect(collector.GetClient().DumpObject(storage, objects[i], folder,
+				textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
+		}
+	}
+
+	It("getObjectDiffs does not report fields ignored by built-in, file and flag rules", func() {
+		clusterProfile := &configv1beta1.ClusterProfile{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        randomString(),
+				Annotations: map[string]string{"example.com/build": "1"},
+			},
+			Spec: configv1beta1.Spec{
+				ClusterSelector: libsveltosv1beta1.Selector{
+					LabelSelector: metav1.LabelSelector{MatchLabels: map[string]string{"env": "qa"}},
+				},
+			},
+		}
+		cluster := &libsveltosv1beta1.SveltosCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: randomString(), Name: randomString(),
+				Labels:            map[string]string{"env": "qa", "example.com/zone": "a"},
+				Annotations:       map[string]string{"example.com/build": "1"},
+				CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Hour)),
+			},
+		}
+
+		fromFolder := path.Join("snapshot", randomString(), "from")
+		dumpSample(fromFolder, clusterProfile.DeepCopy(), cluster.DeepCopy())
+
+		clusterProfile.Annotations["example.com/build"] = "2"
+		clusterProfile.Spec.ClusterSelector.MatchLabels["env"] = "production"
+		cluster.Annotations["example.com/build"] = "2"
+		cluster.Labels["example.com/zone"] = "b"
+		cluster.CreationTimestamp = metav1.NewTime(time.Now())
+
+		toFolder := path.Join("snapshot", randomString(), "to")
+		dumpSample(toFolder, clusterProfile, cluster)
+
+		rulesFile := filepath.Join(storageDir, "ignore.yaml")
+		Expect(os.WriteFile(rulesFile, []byte(ignoreRulesFile), 0600)).To(Succeed())
+		rules, err := snapshot.LoadIgnoreRules(rulesFile)
+		Expect(err).To(BeNil())
+		fieldRules, err := snapshot.ParseIgnoreFields(`SveltosCluster:metadata.labels["example.com/zone"]`)
+		Expect(err).To(BeNil())
+		rules.Rules = append(rules.Rules, fieldRules...)
+
+		logger := textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1)))
+		scopes := []*collector.SampleScope{nil, nil}
+		diffs, err := snapshot.GetObjectDiffs(storage, fromFolder, toFolder,
+			&snapshot.DiffOptions{IgnoreRules: rules}, scopes, logger)
+		Expect(err).To(BeNil())
+		Expect(diffs).To(BeEmpty())
+
+		// Without rules, only built-in ones (creationTimestamp) apply
+		diffs, err = snapshot.GetObjectDiffs(storage, fromFolder, toFolder, &snapshot.DiffOptions{}, scopes, logger)
+		Expect(err).To(BeNil())
+		Expect(diffs).To(HaveLen(2))
+		Expect(diffs[1].Changes).To(ConsistOf(
+			snapshot.FieldChange{Path: `metadata.annotations["example.com/build"]`, From: "1", To: "2"},
+			snapshot.FieldChange{Path: `metadata.labels["example.com/zone"]`, From: "a", To: "b"},
+		))
+
+		// Built-in rules can be skipped
+		rules.SkipDefaults = true
+		diffs, err = snapshot.GetObjectDiffs(storage, fromFolder, toFolder,
+			&snapshot.DiffOptions{IgnoreRules: rules}, scopes, logger)
+		Expect(err).To(BeNil())
+		Expect(diffs).To(HaveLen(1))
+		Expect(diffs[0].Kind).To(Equal(libsveltosv1beta1.SveltosClusterKind))
+		Expect(diffs[0].Changes).To(HaveLen(1))
+		Expect(diffs[0].Changes[0].Path).To(Equal("metadata.creationTimestamp"))
+	})
+
+	It("parseIgnoreFields accepts wildcards and kind prefixes and rejects invalid paths", func() {
+		rules, err := snapshot.ParseIgnoreFields(`spec.helmCharts[*].values, ConfigMap:data["app.conf"]`)
+		Expect(err).To(BeNil())
+		Expect(rules).To(Equal([]snapshot.IgnoreRule{
+			{Fields: []string{"spec.helmCharts[*].values"}},
+			{Kind: "ConfigMap", Fields: []string{`data["app.conf"]`}},
+		}))
+
+		for _, invalid := range []string{"spec..helmCharts", "spec.helmCharts[0", `metadata.labels["env]`, "spec[]"} {
+			_, err = snapshot.ParseIgnoreFields(invalid)
+			Expect(err).ToNot(BeNil(), invalid)
+		}
+
+		rulesFile := filepath.Join(storageDir, "ignore.yaml")
+		Expect(os.WriteFile(rulesFile, []byte("rules:\n- fields: [spec..x]\n"), 0600)).To(Succeed())
+		_, err = snapshot.LoadIgnoreRules(rulesFile)
+		Expect(err).ToNot(BeNil())
+
+		Expect(os.WriteFile(rulesFile, []byte("ignore: [spec]\n"), 0600)).To(Succeed())
+		_, err = snapshot.LoadIgnoreRules(rulesFile)
+		Expect(err).ToNot(BeNil())
+	})
+
+	It("rollback plan leaves unchanged objects whose only differences are ignored", func() {
+		name := randomString()
+		namespace := randomString()
+		configMap := getConfigMap(namespace, name)
+
+		scheme, err := utils.GetScheme()
+		Expect(err).To(BeNil())
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(configMap).Build()
+		utils.InitalizeManagementClusterAcces(scheme, nil, nil, c)
+
+		currentConfigMap := &corev1.ConfigMap{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: name},
+			currentConfigMap)).To(Succeed())
+		updateConfigMapData(currentConfigMap)
+
+		plan := snapshot.NewRollbackPlanWithIgnoreRules(&snapshot.IgnoreRules{
+			Rules: []snapshot.IgnoreRule{{Kind: "configmap", Fields: []string{"data"}}},
+		})
+		Expect(snapshot.RollbackConfigMaps(context.TODO(), []*unstructured.Unstructured{configMap}, plan,
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
+		Expect(plan.Entries).To(HaveLen(1))
+		Expect(plan.Entries[0].Action).To(Equal(snapshot.RollbackActionUnchanged))
+		Expect(plan.Entries[0].Changes).To(BeEmpty())
+
+		plan = snapshot.NewRollbackPlan()
+		Expect(snapshot.RollbackConfigMaps(context.TODO(), []*unstructured.Unstructured{configMap}, plan,
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
+		Expect(plan.Entries).To(HaveLen(1))
+		Expect(plan.Entries[0].Action).To(Equal(snapshot.RollbackActionUpdate))
+	})
+})
@@ -21,6 +21,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"path"
 	"strings"
 
 	"github.com/docopt/docopt-go"
@@ -37,11 +38,19 @@ import (
 var (
 	// snapshotName is the name of the Snaphost instance which caused a snapshot to be collected
 	// snapshotDate is a string containing the Date a snapshot was taken
-	genListSnapshotRow = func(snaphostName, snapshotDate string,
+	// pinned indicates whether the snapshot is pinned
+	// note is the note set on the snapshot, if any
+	genListSnapshotRow = func(snaphostName, snapshotDate string, pinned bool, note string,
 	) []string {
+		pinnedValue := ""
+		if pinned {
+			pinnedValue = "yes"
+		}
 		return []string{
 			snaphostName,
 			snapshotDate,
+			pinnedValue,
+			note,
 		}
 	}
 )
@@ -56,7 +65,7 @@ func doConsiderSnapshot(snaphostInstance *utilsv1beta1.Snapshot, passedSnapshot
 
 func listSnapshots(ctx context.Context, passedSnapshotName string, logger logr.Logger) error {
 	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"SNAPSHOT POLICY", "DATE"})
+	table.SetHeader([]string{"SNAPSHOT POLICY", "DATE", "PINNED", "NOTE"})
 
 	if err := displaySnapshots(ctx, passedSnapshotName, table, logger); err != nil {
 		return err
@@ -79,7 +88,7 @@ func displaySnapshots(ctx context.Context, passedSnapshotName string,
 	}
 	for i := range snapshotList.Items {
 		if doConsiderSnapshot(&snapshotList.Items[i], passedSnapshotName) {
-			err = displaySnapshot(&snapshotList.Items[i], table, logger)
+			err = displaySnapshot(ctx, &snapshotList.Items[i], table, logger)
 			if err != nil {
 				return nil
 			}
@@ -89,18 +98,31 @@ func displaySnapshots(ctx context.Context, passedSnapshotName string,
 	return nil
 }
 
-func displaySnapshot(snapshotInstance *utilsv1beta1.Snapshot,
+func displaySnapshot(ctx context.Context, snapshotInstance *utilsv1beta1.Snapshot,
 	table *tablewriter.Table, logger logr.Logger) error {
 
 	logger.V(logs.LogDebug).Info(fmt.Sprintf("Considering Snapshot instance %s", snapshotInstance.Name))
+	storage, err := getSnapshotStorage(ctx, snapshotInstance)
+	if err != nil {
+		return err
+	}
+
 	snapshotClient := collector.GetClient()
-	results, err := snapshotClient.ListCollections(snapshotInstance.Spec.Storage, snapshotInstance.Name,
+	results, err := snapshotClient.ListCollections(storage, snapshotInstance.Name,
 		collector.Snapshot, logger)
 	if err != nil {
 		return err
 	}
+	artifactFolder, err := snapshotClient.GetFolder(storage, snapshotInstance.Name, collector.Snapshot, logger)
+	if err != nil {
+		return err
+	}
 	for i := range results {
-		table.Append(genListSnapshotRow(snapshotInstance.Name, results[i]))
+		annotations, err := snapshotClient.GetSampleAnnotations(storage, path.Join(*artifactFolder, results[i]))
+		if err != nil {
+			return err
+		}
+		table.Append(genListSnapshotRow(snapshotInstance.Name, results[i], annotations.Pinned, annotations.Note))
 	}
 	return nil
 }
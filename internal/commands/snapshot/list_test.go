@@ -82,12 +82,12 @@ var _ = Describe("Snapshot List", func() {
 		Expect(err).To(BeNil())
 		/*
 		  // Following is an example of snapshot list
-		   +-----------------+---------------------+
-		   | SNAPSHOT POLICY |        DATE         |
-		   +-----------------+---------------------+
-		   | daily           | 2022-10-07:01:10:59 |
-		   | daily           | 2022-10-07:02:10:56 |
-		   +-----------------+---------------------+
+		   +-----------------+---------------------+--------+------+
+		   | SNAPSHOT POLICY |        DATE         | PINNED | NOTE |
+		   +-----------------+---------------------+--------+------+
+		   | daily           | 2022-10-07:01:10:59 |        |      |
+		   | daily           | 2022-10-07:02:10:56 |        |      |
+		   +-----------------+---------------------+--------+------+
 		*/
 
 		foundCollection := 0
@@ -0,0 +1,130 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot
+
+import (
+	"context"
+	"crypto/ed25519"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/docopt/docopt-go"
+	"github.com/go-logr/logr"
+	"github.com/olekukonko/tablewriter"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2/textlogger"
+
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+	utilsv1beta1 "github.com/projectsveltos/sveltosctl/api/v1beta1"
+	"github.com/projectsveltos/sveltosctl/internal/collector"
+	"github.com/projectsveltos/sveltosctl/internal/utils"
+)
+
+// migrateSamples moves the objects of every sample of Snapshot snapshotName still stored
+// as a directory of objects to the content-addressed objects folder. If dryRun is set,
+// nothing is modified.
+// Returns the samples migrated (or which would be migrated).
+func migrateSamples(ctx context.Context, snapshotName string, dryRun bool, logger logr.Logger) ([]string, error) {
+	logger.V(logs.LogDebug).Info(fmt.Sprintf("Getting Snapshot %s", snapshotName))
+
+	snapshotInstance := &utilsv1beta1.Snapshot{}
+	err := utils.GetAccessInstance().GetResource(ctx, types.NamespacedName{Name: snapshotName}, snapshotInstance)
+	if err != nil {
+		return nil, err
+	}
+
+	storage, err := getSnapshotStorage(ctx, snapshotInstance)
+	if err != nil {
+		return nil, err
+	}
+
+	// Samples taken before manifests were introduced get one, signed if the Snapshot signs manifests
+	var signingKey ed25519.PrivateKey
+	if snapshotInstance.Spec.Signing != nil && !dryRun {
+		signingKey, err = collector.GetSigningKey(ctx, utils.GetAccessInstance().GetClient(), snapshotInstance.Spec.Signing)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return collector.GetClient().MigrateCollections(storage, snapshotName, collector.Snapshot,
+		signingKey, dryRun, logger)
+}
+
+// Migrate moves samples to the content-addressed layout
+func Migrate(ctx context.Context, args []string, logger logr.Logger) error {
+	doc := `Usage:
+	sveltosctl snapshot migrate [options] --snapshot=<name> [--dry-run] [--verbose]
+
+     --snapshot=<name>      Name of the Snapshot instance
+
+Options:
+  -h --help                  Show this screen.
+     --dry-run               Only lists the samples which would be migrated.
+     --verbose               Verbose mode. Print each step.
+
+Description:
+  The snapshot migrate command moves the objects of samples stored as a directory of objects
+  (samples taken by previous versions) to the objects folder shared by all samples of the Snapshot,
+  where identical objects are stored only once. Samples without manifest get one first.
+  Run it while no collection is in progress for the Snapshot.
+`
+
+	parsedArgs, err := docopt.ParseArgs(doc, nil, "1.0")
+	if err != nil {
+		logger.V(logs.LogDebug).Error(err, "failed to parse args")
+		return fmt.Errorf(
+			"invalid option: 'sveltosctl %s'. Use flag '--help' to read about a specific subcommand. Error: %w",
+			strings.Join(args, " "),
+			err,
+		)
+	}
+
+	_ = flag.Lookup("v").Value.Set(fmt.Sprint(logs.LogInfo))
+	verbose := parsedArgs["--verbose"].(bool)
+	if verbose {
+		err = flag.Lookup("v").Value.Set(fmt.Sprint(logs.LogDebug))
+		if err != nil {
+			return err
+		}
+	}
+
+	logger = textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1)))
+
+	snapshostName := parsedArgs["--snapshot"].(string)
+	dryRun := parsedArgs["--dry-run"].(bool)
+
+	samples, err := migrateSamples(ctx, snapshostName, dryRun, logger)
+	if err != nil {
+		return err
+	}
+
+	action := "MIGRATED"
+	if dryRun {
+		action = "WOULD BE MIGRATED"
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"SNAPSHOT POLICY", "DATE", "ACTION"})
+	for i := range samples {
+		table.Append([]string{snapshostName, samples[i], action})
+	}
+	table.Render()
+
+	return nil
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot_test
+
+import (
+	"context"
+	"os"
+	"path"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	configv1beta1 "github.com/projectsveltos/addon-controller/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2/textlogger"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	utilsv1beta1 "github.com/projectsveltos/sveltosctl/api/v1beta1"
+	"github.com/projectsveltos/sveltosctl/internal/collector"
+	"github.com/projectsveltos/sveltosctl/internal/commands/snapshot"
+	"github.com/projectsveltos/sveltosctl/internal/utils"
+)
+
+var _ = Describe("Snapshot Migrate", func() {
+	var snapshotInstance *utilsv1beta1.Snapshot
+	var clusterProfile *configv1beta1.ClusterProfile
+	var sample string
+
+	BeforeEach(func() {
+		storageDir, err := os.MkdirTemp("", randomString())
+		Expect(err).To(BeNil())
+
+		snapshotInstance = &utilsv1beta1.Snapshot{
+			ObjectMeta: metav1.ObjectMeta{Name: randomString()},
+			Spec: utilsv1beta1.SnapshotSpec{
+				Storage: storageDir,
+			},
+		}
+
+		clusterProfile = &configv1beta1.ClusterProfile{
+			ObjectMeta: metav1.ObjectMeta{Name: randomString()},
+		}
+
+		// Sample stored as a directory of objects, without manifest
+		sample = time.Now().Add(-time.Hour).Format(timeFormat)
+		Expect(collector.GetClient().DumpObject(collector.NewFilesystemStorage(storageDir), clusterProfile.DeepCopy(),
+			path.Join("snapshot", snapshotInstance.Name, sample),
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(snapshotInstance.Spec.Storage)
+	})
+
+	It("migrateSamples packs samples which can then be verified and restored", func() {
+		initObjects := []client.Object{snapshotInstance}
+		scheme, err := utils.GetScheme()
+		Expect(err).To(BeNil())
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initObjects...).Build()
+		utils.InitalizeManagementClusterAcces(scheme, nil, nil, c)
+
+		logger := textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1)))
+
+		migrated, err := snapshot.MigrateSamples(context.TODO(), snapshotInstance.Name, true, logger)
+		Expect(err).To(BeNil())
+		Expect(migrated).To(ConsistOf(sample))
+		_, err = snapshot.VerifySample(context.TODO(), snapshotInstance.Name, sample, logger)
+		Expect(err).ToNot(BeNil())
+
+		migrated, err = snapshot.MigrateSamples(context.TODO(), snapshotInstance.Name, false, logger)
+		Expect(err).To(BeNil())
+		Expect(migrated).To(ConsistOf(sample))
+
+		_, err = os.Stat(path.Join(snapshotInstance.Spec.Storage, "snapshot", snapshotInstance.Name, sample,
+			configv1beta1.ClusterProfileKind))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+
+		manifest, err := snapshot.VerifySample(context.TODO(), snapshotInstance.Name, sample, logger)
+		Expect(err).To(BeNil())
+		Expect(manifest.Counts).To(Equal(map[string]int{configv1beta1.ClusterProfileKind: 1}))
+
+		_, err = snapshot.ExecuteRollback(context.TODO(), snapshotInstance.Name, sample, &snapshot.RollbackFilters{},
+			&snapshot.RollbackOptions{SkipConfirmation: true}, logger)
+		Expect(err).To(BeNil())
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: clusterProfile.Name},
+			&configv1beta1.ClusterProfile{})).To(Succeed())
+	})
+})
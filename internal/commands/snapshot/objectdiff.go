@@ -0,0 +1,253 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/olekukonko/tablewriter"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	libsveltosv1beta1 "github.com/projectsveltos/libsveltos/api/v1beta1"
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+	"github.com/projectsveltos/sveltosctl/internal/collector"
+)
+
+// ObjectDiffAction is the type of change of an object between two samples
+type ObjectDiffAction string
+
+const (
+	// ObjectDiffAdded indicates object is only in the to sample
+	ObjectDiffAdded = ObjectDiffAction("added")
+	// ObjectDiffRemoved indicates object is only in the from sample
+	ObjectDiffRemoved = ObjectDiffAction("removed")
+	// ObjectDiffModified indicates object is in both samples with different content
+	ObjectDiffModified = ObjectDiffAction("modified")
+)
+
+// ObjectDiff represents an object added, removed or modified between two samples
+type ObjectDiff struct {
+	Kind      string           `json:"kind"`
+	Namespace string           `json:"namespace,omitempty"`
+	Name      string           `json:"name"`
+	Action    ObjectDiffAction `json:"action"`
+
+	// Changes contains the field level changes of a modified object
+	Changes []FieldChange `json:"changes,omitempty"`
+}
+
+// isKindSelected returns true if kind is one of kinds. No kinds means every kind is selected.
+func isKindSelected(kinds []string, kind string) bool {
+	if len(kinds) == 0 {
+		return true
+	}
+
+	for i := range kinds {
+		if strings.EqualFold(kinds[i], kind) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getFieldChanges returns the changes between from and to, two versions of the same object,
+// which are not ignored by rules
+func getFieldChanges(from, to *unstructured.Unstructured, rules *IgnoreRules) []FieldChange {
+	return rules.filterChanges(to.GetKind(), diffFields("", from.UnstructuredContent(), to.UnstructuredContent()))
+}
+
+// withoutSecretData returns a copy of secret without data, stringData and the annotation
+// recording how Secret was stored
+func withoutSecretData(secret *unstructured.Unstructured) *unstructured.Unstructured {
+	result := secret.DeepCopy()
+	unstructured.RemoveNestedField(result.Object, "data")
+	unstructured.RemoveNestedField(result.Object, "stringData")
+
+	annotations := result.GetAnnotations()
+	delete(annotations, collector.SecretModeAnnotation)
+	if len(annotations) == 0 {
+		annotations = nil
+	}
+	result.SetAnnotations(annotations)
+
+	return result
+}
+
+// getSecretChanges returns the changes, not ignored by rules, between from and to, two versions
+// of the same Secret. Data is compared by digests, so a Secret stored hashed and the same Secret
+// stored in full have no changes. Values are redacted.
+func getSecretChanges(from, to *unstructured.Unstructured, rules *IgnoreRules) ([]FieldChange, error) {
+	fromDigests, err := collector.GetSecretDigests(from)
+	if err != nil {
+		return nil, err
+	}
+	toDigests, err := collector.GetSecretDigests(to)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := rules.filterChanges(to.GetKind(), diffFields("data", toUnstructuredMap(fromDigests),
+		toUnstructuredMap(toDigests)))
+	changes = append(changes, getFieldChanges(withoutSecretData(from), withoutSecretData(to), rules)...)
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+
+	redactSecretChanges(changes)
+	return changes, nil
+}
+
+func toUnstructuredMap(values map[string]string) map[string]interface{} {
+	result := make(map[string]interface{}, len(values))
+	for k := range values {
+		result[k] = values[k]
+	}
+	return result
+}
+
+// getObjectChanges returns the changes, not ignored by rules, between from and to, two versions
+// of the same object. Secret values are never returned.
+func getObjectChanges(from, to *unstructured.Unstructured, rules *IgnoreRules) ([]FieldChange, error) {
+	if to.GetKind() == string(libsveltosv1beta1.SecretReferencedResourceKind) {
+		return getSecretChanges(from, to, rules)
+	}
+
+	return getFieldChanges(from, to, rules), nil
+}
+
+func getObjectDiffKey(kind, namespace, name string) string {
+	return fmt.Sprintf("%s:%s/%s", kind, namespace, name)
+}
+
+// isSampleObjectInScopes returns true if object is in scope of all samples.
+// ConfigMaps and Secrets are only collected when referenced.
+func isSampleObjectInScopes(scopes []*collector.SampleScope, object *unstructured.Unstructured) (bool, error) {
+	kind := object.GetKind()
+	if kind == string(libsveltosv1beta1.ConfigMapReferencedResourceKind) ||
+		kind == string(libsveltosv1beta1.SecretReferencedResourceKind) {
+
+		return isReferencedObjectInScopes(scopes, kind, object), nil
+	}
+
+	return isObjectInScopes(scopes, kind, object)
+}
+
+// getSampleObjectMap returns the objects, of the selected kinds and in scope of all samples,
+// stored in the sample in folder. Namespaced objects are returned only if in passedNamespace
+// (when set).
+func getSampleObjectMap(storage collector.Storage, folder string, kinds []string, passedNamespace string,
+	scopes []*collector.SampleScope, logger logr.Logger) (map[string]*unstructured.Unstructured, error) {
+
+	objects, err := collector.GetClient().GetSampleObjects(storage, folder, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]*unstructured.Unstructured, len(objects))
+	for i := range objects {
+		object := objects[i]
+		if !isKindSelected(kinds, object.GetKind()) {
+			continue
+		}
+		if object.GetNamespace() != "" && !doConsiderNamespace(object.GetNamespace(), passedNamespace) {
+			continue
+		}
+		inScope, err := isSampleObjectInScopes(scopes, object)
+		if err != nil {
+			return nil, err
+		}
+		if inScope {
+			result[getObjectDiffKey(object.GetKind(), object.GetNamespace(), object.GetName())] = object
+		}
+	}
+
+	logger.V(logs.LogDebug).Info(fmt.Sprintf("found %d objects to compare in folder %s", len(result), folder))
+	return result, nil
+}
+
+// getObjectDiffs returns, sorted by kind, namespace and name, the objects added, removed
+// or modified between the samples stored in fromFolder and toFolder. For modified objects
+// the field level changes, but the ones ignored by options.IgnoreRules, are returned.
+// Secrets are compared by digests and their values are never returned.
+func getObjectDiffs(storage collector.Storage, fromFolder, toFolder string, options *DiffOptions,
+	scopes []*collector.SampleScope, logger logr.Logger) ([]ObjectDiff, error) {
+
+	fromMap, err := getSampleObjectMap(storage, fromFolder, options.Kinds, options.Namespace, scopes, logger)
+	if err != nil {
+		return nil, err
+	}
+	toMap, err := getSampleObjectMap(storage, toFolder, options.Kinds, options.Namespace, scopes, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	diffs := make([]ObjectDiff, 0)
+	for key, to := range toMap {
+		diff := ObjectDiff{Kind: to.GetKind(), Namespace: to.GetNamespace(), Name: to.GetName()}
+		from, ok := fromMap[key]
+		if !ok {
+			diff.Action = ObjectDiffAdded
+			diffs = append(diffs, diff)
+			continue
+		}
+		diff.Changes, err = getObjectChanges(from, to, options.IgnoreRules)
+		if err != nil {
+			return nil, err
+		}
+		if len(diff.Changes) == 0 {
+			continue
+		}
+		diff.Action = ObjectDiffModified
+		diffs = append(diffs, diff)
+	}
+
+	for key, from := range fromMap {
+		if _, ok := toMap[key]; !ok {
+			diffs = append(diffs, ObjectDiff{Kind: from.GetKind(), Namespace: from.GetNamespace(),
+				Name: from.GetName(), Action: ObjectDiffRemoved})
+		}
+	}
+
+	sort.Slice(diffs, func(i, j int) bool {
+		return getObjectDiffKey(diffs[i].Kind, diffs[i].Namespace, diffs[i].Name) <
+			getObjectDiffKey(diffs[j].Kind, diffs[j].Namespace, diffs[j].Name)
+	})
+
+	return diffs, nil
+}
+
+// printObjectDiffs displays, for each object, the action and, for modified objects,
+// the field level changes
+func printObjectDiffs(w io.Writer, diffs []ObjectDiff) {
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"KIND", "NAMESPACE", "NAME", "ACTION", "CHANGES"})
+	table.SetAutoWrapText(false)
+	for i := range diffs {
+		changes := make([]string, len(diffs[i].Changes))
+		for j := range diffs[i].Changes {
+			changes[j] = diffs[i].Changes[j].String()
+		}
+		table.Append([]string{diffs[i].Kind, diffs[i].Namespace, diffs[i].Name, string(diffs[i].Action),
+			strings.Join(changes, "\n")})
+	}
+	table.Render()
+}
@@ -0,0 +1,210 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2/textlogger"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	configv1beta1 "github.com/projectsveltos/addon-controller/api/v1beta1"
+	libsveltosv1beta1 "github.com/projectsveltos/libsveltos/api/v1beta1"
+	"github.com/projectsveltos/sveltosctl/internal/collector"
+	"github.com/projectsveltos/sveltosctl/internal/commands/snapshot"
+)
+
+var _ = Describe("Snapshot Object Diff", func() {
+	var storageDir string
+	var storage collector.Storage
+
+	BeforeEach(func() {
+		var err error
+		storageDir, err = os.MkdirTemp("", randomString())
+		Expect(err).To(BeNil())
+		storage = collector.NewFilesystemStorage(storageDir)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(storageDir)
+	})
+
+	dumpSample := func(folder string, objects ...client.Object) {
+		for i := range objects {
+			Expect(collector.GetClient().DumpObject(storage, objects[i], folder,
+				textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
+		}
+	}
+
+	It("getObjectDiffs reports field level changes for every kind", func() {
+		namespace := randomString()
+		clusterProfile := &configv1beta1.ClusterProfile{
+			ObjectMeta: metav1.ObjectMeta{Name: randomString()},
+			Spec: configv1beta1.Spec{
+				ClusterSelector: libsveltosv1beta1.Selector{
+					LabelSelector: metav1.LabelSelector{MatchLabels: map[string]string{"env": "qa"}},
+				},
+			},
+		}
+		cluster := &libsveltosv1beta1.SveltosCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: namespace, Name: randomString(),
+				Labels:            map[string]string{"env": "qa"},
+				CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Hour)),
+			},
+		}
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: randomString()},
+			Data:       map[string][]byte{"password": []byte(randomString())},
+		}
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: randomString()},
+			Data:       map[string]string{"key": randomString()},
+		}
+		unchanged := &libsveltosv1beta1.Classifier{ObjectMeta: metav1.ObjectMeta{Name: randomString()}}
+
+		fromFolder := path.Join("snapshot", randomString(), "from")
+		dumpSample(fromFolder, clusterProfile.DeepCopy(), cluster.DeepCopy(), secret.DeepCopy(),
+			configMap.DeepCopy(), unchanged.DeepCopy())
+
+		clusterProfile.Spec.ClusterSelector.MatchLabels["env"] = "production"
+		cluster.Labels["env"] = "production"
+		// Fields set by the API server are not compared
+		cluster.CreationTimestamp = metav1.NewTime(time.Now())
+		secret.Data["password"] = []byte(randomString())
+		roleRequest := &libsveltosv1beta1.RoleRequest{ObjectMeta: metav1.ObjectMeta{Name: randomString()}}
+
+		toFolder := path.Join("snapshot", randomString(), "to")
+		dumpSample(toFolder, clusterProfile, cluster, secret, unchanged, roleRequest)
+
+		logger := textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1)))
+		scopes := []*collector.SampleScope{nil, nil}
+		diffs, err := snapshot.GetObjectDiffs(storage, fromFolder, toFolder, &snapshot.DiffOptions{}, scopes, logger)
+		Expect(err).To(BeNil())
+		Expect(diffs).To(ConsistOf(
+			snapshot.ObjectDiff{Kind: configv1beta1.ClusterProfileKind, Name: clusterProfile.Name,
+				Action: snapshot.ObjectDiffModified, Changes: []snapshot.FieldChange{
+					{Path: "spec.clusterSelector.matchLabels.env", From: "qa", To: "production"},
+				}},
+			snapshot.ObjectDiff{Kind: libsveltosv1beta1.SveltosClusterKind, Namespace: namespace, Name: cluster.Name,
+				Action: snapshot.ObjectDiffModified, Changes: []snapshot.FieldChange{
+					{Path: "metadata.labels.env", From: "qa", To: "production"},
+				}},
+			snapshot.ObjectDiff{Kind: "Secret", Namespace: namespace, Name: secret.Name,
+				Action: snapshot.ObjectDiffModified, Changes: []snapshot.FieldChange{
+					{Path: "data.password", From: "<redacted>", To: "<redacted>"},
+				}},
+			snapshot.ObjectDiff{Kind: "ConfigMap", Namespace: namespace, Name: configMap.Name,
+				Action: snapshot.ObjectDiffRemoved},
+			snapshot.ObjectDiff{Kind: libsveltosv1beta1.RoleRequestKind, Name: roleRequest.Name,
+				Action: snapshot.ObjectDiffAdded},
+		))
+
+		// Only selected kinds are compared
+		diffs, err = snapshot.GetObjectDiffs(storage, fromFolder, toFolder,
+			&snapshot.DiffOptions{Kinds: []string{"clusterprofile", "RoleRequest"}}, scopes, logger)
+		Expect(err).To(BeNil())
+		Expect(diffs).To(HaveLen(2))
+		Expect(diffs[0].Kind).To(Equal(configv1beta1.ClusterProfileKind))
+		Expect(diffs[1].Kind).To(Equal(libsveltosv1beta1.RoleRequestKind))
+
+		// Namespaced objects in other namespaces are not compared
+		diffs, err = snapshot.GetObjectDiffs(storage, fromFolder, toFolder,
+			&snapshot.DiffOptions{Namespace: randomString()}, scopes, logger)
+		Expect(err).To(BeNil())
+		Expect(diffs).To(HaveLen(2))
+
+		// Objects out of scope are not compared
+		excludeSecrets := &collector.SampleScope{ExcludeKinds: []string{"Secret", libsveltosv1beta1.RoleRequestKind}}
+		diffs, err = snapshot.GetObjectDiffs(storage, fromFolder, toFolder, &snapshot.DiffOptions{},
+			[]*collector.SampleScope{nil, excludeSecrets}, logger)
+		Expect(err).To(BeNil())
+		Expect(diffs).To(HaveLen(3))
+	})
+
+	It("getObjectDiffs compares Secrets by digests", func() {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: randomString(), Name: randomString()},
+			Data:       map[string][]byte{"password": []byte(randomString())},
+		}
+
+		fromFolder := path.Join("snapshot", randomString(), "from")
+		dumpSample(fromFolder, secret.DeepCopy())
+
+		// Same Secret, stored hashed
+		hashed := secret.DeepCopy()
+		collector.HashSecret(hashed)
+		toFolder := path.Join("snapshot", randomString(), "to")
+		dumpSample(toFolder, hashed)
+
+		logger := textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1)))
+		scopes := []*collector.SampleScope{nil, nil}
+		diffs, err := snapshot.GetObjectDiffs(storage, fromFolder, toFolder, &snapshot.DiffOptions{}, scopes, logger)
+		Expect(err).To(BeNil())
+		Expect(diffs).To(BeEmpty())
+
+		hashed = secret.DeepCopy()
+		hashed.Data["password"] = []byte(randomString())
+		collector.HashSecret(hashed)
+		toFolder = path.Join("snapshot", randomString(), "to")
+		dumpSample(toFolder, hashed)
+
+		diffs, err = snapshot.GetObjectDiffs(storage, fromFolder, toFolder, &snapshot.DiffOptions{}, scopes, logger)
+		Expect(err).To(BeNil())
+		Expect(diffs).To(ConsistOf(
+			snapshot.ObjectDiff{Kind: "Secret", Namespace: secret.Namespace, Name: secret.Name,
+				Action: snapshot.ObjectDiffModified, Changes: []snapshot.FieldChange{
+					{Path: "data.password", From: "<redacted>", To: "<redacted>"},
+				}},
+		))
+	})
+
+	It("listFolderDiffsInTables reports each object once", func() {
+		classifier := &libsveltosv1beta1.Classifier{ObjectMeta: metav1.ObjectMeta{Name: randomString()}}
+		Expect(addTypeInformationToObject(classifier)).To(Succeed())
+
+		fromFolder := path.Join("snapshot", randomString(), "from")
+		Expect(os.MkdirAll(path.Join(storageDir, fromFolder), os.ModePerm)).To(Succeed())
+		toFolder := path.Join("snapshot", randomString(), "to")
+		dumpSample(toFolder, classifier)
+
+		old := os.Stdout
+		r, w, _ := os.Pipe()
+		os.Stdout = w
+		err := snapshot.ListFolderDiffsInTables(storage, fromFolder, toFolder,
+			&snapshot.DiffOptions{Kinds: []string{libsveltosv1beta1.ClassifierKind}},
+			[]*collector.SampleScope{nil, nil}, textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
+		w.Close()
+		os.Stdout = old
+		Expect(err).To(BeNil())
+
+		var buf bytes.Buffer
+		_, err = io.Copy(&buf, r)
+		Expect(err).To(BeNil())
+		Expect(strings.Count(buf.String(), classifier.Name)).To(Equal(1))
+	})
+})
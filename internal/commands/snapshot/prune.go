@@ -0,0 +1,123 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/docopt/docopt-go"
+	"github.com/go-logr/logr"
+	"github.com/olekukonko/tablewriter"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2/textlogger"
+
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+	utilsv1beta1 "github.com/projectsveltos/sveltosctl/api/v1beta1"
+	"github.com/projectsveltos/sveltosctl/internal/collector"
+	"github.com/projectsveltos/sveltosctl/internal/utils"
+)
+
+// pruneSamples removes the samples of Snapshot snapshotName its retention policy does
+// not keep. If dryRun is set, nothing is removed.
+// Returns the samples removed (or which would be removed).
+func pruneSamples(ctx context.Context, snapshotName string, dryRun bool, logger logr.Logger) ([]string, error) {
+	logger.V(logs.LogDebug).Info(fmt.Sprintf("Getting Snapshot %s", snapshotName))
+
+	snapshotInstance := &utilsv1beta1.Snapshot{}
+	err := utils.GetAccessInstance().GetResource(ctx, types.NamespacedName{Name: snapshotName}, snapshotInstance)
+	if err != nil {
+		return nil, err
+	}
+
+	if snapshotInstance.Spec.Retention == nil {
+		return nil, fmt.Errorf("retention is not configured for snapshot %s", snapshotName)
+	}
+
+	storage, err := getSnapshotStorage(ctx, snapshotInstance)
+	if err != nil {
+		return nil, err
+	}
+
+	return collector.GetClient().PruneCollections(storage, snapshotName, collector.Snapshot,
+		snapshotInstance.Spec.Retention, time.Now(), dryRun, logger)
+}
+
+// Prune removes samples not kept by the Snapshot retention policy
+func Prune(ctx context.Context, args []string, logger logr.Logger) error {
+	doc := `Usage:
+	sveltosctl snapshot prune [options] --snapshot=<name> [--dry-run] [--verbose]
+
+     --snapshot=<name>      Name of the Snapshot instance
+
+Options:
+  -h --help                  Show this screen.
+     --dry-run               Only lists the samples which would be removed.
+     --verbose               Verbose mode. Print each step.
+
+Description:
+  The snapshot prune command removes every sample the Snapshot retention policy does not keep.
+  Retention is evaluated after each collection as well. This command allows to preview
+  (--dry-run) or immediately apply a retention policy.
+`
+
+	parsedArgs, err := docopt.ParseArgs(doc, nil, "1.0")
+	if err != nil {
+		logger.V(logs.LogDebug).Error(err, "failed to parse args")
+		return fmt.Errorf(
+			"invalid option: 'sveltosctl %s'. Use flag '--help' to read about a specific subcommand. Error: %w",
+			strings.Join(args, " "),
+			err,
+		)
+	}
+
+	_ = flag.Lookup("v").Value.Set(fmt.Sprint(logs.LogInfo))
+	verbose := parsedArgs["--verbose"].(bool)
+	if verbose {
+		err = flag.Lookup("v").Value.Set(fmt.Sprint(logs.LogDebug))
+		if err != nil {
+			return err
+		}
+	}
+
+	logger = textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1)))
+
+	snapshostName := parsedArgs["--snapshot"].(string)
+	dryRun := parsedArgs["--dry-run"].(bool)
+
+	samples, err := pruneSamples(ctx, snapshostName, dryRun, logger)
+	if err != nil {
+		return err
+	}
+
+	action := "REMOVED"
+	if dryRun {
+		action = "WOULD BE REMOVED"
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"SNAPSHOT POLICY", "DATE", "ACTION"})
+	for i := range samples {
+		table.Append([]string{snapshostName, samples[i], action})
+	}
+	table.Render()
+
+	return nil
+}
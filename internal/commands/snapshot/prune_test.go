@@ -0,0 +1,88 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot_test
+
+import (
+	"context"
+	"os"
+	"path"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/klog/v2/textlogger"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	utilsv1beta1 "github.com/projectsveltos/sveltosctl/api/v1beta1"
+	"github.com/projectsveltos/sveltosctl/internal/commands/snapshot"
+	"github.com/projectsveltos/sveltosctl/internal/utils"
+)
+
+var _ = Describe("Snapshot Prune", func() {
+	var snapshotInstance *utilsv1beta1.Snapshot
+	var samples []string
+
+	BeforeEach(func() {
+		snapshotInstance, samples = createSnapshotWithSamples(48*time.Hour, 24*time.Hour, 0)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(snapshotInstance.Spec.Storage)
+	})
+
+	It("pruneSamples removes samples not kept by the retention policy", func() {
+		initObjects := []client.Object{snapshotInstance}
+		scheme, err := utils.GetScheme()
+		Expect(err).To(BeNil())
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initObjects...).Build()
+		utils.InitalizeManagementClusterAcces(scheme, nil, nil, c)
+
+		// Dry run does not remove anything
+		pruned, err := snapshot.PruneSamples(context.TODO(), snapshotInstance.Name, true,
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
+		Expect(err).To(BeNil())
+		Expect(pruned).To(Equal(samples[:2]))
+		for i := range samples {
+			_, err = os.Stat(path.Join(snapshotInstance.Spec.Storage, "snapshot", snapshotInstance.Name, samples[i]))
+			Expect(err).To(BeNil())
+		}
+
+		pruned, err = snapshot.PruneSamples(context.TODO(), snapshotInstance.Name, false,
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
+		Expect(err).To(BeNil())
+		Expect(pruned).To(Equal(samples[:2]))
+		entries, err := os.ReadDir(path.Join(snapshotInstance.Spec.Storage, "snapshot", snapshotInstance.Name))
+		Expect(err).To(BeNil())
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].Name()).To(Equal(samples[2]))
+	})
+
+	It("pruneSamples fails when retention is not configured", func() {
+		snapshotInstance.Spec.Retention = nil
+		initObjects := []client.Object{snapshotInstance}
+		scheme, err := utils.GetScheme()
+		Expect(err).To(BeNil())
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initObjects...).Build()
+		utils.InitalizeManagementClusterAcces(scheme, nil, nil, c)
+
+		_, err = snapshot.PruneSamples(context.TODO(), snapshotInstance.Name, true,
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
+		Expect(err).ToNot(BeNil())
+	})
+})
@@ -20,8 +20,10 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"os"
-	"path/filepath"
+	"path"
+	"sort"
 	"strings"
 
 	"github.com/docopt/docopt-go"
@@ -35,6 +37,7 @@ import (
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 
 	configv1beta1 "github.com/projectsveltos/addon-controller/api/v1beta1"
+	eventv1beta1 "github.com/projectsveltos/event-manager/api/v1beta1"
 	libsveltosv1beta1 "github.com/projectsveltos/libsveltos/api/v1beta1"
 	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
 	utilsv1beta1 "github.com/projectsveltos/sveltosctl/api/v1beta1"
@@ -42,10 +45,106 @@ import (
 	"github.com/projectsveltos/sveltosctl/internal/utils"
 )
 
-func rollbackConfiguration(ctx context.Context,
-	snapshotName, sample, passedNamespace, passedCluster, passedProfile,
-	passedClassifier, passedRoleRequest string,
-	logger logr.Logger) error {
+// RollbackFilters restricts a rollback to a subset of the objects in a sample.
+// An empty field means objects of that kind are not filtered.
+type RollbackFilters struct {
+	// Namespace restricts rollback of namespaced resources (ConfigMaps, Secrets, Clusters,
+	// Profiles and Sets) to this namespace
+	Namespace string
+	// Cluster is the name of the cluster whose labels are rolled back
+	Cluster string
+	// Profile is the ClusterProfile/Profile in the form kind/name
+	Profile            string
+	Classifier         string
+	RoleRequest        string
+	EventSource        string
+	EventTrigger       string
+	HealthCheck        string
+	ClusterHealthCheck string
+	ClusterSet         string
+	Set                string
+}
+
+// RollbackOptions controls how a rollback is performed
+type RollbackOptions struct {
+	// DryRun, when set, causes nothing to be modified. The rollback plan is displayed instead.
+	DryRun bool
+	// Prune, when set, causes the instances of rolled back kinds which are not
+	// present in the sample to be deleted
+	Prune bool
+	// SkipConfirmation, when set, prunes resources without asking for confirmation
+	SkipConfirmation bool
+	// TakeSample, when set, is used to take a pre-rollback sample of the current
+	// configuration before anything is modified
+	TakeSample SampleCollector
+	// Force, when set, restores the sample even if it does not match its manifest
+	Force bool
+	// IgnoreRules contains the fields which are not compared. Objects whose only differences
+	// are ignored fields are left unchanged. If nil, only built-in rules apply.
+	IgnoreRules *IgnoreRules
+}
+
+// SampleCollector synchronously takes a new sample for Snapshot snapshotName,
+// tagging it with trigger. Returns the name of the new sample.
+type SampleCollector func(ctx context.Context, snapshotName string, trigger collector.SampleTrigger,
+	logger logr.Logger) (string, error)
+
+// RollbackResult is the outcome of a rollback
+type RollbackResult struct {
+	// Entries contains, for each object processed, the action taken (or, in dry run
+	// mode, the action rollback would take)
+	Entries []RollbackPlanEntry
+
+	// PreRollbackSample is the name of the sample taken before anything was modified.
+	// Empty in dry run mode or when no SampleCollector is set.
+	PreRollbackSample string
+
+	plan *rollbackPlan
+}
+
+func rollbackConfiguration(ctx context.Context, snapshotName, sample string, filters *RollbackFilters,
+	options *RollbackOptions, logger logr.Logger) error {
+
+	result, err := ExecuteRollback(ctx, snapshotName, sample, filters, options, logger)
+	if result != nil {
+		printSkippedEntries(os.Stderr, result.Entries)
+	}
+	if result != nil && result.PreRollbackSample != "" {
+		fmt.Fprintf(os.Stdout, "Configuration before rollback saved in sample %s. Use 'sveltosctl snapshot undo --snapshot=%s' to restore it.\n",
+			result.PreRollbackSample, snapshotName)
+	}
+	if err != nil {
+		return err
+	}
+
+	if options.DryRun {
+		matchChanges, err := getProfileMatchChanges(ctx, result.plan, logger)
+		if err != nil {
+			return err
+		}
+
+		printRollbackPlan(os.Stdout, result.plan, matchChanges)
+	}
+
+	return nil
+}
+
+// printSkippedEntries warns about the objects rollback leaves untouched because they
+// cannot be restored from the sample
+func printSkippedEntries(w io.Writer, entries []RollbackPlanEntry) {
+	for i := range entries {
+		if entries[i].Action == RollbackActionSkipped {
+			fmt.Fprintf(w, "Warning: %s %s/%s not restored: %s\n", entries[i].Kind, entries[i].Namespace,
+				entries[i].Name, entries[i].Message)
+		}
+	}
+}
+
+// ExecuteRollback rolls the configuration back to sample of Snapshot snapshotName.
+// In dry run mode nothing is modified. Returned result contains the objects processed
+// so far, also when an error is returned.
+func ExecuteRollback(ctx context.Context, snapshotName, sample string, filters *RollbackFilters,
+	options *RollbackOptions, logger logr.Logger) (*RollbackResult, error) {
 
 	logger.V(logs.LogDebug).Info(fmt.Sprintf("Getting Snapshot %s", snapshotName))
 
@@ -54,78 +153,160 @@ func rollbackConfiguration(ctx context.Context,
 	snapshotInstance := &utilsv1beta1.Snapshot{}
 	err := instance.GetResource(ctx, types.NamespacedName{Name: snapshotName}, snapshotInstance)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	storage, err := getSnapshotStorage(ctx, snapshotInstance)
+	if err != nil {
+		return nil, err
 	}
 
 	logger.V(logs.LogDebug).Info(fmt.Sprintf("Getting snapshot folder for %s", sample))
 	snapshotClient := collector.GetClient()
-	artifactFolder, err := snapshotClient.GetFolder(snapshotInstance.Spec.Storage, snapshotInstance.Name,
+	artifactFolder, err := snapshotClient.GetFolder(storage, snapshotInstance.Name,
 		collector.Snapshot, logger)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	folder := filepath.Join(*artifactFolder, sample)
-	// Get the two directories containing the collected snaphosts
-	_, err = os.Stat(folder)
-	if os.IsNotExist(err) {
-		logger.V(logs.LogDebug).Info(fmt.Sprintf("Folder %s does not exist for snapshot instance: %s",
-			folder, snapshotName))
-		return err
+	folder := path.Join(*artifactFolder, sample)
+	err = verifySampleExists(storage, folder, snapshotName, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	if options.Force {
+		logger.V(logs.LogDebug).Info("skipping sample verification")
+	} else if _, err = verifySampleManifest(ctx, snapshotInstance, storage, folder); err != nil {
+		return nil, fmt.Errorf("sample %s failed verification (use force to restore it anyway): %w", sample, err)
+	}
+
+	var toPrune []*unstructured.Unstructured
+	if options.Prune {
+		toPrune, err = getPruneCandidates(ctx, storage, folder, filters, logger)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := &RollbackResult{plan: newRollbackPlan()}
+	result.plan.ignore = options.IgnoreRules
+	if !options.DryRun {
+		if len(toPrune) > 0 && !options.SkipConfirmation {
+			confirmed, err := confirmPrune(os.Stdin, os.Stdout, toPrune)
+			if err != nil {
+				return nil, err
+			}
+			if !confirmed {
+				return nil, fmt.Errorf("rollback aborted")
+			}
+		}
+
+		if options.TakeSample != nil {
+			result.PreRollbackSample, err = options.TakeSample(ctx, snapshotName, collector.SampleTriggerPreRollback, logger)
+			if err != nil {
+				return nil, fmt.Errorf("failed to take pre-rollback sample: %w", err)
+			}
+			logger.V(logs.LogInfo).Info(fmt.Sprintf("pre-rollback sample %s taken", result.PreRollbackSample))
+		}
+
+		result.plan.apply = true
 	}
 
-	return rollbackConfigurationToSnapshot(ctx, folder, passedNamespace, passedCluster, passedProfile,
-		passedClassifier, passedRoleRequest, logger)
+	err = rollbackConfigurationToSnapshot(ctx, storage, folder, filters, result.plan, logger)
+	if err == nil {
+		err = pruneResources(ctx, toPrune, result.plan, logger)
+	}
+
+	result.Entries = result.plan.Entries
+	return result, err
 }
 
-func rollbackConfigurationToSnapshot(ctx context.Context, folder, passedNamespace, passedCluster,
-	passedProfile, passedClassifier, passedRoleRequest string,
-	logger logr.Logger) error {
+func rollbackConfigurationToSnapshot(ctx context.Context, storage collector.Storage, folder string,
+	filters *RollbackFilters, plan *rollbackPlan, logger logr.Logger) error {
 
 	logger.V(logs.LogDebug).Info("roll back configuration: configmaps")
-	err := getAndRollbackConfigMaps(ctx, folder, passedNamespace, logger)
+	err := getAndRollbackConfigMaps(ctx, storage, folder, filters.Namespace, plan, logger)
 	if err != nil {
 		return err
 	}
 
 	logger.V(logs.LogDebug).Info("roll back configuration: secrets")
-	err = getAndRollbackSecrets(ctx, folder, passedNamespace, logger)
+	err = getAndRollbackSecrets(ctx, storage, folder, filters.Namespace, plan, logger)
+	if err != nil {
+		return err
+	}
+
+	logger.V(logs.LogDebug).Info("roll back configuration: other referenced resources")
+	err = getAndRollbackReferencedResources(ctx, storage, folder, filters.Namespace, plan, logger)
 	if err != nil {
 		return err
 	}
 
 	logger.V(logs.LogDebug).Info("roll back configuration: clusters")
-	err = getAndRollbackClusters(ctx, folder, passedNamespace, passedCluster, logger)
+	err = getAndRollbackClusters(ctx, storage, folder, filters.Namespace, filters.Cluster, plan, logger)
+	if err != nil {
+		return err
+	}
+
+	logger.V(logs.LogDebug).Info("roll back configuration: clustersets/sets")
+	err = getAndRollbackClusterResources(ctx, storage, folder, libsveltosv1beta1.ClusterSetKind,
+		filters.ClusterSet, plan, logger)
+	if err != nil {
+		return err
+	}
+	err = getAndRollbackSets(ctx, storage, folder, filters.Namespace, filters.Set, plan, logger)
 	if err != nil {
 		return err
 	}
 
 	logger.V(logs.LogDebug).Info("roll back configuration: profile")
-	err = getAndRollbackProfiles(ctx, folder, passedNamespace, passedProfile, logger)
+	err = getAndRollbackProfiles(ctx, storage, folder, filters.Namespace, filters.Profile, plan, logger)
 	if err != nil {
 		return err
 	}
 
 	logger.V(logs.LogDebug).Info("roll back configuration: classifiers")
-	err = getAndRollbackClassifiers(ctx, folder, passedClassifier, logger)
+	err = getAndRollbackClassifiers(ctx, storage, folder, filters.Classifier, plan, logger)
 	if err != nil {
 		return err
 	}
 
 	logger.V(logs.LogDebug).Info("roll back configuration: rolerequests")
-	err = getAndRollbackRoleRequests(ctx, folder, passedRoleRequest, logger)
+	err = getAndRollbackRoleRequests(ctx, storage, folder, filters.RoleRequest, plan, logger)
 	if err != nil {
 		return err
 	}
 
+	// Following resources are rolled back by restoring their Spec section
+	specResources := []struct {
+		kind       string
+		passedName string
+	}{
+		{kind: libsveltosv1beta1.EventSourceKind, passedName: filters.EventSource},
+		{kind: eventv1beta1.EventTriggerKind, passedName: filters.EventTrigger},
+		{kind: libsveltosv1beta1.HealthCheckKind, passedName: filters.HealthCheck},
+		{kind: libsveltosv1beta1.ClusterHealthCheckKind, passedName: filters.ClusterHealthCheck},
+	}
+	for i := range specResources {
+		logger.V(logs.LogDebug).Info(fmt.Sprintf("roll back configuration: %s", specResources[i].kind))
+		err = getAndRollbackClusterResources(ctx, storage, folder, specResources[i].kind,
+			specResources[i].passedName, plan, logger)
+		if err != nil {
+			return err
+		}
+	}
+
 	logger.V(logs.LogDebug).Info("rolled back configuration")
 
 	return nil
 }
 
-func getAndRollbackConfigMaps(ctx context.Context, folder, passedNamespace string, logger logr.Logger) error {
+func getAndRollbackConfigMaps(ctx context.Context, storage collector.Storage, folder, passedNamespace string,
+	plan *rollbackPlan, logger logr.Logger) error {
+
 	snapshotClient := collector.GetClient()
-	cmMap, err := snapshotClient.GetNamespacedResources(folder, "ConfigMap", logger)
+	cmMap, err := snapshotClient.GetNamespacedResources(storage, folder, "ConfigMap", logger)
 	if err != nil {
 		logger.V(logs.LogDebug).Info(fmt.Sprintf("failed to collect ConfigMaps from folder %s", folder))
 		return err
@@ -134,7 +315,7 @@ func getAndRollbackConfigMaps(ctx context.Context, folder, passedNamespace strin
 	for ns := range cmMap {
 		if passedNamespace == "" || ns == passedNamespace {
 			logger.V(logs.LogDebug).Info(fmt.Sprintf("rollback ConfigMaps in namespace %s", ns))
-			err = rollbackConfigMaps(ctx, cmMap[ns], logger)
+			err = rollbackConfigMaps(ctx, cmMap[ns], plan, logger)
 			if err != nil {
 				return err
 			}
@@ -144,9 +325,11 @@ func getAndRollbackConfigMaps(ctx context.Context, folder, passedNamespace strin
 	return nil
 }
 
-func getAndRollbackSecrets(ctx context.Context, folder, passedNamespace string, logger logr.Logger) error {
+func getAndRollbackSecrets(ctx context.Context, storage collector.Storage, folder, passedNamespace string,
+	plan *rollbackPlan, logger logr.Logger) error {
+
 	snapshotClient := collector.GetClient()
-	secretMap, err := snapshotClient.GetNamespacedResources(folder, "Secret", logger)
+	secretMap, err := snapshotClient.GetNamespacedResources(storage, folder, "Secret", logger)
 	if err != nil {
 		logger.V(logs.LogDebug).Info(fmt.Sprintf("failed to collect Secret from folder %s", folder))
 		return err
@@ -155,7 +338,7 @@ func getAndRollbackSecrets(ctx context.Context, folder, passedNamespace string,
 	for ns := range secretMap {
 		if passedNamespace == "" || ns == passedNamespace {
 			logger.V(logs.LogDebug).Info(fmt.Sprintf("rollback ConfigMaps in namespace %s", ns))
-			err = rollbackSecrets(ctx, secretMap[ns], logger)
+			err = rollbackSecrets(ctx, secretMap[ns], plan, logger)
 			if err != nil {
 				return err
 			}
@@ -165,21 +348,182 @@ func getAndRollbackSecrets(ctx context.Context, folder, passedNamespace string,
 	return nil
 }
 
-func getAndRollbackClusters(ctx context.Context, folder, passedNamespace, passedCluster string, logger logr.Logger) error {
-	if err := getAndRollbackCAPIClusters(ctx, folder, passedNamespace, passedCluster, logger); err != nil {
+// namespacedKindsWithDedicatedRollback contains the namespaced kinds stored in a sample which are
+// either rolled back by a dedicated function or never rolled back
+var namespacedKindsWithDedicatedRollback = map[string]bool{
+	string(libsveltosv1beta1.ConfigMapReferencedResourceKind): true,
+	string(libsveltosv1beta1.SecretReferencedResourceKind):    true,
+	"Cluster":                              true,
+	libsveltosv1beta1.SveltosClusterKind:   true,
+	configv1beta1.ProfileKind:              true,
+	libsveltosv1beta1.SetKind:              true,
+	configv1beta1.ClusterConfigurationKind: true,
+}
+
+// getAndRollbackReferencedResources rolls back any other namespaced resource in the sample.
+// Those are resources (Flux sources, resources used by templates, ...) referenced by
+// ClusterProfiles/Profiles, EventTriggers and RoleRequests at the time sample was taken.
+func getAndRollbackReferencedResources(ctx context.Context, storage collector.Storage, folder, passedNamespace string,
+	plan *rollbackPlan, logger logr.Logger) error {
+
+	kinds, err := getNamespacedKinds(storage, folder)
+	if err != nil {
+		return err
+	}
+
+	snapshotClient := collector.GetClient()
+	for i := range kinds {
+		if namespacedKindsWithDedicatedRollback[kinds[i]] {
+			continue
+		}
+
+		resourceMap, err := snapshotClient.GetNamespacedResources(storage, folder, kinds[i], logger)
+		if err != nil {
+			logger.V(logs.LogDebug).Info(fmt.Sprintf("failed to collect %s from folder %s", kinds[i], folder))
+			return err
+		}
+
+		for ns := range resourceMap {
+			if passedNamespace != "" && ns != passedNamespace {
+				continue
+			}
+			logger.V(logs.LogDebug).Info(fmt.Sprintf("rollback %s in namespace %s", kinds[i], ns))
+			for j := range resourceMap[ns] {
+				if err := rollbackReferencedResource(ctx, resourceMap[ns][j], plan, logger); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// getNamespacedKinds returns, sorted, all kinds with at least one namespaced resource in folder.
+// Namespaced resources are stored in <folder>/<namespace>/<kind>/<name>.yaml while cluster
+// wide ones in <folder>/<kind>/<name>.yaml
+func getNamespacedKinds(storage collector.Storage, folder string) ([]string, error) {
+	entries, err := storage.ReadDir(folder)
+	if err != nil {
+		return nil, err
+	}
+
+	kinds := make(map[string]bool)
+	for i := range entries {
+		if !entries[i].IsDir {
+			continue
+		}
+		subEntries, err := storage.ReadDir(path.Join(folder, entries[i].Name))
+		if err != nil {
+			return nil, err
+		}
+		for j := range subEntries {
+			if subEntries[j].IsDir {
+				kinds[subEntries[j].Name] = true
+			}
+		}
+	}
+
+	result := make([]string, 0, len(kinds))
+	for k := range kinds {
+		result = append(result, k)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// rollbackReferencedResource does following:
+// - if resource currently does not exist, recreates it
+// - if resource does exist, updates all its fields but metadata and status
+// - if sveltosctl is not allowed to read or write resource, warns and leaves resource untouched
+func rollbackReferencedResource(ctx context.Context, resource *unstructured.Unstructured, plan *rollbackPlan,
+	logger logr.Logger) error {
+
+	instance := utils.GetAccessInstance()
+
+	current := &unstructured.Unstructured{}
+	current.SetGroupVersionKind(resource.GroupVersionKind())
+	err := instance.GetResource(ctx,
+		types.NamespacedName{Namespace: resource.GetNamespace(), Name: resource.GetName()}, current)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			logger.V(logs.LogDebug).Info(fmt.Sprintf("Creating %s %s/%s",
+				resource.GetKind(), resource.GetNamespace(), resource.GetName()))
+			return skipForbiddenResource(resource, plan, createResource(ctx, resource, plan), logger)
+		}
+		return skipForbiddenResource(resource, plan, err, logger)
+	}
+
+	original := current.DeepCopy()
+	for field := range current.Object {
+		if !isMetadataOrStatusField(field) {
+			delete(current.Object, field)
+		}
+	}
+	for field := range resource.Object {
+		if !isMetadataOrStatusField(field) {
+			current.Object[field] = runtime.DeepCopyJSONValue(resource.Object[field])
+		}
+	}
+
+	logger.V(logs.LogDebug).Info(fmt.Sprintf("Updating %s %s/%s",
+		resource.GetKind(), resource.GetNamespace(), resource.GetName()))
+	return skipForbiddenResource(resource, plan,
+		updateResource(ctx, resource.GetKind(), original, current, plan), logger)
+}
+
+// skipForbiddenResource returns err unless it is a Forbidden error. In that case resource
+// is reported as skipped. Referenced resources can be of any kind and sveltosctl ClusterRole
+// cannot grant access to all of them, so rollback continues with the other resources.
+func skipForbiddenResource(resource *unstructured.Unstructured, plan *rollbackPlan, err error,
+	logger logr.Logger) error {
+
+	if err == nil || !apierrors.IsForbidden(err) {
+		return err
+	}
+
+	message := fmt.Sprintf("not allowed to restore it: %v", err)
+	logger.V(logs.LogInfo).Info(fmt.Sprintf("%s %s/%s: %s",
+		resource.GetKind(), resource.GetNamespace(), resource.GetName(), message))
+	if plan != nil {
+		plan.skip(&RollbackPlanEntry{
+			Kind:      resource.GetKind(),
+			Namespace: resource.GetNamespace(),
+			Name:      resource.GetName(),
+			Message:   message,
+		})
+	}
+	return nil
+}
+
+func isMetadataOrStatusField(field string) bool {
+	switch field {
+	case "apiVersion", "kind", "metadata", "status":
+		return true
+	default:
+		return false
+	}
+}
+
+func getAndRollbackClusters(ctx context.Context, storage collector.Storage, folder, passedNamespace, passedCluster string,
+	plan *rollbackPlan, logger logr.Logger) error {
+
+	if err := getAndRollbackCAPIClusters(ctx, storage, folder, passedNamespace, passedCluster, plan, logger); err != nil {
 		return err
 	}
 
-	if err := getAndRollbackSveltosClusters(ctx, folder, passedNamespace, passedCluster, logger); err != nil {
+	if err := getAndRollbackSveltosClusters(ctx, storage, folder, passedNamespace, passedCluster, plan, logger); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func getAndRollbackCAPIClusters(ctx context.Context, folder, passedNamespace, passedCluster string, logger logr.Logger) error {
+func getAndRollbackCAPIClusters(ctx context.Context, storage collector.Storage, folder, passedNamespace, passedCluster string,
+	plan *rollbackPlan, logger logr.Logger) error {
+
 	snapshotClient := collector.GetClient()
-	clusterMap, err := snapshotClient.GetNamespacedResources(folder, "Cluster", logger)
+	clusterMap, err := snapshotClient.GetNamespacedResources(storage, folder, "Cluster", logger)
 	if err != nil {
 		logger.V(logs.LogDebug).Info(fmt.Sprintf("failed to collect Cluster from folder %s", folder))
 		return err
@@ -188,7 +532,7 @@ func getAndRollbackCAPIClusters(ctx context.Context, folder, passedNamespace, pa
 	for ns := range clusterMap {
 		if passedNamespace == "" || ns == passedNamespace {
 			logger.V(logs.LogDebug).Info(fmt.Sprintf("rollback Clusters in namespace %s", ns))
-			err = rollbackClusters(ctx, clusterMap[ns], passedCluster, libsveltosv1beta1.ClusterTypeCapi, logger)
+			err = rollbackClusters(ctx, clusterMap[ns], passedCluster, libsveltosv1beta1.ClusterTypeCapi, plan, logger)
 			if err != nil {
 				return err
 			}
@@ -198,9 +542,11 @@ func getAndRollbackCAPIClusters(ctx context.Context, folder, passedNamespace, pa
 	return nil
 }
 
-func getAndRollbackSveltosClusters(ctx context.Context, folder, passedNamespace, passedCluster string, logger logr.Logger) error {
+func getAndRollbackSveltosClusters(ctx context.Context, storage collector.Storage, folder, passedNamespace, passedCluster string,
+	plan *rollbackPlan, logger logr.Logger) error {
+
 	snapshotClient := collector.GetClient()
-	clusterMap, err := snapshotClient.GetNamespacedResources(folder, libsveltosv1beta1.SveltosClusterKind, logger)
+	clusterMap, err := snapshotClient.GetNamespacedResources(storage, folder, libsveltosv1beta1.SveltosClusterKind, logger)
 	if err != nil {
 		logger.V(logs.LogDebug).Info(fmt.Sprintf("failed to collect SveltosCluster from folder %s", folder))
 		return err
@@ -209,7 +555,7 @@ func getAndRollbackSveltosClusters(ctx context.Context, folder, passedNamespace,
 	for ns := range clusterMap {
 		if passedNamespace == "" || ns == passedNamespace {
 			logger.V(logs.LogDebug).Info(fmt.Sprintf("rollback Clusters in namespace %s", ns))
-			err = rollbackClusters(ctx, clusterMap[ns], passedCluster, libsveltosv1beta1.ClusterTypeSveltos, logger)
+			err = rollbackClusters(ctx, clusterMap[ns], passedCluster, libsveltosv1beta1.ClusterTypeSveltos, plan, logger)
 			if err != nil {
 				return err
 			}
@@ -219,9 +565,11 @@ func getAndRollbackSveltosClusters(ctx context.Context, folder, passedNamespace,
 	return nil
 }
 
-func getAndRollbackProfiles(ctx context.Context, folder, passedNamespace, passedProfile string, logger logr.Logger) error {
+func getAndRollbackProfiles(ctx context.Context, storage collector.Storage, folder, passedNamespace, passedProfile string,
+	plan *rollbackPlan, logger logr.Logger) error {
+
 	snapshotClient := collector.GetClient()
-	clusterProfiles, err := snapshotClient.GetClusterResources(folder, configv1beta1.ClusterProfileKind, logger)
+	clusterProfiles, err := snapshotClient.GetClusterResources(storage, folder, configv1beta1.ClusterProfileKind, logger)
 	if err != nil {
 		logger.V(logs.LogDebug).Info(fmt.Sprintf("failed to collect ClusterProfile from folder %s", folder))
 		return err
@@ -229,16 +577,16 @@ func getAndRollbackProfiles(ctx context.Context, folder, passedNamespace, passed
 
 	for i := range clusterProfiles {
 		cp := clusterProfiles[i]
-		if passedProfile == "" || cp.GetName() == fmt.Sprintf("ClusterProfile/%s", passedProfile) {
+		if passedProfile == "" || passedProfile == fmt.Sprintf("%s/%s", configv1beta1.ClusterProfileKind, cp.GetName()) {
 			logger.V(logs.LogDebug).Info(fmt.Sprintf("rollback ClusterProfile %s", cp.GetName()))
-			err = rollbackClusterProfile(ctx, cp, logger)
+			err = rollbackClusterProfile(ctx, cp, plan, logger)
 			if err != nil {
 				return err
 			}
 		}
 	}
 
-	profiles, err := snapshotClient.GetNamespacedResources(folder, configv1beta1.ProfileKind, logger)
+	profiles, err := snapshotClient.GetNamespacedResources(storage, folder, configv1beta1.ProfileKind, logger)
 	if err != nil {
 		logger.V(logs.LogDebug).Info(fmt.Sprintf("failed to collect Profile from folder %s", folder))
 		return err
@@ -247,7 +595,7 @@ func getAndRollbackProfiles(ctx context.Context, folder, passedNamespace, passed
 	for ns := range profiles {
 		if passedNamespace == "" || ns == passedNamespace {
 			logger.V(logs.LogDebug).Info(fmt.Sprintf("rollback Profiles in namespace %s", ns))
-			err = rollbackProfiles(ctx, profiles[ns], passedProfile, logger)
+			err = rollbackProfiles(ctx, profiles[ns], passedProfile, plan, logger)
 			if err != nil {
 				return err
 			}
@@ -258,13 +606,13 @@ func getAndRollbackProfiles(ctx context.Context, folder, passedNamespace, passed
 }
 
 func rollbackProfiles(ctx context.Context, resources []*unstructured.Unstructured,
-	passedProfile string, logger logr.Logger) error {
+	passedProfile string, plan *rollbackPlan, logger logr.Logger) error {
 
 	for i := range resources {
 		p := resources[i]
-		if passedProfile == "" || p.GetName() == fmt.Sprintf("Profile/%s", passedProfile) {
+		if passedProfile == "" || passedProfile == fmt.Sprintf("%s/%s", configv1beta1.ProfileKind, p.GetName()) {
 			logger.V(logs.LogDebug).Info(fmt.Sprintf("rollback Profile %s", p.GetName()))
-			err := rollbackProfile(ctx, p, logger)
+			err := rollbackProfile(ctx, p, plan, logger)
 			if err != nil {
 				return err
 			}
@@ -274,9 +622,11 @@ func rollbackProfiles(ctx context.Context, resources []*unstructured.Unstructure
 	return nil
 }
 
-func getAndRollbackClassifiers(ctx context.Context, folder, passedClassifier string, logger logr.Logger) error {
+func getAndRollbackClassifiers(ctx context.Context, storage collector.Storage, folder, passedClassifier string,
+	plan *rollbackPlan, logger logr.Logger) error {
+
 	snapshotClient := collector.GetClient()
-	classifiers, err := snapshotClient.GetClusterResources(folder, libsveltosv1beta1.ClassifierKind, logger)
+	classifiers, err := snapshotClient.GetClusterResources(storage, folder, libsveltosv1beta1.ClassifierKind, logger)
 	if err != nil {
 		logger.V(logs.LogDebug).Info(fmt.Sprintf("failed to collect Classifiers from folder %s", folder))
 		return err
@@ -286,7 +636,7 @@ func getAndRollbackClassifiers(ctx context.Context, folder, passedClassifier str
 		cl := classifiers[i]
 		if passedClassifier == "" || cl.GetName() == passedClassifier {
 			logger.V(logs.LogDebug).Info(fmt.Sprintf("rollback Classifier %s", cl.GetName()))
-			err = rollbackClassifier(ctx, cl, logger)
+			err = rollbackClassifier(ctx, cl, plan, logger)
 			if err != nil {
 				return err
 			}
@@ -296,19 +646,21 @@ func getAndRollbackClassifiers(ctx context.Context, folder, passedClassifier str
 	return nil
 }
 
-func getAndRollbackRoleRequests(ctx context.Context, folder, passedRoleRequest string, logger logr.Logger) error {
+func getAndRollbackRoleRequests(ctx context.Context, storage collector.Storage, folder, passedRoleRequest string,
+	plan *rollbackPlan, logger logr.Logger) error {
+
 	snapshotClient := collector.GetClient()
-	roleRequests, err := snapshotClient.GetClusterResources(folder, libsveltosv1beta1.RoleRequestKind, logger)
+	roleRequests, err := snapshotClient.GetClusterResources(storage, folder, libsveltosv1beta1.RoleRequestKind, logger)
 	if err != nil {
 		logger.V(logs.LogDebug).Info(fmt.Sprintf("failed to collect RoleRequests from folder %s", folder))
 		return err
 	}
 
 	for i := range roleRequests {
-		cl := roleRequests[i]
-		if passedRoleRequest == "" || cl.GetName() == passedRoleRequest {
-			logger.V(logs.LogDebug).Info(fmt.Sprintf("rollback RoleRequest %s", cl.GetName()))
-			err = rollbackClassifier(ctx, cl, logger)
+		rr := roleRequests[i]
+		if passedRoleRequest == "" || rr.GetName() == passedRoleRequest {
+			logger.V(logs.LogDebug).Info(fmt.Sprintf("rollback RoleRequest %s", rr.GetName()))
+			err = rollbackRoleRequest(ctx, rr, plan, logger)
 			if err != nil {
 				return err
 			}
@@ -318,9 +670,66 @@ func getAndRollbackRoleRequests(ctx context.Context, folder, passedRoleRequest s
 	return nil
 }
 
-func rollbackConfigMaps(ctx context.Context, resources []*unstructured.Unstructured, logger logr.Logger) error {
+// getAndRollbackClusterResources rolls back all cluster wide resources of the given kind
+// present in the sample. If passedName is set, only resource with that name is rolled back.
+func getAndRollbackClusterResources(ctx context.Context, storage collector.Storage, folder, kind, passedName string,
+	plan *rollbackPlan, logger logr.Logger) error {
+
+	snapshotClient := collector.GetClient()
+	resources, err := snapshotClient.GetClusterResources(storage, folder, kind, logger)
+	if err != nil {
+		logger.V(logs.LogDebug).Info(fmt.Sprintf("failed to collect %s from folder %s", kind, folder))
+		return err
+	}
+
 	for i := range resources {
-		err := rollbackConfigMap(ctx, resources[i], logger)
+		r := resources[i]
+		if passedName == "" || r.GetName() == passedName {
+			logger.V(logs.LogDebug).Info(fmt.Sprintf("rollback %s %s", kind, r.GetName()))
+			err = rollbackSpec(ctx, r, plan, logger)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func getAndRollbackSets(ctx context.Context, storage collector.Storage, folder, passedNamespace, passedSet string,
+	plan *rollbackPlan, logger logr.Logger) error {
+
+	snapshotClient := collector.GetClient()
+	sets, err := snapshotClient.GetNamespacedResources(storage, folder, libsveltosv1beta1.SetKind, logger)
+	if err != nil {
+		logger.V(logs.LogDebug).Info(fmt.Sprintf("failed to collect Sets from folder %s", folder))
+		return err
+	}
+
+	for ns := range sets {
+		if passedNamespace != "" && ns != passedNamespace {
+			continue
+		}
+		logger.V(logs.LogDebug).Info(fmt.Sprintf("rollback Sets in namespace %s", ns))
+		for i := range sets[ns] {
+			s := sets[ns][i]
+			if passedSet == "" || s.GetName() == passedSet {
+				err = rollbackSpec(ctx, s, plan, logger)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func rollbackConfigMaps(ctx context.Context, resources []*unstructured.Unstructured, plan *rollbackPlan,
+	logger logr.Logger) error {
+
+	for i := range resources {
+		err := rollbackConfigMap(ctx, resources[i], plan, logger)
 		if err != nil {
 			return err
 		}
@@ -332,7 +741,9 @@ func rollbackConfigMaps(ctx context.Context, resources []*unstructured.Unstructu
 // rollbackConfigMap does following:
 // - if ConfigMap currently does not exist, recreates it
 // - if ConfigMap does exist, updates it Data/BinaryData
-func rollbackConfigMap(ctx context.Context, resource *unstructured.Unstructured, logger logr.Logger) error {
+func rollbackConfigMap(ctx context.Context, resource *unstructured.Unstructured, plan *rollbackPlan,
+	logger logr.Logger) error {
+
 	instance := utils.GetAccessInstance()
 
 	currentConfigMap := &corev1.ConfigMap{}
@@ -343,7 +754,7 @@ func rollbackConfigMap(ctx context.Context, resource *unstructured.Unstructured,
 		if apierrors.IsNotFound(err) {
 			logger.V(logs.LogDebug).Info(fmt.Sprintf("Creating ConfigMap %s/%s",
 				resource.GetNamespace(), resource.GetName()))
-			return instance.CreateResource(ctx, resource)
+			return createResource(ctx, resource, plan)
 		}
 		return err
 	}
@@ -355,17 +766,20 @@ func rollbackConfigMap(ctx context.Context, resource *unstructured.Unstructured,
 		return err
 	}
 
+	original := currentConfigMap.DeepCopy()
 	currentConfigMap.Data = passedConfigMap.Data
 	currentConfigMap.BinaryData = passedConfigMap.BinaryData
 
 	logger.V(logs.LogDebug).Info(fmt.Sprintf("Updating ConfigMap %s/%s",
 		resource.GetNamespace(), resource.GetName()))
-	return instance.UpdateResource(ctx, currentConfigMap)
+	return updateResource(ctx, "ConfigMap", original, currentConfigMap, plan)
 }
 
-func rollbackSecrets(ctx context.Context, resources []*unstructured.Unstructured, logger logr.Logger) error {
+func rollbackSecrets(ctx context.Context, resources []*unstructured.Unstructured, plan *rollbackPlan,
+	logger logr.Logger) error {
+
 	for i := range resources {
-		err := rollbackSecret(ctx, resources[i], logger)
+		err := rollbackSecret(ctx, resources[i], plan, logger)
 		if err != nil {
 			return err
 		}
@@ -375,9 +789,28 @@ func rollbackSecrets(ctx context.Context, resources []*unstructured.Unstructured
 }
 
 // rollbackSecret does following:
+// - if Secret was not stored in full, warns and leaves Secret untouched
 // - if Secret currently does not exist, recreates it
 // - if Secret does exist, updates it Data/StringData
-func rollbackSecret(ctx context.Context, resource *unstructured.Unstructured, logger logr.Logger) error {
+func rollbackSecret(ctx context.Context, resource *unstructured.Unstructured, plan *rollbackPlan,
+	logger logr.Logger) error {
+
+	if !collector.IsSecretStoredInFull(resource) {
+		message := fmt.Sprintf("Secret was not stored in full (mode %s). It is not rolled back.",
+			resource.GetAnnotations()[collector.SecretModeAnnotation])
+		logger.V(logs.LogInfo).Info(fmt.Sprintf("Secret %s/%s: %s", resource.GetNamespace(), resource.GetName(), message))
+		if plan != nil {
+			plan.addEntry(&RollbackPlanEntry{
+				Action:    RollbackActionSkipped,
+				Kind:      "Secret",
+				Namespace: resource.GetNamespace(),
+				Name:      resource.GetName(),
+				Message:   message,
+			}, nil)
+		}
+		return nil
+	}
+
 	instance := utils.GetAccessInstance()
 
 	currentSecret := &corev1.Secret{}
@@ -388,7 +821,7 @@ func rollbackSecret(ctx context.Context, resource *unstructured.Unstructured, lo
 		if apierrors.IsNotFound(err) {
 			logger.V(logs.LogDebug).Info(fmt.Sprintf("Creating Secret %s/%s",
 				resource.GetNamespace(), resource.GetName()))
-			return instance.CreateResource(ctx, resource)
+			return createResource(ctx, resource, plan)
 		}
 		return err
 	}
@@ -400,21 +833,22 @@ func rollbackSecret(ctx context.Context, resource *unstructured.Unstructured, lo
 		return err
 	}
 
+	original := currentSecret.DeepCopy()
 	currentSecret.Data = passedSecret.Data
 	currentSecret.StringData = passedSecret.StringData
 
 	logger.V(logs.LogDebug).Info(fmt.Sprintf("Updating Secret %s/%s",
 		resource.GetNamespace(), resource.GetName()))
-	return instance.UpdateResource(ctx, currentSecret)
+	return updateResource(ctx, "Secret", original, currentSecret, plan)
 }
 
 func rollbackClusters(ctx context.Context, resources []*unstructured.Unstructured, passedCluster string,
-	clusterType libsveltosv1beta1.ClusterType, logger logr.Logger) error {
+	clusterType libsveltosv1beta1.ClusterType, plan *rollbackPlan, logger logr.Logger) error {
 
 	for i := range resources {
 		if passedCluster == "" || resources[i].GetName() == passedCluster {
 			logger.V(logs.LogDebug).Info(fmt.Sprintf("rollback Cluster %s", resources[i].GetName()))
-			err := rollbackCluster(ctx, resources[i], clusterType, logger)
+			err := rollbackCluster(ctx, resources[i], clusterType, plan, logger)
 			if err != nil {
 				return err
 			}
@@ -427,16 +861,18 @@ func rollbackClusters(ctx context.Context, resources []*unstructured.Unstructure
 // rollbackCluster does not nothing if Cluster currently does not exist.
 // If Cluster currently exists, then it updates Cluster.Labels
 func rollbackCluster(ctx context.Context, resource *unstructured.Unstructured,
-	clusterType libsveltosv1beta1.ClusterType, logger logr.Logger) error {
+	clusterType libsveltosv1beta1.ClusterType, plan *rollbackPlan, logger logr.Logger) error {
 
 	if clusterType == libsveltosv1beta1.ClusterTypeCapi {
-		return rollbackCAPICluster(ctx, resource, logger)
+		return rollbackCAPICluster(ctx, resource, plan, logger)
 	}
 
-	return rollbackSveltosCluster(ctx, resource, logger)
+	return rollbackSveltosCluster(ctx, resource, plan, logger)
 }
 
-func rollbackCAPICluster(ctx context.Context, resource *unstructured.Unstructured, logger logr.Logger) error {
+func rollbackCAPICluster(ctx context.Context, resource *unstructured.Unstructured, plan *rollbackPlan,
+	logger logr.Logger) error {
+
 	instance := utils.GetAccessInstance()
 
 	currentCluster := &clusterv1.Cluster{}
@@ -459,14 +895,17 @@ func rollbackCAPICluster(ctx context.Context, resource *unstructured.Unstructure
 		return err
 	}
 
+	original := currentCluster.DeepCopy()
 	currentCluster.Labels = passedCluster.Labels
 
 	logger.V(logs.LogDebug).Info(fmt.Sprintf("Updating Cluster %s",
 		resource.GetName()))
-	return instance.UpdateResource(ctx, currentCluster)
+	return updateResource(ctx, "Cluster", original, currentCluster, plan)
 }
 
-func rollbackSveltosCluster(ctx context.Context, resource *unstructured.Unstructured, logger logr.Logger) error {
+func rollbackSveltosCluster(ctx context.Context, resource *unstructured.Unstructured, plan *rollbackPlan,
+	logger logr.Logger) error {
+
 	instance := utils.GetAccessInstance()
 
 	currentCluster := &libsveltosv1beta1.SveltosCluster{}
@@ -489,17 +928,20 @@ func rollbackSveltosCluster(ctx context.Context, resource *unstructured.Unstruct
 		return err
 	}
 
+	original := currentCluster.DeepCopy()
 	currentCluster.Labels = passedCluster.Labels
 
 	logger.V(logs.LogDebug).Info(fmt.Sprintf("Updating Cluster %s",
 		resource.GetName()))
-	return instance.UpdateResource(ctx, currentCluster)
+	return updateResource(ctx, libsveltosv1beta1.SveltosClusterKind, original, currentCluster, plan)
 }
 
 // rollbackClusterProfile does following:
 // - if ClusterProfile currently does not exist, recreates it
 // - if ClusterProfile does exist, updates it Spec section
-func rollbackClusterProfile(ctx context.Context, resource *unstructured.Unstructured, logger logr.Logger) error {
+func rollbackClusterProfile(ctx context.Context, resource *unstructured.Unstructured, plan *rollbackPlan,
+	logger logr.Logger) error {
+
 	instance := utils.GetAccessInstance()
 
 	currentClusterProfile := &configv1beta1.ClusterProfile{}
@@ -509,7 +951,7 @@ func rollbackClusterProfile(ctx context.Context, resource *unstructured.Unstruct
 		if apierrors.IsNotFound(err) {
 			logger.V(logs.LogDebug).Info(fmt.Sprintf("Creating ClusterProfile %s",
 				resource.GetName()))
-			return instance.CreateResource(ctx, resource)
+			return createResource(ctx, resource, plan)
 		}
 		return err
 	}
@@ -521,17 +963,20 @@ func rollbackClusterProfile(ctx context.Context, resource *unstructured.Unstruct
 		return err
 	}
 
+	original := currentClusterProfile.DeepCopy()
 	currentClusterProfile.Spec = passedClusterProfile.Spec
 
 	logger.V(logs.LogDebug).Info(fmt.Sprintf("Updating ClusterProfile %s",
 		resource.GetName()))
-	return instance.UpdateResource(ctx, currentClusterProfile)
+	return updateResource(ctx, configv1beta1.ClusterProfileKind, original, currentClusterProfile, plan)
 }
 
 // rollbackProfile does following:
 // - if Profile currently does not exist, recreates it
 // - if Profile does exist, updates it Spec section
-func rollbackProfile(ctx context.Context, resource *unstructured.Unstructured, logger logr.Logger) error {
+func rollbackProfile(ctx context.Context, resource *unstructured.Unstructured, plan *rollbackPlan,
+	logger logr.Logger) error {
+
 	instance := utils.GetAccessInstance()
 
 	currentProfile := &configv1beta1.Profile{}
@@ -542,7 +987,7 @@ func rollbackProfile(ctx context.Context, resource *unstructured.Unstructured, l
 		if apierrors.IsNotFound(err) {
 			logger.V(logs.LogDebug).Info(fmt.Sprintf("Creating Profile %s/%s",
 				resource.GetNamespace(), resource.GetName()))
-			return instance.CreateResource(ctx, resource)
+			return createResource(ctx, resource, plan)
 		}
 		return err
 	}
@@ -554,17 +999,20 @@ func rollbackProfile(ctx context.Context, resource *unstructured.Unstructured, l
 		return err
 	}
 
+	original := currentProfile.DeepCopy()
 	currentProfile.Spec = passedProfile.Spec
 
 	logger.V(logs.LogDebug).Info(fmt.Sprintf("Updating Profile %s",
 		resource.GetName()))
-	return instance.UpdateResource(ctx, currentProfile)
+	return updateResource(ctx, configv1beta1.ProfileKind, original, currentProfile, plan)
 }
 
 // rollbackClassifier does following:
 // - if Classifier currently does not exist, recreates it
 // - if Classifier does exist, updates it Spec section
-func rollbackClassifier(ctx context.Context, resource *unstructured.Unstructured, logger logr.Logger) error {
+func rollbackClassifier(ctx context.Context, resource *unstructured.Unstructured, plan *rollbackPlan,
+	logger logr.Logger) error {
+
 	instance := utils.GetAccessInstance()
 
 	currentClassifier := &libsveltosv1beta1.Classifier{}
@@ -574,7 +1022,7 @@ func rollbackClassifier(ctx context.Context, resource *unstructured.Unstructured
 		if apierrors.IsNotFound(err) {
 			logger.V(logs.LogDebug).Info(fmt.Sprintf("Creating Classifier %s",
 				resource.GetName()))
-			return instance.CreateResource(ctx, resource)
+			return createResource(ctx, resource, plan)
 		}
 		return err
 	}
@@ -586,47 +1034,158 @@ func rollbackClassifier(ctx context.Context, resource *unstructured.Unstructured
 		return err
 	}
 
+	original := currentClassifier.DeepCopy()
 	currentClassifier.Spec = passedClassifier.Spec
 
 	logger.V(logs.LogDebug).Info(fmt.Sprintf("Updating Classifier %s",
 		resource.GetName()))
-	return instance.UpdateResource(ctx, currentClassifier)
+	return updateResource(ctx, libsveltosv1beta1.ClassifierKind, original, currentClassifier, plan)
+}
+
+// rollbackRoleRequest does following:
+// - if RoleRequest currently does not exist, recreates it
+// - if RoleRequest does exist, updates it Spec section
+func rollbackRoleRequest(ctx context.Context, resource *unstructured.Unstructured, plan *rollbackPlan,
+	logger logr.Logger) error {
+
+	instance := utils.GetAccessInstance()
+
+	currentRoleRequest := &libsveltosv1beta1.RoleRequest{}
+	err := instance.GetResource(ctx,
+		types.NamespacedName{Name: resource.GetName()}, currentRoleRequest)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			logger.V(logs.LogDebug).Info(fmt.Sprintf("Creating RoleRequest %s",
+				resource.GetName()))
+			return createResource(ctx, resource, plan)
+		}
+		return err
+	}
+
+	passedRoleRequest := &libsveltosv1beta1.RoleRequest{}
+	err = runtime.DefaultUnstructuredConverter.
+		FromUnstructured(resource.UnstructuredContent(), passedRoleRequest)
+	if err != nil {
+		return err
+	}
+
+	original := currentRoleRequest.DeepCopy()
+	currentRoleRequest.Spec = passedRoleRequest.Spec
+
+	logger.V(logs.LogDebug).Info(fmt.Sprintf("Updating RoleRequest %s",
+		resource.GetName()))
+	return updateResource(ctx, libsveltosv1beta1.RoleRequestKind, original, currentRoleRequest, plan)
+}
+
+// rollbackSpec does following:
+// - if resource currently does not exist, recreates it
+// - if resource does exist, updates it Spec section
+// It is used for any resource kind whose rollback consists of restoring the Spec section.
+func rollbackSpec(ctx context.Context, resource *unstructured.Unstructured, plan *rollbackPlan,
+	logger logr.Logger) error {
+
+	instance := utils.GetAccessInstance()
+
+	current := &unstructured.Unstructured{}
+	current.SetGroupVersionKind(resource.GroupVersionKind())
+	err := instance.GetResource(ctx,
+		types.NamespacedName{Namespace: resource.GetNamespace(), Name: resource.GetName()}, current)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			logger.V(logs.LogDebug).Info(fmt.Sprintf("Creating %s %s",
+				resource.GetKind(), resource.GetName()))
+			return createResource(ctx, resource, plan)
+		}
+		return err
+	}
+
+	original := current.DeepCopy()
+	spec, found, err := unstructured.NestedFieldCopy(resource.Object, "spec")
+	if err != nil {
+		return err
+	}
+	if found {
+		current.Object["spec"] = spec
+	} else {
+		delete(current.Object, "spec")
+	}
+
+	logger.V(logs.LogDebug).Info(fmt.Sprintf("Updating %s %s",
+		resource.GetKind(), resource.GetName()))
+	return updateResource(ctx, resource.GetKind(), original, current, plan)
 }
 
 // Rollback system to any previous configuration snapshot
-func Rollback(ctx context.Context, args []string, logger logr.Logger) error {
+func Rollback(ctx context.Context, args []string, takeSample SampleCollector, logger logr.Logger) error {
 	//nolint: lll // command syntax
 	doc := `Usage:
-	sveltosctl snapshot rollback [options] --snapshot=<name> --sample=<name> [--namespace=<name>] [--profile=<name>] [--cluster=<name>] [--classifier=<name>] [--rolerequest=<name>] [--verbose]
-
-     --snapshot=<name>       Name of the Snapshot instance
-     --sample=<name>         Name of the directory containing this sample.
-                             Use sveltosctl snapshot list to see all collected snapshosts.
-     --namespace=<name>      Rollbacks only ConfigMaps/Secrets and Cluster labels in this namespace.
-                             If not specified all namespaces are considered.
-     --cluster=<name>        Rollback only clusters with this name.
-                             If not specified all clusters are updated.
-     --profile=<kind/name>   Rollback only clusterprofile/profile with this name.
-                             If not specified all clusterprofiles are updated.
-     --classifier=<name>     Rollback only classifier with this name.
-                             If not specified all classifiers are updated.
-     --rolerequest=<name>    Rollback only roleRequest with this name.
-                             If not specified all roleRequests are updated.
+	sveltosctl snapshot rollback [options] --snapshot=<name> --sample=<name> [--namespace=<name>] [--profile=<name>] [--cluster=<name>] [--classifier=<name>] [--rolerequest=<name>] [--eventsource=<name>] [--eventtrigger=<name>] [--healthcheck=<name>] [--clusterhealthcheck=<name>] [--clusterset=<name>] [--set=<name>] [--prune] [--yes] [--dry-run] [--force] [--ignore-fields=<list>] [--ignore-file=<path>] [--verbose]
+
+     --snapshot=<name>            Name of the Snapshot instance
+     --sample=<name>              Name of the directory containing this sample.
+                                  Use sveltosctl snapshot list to see all collected snapshosts.
+     --namespace=<name>           Rollbacks only ConfigMaps/Secrets, Cluster labels, Profiles and Sets in this namespace.
+                                  If not specified all namespaces are considered.
+     --cluster=<name>             Rollback only clusters with this name.
+                                  If not specified all clusters are updated.
+     --profile=<kind/name>        Rollback only clusterprofile/profile with this name.
+                                  If not specified all clusterprofiles are updated.
+     --classifier=<name>          Rollback only classifier with this name.
+                                  If not specified all classifiers are updated.
+     --rolerequest=<name>         Rollback only roleRequest with this name.
+                                  If not specified all roleRequests are updated.
+     --eventsource=<name>         Rollback only eventSource with this name.
+                                  If not specified all eventSources are updated.
+     --eventtrigger=<name>        Rollback only eventTrigger with this name.
+                                  If not specified all eventTriggers are updated.
+     --healthcheck=<name>         Rollback only healthCheck with this name.
+                                  If not specified all healthChecks are updated.
+     --clusterhealthcheck=<name>  Rollback only clusterHealthCheck with this name.
+                                  If not specified all clusterHealthChecks are updated.
+     --clusterset=<name>          Rollback only clusterSet with this name.
+                                  If not specified all clusterSets are updated.
+     --set=<name>                 Rollback only set with this name.
+                                  If not specified all sets are updated.
 
 Options:
   -h --help                  Show this screen.
+     --prune                 Delete ClusterProfiles, Profiles, Classifiers, RoleRequests, EventSources, EventTriggers,
+                             HealthChecks, ClusterHealthChecks, ClusterSets and Sets not present in the sample.
+                             When filters above select resources by name, only the kinds those filters name are
+                             pruned (so --cluster alone prunes nothing); --namespace alone prunes only Profiles
+                             and Sets in that namespace. A confirmation is asked before deleting.
+     --yes                   Do not ask for confirmation before pruning.
+     --dry-run               Do not modify anything. Print, for each object, whether it would be created,
+                             updated (along with the fields changing) or left unchanged, and which clusters
+                             would gain or lose a match with a ClusterProfile/Profile because of label changes.
+     --force                 Restore the sample even if it fails verification against its manifest
+                             (for instance samples taken before manifests were introduced).
+     --ignore-fields=<list>  Comma separated list of field paths which are not compared (see sveltosctl snapshot diff).
+                             Objects whose only differences are ignored fields are left unchanged.
+     --ignore-file=<path>    YAML file containing ignore rules (see sveltosctl snapshot diff).
      --verbose               Verbose mode. Print each step.  
 
 Description:
   The snapshot rollback allows to rollback system to any previous configuration snapshot.
   Following objects will be rolled back:
-  - ClusterProfiles, Labels and Spec sections
-  - RoleRequest, Spec section
-  - ConfigMaps/Secrets referenced by at least one ClusterProfile/RoleRequest at the time snapshot was taken.
-  - Classifiers
+  - ClusterProfiles/Profiles, Spec section
+  - RoleRequests, Classifiers, EventSources, EventTriggers, HealthChecks, ClusterHealthChecks,
+    ClusterSets and Sets, Spec section
+  - ConfigMaps, Secrets, Flux sources and any other resource referenced by at least one ClusterProfile/Profile,
+    EventTrigger or RoleRequest at the time snapshot was taken (PolicyRefs, Helm ValuesFrom, KustomizationRefs,
+    TemplateResourceRefs, ...).
   If, at the time the rollback happens, such resources do not exist, those will be recreated.
-  If such resources exist, Data/BinaryData for ConfigMaps and Data/StringData for Secrets will be updated.
+  If such resources exist, Data/BinaryData for ConfigMaps, Data/StringData for Secrets and every field
+  but metadata and status for any other resource will be updated.
+  Secrets stored hashed, because of the Snapshot secret policies, are not rolled back and a warning is printed.
   - Clusters, only labels will be updated.
+  Use --dry-run to review the rollback plan before applying it.
+  By default, resources created after the sample was taken are left untouched. Use --prune to delete those.
+  Resources out of the sample scope (see Snapshot includeKinds, excludeKinds, namespaceSelector and
+  profileSelector) are never pruned.
+  Before modifying anything, a pre-rollback sample of the current configuration is taken.
+  Use sveltosctl snapshot undo to restore it.
+  Samples are verified against their manifest (see sveltosctl snapshot verify) before being used.
 `
 
 	parsedArgs, err := docopt.ParseArgs(doc, nil, "1.0")
@@ -653,31 +1212,42 @@ Description:
 	snapshostName := parsedArgs["--snapshot"].(string)
 	sample := parsedArgs["--sample"].(string)
 
-	namespace := ""
-	if passedNamespace := parsedArgs["--namespace"]; passedNamespace != nil {
-		namespace = passedNamespace.(string)
+	ignoreRules, err := getIgnoreRules(parsedArgs)
+	if err != nil {
+		return err
 	}
 
-	profile := ""
-	if passedProfile := parsedArgs["--profile"]; passedProfile != nil {
-		profile = passedProfile.(string)
+	options := &RollbackOptions{
+		DryRun:           parsedArgs["--dry-run"].(bool),
+		Prune:            parsedArgs["--prune"].(bool),
+		SkipConfirmation: parsedArgs["--yes"].(bool),
+		TakeSample:       takeSample,
+		Force:            parsedArgs["--force"].(bool),
+		IgnoreRules:      ignoreRules,
 	}
 
-	classifier := ""
-	if passedClassifier := parsedArgs["--classifier"]; passedClassifier != nil {
-		classifier = passedClassifier.(string)
-	}
+	return rollbackConfiguration(ctx, snapshostName, sample, getRollbackFilters(parsedArgs), options, logger)
+}
 
-	roleRequest := ""
-	if passedRoleRequest := parsedArgs["--rolerequest"]; passedRoleRequest != nil {
-		roleRequest = passedRoleRequest.(string)
+func getRollbackFilters(parsedArgs docopt.Opts) *RollbackFilters {
+	getFilter := func(option string) string {
+		if value := parsedArgs[option]; value != nil {
+			return value.(string)
+		}
+		return ""
 	}
 
-	cluster := ""
-	if passedCluster := parsedArgs["--cluster"]; passedCluster != nil {
-		cluster = passedCluster.(string)
+	return &RollbackFilters{
+		Namespace:          getFilter("--namespace"),
+		Cluster:            getFilter("--cluster"),
+		Profile:            getFilter("--profile"),
+		Classifier:         getFilter("--classifier"),
+		RoleRequest:        getFilter("--rolerequest"),
+		EventSource:        getFilter("--eventsource"),
+		EventTrigger:       getFilter("--eventtrigger"),
+		HealthCheck:        getFilter("--healthcheck"),
+		ClusterHealthCheck: getFilter("--clusterhealthcheck"),
+		ClusterSet:         getFilter("--clusterset"),
+		Set:                getFilter("--set"),
 	}
-
-	return rollbackConfiguration(ctx, snapshostName, sample, namespace, cluster, profile,
-		classifier, roleRequest, logger)
 }
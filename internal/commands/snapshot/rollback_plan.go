@@ -0,0 +1,495 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/olekukonko/tablewriter"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	configv1beta1 "github.com/projectsveltos/addon-controller/api/v1beta1"
+	libsveltosv1beta1 "github.com/projectsveltos/libsveltos/api/v1beta1"
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+	"github.com/projectsveltos/sveltosctl/internal/utils"
+)
+
+// RollbackAction is the action rollback takes on a single object
+type RollbackAction string
+
+const (
+	// RollbackActionCreate indicates object does not exist and will be recreated
+	RollbackActionCreate = RollbackAction("create")
+	// RollbackActionUpdate indicates object exists and will be updated
+	RollbackActionUpdate = RollbackAction("update")
+	// RollbackActionUnchanged indicates object already matches the sample
+	RollbackActionUnchanged = RollbackAction("unchanged")
+	// RollbackActionDelete indicates object is not in the sample and will be deleted (prune)
+	RollbackActionDelete = RollbackAction("delete")
+	// RollbackActionFailed indicates applying the action to the object failed
+	RollbackActionFailed = RollbackAction("failed")
+	// RollbackActionSkipped indicates object cannot be restored from the sample and is left untouched
+	RollbackActionSkipped = RollbackAction("skipped")
+)
+
+const (
+	// ProfileMatchGained indicates a cluster will start matching a ClusterProfile/Profile
+	ProfileMatchGained = "gained"
+	// ProfileMatchLost indicates a cluster will stop matching a ClusterProfile/Profile
+	ProfileMatchLost = "lost"
+
+	redactedValue = "<redacted>"
+)
+
+// RollbackPlanEntry describes what rollback does to a single object
+type RollbackPlanEntry struct {
+	Action    RollbackAction `json:"action"`
+	Kind      string         `json:"kind"`
+	Namespace string         `json:"namespace,omitempty"`
+	Name      string         `json:"name"`
+	// Changes contains field level changes. Set only for updates.
+	Changes []FieldChange `json:"changes,omitempty"`
+	// Message provides more information, for instance why applying the action failed
+	Message string `json:"message,omitempty"`
+}
+
+// ProfileMatchChange describes a cluster which gains or loses a ClusterProfile/Profile
+// match because of a rollback
+type ProfileMatchChange struct {
+	// Profile is the ClusterProfile/Profile in the form kind/name or kind/namespace/name
+	Profile string `json:"profile"`
+	// Cluster is the cluster in the form kind:namespace/name
+	Cluster string `json:"cluster"`
+	// Change is either gained or lost
+	Change string `json:"change"`
+}
+
+// rollbackPlan collects what a rollback would do without modifying anything.
+// A nil rollbackPlan means rollback is applied.
+type rollbackPlan struct {
+	Entries []RollbackPlanEntry
+
+	// apply, when set, causes each entry to be applied as well. Entries are
+	// added only once applied.
+	apply bool
+
+	// desired contains, for each planned object, its content after rollback.
+	// Key is built by planKey
+	desired map[string]*unstructured.Unstructured
+
+	// ignore contains the fields which are not compared. An object whose only
+	// changes are ignored fields is left unchanged.
+	ignore *IgnoreRules
+}
+
+func newRollbackPlan() *rollbackPlan {
+	return &rollbackPlan{
+		Entries: make([]RollbackPlanEntry, 0),
+		desired: make(map[string]*unstructured.Unstructured),
+	}
+}
+
+func planKey(kind, namespace, name string) string {
+	return fmt.Sprintf("%s:%s/%s", kind, namespace, name)
+}
+
+func (p *rollbackPlan) addEntry(entry *RollbackPlanEntry, desired *unstructured.Unstructured) {
+	p.Entries = append(p.Entries, *entry)
+	p.desired[planKey(entry.Kind, entry.Namespace, entry.Name)] = desired
+}
+
+// addFailure records that applying entry failed with err. Entry action is reported in message.
+func (p *rollbackPlan) addFailure(entry *RollbackPlanEntry, err error) {
+	entry.Message = fmt.Sprintf("%s failed: %v", entry.Action, err)
+	entry.Action = RollbackActionFailed
+	p.Entries = append(p.Entries, *entry)
+}
+
+// skip records entry as skipped. A failure previously recorded for the same object
+// is replaced.
+func (p *rollbackPlan) skip(entry *RollbackPlanEntry) {
+	p.Entries = slices.DeleteFunc(p.Entries, func(e RollbackPlanEntry) bool {
+		return e.Action == RollbackActionFailed && e.Kind == entry.Kind &&
+			e.Namespace == entry.Namespace && e.Name == entry.Name
+	})
+	entry.Action = RollbackActionSkipped
+	p.addEntry(entry, nil)
+}
+
+// getDesired returns the content an object has after rollback. Returns nil if
+// object is not part of the plan.
+func (p *rollbackPlan) getDesired(kind, namespace, name string) *unstructured.Unstructured {
+	return p.desired[planKey(kind, namespace, name)]
+}
+
+// createResource creates resource. If plan is not nil, resource is not created
+// (unless plan is applied) and a create entry is added to the plan.
+func createResource(ctx context.Context, resource *unstructured.Unstructured, plan *rollbackPlan) error {
+	if plan == nil {
+		return utils.GetAccessInstance().CreateResource(ctx, resource)
+	}
+
+	entry := &RollbackPlanEntry{
+		Action:    RollbackActionCreate,
+		Kind:      resource.GetKind(),
+		Namespace: resource.GetNamespace(),
+		Name:      resource.GetName(),
+	}
+	if plan.apply {
+		if err := utils.GetAccessInstance().CreateResource(ctx, resource); err != nil {
+			plan.addFailure(entry, err)
+			return err
+		}
+	}
+
+	plan.addEntry(entry, resource)
+	return nil
+}
+
+// updateResource updates current. original is the object as currently present
+// in the management cluster and current is same object with rolled back fields.
+// If plan is not nil, nothing is updated (unless plan is applied) and an update
+// (or unchanged) entry with the field level changes, but the ignored ones, is added to the plan.
+func updateResource(ctx context.Context, kind string, original, current client.Object, plan *rollbackPlan) error {
+	if plan == nil {
+		return utils.GetAccessInstance().UpdateResource(ctx, current)
+	}
+
+	originalContent, err := runtime.DefaultUnstructuredConverter.ToUnstructured(original)
+	if err != nil {
+		return err
+	}
+	currentContent, err := runtime.DefaultUnstructuredConverter.ToUnstructured(current)
+	if err != nil {
+		return err
+	}
+
+	entry := &RollbackPlanEntry{
+		Action:    RollbackActionUnchanged,
+		Kind:      kind,
+		Namespace: current.GetNamespace(),
+		Name:      current.GetName(),
+		Changes:   plan.ignore.filterChanges(kind, diffFields("", originalContent, currentContent)),
+	}
+	if len(entry.Changes) > 0 {
+		entry.Action = RollbackActionUpdate
+	}
+	if kind == "Secret" {
+		redactSecretChanges(entry.Changes)
+	}
+
+	if plan.apply && entry.Action == RollbackActionUpdate {
+		err = utils.GetAccessInstance().UpdateResource(ctx, current)
+		if err != nil {
+			plan.addFailure(entry, err)
+			return err
+		}
+	}
+
+	desired := &unstructured.Unstructured{Object: currentContent}
+	desired.SetKind(kind)
+	plan.addEntry(entry, desired)
+	return nil
+}
+
+// redactSecretChanges hides Secret values. Only the keys being changed are reported.
+func redactSecretChanges(changes []FieldChange) {
+	for i := range changes {
+		if changes[i].From != nil {
+			changes[i].From = redactedValue
+		}
+		if changes[i].To != nil {
+			changes[i].To = redactedValue
+		}
+	}
+}
+
+// profileSelector contains the information needed to evaluate which clusters
+// a ClusterProfile/Profile matches
+type profileSelector struct {
+	// namespace is empty for ClusterProfiles. Profiles match only clusters in their namespace.
+	namespace string
+	selector  libsveltosv1beta1.Selector
+}
+
+// clusterLabels contains the labels of a CAPI Cluster/SveltosCluster
+type clusterLabels struct {
+	namespace string
+	labels    map[string]string
+}
+
+// getProfileMatchChanges returns the clusters which, after the planned rollback,
+// gain or lose a match with a ClusterProfile/Profile because of either cluster labels
+// or profile clusterSelector changes.
+func getProfileMatchChanges(ctx context.Context, plan *rollbackPlan, logger logr.Logger,
+) ([]ProfileMatchChange, error) {
+
+	currentProfiles, err := getCurrentProfileSelectors(ctx)
+	if err != nil {
+		return nil, err
+	}
+	desiredProfiles, err := getDesiredProfileSelectors(plan, currentProfiles)
+	if err != nil {
+		return nil, err
+	}
+
+	currentClusters, err := getCurrentClusterLabels(ctx, logger)
+	if err != nil {
+		return nil, err
+	}
+	desiredClusters := getDesiredClusterLabels(plan, currentClusters)
+
+	profileNames := make([]string, 0, len(desiredProfiles))
+	for profile := range desiredProfiles {
+		profileNames = append(profileNames, profile)
+	}
+	for profile := range currentProfiles {
+		if _, ok := desiredProfiles[profile]; !ok {
+			profileNames = append(profileNames, profile)
+		}
+	}
+	sort.Strings(profileNames)
+
+	clusterNames := make([]string, 0, len(currentClusters))
+	for cluster := range currentClusters {
+		clusterNames = append(clusterNames, cluster)
+	}
+	sort.Strings(clusterNames)
+
+	changes := make([]ProfileMatchChange, 0)
+	for _, profile := range profileNames {
+		for _, cluster := range clusterNames {
+			before, err := isClusterMatching(currentProfiles[profile], currentClusters[cluster])
+			if err != nil {
+				return nil, err
+			}
+			after, err := isClusterMatching(desiredProfiles[profile], desiredClusters[cluster])
+			if err != nil {
+				return nil, err
+			}
+
+			switch {
+			case !before && after:
+				changes = append(changes, ProfileMatchChange{Profile: profile, Cluster: cluster, Change: ProfileMatchGained})
+			case before && !after:
+				changes = append(changes, ProfileMatchChange{Profile: profile, Cluster: cluster, Change: ProfileMatchLost})
+			}
+		}
+	}
+
+	return changes, nil
+}
+
+// isClusterMatching returns true if cluster matches profile clusterSelector.
+// As in Sveltos, an empty clusterSelector matches no cluster.
+func isClusterMatching(profile *profileSelector, cluster *clusterLabels) (bool, error) {
+	if profile == nil || cluster == nil {
+		return false, nil
+	}
+
+	if len(profile.selector.MatchLabels) == 0 && len(profile.selector.MatchExpressions) == 0 {
+		return false, nil
+	}
+
+	if profile.namespace != "" && profile.namespace != cluster.namespace {
+		return false, nil
+	}
+
+	selector, err := profile.selector.ToSelector()
+	if err != nil {
+		return false, err
+	}
+
+	return selector.Matches(labels.Set(cluster.labels)), nil
+}
+
+func getCurrentProfileSelectors(ctx context.Context) (map[string]*profileSelector, error) {
+	instance := utils.GetAccessInstance()
+
+	result := make(map[string]*profileSelector)
+
+	clusterProfiles := &configv1beta1.ClusterProfileList{}
+	if err := instance.ListResources(ctx, clusterProfiles); err != nil {
+		return nil, err
+	}
+	for i := range clusterProfiles.Items {
+		cp := &clusterProfiles.Items[i]
+		result[getProfileName(configv1beta1.ClusterProfileKind, "", cp.Name)] =
+			&profileSelector{selector: cp.Spec.ClusterSelector}
+	}
+
+	profiles := &configv1beta1.ProfileList{}
+	if err := instance.ListResources(ctx, profiles); err != nil {
+		return nil, err
+	}
+	for i := range profiles.Items {
+		p := &profiles.Items[i]
+		result[getProfileName(configv1beta1.ProfileKind, p.Namespace, p.Name)] =
+			&profileSelector{namespace: p.Namespace, selector: p.Spec.ClusterSelector}
+	}
+
+	return result, nil
+}
+
+// getDesiredProfileSelectors returns ClusterProfiles/Profiles clusterSelectors after the planned rollback
+func getDesiredProfileSelectors(plan *rollbackPlan, current map[string]*profileSelector,
+) (map[string]*profileSelector, error) {
+
+	result := make(map[string]*profileSelector, len(current))
+	for profile := range current {
+		result[profile] = current[profile]
+	}
+
+	for i := range plan.Entries {
+		entry := &plan.Entries[i]
+		if entry.Kind != configv1beta1.ClusterProfileKind && entry.Kind != configv1beta1.ProfileKind ||
+			entry.Action == RollbackActionFailed {
+			continue
+		}
+
+		profileName := getProfileName(entry.Kind, entry.Namespace, entry.Name)
+		if entry.Action == RollbackActionDelete {
+			delete(result, profileName)
+			continue
+		}
+
+		desired := plan.getDesired(entry.Kind, entry.Namespace, entry.Name)
+		spec := &configv1beta1.Spec{}
+		content, _, err := unstructured.NestedMap(desired.Object, "spec")
+		if err != nil {
+			return nil, err
+		}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(content, spec); err != nil {
+			return nil, err
+		}
+
+		result[profileName] = &profileSelector{namespace: entry.Namespace, selector: spec.ClusterSelector}
+	}
+
+	return result, nil
+}
+
+func getCurrentClusterLabels(ctx context.Context, logger logr.Logger) (map[string]*clusterLabels, error) {
+	instance := utils.GetAccessInstance()
+
+	result := make(map[string]*clusterLabels)
+
+	capiClusters := &clusterv1.ClusterList{}
+	err := instance.ListResources(ctx, capiClusters)
+	if err != nil {
+		if !meta.IsNoMatchError(err) {
+			return nil, err
+		}
+		logger.V(logs.LogDebug).Info("CAPI Cluster CRD is not installed")
+	}
+	for i := range capiClusters.Items {
+		c := &capiClusters.Items[i]
+		result[getClusterName("Cluster", c.Namespace, c.Name)] =
+			&clusterLabels{namespace: c.Namespace, labels: c.Labels}
+	}
+
+	sveltosClusters := &libsveltosv1beta1.SveltosClusterList{}
+	if err := instance.ListResources(ctx, sveltosClusters); err != nil {
+		return nil, err
+	}
+	for i := range sveltosClusters.Items {
+		c := &sveltosClusters.Items[i]
+		result[getClusterName(libsveltosv1beta1.SveltosClusterKind, c.Namespace, c.Name)] =
+			&clusterLabels{namespace: c.Namespace, labels: c.Labels}
+	}
+
+	return result, nil
+}
+
+// getDesiredClusterLabels returns clusters labels after the planned rollback. Rollback
+// never creates clusters, so only existing clusters are considered.
+func getDesiredClusterLabels(plan *rollbackPlan, current map[string]*clusterLabels) map[string]*clusterLabels {
+	result := make(map[string]*clusterLabels, len(current))
+	for cluster := range current {
+		result[cluster] = current[cluster]
+	}
+
+	for i := range plan.Entries {
+		entry := &plan.Entries[i]
+		if entry.Kind != "Cluster" && entry.Kind != libsveltosv1beta1.SveltosClusterKind ||
+			entry.Action == RollbackActionFailed {
+			continue
+		}
+
+		desired := plan.getDesired(entry.Kind, entry.Namespace, entry.Name)
+		result[getClusterName(entry.Kind, entry.Namespace, entry.Name)] =
+			&clusterLabels{namespace: entry.Namespace, labels: desired.GetLabels()}
+	}
+
+	return result
+}
+
+func getProfileName(kind, namespace, name string) string {
+	if namespace == "" {
+		return fmt.Sprintf("%s/%s", kind, name)
+	}
+	return fmt.Sprintf("%s/%s/%s", kind, namespace, name)
+}
+
+func getClusterName(kind, namespace, name string) string {
+	return fmt.Sprintf("%s:%s/%s", kind, namespace, name)
+}
+
+// printRollbackPlan displays, for each object, the rollback action and, for
+// updates, the field level changes. It then displays the clusters gaining
+// or losing a ClusterProfile/Profile match.
+func printRollbackPlan(w io.Writer, plan *rollbackPlan, matchChanges []ProfileMatchChange) {
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"ACTION", "KIND", "NAMESPACE", "NAME", "CHANGES"})
+	table.SetAutoWrapText(false)
+	for i := range plan.Entries {
+		entry := &plan.Entries[i]
+		changes := make([]string, len(entry.Changes))
+		for j := range entry.Changes {
+			changes[j] = entry.Changes[j].String()
+		}
+		if entry.Message != "" {
+			changes = append([]string{entry.Message}, changes...)
+		}
+		table.Append([]string{string(entry.Action), entry.Kind, entry.Namespace, entry.Name,
+			strings.Join(changes, "\n")})
+	}
+	table.Render()
+
+	if len(matchChanges) == 0 {
+		fmt.Fprintln(w, "No cluster gains or loses a ClusterProfile/Profile match")
+		return
+	}
+
+	matchTable := tablewriter.NewWriter(w)
+	matchTable.SetHeader([]string{"PROFILE", "CLUSTER", "MATCH"})
+	for i := range matchChanges {
+		matchTable.Append([]string{matchChanges[i].Profile, matchChanges[i].Cluster, matchChanges[i].Change})
+	}
+	matchTable.Render()
+}
@@ -0,0 +1,299 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/olekukonko/tablewriter"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	configv1beta1 "github.com/projectsveltos/addon-controller/api/v1beta1"
+	eventv1beta1 "github.com/projectsveltos/event-manager/api/v1beta1"
+	libsveltosv1beta1 "github.com/projectsveltos/libsveltos/api/v1beta1"
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+	"github.com/projectsveltos/sveltosctl/internal/collector"
+	"github.com/projectsveltos/sveltosctl/internal/utils"
+)
+
+// prunableKind is a kind whose instances not present in a sample are deleted by
+// rollback when prune is set.
+// ConfigMaps/Secrets are not prunable as a sample only contains the ones referenced
+// by Sveltos resources. Clusters are not prunable as rollback only restores their labels.
+type prunableKind struct {
+	gvk        schema.GroupVersionKind
+	namespaced bool
+	// getFilter returns the name of the only instance rollback filters select. Empty
+	// if the kind is not filtered.
+	getFilter func(filters *RollbackFilters) string
+}
+
+var prunableKinds = []prunableKind{
+	{
+		gvk: configv1beta1.GroupVersion.WithKind(configv1beta1.ClusterProfileKind),
+		getFilter: func(filters *RollbackFilters) string {
+			return getProfileFilter(filters, configv1beta1.ClusterProfileKind)
+		},
+	},
+	{
+		gvk:        configv1beta1.GroupVersion.WithKind(configv1beta1.ProfileKind),
+		namespaced: true,
+		getFilter:  func(filters *RollbackFilters) string { return getProfileFilter(filters, configv1beta1.ProfileKind) },
+	},
+	{
+		gvk:       libsveltosv1beta1.GroupVersion.WithKind(libsveltosv1beta1.ClassifierKind),
+		getFilter: func(filters *RollbackFilters) string { return filters.Classifier },
+	},
+	{
+		gvk:       libsveltosv1beta1.GroupVersion.WithKind(libsveltosv1beta1.RoleRequestKind),
+		getFilter: func(filters *RollbackFilters) string { return filters.RoleRequest },
+	},
+	{
+		gvk:       libsveltosv1beta1.GroupVersion.WithKind(libsveltosv1beta1.EventSourceKind),
+		getFilter: func(filters *RollbackFilters) string { return filters.EventSource },
+	},
+	{
+		gvk:       eventv1beta1.GroupVersion.WithKind(eventv1beta1.EventTriggerKind),
+		getFilter: func(filters *RollbackFilters) string { return filters.EventTrigger },
+	},
+	{
+		gvk:       libsveltosv1beta1.GroupVersion.WithKind(libsveltosv1beta1.HealthCheckKind),
+		getFilter: func(filters *RollbackFilters) string { return filters.HealthCheck },
+	},
+	{
+		gvk:       libsveltosv1beta1.GroupVersion.WithKind(libsveltosv1beta1.ClusterHealthCheckKind),
+		getFilter: func(filters *RollbackFilters) string { return filters.ClusterHealthCheck },
+	},
+	{
+		gvk:       libsveltosv1beta1.GroupVersion.WithKind(libsveltosv1beta1.ClusterSetKind),
+		getFilter: func(filters *RollbackFilters) string { return filters.ClusterSet },
+	},
+	{
+		gvk:        libsveltosv1beta1.GroupVersion.WithKind(libsveltosv1beta1.SetKind),
+		namespaced: true,
+		getFilter:  func(filters *RollbackFilters) string { return filters.Set },
+	},
+}
+
+// getProfileFilter returns the name of the profile filters select if of given kind
+// (ClusterProfile or Profile), empty otherwise
+func getProfileFilter(filters *RollbackFilters, kind string) string {
+	name, found := strings.CutPrefix(filters.Profile, kind+"/")
+	if !found {
+		return ""
+	}
+	return name
+}
+
+// hasNameFilters returns true if filters select instances by name, of any kind
+func hasNameFilters(filters *RollbackFilters) bool {
+	return filters.Cluster != "" || filters.Profile != "" || filters.Classifier != "" ||
+		filters.RoleRequest != "" || filters.EventSource != "" || filters.EventTrigger != "" ||
+		filters.HealthCheck != "" || filters.ClusterHealthCheck != "" || filters.ClusterSet != "" ||
+		filters.Set != ""
+}
+
+// isPruned returns true if instances of kind can be pruned with filters.
+// When filters select instances by name, only the kinds filters name are pruned (so
+// pruning with a Cluster filter deletes nothing). Otherwise, when a namespace is passed,
+// only namespaced kinds are pruned.
+func (k *prunableKind) isPruned(filters *RollbackFilters) bool {
+	if hasNameFilters(filters) {
+		return k.getFilter(filters) != ""
+	}
+	if filters.Namespace != "" {
+		return k.namespaced
+	}
+	return true
+}
+
+// isSelected returns true if instance of kind in namespace/name is within rollback filters
+func (k *prunableKind) isSelected(filters *RollbackFilters, namespace, name string) bool {
+	if k.namespaced && filters.Namespace != "" && namespace != filters.Namespace {
+		return false
+	}
+	passedName := k.getFilter(filters)
+	return passedName == "" || passedName == name
+}
+
+// getPruneCandidates returns all the live instances of the prunable kinds which are
+// within rollback filters and are not present in the sample (see prunableKind.isPruned).
+// Instances out of the sample scope are absent on purpose and never candidates.
+func getPruneCandidates(ctx context.Context, storage collector.Storage, folder string,
+	filters *RollbackFilters, logger logr.Logger) ([]*unstructured.Unstructured, error) {
+
+	metadata, err := collector.GetClient().GetSampleMetadata(storage, folder)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make([]*unstructured.Unstructured, 0)
+	for i := range prunableKinds {
+		if !prunableKinds[i].isPruned(filters) {
+			logger.V(logs.LogDebug).Info(fmt.Sprintf("%s not selected by filters", prunableKinds[i].gvk.Kind))
+			continue
+		}
+		if !metadata.Scope.IsKindInScope(prunableKinds[i].gvk.Kind) {
+			logger.V(logs.LogDebug).Info(fmt.Sprintf("%s not in sample scope", prunableKinds[i].gvk.Kind))
+			continue
+		}
+		kindCandidates, err := getPruneCandidatesForKind(ctx, storage, folder, &prunableKinds[i], filters,
+			metadata.Scope, logger)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, kindCandidates...)
+	}
+
+	return candidates, nil
+}
+
+func getPruneCandidatesForKind(ctx context.Context, storage collector.Storage, folder string,
+	kind *prunableKind, filters *RollbackFilters, scope *collector.SampleScope, logger logr.Logger,
+) ([]*unstructured.Unstructured, error) {
+
+	inSample, err := getSampleObjects(storage, folder, kind, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(kind.gvk.GroupVersion().WithKind(kind.gvk.Kind + "List"))
+	err = utils.GetAccessInstance().ListResources(ctx, list)
+	if err != nil {
+		if !meta.IsNoMatchError(err) {
+			return nil, err
+		}
+		// Optional CRD (for instance EventTrigger) is not installed: nothing to prune
+		logger.V(logs.LogDebug).Info(fmt.Sprintf("%s CRD is not installed", kind.gvk.Kind))
+		return nil, nil
+	}
+
+	candidates := make([]*unstructured.Unstructured, 0)
+	for i := range list.Items {
+		u := &list.Items[i]
+		if !kind.isSelected(filters, u.GetNamespace(), u.GetName()) {
+			continue
+		}
+		inScope, err := scope.IsObjectInScope(kind.gvk.Kind, u)
+		if err != nil {
+			return nil, err
+		}
+		if !inScope {
+			continue
+		}
+		if inSample[planKey(kind.gvk.Kind, u.GetNamespace(), u.GetName())] {
+			continue
+		}
+		logger.V(logs.LogDebug).Info(fmt.Sprintf("%s %s/%s not in sample", kind.gvk.Kind, u.GetNamespace(), u.GetName()))
+		candidates = append(candidates, u)
+	}
+
+	return candidates, nil
+}
+
+// getSampleObjects returns the set of instances of kind present in the sample
+func getSampleObjects(storage collector.Storage, folder string, kind *prunableKind,
+	logger logr.Logger) (map[string]bool, error) {
+
+	snapshotClient := collector.GetClient()
+
+	resources := make([]*unstructured.Unstructured, 0)
+	if kind.namespaced {
+		resourceMap, err := snapshotClient.GetNamespacedResources(storage, folder, kind.gvk.Kind, logger)
+		if err != nil {
+			return nil, err
+		}
+		for ns := range resourceMap {
+			resources = append(resources, resourceMap[ns]...)
+		}
+	} else {
+		var err error
+		resources, err = snapshotClient.GetClusterResources(storage, folder, kind.gvk.Kind, logger)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := make(map[string]bool, len(resources))
+	for i := range resources {
+		result[planKey(kind.gvk.Kind, resources[i].GetNamespace(), resources[i].GetName())] = true
+	}
+
+	return result, nil
+}
+
+// pruneResources deletes resources. If plan is not nil, nothing is deleted (unless
+// plan is applied) and a delete entry is added to the plan for each resource.
+func pruneResources(ctx context.Context, resources []*unstructured.Unstructured, plan *rollbackPlan,
+	logger logr.Logger) error {
+
+	for i := range resources {
+		r := resources[i]
+		entry := &RollbackPlanEntry{
+			Action:    RollbackActionDelete,
+			Kind:      r.GetKind(),
+			Namespace: r.GetNamespace(),
+			Name:      r.GetName(),
+		}
+		if plan == nil || plan.apply {
+			logger.V(logs.LogDebug).Info(fmt.Sprintf("Deleting %s %s/%s", r.GetKind(), r.GetNamespace(), r.GetName()))
+			err := utils.GetAccessInstance().DeleteResource(ctx, r)
+			if err != nil && !apierrors.IsNotFound(err) {
+				if plan != nil {
+					plan.addFailure(entry, err)
+				}
+				return err
+			}
+		}
+
+		if plan != nil {
+			plan.addEntry(entry, nil)
+		}
+	}
+
+	return nil
+}
+
+// confirmPrune lists resources which are going to be deleted and asks for confirmation.
+// Returns true only if answer is y or yes.
+func confirmPrune(in io.Reader, out io.Writer, resources []*unstructured.Unstructured) (bool, error) {
+	fmt.Fprintln(out, "Following resources are not present in the sample and will be deleted:")
+	table := tablewriter.NewWriter(out)
+	table.SetHeader([]string{"KIND", "NAMESPACE", "NAME"})
+	for i := range resources {
+		table.Append([]string{resources[i].GetKind(), resources[i].GetNamespace(), resources[i].GetName()})
+	}
+	table.Render()
+
+	fmt.Fprintf(out, "Do you want to continue? [y/N]: ")
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
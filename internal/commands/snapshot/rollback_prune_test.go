@@ -0,0 +1,233 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2/textlogger"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	configv1beta1 "github.com/projectsveltos/addon-controller/api/v1beta1"
+	eventv1beta1 "github.com/projectsveltos/event-manager/api/v1beta1"
+	libsveltosv1beta1 "github.com/projectsveltos/libsveltos/api/v1beta1"
+	"github.com/projectsveltos/sveltosctl/internal/collector"
+	"github.com/projectsveltos/sveltosctl/internal/commands/snapshot"
+	"github.com/projectsveltos/sveltosctl/internal/utils"
+)
+
+var _ = Describe("Snapshot Rollback Prune", func() {
+	var sampleClusterProfile *configv1beta1.ClusterProfile
+	var newClusterProfile *configv1beta1.ClusterProfile
+	var newClassifier *libsveltosv1beta1.Classifier
+	var newProfile *configv1beta1.Profile
+	var otherNamespaceProfile *configv1beta1.Profile
+	var folder string
+	var c client.Client
+
+	BeforeEach(func() {
+		sampleClusterProfile = &configv1beta1.ClusterProfile{
+			ObjectMeta: metav1.ObjectMeta{Name: randomString()},
+		}
+		newClusterProfile = &configv1beta1.ClusterProfile{
+			ObjectMeta: metav1.ObjectMeta{Name: randomString()},
+		}
+		newClassifier = &libsveltosv1beta1.Classifier{
+			ObjectMeta: metav1.ObjectMeta{Name: randomString()},
+		}
+		newProfile = &configv1beta1.Profile{
+			ObjectMeta: metav1.ObjectMeta{Namespace: randomString(), Name: randomString()},
+		}
+		otherNamespaceProfile = &configv1beta1.Profile{
+			ObjectMeta: metav1.ObjectMeta{Namespace: randomString(), Name: randomString()},
+		}
+
+		var err error
+		folder, err = os.MkdirTemp("", randomString())
+		Expect(err).To(BeNil())
+		Expect(collector.GetClient().DumpObject(localStorage, sampleClusterProfile.DeepCopy(), folder,
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
+
+		initObjects := []client.Object{sampleClusterProfile, newClusterProfile, newClassifier,
+			newProfile, otherNamespaceProfile}
+		scheme, err := utils.GetScheme()
+		Expect(err).To(BeNil())
+		c = fake.NewClientBuilder().WithScheme(scheme).WithObjects(initObjects...).Build()
+		utils.InitalizeManagementClusterAcces(scheme, nil, nil, c)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(folder)
+	})
+
+	It("getPruneCandidates returns resources not in the sample", func() {
+		candidates, err := snapshot.GetPruneCandidates(context.TODO(), localStorage, folder,
+			&snapshot.RollbackFilters{}, textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
+		Expect(err).To(BeNil())
+
+		names := make([]string, len(candidates))
+		for i := range candidates {
+			names[i] = candidates[i].GetKind() + ":" + candidates[i].GetName()
+		}
+		Expect(names).To(ConsistOf(
+			configv1beta1.ClusterProfileKind+":"+newClusterProfile.Name,
+			libsveltosv1beta1.ClassifierKind+":"+newClassifier.Name,
+			configv1beta1.ProfileKind+":"+newProfile.Name,
+			configv1beta1.ProfileKind+":"+otherNamespaceProfile.Name,
+		))
+	})
+
+	It("getPruneCandidates respects namespace, profile and per kind filters", func() {
+		candidates, err := snapshot.GetPruneCandidates(context.TODO(), localStorage, folder,
+			&snapshot.RollbackFilters{
+				Namespace:  newProfile.Namespace,
+				Profile:    configv1beta1.ProfileKind + "/" + newProfile.Name,
+				Classifier: randomString(),
+			}, textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
+		Expect(err).To(BeNil())
+		Expect(candidates).To(HaveLen(1))
+		Expect(candidates[0].GetKind()).To(Equal(configv1beta1.ProfileKind))
+		Expect(candidates[0].GetName()).To(Equal(newProfile.Name))
+	})
+
+	It("getPruneCandidates prunes only the kinds filters name", func() {
+		logger := textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1)))
+
+		// Classifiers and Profiles are not named by the filter
+		candidates, err := snapshot.GetPruneCandidates(context.TODO(), localStorage, folder,
+			&snapshot.RollbackFilters{Profile: configv1beta1.ClusterProfileKind + "/" + newClusterProfile.Name}, logger)
+		Expect(err).To(BeNil())
+		Expect(candidates).To(HaveLen(1))
+		Expect(candidates[0].GetKind()).To(Equal(configv1beta1.ClusterProfileKind))
+		Expect(candidates[0].GetName()).To(Equal(newClusterProfile.Name))
+
+		// Clusters are never pruned
+		candidates, err = snapshot.GetPruneCandidates(context.TODO(), localStorage, folder,
+			&snapshot.RollbackFilters{Cluster: randomString()}, logger)
+		Expect(err).To(BeNil())
+		Expect(candidates).To(BeEmpty())
+
+		// Namespace alone selects namespaced kinds only
+		candidates, err = snapshot.GetPruneCandidates(context.TODO(), localStorage, folder,
+			&snapshot.RollbackFilters{Namespace: newProfile.Namespace}, logger)
+		Expect(err).To(BeNil())
+		Expect(candidates).To(HaveLen(1))
+		Expect(candidates[0].GetKind()).To(Equal(configv1beta1.ProfileKind))
+		Expect(candidates[0].GetName()).To(Equal(newProfile.Name))
+	})
+
+	It("getPruneCandidates ignores resources out of the sample scope", func() {
+		newClusterProfile.Labels = map[string]string{"team": "platform"}
+		Expect(c.Update(context.TODO(), newClusterProfile)).To(Succeed())
+
+		Expect(collector.GetClient().WriteSampleMetadata(localStorage, folder, &collector.SampleMetadata{
+			Trigger: collector.SampleTriggerSchedule,
+			Scope: &collector.SampleScope{
+				ExcludeKinds:      []string{libsveltosv1beta1.ClassifierKind},
+				NamespaceSelector: &metav1.LabelSelector{},
+				Namespaces:        []string{newProfile.Namespace},
+				ProfileSelector:   &metav1.LabelSelector{MatchLabels: map[string]string{"team": "platform"}},
+			},
+		})).To(Succeed())
+
+		candidates, err := snapshot.GetPruneCandidates(context.TODO(), localStorage, folder,
+			&snapshot.RollbackFilters{}, textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
+		Expect(err).To(BeNil())
+
+		// Classifiers were not collected, otherNamespaceProfile namespace was not selected and
+		// newProfile does not match the profile selector
+		names := make([]string, len(candidates))
+		for i := range candidates {
+			names[i] = candidates[i].GetKind() + ":" + candidates[i].GetName()
+		}
+		Expect(names).To(ConsistOf(configv1beta1.ClusterProfileKind + ":" + newClusterProfile.Name))
+	})
+
+	It("getPruneCandidates skips kinds whose CRD is not installed", func() {
+		scheme, err := utils.GetScheme()
+		Expect(err).To(BeNil())
+		funcs := interceptor.Funcs{
+			List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+				gvk := list.GetObjectKind().GroupVersionKind()
+				if gvk.Kind == eventv1beta1.EventTriggerKind+"List" {
+					return &meta.NoKindMatchError{GroupKind: gvk.GroupKind(), SearchedVersions: []string{gvk.Version}}
+				}
+				return c.List(ctx, list, opts...)
+			},
+		}
+		c = fake.NewClientBuilder().WithScheme(scheme).WithObjects(newClusterProfile).
+			WithInterceptorFuncs(funcs).Build()
+		utils.InitalizeManagementClusterAcces(scheme, nil, nil, c)
+
+		candidates, err := snapshot.GetPruneCandidates(context.TODO(), localStorage, folder,
+			&snapshot.RollbackFilters{}, textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
+		Expect(err).To(BeNil())
+		Expect(candidates).To(HaveLen(1))
+		Expect(candidates[0].GetKind()).To(Equal(configv1beta1.ClusterProfileKind))
+		Expect(candidates[0].GetName()).To(Equal(newClusterProfile.Name))
+	})
+
+	It("pruneResources deletes resources only when no plan is passed", func() {
+		candidates, err := snapshot.GetPruneCandidates(context.TODO(), localStorage, folder,
+			&snapshot.RollbackFilters{}, textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
+		Expect(err).To(BeNil())
+
+		plan := snapshot.NewRollbackPlan()
+		Expect(snapshot.PruneResources(context.TODO(), candidates, plan,
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
+		Expect(plan.Entries).To(HaveLen(len(candidates)))
+		for i := range plan.Entries {
+			Expect(plan.Entries[i].Action).To(Equal(snapshot.RollbackActionDelete))
+		}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: newClusterProfile.Name},
+			&configv1beta1.ClusterProfile{})).To(Succeed())
+
+		Expect(snapshot.PruneResources(context.TODO(), candidates, nil,
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: newClusterProfile.Name},
+			&configv1beta1.ClusterProfile{})).ToNot(Succeed())
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: newClassifier.Name},
+			&libsveltosv1beta1.Classifier{})).ToNot(Succeed())
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: sampleClusterProfile.Name},
+			&configv1beta1.ClusterProfile{})).To(Succeed())
+	})
+
+	It("confirmPrune accepts only y or yes", func() {
+		candidates, err := snapshot.GetPruneCandidates(context.TODO(), localStorage, folder,
+			&snapshot.RollbackFilters{}, textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
+		Expect(err).To(BeNil())
+
+		for answer, expected := range map[string]bool{"y\n": true, "YES\n": true, "n\n": false, "": false, "sure\n": false} {
+			var out bytes.Buffer
+			confirmed, err := snapshot.ConfirmPrune(strings.NewReader(answer), &out, candidates)
+			Expect(err).To(BeNil())
+			Expect(confirmed).To(Equal(expected))
+			Expect(out.String()).To(ContainSubstring(newClusterProfile.Name))
+		}
+	})
+})
@@ -17,6 +17,7 @@ limitations under the License.
 package snapshot_test
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"os"
@@ -27,16 +28,22 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/klog/v2/textlogger"
 	kubectlscheme "k8s.io/kubectl/pkg/scheme"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
 
 	configv1beta1 "github.com/projectsveltos/addon-controller/api/v1beta1"
+	eventv1beta1 "github.com/projectsveltos/event-manager/api/v1beta1"
 	libsveltosv1beta1 "github.com/projectsveltos/libsveltos/api/v1beta1"
+	utilsv1beta1 "github.com/projectsveltos/sveltosctl/api/v1beta1"
 	"github.com/projectsveltos/sveltosctl/internal/collector"
 	"github.com/projectsveltos/sveltosctl/internal/commands/snapshot"
 	"github.com/projectsveltos/sveltosctl/internal/utils"
@@ -175,7 +182,7 @@ var _ = Describe("Snapshot Rollback", func() {
 		updateConfigMapData(currentConfigMap)
 
 		// Rollback
-		Expect(snapshot.RollbackConfigMaps(context.TODO(), []*unstructured.Unstructured{configMap},
+		Expect(snapshot.RollbackConfigMaps(context.TODO(), []*unstructured.Unstructured{configMap}, nil,
 			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
 
 		Expect(instance.GetResource(context.TODO(),
@@ -210,7 +217,7 @@ var _ = Describe("Snapshot Rollback", func() {
 		updateSecretData(currentSecret)
 
 		// Rollback
-		Expect(snapshot.RollbackSecrets(context.TODO(), []*unstructured.Unstructured{secret},
+		Expect(snapshot.RollbackSecrets(context.TODO(), []*unstructured.Unstructured{secret}, nil,
 			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
 
 		Expect(instance.GetResource(context.TODO(),
@@ -218,6 +225,65 @@ var _ = Describe("Snapshot Rollback", func() {
 		Expect(reflect.DeepEqual(currentSecret.Data, originalData)).To(BeTrue())
 	})
 
+	It("rollbackSecrets leaves untouched Secrets not stored in full", func() {
+		name := randomString()
+		namespace := randomString()
+		secret := getSecret(namespace, name)
+
+		initObjects := []client.Object{secret}
+		scheme, err := utils.GetScheme()
+		Expect(err).To(BeNil())
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initObjects...).Build()
+
+		utils.InitalizeManagementClusterAcces(scheme, nil, nil, c)
+
+		instance := utils.GetAccessInstance()
+
+		currentSecret := &corev1.Secret{}
+		Expect(instance.GetResource(context.TODO(),
+			types.NamespacedName{Namespace: namespace, Name: name}, currentSecret)).To(Succeed())
+
+		// Sample contains the hashed Secret
+		hashedSecret := currentSecret.DeepCopy()
+		collector.HashSecret(hashedSecret)
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(hashedSecret)
+		Expect(err).To(BeNil())
+		sampleSecret := &unstructured.Unstructured{Object: content}
+
+		updateSecretData(currentSecret)
+		Expect(instance.GetResource(context.TODO(),
+			types.NamespacedName{Namespace: namespace, Name: name}, currentSecret)).To(Succeed())
+		modifiedData := currentSecret.Data
+
+		// Plan reports Secret is not restored
+		plan := snapshot.NewRollbackPlan()
+		Expect(snapshot.RollbackSecrets(context.TODO(), []*unstructured.Unstructured{sampleSecret}, plan,
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
+		Expect(plan.Entries).To(HaveLen(1))
+		Expect(plan.Entries[0].Action).To(Equal(snapshot.RollbackActionSkipped))
+		Expect(plan.Entries[0].Kind).To(Equal("Secret"))
+		Expect(plan.Entries[0].Name).To(Equal(name))
+		Expect(plan.Entries[0].Message).To(ContainSubstring(string(utilsv1beta1.SecretModeHashed)))
+
+		var warnings bytes.Buffer
+		snapshot.PrintSkippedEntries(&warnings, plan.Entries)
+		Expect(warnings.String()).To(Equal(fmt.Sprintf("Warning: Secret %s/%s not restored: %s\n",
+			namespace, name, plan.Entries[0].Message)))
+
+		Expect(snapshot.RollbackSecrets(context.TODO(), []*unstructured.Unstructured{sampleSecret}, nil,
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
+		Expect(instance.GetResource(context.TODO(),
+			types.NamespacedName{Namespace: namespace, Name: name}, currentSecret)).To(Succeed())
+		Expect(currentSecret.Data).To(Equal(modifiedData))
+
+		// Secret missing in the cluster is not recreated with hashed Data
+		Expect(c.Delete(context.TODO(), currentSecret)).To(Succeed())
+		Expect(snapshot.RollbackSecrets(context.TODO(), []*unstructured.Unstructured{sampleSecret}, nil,
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
+		Expect(instance.GetResource(context.TODO(),
+			types.NamespacedName{Namespace: namespace, Name: name}, currentSecret)).ToNot(Succeed())
+	})
+
 	It("rollbackClusterProfile rollbacks clusterProfile", func() {
 		name := randomString()
 		cp := getClusterProfile(name)
@@ -241,7 +307,7 @@ var _ = Describe("Snapshot Rollback", func() {
 		updateClusterProfileSpec(currentCP)
 
 		// Rollback
-		Expect(snapshot.RollbackClusterProfile(context.TODO(), cp,
+		Expect(snapshot.RollbackClusterProfile(context.TODO(), cp, nil,
 			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
 
 		Expect(instance.GetResource(context.TODO(),
@@ -277,7 +343,7 @@ var _ = Describe("Snapshot Rollback", func() {
 
 		// Rollback
 		Expect(snapshot.RollbackClusters(context.TODO(), []*unstructured.Unstructured{cluster}, "",
-			libsveltosv1beta1.ClusterTypeCapi,
+			libsveltosv1beta1.ClusterTypeCapi, nil,
 			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
 
 		Expect(instance.GetResource(context.TODO(),
@@ -345,7 +411,7 @@ var _ = Describe("Snapshot Rollback", func() {
 		updateClusterLabels(currentCluster)
 		updateClusterProfileSpec(currentClusterProfile)
 
-		Expect(snapshot.RollbackConfigurationToSnapshot(context.TODO(), folder, "", "", "", "", "",
+		Expect(snapshot.RollbackConfigurationToSnapshot(context.TODO(), localStorage, folder, &snapshot.RollbackFilters{}, nil,
 			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
 
 		Expect(instance.GetResource(context.TODO(),
@@ -365,6 +431,245 @@ var _ = Describe("Snapshot Rollback", func() {
 		Expect(reflect.DeepEqual(currentClusterProfile.Spec, originalClusterProfileSpec)).To(BeTrue())
 	})
 
+	It("rollbackConfigurationToSnapshot with a plan does not modify anything and reports the plan", func() {
+		name := randomString()
+		namespace := randomString()
+
+		configMap := getConfigMap(namespace, name)
+		secret := getSecret(namespace, name)
+		cluster := getCluster(namespace, name)
+		clusterProfile := getClusterProfile(name)
+		// Only present in the sample
+		deletedConfigMap := getConfigMap(namespace, randomString())
+
+		// ClusterProfile matching the labels cluster has in the sample
+		matchingClusterProfile := &configv1beta1.ClusterProfile{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: randomString(),
+			},
+			Spec: configv1beta1.Spec{
+				ClusterSelector: libsveltosv1beta1.Selector{
+					LabelSelector: metav1.LabelSelector{
+						MatchLabels: map[string]string{"env": "production"},
+					},
+				},
+			},
+		}
+
+		initObjects := []client.Object{configMap, secret, cluster, clusterProfile, matchingClusterProfile}
+		scheme, err := utils.GetScheme()
+		Expect(err).To(BeNil())
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initObjects...).Build()
+
+		snapshotName := randomString()
+		snapshotStorage := randomString()
+		folder := createDirectoryWithObjects(snapshotName, snapshotStorage,
+			[]*unstructured.Unstructured{configMap, secret, cluster, clusterProfile, deletedConfigMap})
+
+		utils.InitalizeManagementClusterAcces(scheme, nil, nil, c)
+		instance := utils.GetAccessInstance()
+
+		currentConfigMap := &corev1.ConfigMap{}
+		Expect(instance.GetResource(context.TODO(),
+			types.NamespacedName{Namespace: namespace, Name: name}, currentConfigMap)).To(Succeed())
+		updateConfigMapData(currentConfigMap)
+
+		currentSecret := &corev1.Secret{}
+		Expect(instance.GetResource(context.TODO(),
+			types.NamespacedName{Namespace: namespace, Name: name}, currentSecret)).To(Succeed())
+		updateSecretData(currentSecret)
+
+		currentCluster := &clusterv1.Cluster{}
+		Expect(instance.GetResource(context.TODO(),
+			types.NamespacedName{Namespace: namespace, Name: name}, currentCluster)).To(Succeed())
+		updateClusterLabels(currentCluster)
+
+		plan := snapshot.NewRollbackPlan()
+		Expect(snapshot.RollbackConfigurationToSnapshot(context.TODO(), localStorage, folder, &snapshot.RollbackFilters{}, plan,
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
+
+		By("verifying nothing was modified")
+		liveConfigMap := &corev1.ConfigMap{}
+		Expect(instance.GetResource(context.TODO(),
+			types.NamespacedName{Namespace: namespace, Name: name}, liveConfigMap)).To(Succeed())
+		Expect(reflect.DeepEqual(liveConfigMap.Data, currentConfigMap.Data)).To(BeTrue())
+
+		liveCluster := &clusterv1.Cluster{}
+		Expect(instance.GetResource(context.TODO(),
+			types.NamespacedName{Namespace: namespace, Name: name}, liveCluster)).To(Succeed())
+		Expect(reflect.DeepEqual(liveCluster.Labels, currentCluster.Labels)).To(BeTrue())
+
+		Expect(instance.GetResource(context.TODO(),
+			types.NamespacedName{Namespace: namespace, Name: deletedConfigMap.GetName()},
+			&corev1.ConfigMap{})).ToNot(Succeed())
+
+		By("verifying the plan")
+		actions := make(map[string]snapshot.RollbackPlanEntry)
+		for i := range plan.Entries {
+			entry := plan.Entries[i]
+			actions[fmt.Sprintf("%s:%s", entry.Kind, entry.Name)] = entry
+		}
+		Expect(actions).To(HaveLen(5))
+		Expect(actions["ConfigMap:"+deletedConfigMap.GetName()].Action).To(Equal(snapshot.RollbackActionCreate))
+		Expect(actions["ClusterProfile:"+name].Action).To(Equal(snapshot.RollbackActionUnchanged))
+
+		configMapEntry := actions["ConfigMap:"+name]
+		Expect(configMapEntry.Action).To(Equal(snapshot.RollbackActionUpdate))
+		Expect(configMapEntry.Changes).To(ContainElement(
+			HaveField("Path", `data["game.properties"]`)))
+
+		secretEntry := actions["Secret:"+name]
+		Expect(secretEntry.Action).To(Equal(snapshot.RollbackActionUpdate))
+		Expect(secretEntry.Changes).ToNot(BeEmpty())
+		for i := range secretEntry.Changes {
+			Expect(secretEntry.Changes[i].String()).To(MatchRegexp(`^data\.\w+: (<none>|<redacted>) -> (<none>|<redacted>)$`))
+		}
+
+		clusterEntry := actions["Cluster:"+name]
+		Expect(clusterEntry.Action).To(Equal(snapshot.RollbackActionUpdate))
+		Expect(clusterEntry.Changes).To(ContainElement(snapshot.FieldChange{
+			Path: "metadata.labels.env", To: "production"}))
+
+		By("verifying cluster gains a match with ClusterProfile")
+		matchChanges, err := snapshot.GetProfileMatchChanges(context.TODO(), plan,
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
+		Expect(err).To(BeNil())
+		Expect(matchChanges).To(ConsistOf(snapshot.ProfileMatchChange{
+			Profile: "ClusterProfile/" + matchingClusterProfile.Name,
+			Cluster: fmt.Sprintf("Cluster:%s/%s", namespace, name),
+			Change:  snapshot.ProfileMatchGained,
+		}))
+	})
+
+	It("diffFields returns field level changes", func() {
+		from := map[string]interface{}{
+			"spec": map[string]interface{}{
+				"syncMode": "Continuous",
+				"helmCharts": []interface{}{
+					map[string]interface{}{"chartVersion": "v1.0.0"},
+				},
+			},
+		}
+		to := map[string]interface{}{
+			"spec": map[string]interface{}{
+				"helmCharts": []interface{}{
+					map[string]interface{}{"chartVersion": "v1.1.0"},
+					map[string]interface{}{"chartVersion": "v2.0.0"},
+				},
+			},
+		}
+
+		changes := snapshot.DiffFields("", from, to)
+		Expect(changes).To(Equal([]snapshot.FieldChange{
+			{Path: "spec.helmCharts[0].chartVersion", From: "v1.0.0", To: "v1.1.0"},
+			{Path: "spec.helmCharts[1]", To: map[string]interface{}{"chartVersion": "v2.0.0"}},
+			{Path: "spec.syncMode", From: "Continuous"},
+		}))
+		Expect(snapshot.DiffFields("", from, from)).To(BeEmpty())
+	})
+
+	It("rollbackConfigurationToSnapshot rollbacks EventSources, EventTriggers, HealthChecks, ClusterSets and Sets", func() {
+		eventSource := &libsveltosv1beta1.EventSource{
+			ObjectMeta: metav1.ObjectMeta{Name: randomString()},
+			Spec: libsveltosv1beta1.EventSourceSpec{
+				ResourceSelectors: []libsveltosv1beta1.ResourceSelector{
+					{Group: randomString(), Version: "v1", Kind: randomString()},
+				},
+			},
+		}
+		eventTrigger := &eventv1beta1.EventTrigger{
+			ObjectMeta: metav1.ObjectMeta{Name: randomString()},
+			Spec: eventv1beta1.EventTriggerSpec{
+				EventSourceName: eventSource.Name,
+			},
+		}
+		healthCheck := &libsveltosv1beta1.HealthCheck{
+			ObjectMeta: metav1.ObjectMeta{Name: randomString()},
+			Spec: libsveltosv1beta1.HealthCheckSpec{
+				ResourceSelectors: []libsveltosv1beta1.ResourceSelector{
+					{Group: randomString(), Version: "v1", Kind: randomString()},
+				},
+			},
+		}
+		clusterHealthCheck := &libsveltosv1beta1.ClusterHealthCheck{
+			ObjectMeta: metav1.ObjectMeta{Name: randomString()},
+			Spec: libsveltosv1beta1.ClusterHealthCheckSpec{
+				ClusterSelector: libsveltosv1beta1.Selector{
+					LabelSelector: metav1.LabelSelector{MatchLabels: map[string]string{"env": "production"}},
+				},
+			},
+		}
+		clusterSet := &libsveltosv1beta1.ClusterSet{
+			ObjectMeta: metav1.ObjectMeta{Name: randomString()},
+			Spec:       libsveltosv1beta1.Spec{MaxReplicas: 2},
+		}
+		set := &libsveltosv1beta1.Set{
+			ObjectMeta: metav1.ObjectMeta{Namespace: randomString(), Name: randomString()},
+			Spec:       libsveltosv1beta1.Spec{MaxReplicas: 3},
+		}
+
+		folder, err := os.MkdirTemp("", randomString())
+		Expect(err).To(BeNil())
+		collectorClient := collector.GetClient()
+		for _, o := range []client.Object{eventSource, eventTrigger, healthCheck, clusterHealthCheck, clusterSet, set} {
+			Expect(collectorClient.DumpObject(localStorage, o.DeepCopyObject().(client.Object), folder,
+				textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
+		}
+
+		// Modify everything but EventTrigger which does not exist anymore
+		currentEventSource := eventSource.DeepCopy()
+		currentEventSource.Spec.ResourceSelectors[0].Kind = randomString()
+		currentHealthCheck := healthCheck.DeepCopy()
+		currentHealthCheck.Spec.ResourceSelectors[0].Group = randomString()
+		currentClusterHealthCheck := clusterHealthCheck.DeepCopy()
+		currentClusterHealthCheck.Spec.ClusterSelector.MatchLabels = map[string]string{"env": "qa"}
+		currentClusterSet := clusterSet.DeepCopy()
+		currentClusterSet.Spec.MaxReplicas = 5
+		currentSet := set.DeepCopy()
+		currentSet.Spec.MaxReplicas = 5
+
+		initObjects := []client.Object{currentEventSource, currentHealthCheck, currentClusterHealthCheck,
+			currentClusterSet, currentSet}
+		scheme, err := utils.GetScheme()
+		Expect(err).To(BeNil())
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initObjects...).Build()
+		utils.InitalizeManagementClusterAcces(scheme, nil, nil, c)
+
+		By("rolling back with a ClusterSet filter not matching the collected ClusterSet")
+		Expect(snapshot.RollbackConfigurationToSnapshot(context.TODO(), localStorage, folder,
+			&snapshot.RollbackFilters{ClusterSet: randomString()}, nil,
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
+
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: clusterSet.Name}, currentClusterSet)).To(Succeed())
+		Expect(currentClusterSet.Spec.MaxReplicas).To(Equal(5))
+
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: eventSource.Name}, currentEventSource)).To(Succeed())
+		Expect(reflect.DeepEqual(currentEventSource.Spec, eventSource.Spec)).To(BeTrue())
+
+		currentEventTrigger := &eventv1beta1.EventTrigger{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: eventTrigger.Name}, currentEventTrigger)).To(Succeed())
+		Expect(reflect.DeepEqual(currentEventTrigger.Spec, eventTrigger.Spec)).To(BeTrue())
+
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: healthCheck.Name}, currentHealthCheck)).To(Succeed())
+		Expect(reflect.DeepEqual(currentHealthCheck.Spec, healthCheck.Spec)).To(BeTrue())
+
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: clusterHealthCheck.Name},
+			currentClusterHealthCheck)).To(Succeed())
+		Expect(reflect.DeepEqual(currentClusterHealthCheck.Spec, clusterHealthCheck.Spec)).To(BeTrue())
+
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: set.Namespace, Name: set.Name},
+			currentSet)).To(Succeed())
+		Expect(currentSet.Spec.MaxReplicas).To(Equal(3))
+
+		By("rolling back with no filter")
+		Expect(snapshot.RollbackConfigurationToSnapshot(context.TODO(), localStorage, folder,
+			&snapshot.RollbackFilters{}, nil,
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
+
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: clusterSet.Name}, currentClusterSet)).To(Succeed())
+		Expect(currentClusterSet.Spec.MaxReplicas).To(Equal(2))
+	})
+
 	It("getAndRollbackConfigMaps recreates a ConfigMap not existing anymore", func() {
 		folder, err := os.MkdirTemp("", randomString())
 		Expect(err).To(BeNil())
@@ -376,7 +681,7 @@ var _ = Describe("Snapshot Rollback", func() {
 
 		configMap, err := GetUnstructured([]byte(fmt.Sprintf(configMapWithPolicy, namespace, name)))
 		Expect(err).To(BeNil())
-		Expect(collectorClient.DumpObject(configMap, folder,
+		Expect(collectorClient.DumpObject(localStorage, configMap, folder,
 			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
 
 		scheme, err := utils.GetScheme()
@@ -385,7 +690,7 @@ var _ = Describe("Snapshot Rollback", func() {
 		utils.InitalizeManagementClusterAcces(scheme, nil, nil, c)
 
 		// now read it back it should succeed
-		Expect(snapshot.GetAndRollbackConfigMaps(context.TODO(), folder, "",
+		Expect(snapshot.GetAndRollbackConfigMaps(context.TODO(), localStorage, folder, "", nil,
 			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
 
 		currentConfigMap := &corev1.ConfigMap{}
@@ -393,6 +698,114 @@ var _ = Describe("Snapshot Rollback", func() {
 			types.NamespacedName{Namespace: namespace, Name: name}, currentConfigMap)).To(Succeed())
 	})
 
+	It("getAndRollbackReferencedResources restores referenced resources of any kind", func() {
+		folder, err := os.MkdirTemp("", randomString())
+		Expect(err).To(BeNil())
+
+		namespace := randomString()
+		existing := getGitRepository(namespace, randomString(), "https://github.com/projectsveltos/addon-controller")
+		deleted := getGitRepository(namespace, randomString(), "https://github.com/projectsveltos/event-manager")
+		otherNamespace := getGitRepository(randomString(), randomString(), "https://github.com/projectsveltos/libsveltos")
+
+		collectorClient := collector.GetClient()
+		for _, gitRepository := range []*unstructured.Unstructured{existing, deleted, otherNamespace} {
+			Expect(collectorClient.DumpObject(localStorage, gitRepository.DeepCopy(), folder,
+				textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
+		}
+
+		// ConfigMaps are rolled back by getAndRollbackConfigMaps
+		configMap, err := GetUnstructured([]byte(fmt.Sprintf(configMapWithPolicy, namespace, randomString())))
+		Expect(err).To(BeNil())
+		Expect(collectorClient.DumpObject(localStorage, configMap, folder,
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
+
+		current := existing.DeepCopy()
+		Expect(unstructured.SetNestedField(current.Object, randomString(), "spec", "url")).To(Succeed())
+
+		scheme, err := utils.GetScheme()
+		Expect(err).To(BeNil())
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(current).Build()
+		utils.InitalizeManagementClusterAcces(scheme, nil, nil, c)
+
+		Expect(snapshot.GetAndRollbackReferenced(context.TODO(), localStorage, folder, namespace, nil,
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
+
+		for _, gitRepository := range []*unstructured.Unstructured{existing, deleted} {
+			u := &unstructured.Unstructured{}
+			u.SetGroupVersionKind(gitRepository.GroupVersionKind())
+			Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: gitRepository.GetNamespace(),
+				Name: gitRepository.GetName()}, u)).To(Succeed())
+			Expect(u.Object["spec"]).To(Equal(gitRepository.Object["spec"]))
+		}
+
+		// Resources outside of the passed namespace are not rolled back
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(otherNamespace.GroupVersionKind())
+		err = c.Get(context.TODO(), types.NamespacedName{Namespace: otherNamespace.GetNamespace(),
+			Name: otherNamespace.GetName()}, u)
+		Expect(err).ToNot(BeNil())
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+		currentConfigMap := &corev1.ConfigMap{}
+		err = c.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: configMap.GetName()},
+			currentConfigMap)
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("getAndRollbackReferencedResources skips referenced resources it is not allowed to restore", func() {
+		folder, err := os.MkdirTemp("", randomString())
+		Expect(err).To(BeNil())
+
+		namespace := randomString()
+		forbidden := getGitRepository(namespace, randomString(), "https://github.com/projectsveltos/addon-controller")
+		allowed := getGitRepository(namespace, randomString(), "https://github.com/projectsveltos/event-manager")
+
+		collectorClient := collector.GetClient()
+		for _, gitRepository := range []*unstructured.Unstructured{forbidden, allowed} {
+			Expect(collectorClient.DumpObject(localStorage, gitRepository.DeepCopy(), folder,
+				textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
+		}
+
+		scheme, err := utils.GetScheme()
+		Expect(err).To(BeNil())
+		funcs := interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				if obj.GetName() == forbidden.GetName() {
+					return apierrors.NewForbidden(forbidden.GroupVersionKind().GroupVersion().WithResource("gitrepositories").GroupResource(),
+						forbidden.GetName(), fmt.Errorf("RBAC: access denied"))
+				}
+				return c.Create(ctx, obj, opts...)
+			},
+		}
+		c := fake.NewClientBuilder().WithScheme(scheme).WithInterceptorFuncs(funcs).Build()
+		utils.InitalizeManagementClusterAcces(scheme, nil, nil, c)
+
+		plan := snapshot.NewAppliedRollbackPlan()
+		Expect(snapshot.GetAndRollbackReferenced(context.TODO(), localStorage, folder, namespace, plan,
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
+
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(allowed.GroupVersionKind())
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: allowed.GetName()}, u)).To(Succeed())
+
+		Expect(plan.Entries).To(HaveLen(2))
+		for i := range plan.Entries {
+			if plan.Entries[i].Name == forbidden.GetName() {
+				Expect(plan.Entries[i].Action).To(Equal(snapshot.RollbackActionSkipped))
+				Expect(plan.Entries[i].Message).To(ContainSubstring("not allowed to restore it"))
+			} else {
+				Expect(plan.Entries[i].Action).To(Equal(snapshot.RollbackActionCreate))
+			}
+		}
+
+		// Without a plan, rollback continues as well
+		c = fake.NewClientBuilder().WithScheme(scheme).WithInterceptorFuncs(funcs).Build()
+		utils.InitalizeManagementClusterAcces(scheme, nil, nil, c)
+		Expect(snapshot.GetAndRollbackReferenced(context.TODO(), localStorage, folder, namespace, nil,
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: allowed.GetName()}, u)).To(Succeed())
+	})
+
 	It("getAndRollbackClusterProfiles recreats a ClusterProfile not existing anymore", func() {
 		folder, err := os.MkdirTemp("", randomString())
 		Expect(err).To(BeNil())
@@ -403,7 +816,7 @@ var _ = Describe("Snapshot Rollback", func() {
 
 		clusterProfile, err := GetUnstructured([]byte(fmt.Sprintf(clusterProfileTemplate, name)))
 		Expect(err).To(BeNil())
-		Expect(collectorClient.DumpObject(clusterProfile, folder,
+		Expect(collectorClient.DumpObject(localStorage, clusterProfile, folder,
 			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
 
 		scheme, err := utils.GetScheme()
@@ -412,7 +825,7 @@ var _ = Describe("Snapshot Rollback", func() {
 		utils.InitalizeManagementClusterAcces(scheme, nil, nil, c)
 
 		// now read it back it should succeed
-		Expect(snapshot.GetAndRollbackProfiles(context.TODO(), folder, "", "",
+		Expect(snapshot.GetAndRollbackProfiles(context.TODO(), localStorage, folder, "", "", nil,
 			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
 
 		currentClusterProfile := &configv1beta1.ClusterProfile{}
@@ -513,7 +926,7 @@ func createDirectoryWithObjects(snapshotName, snapshotStorage string, objects []
 		By(fmt.Sprintf("Adding %s %s/%s to directory %s",
 			o.GetObjectKind().GroupVersionKind().GroupKind().Kind, o.GetNamespace(), o.GetName(), snapshotDir))
 
-		Expect(collectorClient.DumpObject(o, snapshotDir,
+		Expect(collectorClient.DumpObject(localStorage, o, snapshotDir,
 			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
 	}
 
@@ -533,3 +946,13 @@ func GetUnstructured(object []byte) (*unstructured.Unstructured, error) {
 
 	return request, nil
 }
+
+func getGitRepository(namespace, name, url string) *unstructured.Unstructured {
+	gitRepository := &unstructured.Unstructured{}
+	gitRepository.SetAPIVersion("source.toolkit.fluxcd.io/v1")
+	gitRepository.SetKind("GitRepository")
+	gitRepository.SetNamespace(namespace)
+	gitRepository.SetName(name)
+	Expect(unstructured.SetNestedField(gitRepository.Object, url, "spec", "url")).To(Succeed())
+	return gitRepository
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/docopt/docopt-go"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2/textlogger"
+
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+	utilsv1beta1 "github.com/projectsveltos/sveltosctl/api/v1beta1"
+	"github.com/projectsveltos/sveltosctl/internal/collector"
+	"github.com/projectsveltos/sveltosctl/internal/utils"
+)
+
+// rotateKey re-encrypts, with the current key, all Secrets stored in samples of
+// Snapshot snapshotName. Returns the number of re-encrypted Secrets.
+func rotateKey(ctx context.Context, snapshotName string, logger logr.Logger) (int, error) {
+	logger.V(logs.LogDebug).Info(fmt.Sprintf("Getting Snapshot %s", snapshotName))
+
+	snapshotInstance := &utilsv1beta1.Snapshot{}
+	err := utils.GetAccessInstance().GetResource(ctx, types.NamespacedName{Name: snapshotName}, snapshotInstance)
+	if err != nil {
+		return 0, err
+	}
+
+	if snapshotInstance.Spec.Encryption == nil {
+		return 0, fmt.Errorf("encryption is not configured for snapshot %s", snapshotName)
+	}
+
+	storage, err := getSnapshotStorage(ctx, snapshotInstance)
+	if err != nil {
+		return 0, err
+	}
+
+	return collector.GetClient().ReencryptCollections(storage, snapshotName, collector.Snapshot, logger)
+}
+
+// RotateKey re-encrypts Secrets in all samples with the current encryption key
+func RotateKey(ctx context.Context, args []string, logger logr.Logger) error {
+	doc := `Usage:
+	sveltosctl snapshot rotate-key [options] --snapshot=<name> [--verbose]
+
+     --snapshot=<name>      Name of the Snapshot instance
+
+Options:
+  -h --help                  Show this screen.
+     --verbose               Verbose mode. Print each step.
+
+Description:
+  The snapshot rotate-key re-encrypts, with the current key, every Secret stored in any sample
+  which is either encrypted with a previous key or was stored before encryption was enabled.
+  Previous keys can be removed from the encryption Secret once this command succeeds.
+`
+
+	parsedArgs, err := docopt.ParseArgs(doc, nil, "1.0")
+	if err != nil {
+		logger.V(logs.LogDebug).Error(err, "failed to parse args")
+		return fmt.Errorf(
+			"invalid option: 'sveltosctl %s'. Use flag '--help' to read about a specific subcommand. Error: %w",
+			strings.Join(args, " "),
+			err,
+		)
+	}
+
+	_ = flag.Lookup("v").Value.Set(fmt.Sprint(logs.LogInfo))
+	verbose := parsedArgs["--verbose"].(bool)
+	if verbose {
+		err = flag.Lookup("v").Value.Set(fmt.Sprint(logs.LogDebug))
+		if err != nil {
+			return err
+		}
+	}
+
+	logger = textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1)))
+
+	snapshostName := parsedArgs["--snapshot"].(string)
+
+	count, err := rotateKey(ctx, snapshostName, logger)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stdout, "Re-encrypted %d Secrets in snapshot %s\n", count, snapshostName)
+	return nil
+}
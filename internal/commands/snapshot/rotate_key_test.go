@@ -0,0 +1,130 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot_test
+
+import (
+	"context"
+	"crypto/rand"
+	"os"
+	"path"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2/textlogger"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	utilsv1beta1 "github.com/projectsveltos/sveltosctl/api/v1beta1"
+	"github.com/projectsveltos/sveltosctl/internal/collector"
+	"github.com/projectsveltos/sveltosctl/internal/commands/snapshot"
+	"github.com/projectsveltos/sveltosctl/internal/utils"
+)
+
+var _ = Describe("Snapshot RotateKey", func() {
+	var snapshotInstance *utilsv1beta1.Snapshot
+	var keySecret *corev1.Secret
+	var secret *corev1.Secret
+	var sample string
+
+	BeforeEach(func() {
+		storageDir, err := os.MkdirTemp("", randomString())
+		Expect(err).To(BeNil())
+
+		const keySize = 32
+		key := make([]byte, keySize)
+		_, err = rand.Read(key)
+		Expect(err).To(BeNil())
+
+		keySecret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: randomString(), Name: randomString()},
+			Data:       map[string][]byte{utilsv1beta1.EncryptionKeyKey: key},
+		}
+
+		snapshotInstance = &utilsv1beta1.Snapshot{
+			ObjectMeta: metav1.ObjectMeta{Name: randomString()},
+			Spec: utilsv1beta1.SnapshotSpec{
+				Storage: storageDir,
+				Encryption: &utilsv1beta1.SnapshotEncryption{
+					KeySecretRef: corev1.SecretReference{Namespace: keySecret.Namespace, Name: keySecret.Name},
+				},
+			},
+		}
+
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: randomString(), Name: randomString()},
+			Data:       map[string][]byte{"password": []byte(randomString())},
+		}
+
+		// Sample stored before encryption was enabled
+		sample = time.Now().Format(timeFormat)
+		sampleFolder := path.Join("snapshot", snapshotInstance.Name, sample)
+		Expect(collector.GetClient().DumpObject(collector.NewFilesystemStorage(storageDir), secret.DeepCopy(),
+			sampleFolder, textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
+		// Manifest digests are computed on decrypted content, so rotating the key keeps the sample verifiable
+		Expect(collector.GetClient().WriteSampleManifest(collector.NewFilesystemStorage(storageDir),
+			sampleFolder, nil)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(snapshotInstance.Spec.Storage)
+	})
+
+	It("rotateKey encrypts Secrets which rollback then decrypts", func() {
+		initObjects := []client.Object{snapshotInstance, keySecret}
+		scheme, err := utils.GetScheme()
+		Expect(err).To(BeNil())
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initObjects...).Build()
+		utils.InitalizeManagementClusterAcces(scheme, nil, nil, c)
+
+		count, err := snapshot.RotateKeyForSnapshot(context.TODO(), snapshotInstance.Name,
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
+		Expect(err).To(BeNil())
+		Expect(count).To(Equal(1))
+
+		raw, err := os.ReadFile(path.Join(snapshotInstance.Spec.Storage, "snapshot", snapshotInstance.Name, sample,
+			secret.Namespace, "Secret", secret.Name+".yaml"))
+		Expect(err).To(BeNil())
+		Expect(string(raw)).ToNot(ContainSubstring(secret.Name))
+
+		_, err = snapshot.ExecuteRollback(context.TODO(), snapshotInstance.Name, sample, &snapshot.RollbackFilters{},
+			&snapshot.RollbackOptions{}, textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
+		Expect(err).To(BeNil())
+
+		currentSecret := &corev1.Secret{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: secret.Namespace, Name: secret.Name},
+			currentSecret)).To(Succeed())
+		Expect(currentSecret.Data).To(Equal(secret.Data))
+	})
+
+	It("rotateKey fails when encryption is not configured", func() {
+		snapshotInstance.Spec.Encryption = nil
+		initObjects := []client.Object{snapshotInstance}
+		scheme, err := utils.GetScheme()
+		Expect(err).To(BeNil())
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initObjects...).Build()
+		utils.InitalizeManagementClusterAcces(scheme, nil, nil, c)
+
+		_, err = snapshot.RotateKeyForSnapshot(context.TODO(), snapshotInstance.Name,
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
+		Expect(err).ToNot(BeNil())
+	})
+})
@@ -27,15 +27,22 @@ import (
 	. "github.com/onsi/gomega"
 
 	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/klog/v2/textlogger"
 	"sigs.k8s.io/cluster-api/util"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	utilsv1beta1 "github.com/projectsveltos/sveltosctl/api/v1beta1"
 	"github.com/projectsveltos/sveltosctl/internal/collector"
 	"github.com/projectsveltos/sveltosctl/internal/utils"
 )
 
+var (
+	// localStorage is a filesystem Storage where keys are paths on the local filesystem
+	localStorage = collector.NewFilesystemStorage("")
+)
+
 const (
 	timeFormat = "2006-01-02:15:04:05"
 )
@@ -79,12 +86,41 @@ func createSnapshotDirectoryWithObjects(snapshotName, snapshotStorage string, ob
 	for i := range objects {
 		By(fmt.Sprintf("Dumping object %s %s/%s in folder %s", objects[i].GetObjectKind().GroupVersionKind().Kind,
 			objects[i].GetNamespace(), objects[i].GetName(), snapshotDir))
-		Expect(collectorClient.DumpObject(objects[i], snapshotDir,
+		Expect(collectorClient.DumpObject(localStorage, objects[i], snapshotDir,
 			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
 	}
 	return timeFolder
 }
 
+// createSnapshotWithSamples returns a Snapshot, whose storage is a new temporary directory and whose
+// retention policy keeps one daily sample, and creates an empty sample for each age (time before now).
+// Returned sample names are in the same order as ages.
+func createSnapshotWithSamples(ages ...time.Duration) (*utilsv1beta1.Snapshot, []string) {
+	storageDir, err := os.MkdirTemp("", randomString())
+	Expect(err).To(BeNil())
+
+	daily := int32(1)
+	snapshotInstance := &utilsv1beta1.Snapshot{
+		ObjectMeta: metav1.ObjectMeta{Name: randomString()},
+		Spec: utilsv1beta1.SnapshotSpec{
+			Storage: storageDir,
+			Retention: &utilsv1beta1.RetentionPolicy{
+				Daily: &daily,
+			},
+		},
+	}
+
+	now := time.Now()
+	samples := make([]string, len(ages))
+	for i := range ages {
+		samples[i] = now.Add(-ages[i]).Format(timeFormat)
+		Expect(os.MkdirAll(filepath.Join(storageDir, "snapshot", snapshotInstance.Name, samples[i]),
+			os.ModePerm)).To(Succeed())
+	}
+
+	return snapshotInstance, samples
+}
+
 func randomString() string {
 	const length = 10
 	return util.RandomString(length)
@@ -0,0 +1,109 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/docopt/docopt-go"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2/textlogger"
+
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+	utilsv1beta1 "github.com/projectsveltos/sveltosctl/api/v1beta1"
+	"github.com/projectsveltos/sveltosctl/internal/utils"
+)
+
+// requestSample sets the trigger annotation on Snapshot snapshotName so the snapshot
+// reconciler collects a new sample immediately. Returns the annotation value.
+func requestSample(ctx context.Context, snapshotName string, logger logr.Logger) (string, error) {
+	logger.V(logs.LogDebug).Info(fmt.Sprintf("Getting Snapshot %s", snapshotName))
+
+	instance := utils.GetAccessInstance()
+	snapshotInstance := &utilsv1beta1.Snapshot{}
+	err := instance.GetResource(ctx, types.NamespacedName{Name: snapshotName}, snapshotInstance)
+	if err != nil {
+		return "", err
+	}
+
+	trigger := time.Now().UTC().Format(time.RFC3339Nano)
+	annotations := snapshotInstance.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[utilsv1beta1.SnapshotTriggerAnnotation] = trigger
+	snapshotInstance.SetAnnotations(annotations)
+
+	logger.V(logs.LogDebug).Info(fmt.Sprintf("Setting trigger annotation to %s", trigger))
+	return trigger, instance.UpdateResource(ctx, snapshotInstance)
+}
+
+// Take requests a new sample to be collected immediately
+func Take(ctx context.Context, args []string, logger logr.Logger) error {
+	doc := `Usage:
+	sveltosctl snapshot take [options] --snapshot=<name> [--verbose]
+
+     --snapshot=<name>      Name of the Snapshot instance
+
+Options:
+  -h --help                  Show this screen.
+     --verbose               Verbose mode. Print each step.
+
+Description:
+  The snapshot take requests a new sample to be collected immediately, outside the Snapshot schedule.
+  Sample is collected by the snapshot reconciler, which reports its name in the Snapshot status
+  (lastTriggeredSample).
+`
+
+	parsedArgs, err := docopt.ParseArgs(doc, nil, "1.0")
+	if err != nil {
+		logger.V(logs.LogDebug).Error(err, "failed to parse args")
+		return fmt.Errorf(
+			"invalid option: 'sveltosctl %s'. Use flag '--help' to read about a specific subcommand. Error: %w",
+			strings.Join(args, " "),
+			err,
+		)
+	}
+
+	_ = flag.Lookup("v").Value.Set(fmt.Sprint(logs.LogInfo))
+	verbose := parsedArgs["--verbose"].(bool)
+	if verbose {
+		err = flag.Lookup("v").Value.Set(fmt.Sprint(logs.LogDebug))
+		if err != nil {
+			return err
+		}
+	}
+
+	logger = textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1)))
+
+	snapshostName := parsedArgs["--snapshot"].(string)
+
+	trigger, err := requestSample(ctx, snapshostName, logger)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stdout, "Sample requested (trigger %s). Once collected, its name is reported in Snapshot %s status.\n",
+		trigger, snapshostName)
+	return nil
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2/textlogger"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	utilsv1beta1 "github.com/projectsveltos/sveltosctl/api/v1beta1"
+	"github.com/projectsveltos/sveltosctl/internal/commands/snapshot"
+	"github.com/projectsveltos/sveltosctl/internal/utils"
+)
+
+var _ = Describe("Snapshot Take", func() {
+	It("requestSample sets a new trigger annotation every time", func() {
+		snapshotInstance := &utilsv1beta1.Snapshot{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        randomString(),
+				Annotations: map[string]string{randomString(): randomString()},
+			},
+			Spec: utilsv1beta1.SnapshotSpec{
+				Storage: randomString(),
+			},
+		}
+
+		initObjects := []client.Object{snapshotInstance}
+		scheme, err := utils.GetScheme()
+		Expect(err).To(BeNil())
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initObjects...).Build()
+		utils.InitalizeManagementClusterAcces(scheme, nil, nil, c)
+
+		trigger, err := snapshot.RequestSample(context.TODO(), snapshotInstance.Name,
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
+		Expect(err).To(BeNil())
+		Expect(trigger).ToNot(BeEmpty())
+
+		currentSnapshot := &utilsv1beta1.Snapshot{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: snapshotInstance.Name}, currentSnapshot)).To(Succeed())
+		Expect(currentSnapshot.Annotations).To(HaveKeyWithValue(utilsv1beta1.SnapshotTriggerAnnotation, trigger))
+		Expect(currentSnapshot.Annotations).To(HaveLen(2))
+
+		newTrigger, err := snapshot.RequestSample(context.TODO(), snapshotInstance.Name,
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
+		Expect(err).To(BeNil())
+		Expect(newTrigger).ToNot(Equal(trigger))
+	})
+})
@@ -0,0 +1,131 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/docopt/docopt-go"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2/textlogger"
+
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+	utilsv1beta1 "github.com/projectsveltos/sveltosctl/api/v1beta1"
+	"github.com/projectsveltos/sveltosctl/internal/collector"
+	"github.com/projectsveltos/sveltosctl/internal/utils"
+)
+
+// undoRollback restores the most recent pre-rollback sample of Snapshot snapshotName
+func undoRollback(ctx context.Context, snapshotName string, options *RollbackOptions, logger logr.Logger) error {
+	logger.V(logs.LogDebug).Info(fmt.Sprintf("Getting Snapshot %s", snapshotName))
+
+	snapshotInstance := &utilsv1beta1.Snapshot{}
+	err := utils.GetAccessInstance().GetResource(ctx, types.NamespacedName{Name: snapshotName}, snapshotInstance)
+	if err != nil {
+		return err
+	}
+
+	storage, err := getSnapshotStorage(ctx, snapshotInstance)
+	if err != nil {
+		return err
+	}
+
+	sample, err := getLatestPreRollbackSample(storage, snapshotName, logger)
+	if err != nil {
+		return err
+	}
+
+	logger.V(logs.LogDebug).Info(fmt.Sprintf("Restoring pre-rollback sample %s", sample))
+
+	// Objects created by the rollback being undone are not in the pre-rollback sample.
+	// So always prune.
+	options.Prune = true
+	return rollbackConfiguration(ctx, snapshotName, sample, &RollbackFilters{}, options, logger)
+}
+
+// getLatestPreRollbackSample returns the name of the most recent sample of Snapshot
+// snapshotName taken right before a rollback
+func getLatestPreRollbackSample(storage collector.Storage, snapshotName string, logger logr.Logger) (string, error) {
+	sample, err := collector.GetClient().GetLatestSample(storage, snapshotName, collector.Snapshot,
+		collector.SampleTriggerPreRollback, logger)
+	if err != nil {
+		return "", err
+	}
+	if sample == "" {
+		return "", fmt.Errorf("no pre-rollback sample found for snapshot %s", snapshotName)
+	}
+
+	return sample, nil
+}
+
+// Undo restores the configuration in place before the most recent rollback
+func Undo(ctx context.Context, args []string, takeSample SampleCollector, logger logr.Logger) error {
+	doc := `Usage:
+	sveltosctl snapshot undo [options] --snapshot=<name> [--yes] [--dry-run] [--force] [--verbose]
+
+     --snapshot=<name>      Name of the Snapshot instance
+
+Options:
+  -h --help                  Show this screen.
+     --yes                   Do not ask for confirmation before deleting resources created by the rollback.
+     --dry-run               Do not modify anything. Print the plan to restore the pre-rollback sample.
+     --force                 Restore the pre-rollback sample even if it fails verification against its manifest.
+     --verbose               Verbose mode. Print each step.
+
+Description:
+  The snapshot undo restores the most recent pre-rollback sample, that is the configuration in place
+  right before the last snapshot rollback. Resources not present in such sample are deleted.
+  Undo takes a pre-rollback sample itself, so running undo twice restores the configuration
+  in place before the first undo.
+`
+
+	parsedArgs, err := docopt.ParseArgs(doc, nil, "1.0")
+	if err != nil {
+		logger.V(logs.LogDebug).Error(err, "failed to parse args")
+		return fmt.Errorf(
+			"invalid option: 'sveltosctl %s'. Use flag '--help' to read about a specific subcommand. Error: %w",
+			strings.Join(args, " "),
+			err,
+		)
+	}
+
+	_ = flag.Lookup("v").Value.Set(fmt.Sprint(logs.LogInfo))
+	verbose := parsedArgs["--verbose"].(bool)
+	if verbose {
+		err = flag.Lookup("v").Value.Set(fmt.Sprint(logs.LogDebug))
+		if err != nil {
+			return err
+		}
+	}
+
+	logger = textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1)))
+
+	snapshostName := parsedArgs["--snapshot"].(string)
+
+	options := &RollbackOptions{
+		DryRun:           parsedArgs["--dry-run"].(bool),
+		SkipConfirmation: parsedArgs["--yes"].(bool),
+		TakeSample:       takeSample,
+		Force:            parsedArgs["--force"].(bool),
+	}
+
+	return undoRollback(ctx, snapshostName, options, logger)
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot_test
+
+import (
+	"context"
+	"os"
+	"path"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2/textlogger"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	configv1beta1 "github.com/projectsveltos/addon-controller/api/v1beta1"
+	utilsv1beta1 "github.com/projectsveltos/sveltosctl/api/v1beta1"
+	"github.com/projectsveltos/sveltosctl/internal/collector"
+	"github.com/projectsveltos/sveltosctl/internal/commands/snapshot"
+	"github.com/projectsveltos/sveltosctl/internal/utils"
+)
+
+var _ = Describe("Snapshot Undo", func() {
+	var snapshotInstance *utilsv1beta1.Snapshot
+	var storage collector.Storage
+	var samples []string
+
+	BeforeEach(func() {
+		snapshotInstance = &utilsv1beta1.Snapshot{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: randomString(),
+			},
+			Spec: utilsv1beta1.SnapshotSpec{
+				Storage: randomString(),
+			},
+		}
+
+		numOfCollection := 3
+		snapshotDir := createSnapshotDirectories(snapshotInstance.Name, snapshotInstance.Spec.Storage,
+			numOfCollection)
+		snapshotInstance.Spec.Storage = snapshotDir
+		storage = collector.NewFilesystemStorage(snapshotDir)
+
+		var err error
+		samples, err = collector.GetClient().ListCollections(storage, snapshotInstance.Name, collector.Snapshot,
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
+		Expect(err).To(BeNil())
+		Expect(samples).To(HaveLen(numOfCollection))
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(snapshotInstance.Spec.Storage)
+	})
+
+	It("getLatestPreRollbackSample returns most recent pre-rollback sample", func() {
+		_, err := snapshot.GetLatestPreRollbackSample(storage, snapshotInstance.Name,
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
+		Expect(err).ToNot(BeNil())
+
+		// samples are sorted by time. Mark the two oldest as pre-rollback ones.
+		for i := 0; i < 2; i++ {
+			Expect(collector.GetClient().WriteSampleMetadata(storage,
+				path.Join("snapshot", snapshotInstance.Name, samples[i]),
+				&collector.SampleMetadata{Trigger: collector.SampleTriggerPreRollback})).To(Succeed())
+		}
+
+		sample, err := snapshot.GetLatestPreRollbackSample(storage, snapshotInstance.Name,
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
+		Expect(err).To(BeNil())
+		Expect(sample).To(Equal(samples[1]))
+	})
+
+	It("undoRollback takes a pre-rollback sample and restores the latest pre-rollback one", func() {
+		Expect(collector.GetClient().WriteSampleMetadata(storage,
+			path.Join("snapshot", snapshotInstance.Name, samples[0]),
+			&collector.SampleMetadata{Trigger: collector.SampleTriggerPreRollback})).To(Succeed())
+		Expect(collector.GetClient().WriteSampleManifest(storage,
+			path.Join("snapshot", snapshotInstance.Name, samples[0]), nil)).To(Succeed())
+
+		// ClusterProfile was created by the rollback being undone
+		clusterProfile := &configv1beta1.ClusterProfile{
+			ObjectMeta: metav1.ObjectMeta{Name: randomString()},
+		}
+
+		initObjects := []client.Object{snapshotInstance, clusterProfile}
+		scheme, err := utils.GetScheme()
+		Expect(err).To(BeNil())
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initObjects...).Build()
+		utils.InitalizeManagementClusterAcces(scheme, nil, nil, c)
+
+		triggers := make([]collector.SampleTrigger, 0)
+		takeSample := func(ctx context.Context, snapshotName string, trigger collector.SampleTrigger,
+			logger logr.Logger) (string, error) {
+
+			Expect(snapshotName).To(Equal(snapshotInstance.Name))
+			triggers = append(triggers, trigger)
+			return randomString(), nil
+		}
+
+		// Dry run modifies nothing and takes no sample
+		Expect(snapshot.UndoRollback(context.TODO(), snapshotInstance.Name,
+			&snapshot.RollbackOptions{DryRun: true, TakeSample: takeSample},
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
+		Expect(triggers).To(BeEmpty())
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: clusterProfile.Name},
+			&configv1beta1.ClusterProfile{})).To(Succeed())
+
+		Expect(snapshot.UndoRollback(context.TODO(), snapshotInstance.Name,
+			&snapshot.RollbackOptions{SkipConfirmation: true, TakeSample: takeSample},
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
+		Expect(triggers).To(Equal([]collector.SampleTrigger{collector.SampleTriggerPreRollback}))
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: clusterProfile.Name},
+			&configv1beta1.ClusterProfile{})).ToNot(Succeed())
+	})
+})
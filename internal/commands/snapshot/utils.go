@@ -0,0 +1,51 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+
+	"github.com/go-logr/logr"
+
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+	utilsv1beta1 "github.com/projectsveltos/sveltosctl/api/v1beta1"
+	"github.com/projectsveltos/sveltosctl/internal/collector"
+	"github.com/projectsveltos/sveltosctl/internal/utils"
+)
+
+// getSnapshotStorage returns the Storage where samples for snapshotInstance are stored
+func getSnapshotStorage(ctx context.Context, snapshotInstance *utilsv1beta1.Snapshot) (collector.Storage, error) {
+	return collector.NewStorage(ctx, utils.GetAccessInstance().GetClient(), snapshotInstance.Spec.Storage,
+		snapshotInstance.Spec.StorageBackend, snapshotInstance.Spec.Encryption)
+}
+
+// verifySampleExists returns an error if folder, containing a sample, does not exist
+func verifySampleExists(storage collector.Storage, folder, snapshotName string, logger logr.Logger) error {
+	exist, err := storage.Exists(folder)
+	if err != nil {
+		return err
+	}
+	if !exist {
+		logger.V(logs.LogDebug).Info(fmt.Sprintf("Folder %s does not exist for snapshot instance: %s",
+			folder, snapshotName))
+		return &fs.PathError{Op: "stat", Path: folder, Err: fs.ErrNotExist}
+	}
+
+	return nil
+}
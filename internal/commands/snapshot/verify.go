@@ -0,0 +1,160 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot
+
+import (
+	"context"
+	"crypto/ed25519"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docopt/docopt-go"
+	"github.com/go-logr/logr"
+	"github.com/olekukonko/tablewriter"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2/textlogger"
+
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+	utilsv1beta1 "github.com/projectsveltos/sveltosctl/api/v1beta1"
+	"github.com/projectsveltos/sveltosctl/internal/collector"
+	"github.com/projectsveltos/sveltosctl/internal/utils"
+)
+
+// verifySampleManifest verifies the sample stored in folder matches its manifest and,
+// when snapshotInstance signs manifests, that the manifest signature is valid
+func verifySampleManifest(ctx context.Context, snapshotInstance *utilsv1beta1.Snapshot,
+	storage collector.Storage, folder string) (*collector.SampleManifest, error) {
+
+	var publicKey ed25519.PublicKey
+	if snapshotInstance.Spec.Signing != nil {
+		var err error
+		publicKey, err = collector.GetVerificationKey(ctx, utils.GetAccessInstance().GetClient(),
+			snapshotInstance.Spec.Signing)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return collector.GetClient().VerifySample(storage, folder, publicKey)
+}
+
+// verifySample verifies sample, a sample of Snapshot snapshotName
+func verifySample(ctx context.Context, snapshotName, sample string, logger logr.Logger,
+) (*collector.SampleManifest, error) {
+
+	logger.V(logs.LogDebug).Info(fmt.Sprintf("Getting Snapshot %s", snapshotName))
+
+	snapshotInstance := &utilsv1beta1.Snapshot{}
+	err := utils.GetAccessInstance().GetResource(ctx, types.NamespacedName{Name: snapshotName}, snapshotInstance)
+	if err != nil {
+		return nil, err
+	}
+
+	storage, err := getSnapshotStorage(ctx, snapshotInstance)
+	if err != nil {
+		return nil, err
+	}
+
+	artifactFolder, err := collector.GetClient().GetFolder(storage, snapshotName, collector.Snapshot, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	folder := path.Join(*artifactFolder, sample)
+	err = verifySampleExists(storage, folder, snapshotName, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := verifySampleManifest(ctx, snapshotInstance, storage, folder)
+	if err != nil {
+		return nil, fmt.Errorf("sample %s failed verification: %w", sample, err)
+	}
+
+	return manifest, nil
+}
+
+// Verify checks a sample against its manifest
+func Verify(ctx context.Context, args []string, logger logr.Logger) error {
+	doc := `Usage:
+	sveltosctl snapshot verify [options] --snapshot=<name> --sample=<name> [--verbose]
+
+     --snapshot=<name>      Name of the Snapshot instance
+     --sample=<name>        Name of the sample to verify
+
+Options:
+  -h --help                  Show this screen.
+     --verbose               Verbose mode. Print each step.
+
+Description:
+  The snapshot verify command checks every file stored in the sample against the sample manifest
+  (SHA-256 digests and number of objects per kind). When the Snapshot signs manifests, the manifest
+  signature is verified as well.
+  Rollback refuses to restore a sample which fails verification unless --force is passed.
+`
+
+	parsedArgs, err := docopt.ParseArgs(doc, nil, "1.0")
+	if err != nil {
+		logger.V(logs.LogDebug).Error(err, "failed to parse args")
+		return fmt.Errorf(
+			"invalid option: 'sveltosctl %s'. Use flag '--help' to read about a specific subcommand. Error: %w",
+			strings.Join(args, " "),
+			err,
+		)
+	}
+
+	_ = flag.Lookup("v").Value.Set(fmt.Sprint(logs.LogInfo))
+	verbose := parsedArgs["--verbose"].(bool)
+	if verbose {
+		err = flag.Lookup("v").Value.Set(fmt.Sprint(logs.LogDebug))
+		if err != nil {
+			return err
+		}
+	}
+
+	logger = textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1)))
+
+	snapshostName := parsedArgs["--snapshot"].(string)
+	sample := parsedArgs["--sample"].(string)
+
+	manifest, err := verifySample(ctx, snapshostName, sample, logger)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stdout, "Sample %s of snapshot %s verified (%d files)\n", sample, snapshostName, len(manifest.Files))
+
+	kinds := make([]string, 0, len(manifest.Counts))
+	for kind := range manifest.Counts {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"KIND", "COUNT"})
+	for _, kind := range kinds {
+		table.Append([]string{kind, strconv.Itoa(manifest.Counts[kind])})
+	}
+	table.Render()
+
+	return nil
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"os"
+	"path"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	configv1beta1 "github.com/projectsveltos/addon-controller/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2/textlogger"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	utilsv1beta1 "github.com/projectsveltos/sveltosctl/api/v1beta1"
+	"github.com/projectsveltos/sveltosctl/internal/collector"
+	"github.com/projectsveltos/sveltosctl/internal/commands/snapshot"
+	"github.com/projectsveltos/sveltosctl/internal/utils"
+)
+
+var _ = Describe("Snapshot Verify", func() {
+	var snapshotInstance *utilsv1beta1.Snapshot
+	var keySecret *corev1.Secret
+	var clusterProfile *configv1beta1.ClusterProfile
+	var sample string
+	var sampleFolder string
+	var privateKey ed25519.PrivateKey
+
+	BeforeEach(func() {
+		storageDir, err := os.MkdirTemp("", randomString())
+		Expect(err).To(BeNil())
+
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+		Expect(err).To(BeNil())
+
+		keySecret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: randomString(), Name: randomString()},
+			Data:       map[string][]byte{utilsv1beta1.SigningPrivateKeyKey: privateKey.Seed()},
+		}
+
+		snapshotInstance = &utilsv1beta1.Snapshot{
+			ObjectMeta: metav1.ObjectMeta{Name: randomString()},
+			Spec: utilsv1beta1.SnapshotSpec{
+				Storage: storageDir,
+				Signing: &utilsv1beta1.SnapshotSigning{
+					KeySecretRef: corev1.SecretReference{Namespace: keySecret.Namespace, Name: keySecret.Name},
+				},
+			},
+		}
+
+		clusterProfile = &configv1beta1.ClusterProfile{
+			ObjectMeta: metav1.ObjectMeta{Name: randomString()},
+		}
+
+		sample = time.Now().Add(-time.Hour).Format(timeFormat)
+		sampleFolder = path.Join("snapshot", snapshotInstance.Name, sample)
+		Expect(collector.GetClient().DumpObject(collector.NewFilesystemStorage(storageDir), clusterProfile.DeepCopy(),
+			sampleFolder, textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(snapshotInstance.Spec.Storage)
+	})
+
+	It("verifySample checks manifest and signature", func() {
+		initObjects := []client.Object{snapshotInstance, keySecret}
+		scheme, err := utils.GetScheme()
+		Expect(err).To(BeNil())
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initObjects...).Build()
+		utils.InitalizeManagementClusterAcces(scheme, nil, nil, c)
+
+		logger := textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1)))
+		storage := collector.NewFilesystemStorage(snapshotInstance.Spec.Storage)
+
+		// Sample has no manifest
+		_, err = snapshot.VerifySample(context.TODO(), snapshotInstance.Name, sample, logger)
+		Expect(err).ToNot(BeNil())
+
+		// Snapshot signs manifests, so an unsigned manifest cannot be verified
+		Expect(collector.GetClient().WriteSampleManifest(storage, sampleFolder, nil)).To(Succeed())
+		_, err = snapshot.VerifySample(context.TODO(), snapshotInstance.Name, sample, logger)
+		Expect(err).ToNot(BeNil())
+
+		Expect(collector.GetClient().WriteSampleManifest(storage, sampleFolder, privateKey)).To(Succeed())
+		manifest, err := snapshot.VerifySample(context.TODO(), snapshotInstance.Name, sample, logger)
+		Expect(err).To(BeNil())
+		Expect(manifest.Counts).To(Equal(map[string]int{configv1beta1.ClusterProfileKind: 1}))
+
+		_, err = snapshot.VerifySample(context.TODO(), snapshotInstance.Name, randomString(), logger)
+		Expect(err).ToNot(BeNil())
+	})
+
+	It("rollback refuses samples which fail verification unless forced", func() {
+		initObjects := []client.Object{snapshotInstance, keySecret}
+		scheme, err := utils.GetScheme()
+		Expect(err).To(BeNil())
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initObjects...).Build()
+		utils.InitalizeManagementClusterAcces(scheme, nil, nil, c)
+
+		logger := textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1)))
+
+		_, err = snapshot.ExecuteRollback(context.TODO(), snapshotInstance.Name, sample, &snapshot.RollbackFilters{},
+			&snapshot.RollbackOptions{SkipConfirmation: true}, logger)
+		Expect(err).ToNot(BeNil())
+		Expect(err.Error()).To(ContainSubstring("failed verification"))
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: clusterProfile.Name},
+			&configv1beta1.ClusterProfile{})).ToNot(Succeed())
+
+		_, err = snapshot.ExecuteRollback(context.TODO(), snapshotInstance.Name, sample, &snapshot.RollbackFilters{},
+			&snapshot.RollbackOptions{SkipConfirmation: true, Force: true}, logger)
+		Expect(err).To(BeNil())
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: clusterProfile.Name},
+			&configv1beta1.ClusterProfile{})).To(Succeed())
+	})
+})
@@ -0,0 +1,539 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	configv1beta1 "github.com/projectsveltos/addon-controller/api/v1beta1"
+	libsveltosv1beta1 "github.com/projectsveltos/libsveltos/api/v1beta1"
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+	utilsv1beta1 "github.com/projectsveltos/sveltosctl/api/v1beta1"
+	"github.com/projectsveltos/sveltosctl/internal/collector"
+)
+
+const (
+	// defaultChangeDebounce is used when ChangeTrigger.DebounceSeconds is not set
+	defaultChangeDebounce = 30 * time.Second
+
+	// defaultChangeMinInterval is used when ChangeTrigger.MinIntervalSeconds is not set
+	defaultChangeMinInterval = 5 * time.Minute
+
+	// maxRecordedChanges is the maximum number of changed resources recorded
+	// in a sample metadata
+	maxRecordedChanges = 100
+
+	// profileReferencesIndex indexes ClusterProfiles/Profiles by the ConfigMaps/Secrets
+	// they reference
+	profileReferencesIndex = "referencedConfigMapsAndSecrets"
+)
+
+// pendingChanges contains the changes detected, and not collected yet, for a Snapshot
+type pendingChanges struct {
+	lastChange time.Time
+	changes    []collector.SampleChange
+}
+
+// changeTracker keeps, for each Snapshot with a ChangeTrigger, the changes
+// not collected yet
+type changeTracker struct {
+	mu      sync.Mutex
+	pending map[string]*pendingChanges
+}
+
+var snapshotChanges = newChangeTracker()
+
+func newChangeTracker() *changeTracker {
+	return &changeTracker{pending: make(map[string]*pendingChanges)}
+}
+
+// recordChange records that change happened, at time now, for Snapshot snapshotName
+func (t *changeTracker) recordChange(snapshotName string, change collector.SampleChange, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, ok := t.pending[snapshotName]
+	if !ok {
+		p = &pendingChanges{}
+		t.pending[snapshotName] = p
+	}
+
+	if now.After(p.lastChange) {
+		p.lastChange = now
+	}
+	if len(p.changes) < maxRecordedChanges && !slices.Contains(p.changes, change) {
+		p.changes = append(p.changes, change)
+	}
+}
+
+// getLastChange returns the time of the last change not collected yet for Snapshot
+// snapshotName. Returns false if there is none.
+func (t *changeTracker) getLastChange(snapshotName string) (time.Time, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, ok := t.pending[snapshotName]
+	if !ok {
+		return time.Time{}, false
+	}
+	return p.lastChange, true
+}
+
+// takeChanges returns the changes not collected yet for Snapshot snapshotName and
+// forgets them
+func (t *changeTracker) takeChanges(snapshotName string) []collector.SampleChange {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, ok := t.pending[snapshotName]
+	if !ok {
+		return nil
+	}
+	delete(t.pending, snapshotName)
+	return p.changes
+}
+
+// forget drops all changes for Snapshot snapshotName
+func (t *changeTracker) forget(snapshotName string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.pending, snapshotName)
+}
+
+func getChangeDebounce(changeTrigger *utilsv1beta1.ChangeTrigger) time.Duration {
+	if changeTrigger.DebounceSeconds == nil {
+		return defaultChangeDebounce
+	}
+	return time.Duration(*changeTrigger.DebounceSeconds) * time.Second
+}
+
+func getChangeMinInterval(changeTrigger *utilsv1beta1.ChangeTrigger) time.Duration {
+	if changeTrigger.MinIntervalSeconds == nil {
+		return defaultChangeMinInterval
+	}
+	return time.Duration(*changeTrigger.MinIntervalSeconds) * time.Second
+}
+
+// processSnapshotChanges queues a collection when changes were detected for snapshotInstance,
+// no further change happened during the debounce window and the minimum interval since the
+// last change-triggered sample has elapsed.
+// Returns how long to wait before pending changes can be collected (zero if there is none).
+func processSnapshotChanges(ctx context.Context, snapshotInstance *utilsv1beta1.Snapshot, now time.Time,
+	logger logr.Logger) (time.Duration, error) {
+
+	changeTrigger := snapshotInstance.Spec.ChangeTrigger
+	if changeTrigger == nil {
+		snapshotChanges.forget(snapshotInstance.Name)
+		return 0, nil
+	}
+
+	lastChange, ok := snapshotChanges.getLastChange(snapshotInstance.Name)
+	if !ok {
+		return 0, nil
+	}
+
+	if isCollectionInProgress(snapshotInstance.Status.LastRunStatus) {
+		logger.V(logs.LogDebug).Info("collection in progress. Changes will be collected later")
+		return requeueAfter, nil
+	}
+
+	readyAt := lastChange.Add(getChangeDebounce(changeTrigger))
+	if lastSample := snapshotInstance.Status.LastChangeTriggeredTime; lastSample != nil {
+		if minTime := lastSample.Add(getChangeMinInterval(changeTrigger)); minTime.After(readyAt) {
+			readyAt = minTime
+		}
+	}
+	if now.Before(readyAt) {
+		logger.V(logs.LogDebug).Info(fmt.Sprintf("changes will be collected at %s", readyAt.Format(time.RFC3339)))
+		return readyAt.Sub(now), nil
+	}
+
+	// Changes stay pending until the collection starts
+	logger.V(logs.LogInfo).Info("queuing collection job")
+	err := collector.GetClient().Collect(ctx, snapshotInstance.Name, collector.Snapshot,
+		getCollectSnapshotOnChange())
+	if err != nil {
+		return 0, err
+	}
+
+	inProgress := utilsv1beta1.CollectionStatusInProgress
+	snapshotInstance.Status.LastRunStatus = &inProgress
+	snapshotInstance.Status.LastChangeTriggeredTime = &metav1.Time{Time: now}
+	return 0, nil
+}
+
+// referencingProfile is a ClusterProfile/Profile referencing a resource
+type referencingProfile struct {
+	kind    string
+	profile client.Object
+}
+
+// getReferencingProfiles returns the ClusterProfiles/Profiles referencing the resource
+// of kind in namespace/name
+func getReferencingProfiles(ctx context.Context, c client.Client, kind, namespace, name string,
+	logger logr.Logger) ([]referencingProfile, error) {
+
+	isReferenced := func(references []referencedObject) bool {
+		return slices.ContainsFunc(references, func(ref referencedObject) bool {
+			return ref.kind == kind && ref.namespace == namespace && ref.name == name
+		})
+	}
+
+	profiles := make([]referencingProfile, 0)
+
+	clusterProfiles := &configv1beta1.ClusterProfileList{}
+	if err := c.List(ctx, clusterProfiles); err != nil {
+		return nil, err
+	}
+	for i := range clusterProfiles.Items {
+		cp := &clusterProfiles.Items[i]
+		references := getProfileReferences(&cp.Spec,
+			getMatchingClusterNamespaces(cp.Status.MatchingClusterRefs), logger)
+		if isReferenced(references) {
+			profiles = append(profiles, referencingProfile{kind: configv1beta1.ClusterProfileKind, profile: cp})
+		}
+	}
+
+	profileList := &configv1beta1.ProfileList{}
+	if err := c.List(ctx, profileList); err != nil {
+		return nil, err
+	}
+	for i := range profileList.Items {
+		p := &profileList.Items[i]
+		if isReferenced(getProfileReferences(&p.Spec, []string{p.Namespace}, logger)) {
+			profiles = append(profiles, referencingProfile{kind: configv1beta1.ProfileKind, profile: p})
+		}
+	}
+
+	return profiles, nil
+}
+
+func getReferenceIndexKey(kind, namespace, name string) string {
+	return fmt.Sprintf("%s:%s/%s", kind, namespace, name)
+}
+
+// getReferenceIndexKeys returns the profileReferencesIndex keys of the ConfigMaps/Secrets
+// in references
+func getReferenceIndexKeys(references []referencedObject) []string {
+	keys := make([]string, 0, len(references))
+	for i := range references {
+		if references[i].kind != string(libsveltosv1beta1.ConfigMapReferencedResourceKind) &&
+			references[i].kind != string(libsveltosv1beta1.SecretReferencedResourceKind) {
+
+			continue
+		}
+		keys = append(keys, getReferenceIndexKey(references[i].kind, references[i].namespace, references[i].name))
+	}
+
+	return keys
+}
+
+func indexClusterProfileReferences(obj client.Object) []string {
+	cp := obj.(*configv1beta1.ClusterProfile)
+	return getReferenceIndexKeys(getProfileReferences(&cp.Spec,
+		getMatchingClusterNamespaces(cp.Status.MatchingClusterRefs), logr.Discard()))
+}
+
+func indexProfileReferences(obj client.Object) []string {
+	p := obj.(*configv1beta1.Profile)
+	return getReferenceIndexKeys(getProfileReferences(&p.Spec, []string{p.Namespace}, logr.Discard()))
+}
+
+// isReferencedByProfile returns true if a ClusterProfile/Profile references the ConfigMap/Secret
+// of kind in namespace/name. c must support profileReferencesIndex.
+func isReferencedByProfile(ctx context.Context, c client.Reader, kind, namespace, name string) (bool, error) {
+	fields := client.MatchingFields{profileReferencesIndex: getReferenceIndexKey(kind, namespace, name)}
+
+	clusterProfiles := &configv1beta1.ClusterProfileList{}
+	if err := c.List(ctx, clusterProfiles, fields); err != nil {
+		return false, err
+	}
+	if len(clusterProfiles.Items) > 0 {
+		return true, nil
+	}
+
+	profiles := &configv1beta1.ProfileList{}
+	if err := c.List(ctx, profiles, fields); err != nil {
+		return false, err
+	}
+	return len(profiles.Items) > 0, nil
+}
+
+// getReferencedPredicate returns a predicate reacting only to ConfigMaps/Secrets referenced
+// by a ClusterProfile/Profile. So changes to any other ConfigMap/Secret cost a lookup in
+// profileReferencesIndex only.
+func getReferencedPredicate[T client.Object](ctx context.Context, c client.Reader, kind string,
+	logger logr.Logger) predicate.TypedPredicate[T] {
+
+	return predicate.NewTypedPredicateFuncs(func(o T) bool {
+		referenced, err := isReferencedByProfile(ctx, c, kind, o.GetNamespace(), o.GetName())
+		if err != nil {
+			logger.V(logs.LogInfo).Info(fmt.Sprintf("failed to get profiles referencing %s %s/%s: %v",
+				kind, o.GetNamespace(), o.GetName(), err))
+			return true
+		}
+		return referenced
+	})
+}
+
+// isChangeInScope returns true if a change to obj, an instance of kind, is relevant for
+// a Snapshot with scope. Referenced resources are relevant only if referenced by
+// a ClusterProfile/Profile in scope.
+func isChangeInScope(scope *collector.SampleScope, kind string, obj client.Object,
+	profiles []referencingProfile) (bool, error) {
+
+	if kind != string(libsveltosv1beta1.ConfigMapReferencedResourceKind) &&
+		kind != string(libsveltosv1beta1.SecretReferencedResourceKind) {
+
+		return scope.IsObjectInScope(kind, obj)
+	}
+
+	if !scope.IsReferencedKindInScope(kind) {
+		return false, nil
+	}
+
+	for i := range profiles {
+		inScope, err := scope.IsObjectInScope(profiles[i].kind, profiles[i].profile)
+		if err != nil {
+			return false, err
+		}
+		if inScope {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// getSnapshotsForChange records a change to obj, an instance of kind, for every Snapshot
+// with a ChangeTrigger the change is relevant for. Returns such Snapshots.
+func getSnapshotsForChange(ctx context.Context, c client.Client, kind string, obj client.Object,
+	logger logr.Logger) []reconcile.Request {
+
+	logger = logger.WithValues("kind", kind, "resource", fmt.Sprintf("%s/%s", obj.GetNamespace(), obj.GetName()))
+
+	snapshots := &utilsv1beta1.SnapshotList{}
+	if err := c.List(ctx, snapshots); err != nil {
+		logger.V(logs.LogInfo).Info(fmt.Sprintf("failed to list Snapshots: %v", err))
+		return nil
+	}
+
+	var profiles []referencingProfile
+	if kind == string(libsveltosv1beta1.ConfigMapReferencedResourceKind) ||
+		kind == string(libsveltosv1beta1.SecretReferencedResourceKind) {
+
+		var err error
+		profiles, err = getReferencingProfiles(ctx, c, kind, obj.GetNamespace(), obj.GetName(), logger)
+		if err != nil {
+			logger.V(logs.LogInfo).Info(fmt.Sprintf("failed to get referencing profiles: %v", err))
+			return nil
+		}
+		if len(profiles) == 0 {
+			return nil
+		}
+	}
+
+	change := collector.SampleChange{Kind: kind, Namespace: obj.GetNamespace(), Name: obj.GetName()}
+	now := time.Now()
+	requests := make([]reconcile.Request, 0)
+	for i := range snapshots.Items {
+		snapshot := &snapshots.Items[i]
+		if snapshot.Spec.ChangeTrigger == nil || !snapshot.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		// Namespaces are not evaluated: a change in a namespace out of scope only
+		// causes a sample not containing it
+		scope := &collector.SampleScope{
+			IncludeKinds:    snapshot.Spec.IncludeKinds,
+			ExcludeKinds:    snapshot.Spec.ExcludeKinds,
+			ProfileSelector: snapshot.Spec.ProfileSelector,
+		}
+		inScope, err := isChangeInScope(scope, kind, obj, profiles)
+		if err != nil {
+			logger.V(logs.LogInfo).Info(fmt.Sprintf("failed to evaluate scope of Snapshot %s: %v", snapshot.Name, err))
+			continue
+		}
+		if !inScope {
+			continue
+		}
+
+		logger.V(logs.LogDebug).Info(fmt.Sprintf("change recorded for Snapshot %s", snapshot.Name))
+		snapshotChanges.recordChange(snapshot.Name, change, now)
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKey{Name: snapshot.Name}})
+	}
+
+	return requests
+}
+
+// getChangePredicate returns a predicate reacting to instances deleted, updated when
+// changed returns true, or created after startTime. Instances existing when sveltosctl
+// starts are reported as created by the initial list and are not changes.
+func getChangePredicate[T client.Object](startTime time.Time, changed func(oldObj, newObj T) bool,
+) predicate.TypedPredicate[T] {
+
+	return predicate.TypedFuncs[T]{
+		CreateFunc: func(e event.TypedCreateEvent[T]) bool {
+			creationTime := e.Object.GetCreationTimestamp()
+			return !creationTime.Before(&metav1.Time{Time: startTime})
+		},
+		UpdateFunc: func(e event.TypedUpdateEvent[T]) bool {
+			return changed(e.ObjectOld, e.ObjectNew)
+		},
+		DeleteFunc: func(e event.TypedDeleteEvent[T]) bool {
+			return true
+		},
+		GenericFunc: func(e event.TypedGenericEvent[T]) bool {
+			return false
+		},
+	}
+}
+
+func isSpecOrLabelsChanged[T client.Object](oldObj, newObj T) bool {
+	return oldObj.GetGeneration() != newObj.GetGeneration() ||
+		!reflect.DeepEqual(oldObj.GetLabels(), newObj.GetLabels())
+}
+
+func isLabelsChanged[T client.Object](oldObj, newObj T) bool {
+	return !reflect.DeepEqual(oldObj.GetLabels(), newObj.GetLabels())
+}
+
+// isResourceVersionChanged returns true if newObj was modified. ConfigMaps/Secrets are
+// watched by metadata only, so their data cannot be compared, but any modification
+// changes the resourceVersion while periodic resyncs do not.
+func isResourceVersionChanged(oldObj, newObj *metav1.PartialObjectMetadata) bool {
+	return oldObj.GetResourceVersion() != newObj.GetResourceVersion()
+}
+
+// getPartialObjectMetadata returns the object used to watch instances of gvk by metadata only
+func getPartialObjectMetadata(gvk schema.GroupVersionKind) *metav1.PartialObjectMetadata {
+	obj := &metav1.PartialObjectMetadata{}
+	obj.SetGroupVersionKind(gvk)
+	return obj
+}
+
+func watchChanges[T client.Object](mgr manager.Manager, c controller.Controller, obj T, kind string,
+	startTime time.Time, changed func(oldObj, newObj T) bool, logger logr.Logger,
+	predicates ...predicate.TypedPredicate[T]) error {
+
+	src := source.Kind[T](
+		mgr.GetCache(),
+		obj,
+		handler.TypedEnqueueRequestsFromMapFunc(func(ctx context.Context, o T) []reconcile.Request {
+			return getSnapshotsForChange(ctx, mgr.GetClient(), kind, o, logger)
+		}),
+		append([]predicate.TypedPredicate[T]{getChangePredicate(startTime, changed)}, predicates...)...,
+	)
+
+	return c.Watch(src)
+}
+
+// isClusterAPIInstalled returns true if Cluster API CRDs are installed in the management cluster
+func isClusterAPIInstalled(mgr manager.Manager) (bool, error) {
+	gvk := clusterv1.GroupVersion.WithKind(clusterv1.ClusterKind)
+	_, err := mgr.GetRESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		if meta.IsNoMatchError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// watchSnapshotChanges makes c reconcile Snapshots with a ChangeTrigger when ClusterProfiles,
+// Profiles, ConfigMaps/Secrets those reference or cluster labels change
+func watchSnapshotChanges(ctx context.Context, mgr manager.Manager, c controller.Controller,
+	logger logr.Logger) error {
+
+	startTime := time.Now()
+	logger = logger.WithValues("watcher", "snapshot-changes")
+
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &configv1beta1.ClusterProfile{}, profileReferencesIndex,
+		indexClusterProfileReferences); err != nil {
+		return err
+	}
+
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &configv1beta1.Profile{}, profileReferencesIndex,
+		indexProfileReferences); err != nil {
+		return err
+	}
+
+	if err := watchChanges(mgr, c, &configv1beta1.ClusterProfile{}, configv1beta1.ClusterProfileKind,
+		startTime, isSpecOrLabelsChanged[*configv1beta1.ClusterProfile], logger); err != nil {
+		return err
+	}
+
+	if err := watchChanges(mgr, c, &configv1beta1.Profile{}, configv1beta1.ProfileKind,
+		startTime, isSpecOrLabelsChanged[*configv1beta1.Profile], logger); err != nil {
+		return err
+	}
+
+	// ConfigMaps/Secrets are watched by metadata only, so the cache does not keep a copy of
+	// every ConfigMap/Secret in the cluster. Recording a change only needs namespace and name,
+	// referenced ConfigMaps/Secrets are then read in full when the sample is taken.
+	configMapKind := string(libsveltosv1beta1.ConfigMapReferencedResourceKind)
+	if err := watchChanges(mgr, c, getPartialObjectMetadata(corev1.SchemeGroupVersion.WithKind(configMapKind)),
+		configMapKind, startTime, isResourceVersionChanged, logger,
+		getReferencedPredicate[*metav1.PartialObjectMetadata](ctx, mgr.GetClient(), configMapKind, logger)); err != nil {
+		return err
+	}
+
+	secretKind := string(libsveltosv1beta1.SecretReferencedResourceKind)
+	if err := watchChanges(mgr, c, getPartialObjectMetadata(corev1.SchemeGroupVersion.WithKind(secretKind)),
+		secretKind, startTime, isResourceVersionChanged, logger,
+		getReferencedPredicate[*metav1.PartialObjectMetadata](ctx, mgr.GetClient(), secretKind, logger)); err != nil {
+		return err
+	}
+
+	capiInstalled, err := isClusterAPIInstalled(mgr)
+	if err != nil {
+		return err
+	}
+	if capiInstalled {
+		if err := watchChanges(mgr, c, &clusterv1.Cluster{}, clusterv1.ClusterKind,
+			startTime, isLabelsChanged[*clusterv1.Cluster], logger); err != nil {
+			return err
+		}
+	} else {
+		logger.V(logs.LogInfo).Info("Cluster API not installed. Cluster label changes are not watched")
+	}
+
+	return watchChanges(mgr, c, &libsveltosv1beta1.SveltosCluster{}, libsveltosv1beta1.SveltosClusterKind,
+		startTime, isLabelsChanged[*libsveltosv1beta1.SveltosCluster], logger)
+}
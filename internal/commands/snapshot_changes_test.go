@@ -0,0 +1,287 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands_test
+
+import (
+	"context"
+	"os"
+	"path"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2/textlogger"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	configv1beta1 "github.com/projectsveltos/addon-controller/api/v1beta1"
+	libsveltosv1beta1 "github.com/projectsveltos/libsveltos/api/v1beta1"
+	utilsv1beta1 "github.com/projectsveltos/sveltosctl/api/v1beta1"
+	"github.com/projectsveltos/sveltosctl/internal/collector"
+	"github.com/projectsveltos/sveltosctl/internal/commands"
+	"github.com/projectsveltos/sveltosctl/internal/utils"
+)
+
+var _ = Describe("Snapshot changes", func() {
+	var snapshotInstance *utilsv1beta1.Snapshot
+
+	BeforeEach(func() {
+		storageDir, err := os.MkdirTemp("", randomString())
+		Expect(err).To(BeNil())
+
+		debounceSeconds := int32(30)
+		minIntervalSeconds := int32(300)
+		snapshotInstance = &utilsv1beta1.Snapshot{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: randomString(),
+			},
+			Spec: utilsv1beta1.SnapshotSpec{
+				Schedule: "0 * * * *",
+				Storage:  storageDir,
+				ChangeTrigger: &utilsv1beta1.ChangeTrigger{
+					DebounceSeconds:    &debounceSeconds,
+					MinIntervalSeconds: &minIntervalSeconds,
+				},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		commands.ForgetSnapshotChanges(snapshotInstance.Name)
+		os.RemoveAll(snapshotInstance.Spec.Storage)
+	})
+
+	It("getSnapshotsForChange records changes relevant for Snapshots with a ChangeTrigger", func() {
+		snapshotInstance.Spec.ProfileSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"team": "platform"}}
+
+		scheduledOnly := &utilsv1beta1.Snapshot{
+			ObjectMeta: metav1.ObjectMeta{Name: randomString()},
+			Spec: utilsv1beta1.SnapshotSpec{
+				Schedule: "0 * * * *",
+				Storage:  snapshotInstance.Spec.Storage,
+			},
+		}
+
+		referencedConfigMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: randomString(), Name: randomString()},
+		}
+		otherConfigMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: randomString(), Name: randomString()},
+		}
+
+		clusterProfile := &configv1beta1.ClusterProfile{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   randomString(),
+				Labels: map[string]string{"team": "platform"},
+			},
+			Spec: configv1beta1.Spec{
+				PolicyRefs: []configv1beta1.PolicyRef{
+					{
+						Kind:      string(libsveltosv1beta1.ConfigMapReferencedResourceKind),
+						Namespace: referencedConfigMap.Namespace,
+						Name:      referencedConfigMap.Name,
+					},
+				},
+			},
+		}
+		// Not matching the Snapshot ProfileSelector
+		otherClusterProfile := &configv1beta1.ClusterProfile{
+			ObjectMeta: metav1.ObjectMeta{Name: randomString()},
+			Spec: configv1beta1.Spec{
+				PolicyRefs: []configv1beta1.PolicyRef{
+					{
+						Kind:      string(libsveltosv1beta1.ConfigMapReferencedResourceKind),
+						Namespace: otherConfigMap.Namespace,
+						Name:      otherConfigMap.Name,
+					},
+				},
+			},
+		}
+
+		scheme, err := utils.GetScheme()
+		Expect(err).To(BeNil())
+		initObjects := []client.Object{snapshotInstance, scheduledOnly, clusterProfile, otherClusterProfile,
+			referencedConfigMap, otherConfigMap}
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initObjects...).Build()
+
+		logger := textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1)))
+
+		requests := commands.GetSnapshotsForChange(context.TODO(), c, configv1beta1.ClusterProfileKind,
+			otherClusterProfile, logger)
+		Expect(requests).To(BeEmpty())
+		_, ok := commands.GetSnapshotLastChange(snapshotInstance.Name)
+		Expect(ok).To(BeFalse())
+
+		// ConfigMaps are watched by metadata only
+		requests = commands.GetSnapshotsForChange(context.TODO(), c, string(libsveltosv1beta1.ConfigMapReferencedResourceKind),
+			toPartialObjectMetadata(otherConfigMap), logger)
+		Expect(requests).To(BeEmpty())
+
+		requests = commands.GetSnapshotsForChange(context.TODO(), c, string(libsveltosv1beta1.ConfigMapReferencedResourceKind),
+			toPartialObjectMetadata(referencedConfigMap), logger)
+		Expect(requests).To(HaveLen(1))
+		Expect(requests[0].Name).To(Equal(snapshotInstance.Name))
+		_, ok = commands.GetSnapshotLastChange(snapshotInstance.Name)
+		Expect(ok).To(BeTrue())
+		_, ok = commands.GetSnapshotLastChange(scheduledOnly.Name)
+		Expect(ok).To(BeFalse())
+
+		requests = commands.GetSnapshotsForChange(context.TODO(), c, configv1beta1.ClusterProfileKind,
+			clusterProfile, logger)
+		Expect(requests).To(HaveLen(1))
+		Expect(requests[0].Name).To(Equal(snapshotInstance.Name))
+	})
+
+	It("isResourceVersionChanged ignores resyncs", func() {
+		oldObj := toPartialObjectMetadata(&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: randomString(), Name: randomString(), ResourceVersion: "1"},
+		})
+		newObj := oldObj.DeepCopy()
+		Expect(commands.IsResourceVersionChanged(oldObj, newObj)).To(BeFalse())
+
+		newObj.ResourceVersion = "2"
+		Expect(commands.IsResourceVersionChanged(oldObj, newObj)).To(BeTrue())
+	})
+
+	It("isReferencedByProfile finds ConfigMaps/Secrets referenced by ClusterProfiles/Profiles", func() {
+		configMapKind := string(libsveltosv1beta1.ConfigMapReferencedResourceKind)
+		secretKind := string(libsveltosv1beta1.SecretReferencedResourceKind)
+
+		clusterProfile := &configv1beta1.ClusterProfile{
+			ObjectMeta: metav1.ObjectMeta{Name: randomString()},
+			Spec: configv1beta1.Spec{
+				PolicyRefs: []configv1beta1.PolicyRef{
+					{Kind: configMapKind, Namespace: randomString(), Name: randomString()},
+				},
+			},
+		}
+		// Secret namespace defaults to the Profile namespace
+		profile := &configv1beta1.Profile{
+			ObjectMeta: metav1.ObjectMeta{Namespace: randomString(), Name: randomString()},
+			Spec: configv1beta1.Spec{
+				PolicyRefs: []configv1beta1.PolicyRef{
+					{Kind: secretKind, Name: randomString()},
+				},
+			},
+		}
+
+		scheme, err := utils.GetScheme()
+		Expect(err).To(BeNil())
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(clusterProfile, profile).
+			WithIndex(&configv1beta1.ClusterProfile{}, commands.ProfileReferencesIndex,
+				commands.IndexClusterProfileReferences).
+			WithIndex(&configv1beta1.Profile{}, commands.ProfileReferencesIndex, commands.IndexProfileReferences).
+			Build()
+
+		ref := &clusterProfile.Spec.PolicyRefs[0]
+		Expect(commands.IsReferencedByProfile(context.TODO(), c, configMapKind, ref.Namespace, ref.Name)).To(BeTrue())
+		Expect(commands.IsReferencedByProfile(context.TODO(), c, configMapKind, ref.Namespace,
+			randomString())).To(BeFalse())
+		Expect(commands.IsReferencedByProfile(context.TODO(), c, secretKind, ref.Namespace, ref.Name)).To(BeFalse())
+
+		ref = &profile.Spec.PolicyRefs[0]
+		Expect(commands.IsReferencedByProfile(context.TODO(), c, secretKind, profile.Namespace, ref.Name)).To(BeTrue())
+		Expect(commands.IsReferencedByProfile(context.TODO(), c, secretKind, randomString(), ref.Name)).To(BeFalse())
+	})
+
+	It("processSnapshotChanges collects changes after the debounce window and the minimum interval", func() {
+		scheme, err := utils.GetScheme()
+		Expect(err).To(BeNil())
+		initObjects := []client.Object{snapshotInstance}
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initObjects...).Build()
+		utils.InitalizeManagementClusterAcces(scheme, nil, nil, c)
+		collector.InitializeClient(context.TODO(),
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))), c, 10)
+
+		logger := textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1)))
+
+		// No change: nothing to do
+		wait, err := commands.ProcessSnapshotChanges(context.TODO(), snapshotInstance, time.Now(), logger)
+		Expect(err).To(BeNil())
+		Expect(wait).To(BeZero())
+
+		change := collector.SampleChange{Kind: configv1beta1.ClusterProfileKind, Name: randomString()}
+		changeTime := time.Now()
+		commands.RecordSnapshotChange(snapshotInstance.Name, change, changeTime)
+
+		// Within debounce window
+		wait, err = commands.ProcessSnapshotChanges(context.TODO(), snapshotInstance,
+			changeTime.Add(10*time.Second), logger)
+		Expect(err).To(BeNil())
+		Expect(wait).To(Equal(20 * time.Second))
+		Expect(snapshotInstance.Status.LastChangeTriggeredTime).To(BeNil())
+
+		collectionTime := changeTime.Add(31 * time.Second)
+		wait, err = commands.ProcessSnapshotChanges(context.TODO(), snapshotInstance, collectionTime, logger)
+		Expect(err).To(BeNil())
+		Expect(wait).To(BeZero())
+		Expect(snapshotInstance.Status.LastChangeTriggeredTime).ToNot(BeNil())
+		Expect(snapshotInstance.Status.LastRunStatus).ToNot(BeNil())
+		Expect(*snapshotInstance.Status.LastRunStatus).To(Equal(utilsv1beta1.CollectionStatusInProgress))
+		// Changes are taken only when collection starts, so none is lost if the request
+		// is merged with a request already queued
+		_, ok := commands.GetSnapshotLastChange(snapshotInstance.Name)
+		Expect(ok).To(BeTrue())
+
+		// Changes recorded in the sample metadata
+		Expect(commands.GetCollectSnapshotOnChange()(context.TODO(), c,
+			snapshotInstance.Name, logger)).To(Succeed())
+		_, ok = commands.GetSnapshotLastChange(snapshotInstance.Name)
+		Expect(ok).To(BeFalse())
+		storage := collector.NewFilesystemStorage(snapshotInstance.Spec.Storage)
+		sample, err := collector.GetClient().GetLatestSample(storage, snapshotInstance.Name, collector.Snapshot,
+			collector.SampleTriggerChange, logger)
+		Expect(err).To(BeNil())
+		Expect(sample).ToNot(BeEmpty())
+		metadata, err := collector.GetClient().GetSampleMetadata(storage,
+			path.Join("snapshot", snapshotInstance.Name, sample))
+		Expect(err).To(BeNil())
+		Expect(metadata.Trigger).To(Equal(collector.SampleTriggerChange))
+		Expect(metadata.Changes).To(ConsistOf(change))
+
+		// A new change is collected only once minimum interval has elapsed
+		collected := utilsv1beta1.CollectionStatusCollected
+		snapshotInstance.Status.LastRunStatus = &collected
+		commands.RecordSnapshotChange(snapshotInstance.Name, change, collectionTime.Add(time.Second))
+		wait, err = commands.ProcessSnapshotChanges(context.TODO(), snapshotInstance,
+			collectionTime.Add(time.Minute), logger)
+		Expect(err).To(BeNil())
+		Expect(wait).To(Equal(4 * time.Minute))
+
+		// Changes are dropped once ChangeTrigger is removed
+		snapshotInstance.Spec.ChangeTrigger = nil
+		wait, err = commands.ProcessSnapshotChanges(context.TODO(), snapshotInstance,
+			collectionTime.Add(time.Hour), logger)
+		Expect(err).To(BeNil())
+		Expect(wait).To(BeZero())
+		_, ok = commands.GetSnapshotLastChange(snapshotInstance.Name)
+		Expect(ok).To(BeFalse())
+	})
+})
+
+func toPartialObjectMetadata(obj client.Object) *metav1.PartialObjectMetadata {
+	return &metav1.PartialObjectMetadata{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       obj.GetNamespace(),
+			Name:            obj.GetName(),
+			ResourceVersion: obj.GetResourceVersion(),
+		},
+	}
+}
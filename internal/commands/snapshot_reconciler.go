@@ -48,7 +48,17 @@ func SnapshotReconciler(ctx context.Context, req reconcile.Request) (reconcile.R
 	logger = logger.WithValues("snapshot", snapshotInstance.Name)
 
 	if !snapshotInstance.DeletionTimestamp.IsZero() {
-		return reconcileDelete(ctx, snapshotInstance, collector.Snapshot, snapshotInstance.Spec.Storage,
+		snapshotChanges.forget(snapshotInstance.Name)
+		forgetOnDemandSample(snapshotInstance.Name)
+
+		// Removing samples does not require decrypting them. So do not depend on the encryption Secret.
+		storage, err := collector.NewStorage(ctx, accessInstance.GetClient(), snapshotInstance.Spec.Storage,
+			snapshotInstance.Spec.StorageBackend, nil)
+		if err != nil {
+			logger.V(logs.LogInfo).Info(fmt.Sprintf("failed to get storage: %v", err))
+			return ctrl.Result{}, err
+		}
+		return reconcileDelete(ctx, snapshotInstance, collector.Snapshot, storage,
 			utilsv1beta1.SnapshotFinalizer, logger)
 	}
 
@@ -70,7 +80,19 @@ func reconcileSnapshotNormal(ctx context.Context, snapshotInstance *utilsv1beta1
 	result := snapshotClient.GetResult(ctx, snapshotInstance.Name, collector.Snapshot)
 	updateStatus(result, &collectionSnapshot)
 
+	err := processSnapshotTrigger(ctx, snapshotInstance, result, logger)
+	if err != nil {
+		logger.V(logs.LogInfo).Info(fmt.Sprintf("failed to process trigger. Err: %v", err))
+		return ctrl.Result{}, err
+	}
+
 	now := time.Now()
+	changeWait, err := processSnapshotChanges(ctx, snapshotInstance, now, logger)
+	if err != nil {
+		logger.V(logs.LogInfo).Info(fmt.Sprintf("failed to process changes. Err: %v", err))
+		return ctrl.Result{}, err
+	}
+
 	nextRun, err := schedule(ctx, snapshotInstance, collector.Snapshot,
 		collectSnapshot, &collectionSnapshot, logger)
 	if err != nil {
@@ -93,5 +115,45 @@ func reconcileSnapshotNormal(ctx context.Context, snapshotInstance *utilsv1beta1
 
 	logger.V(logs.LogInfo).Info("reconcile snapshot succeeded")
 	scheduledResult := ctrl.Result{RequeueAfter: nextRun.Sub(now)}
+	if changeWait > 0 && changeWait < scheduledResult.RequeueAfter {
+		// Pending changes are collected before next scheduled run
+		scheduledResult.RequeueAfter = changeWait
+	}
 	return scheduledResult, nil
 }
+
+// processSnapshotTrigger queues a collection when the trigger annotation has not been
+// served yet and, once a sample serving it is published, records trigger and sample in
+// status. Trigger is recorded only then: if collection fails or sveltosctl restarts before
+// the sample is published, a collection is queued again.
+func processSnapshotTrigger(ctx context.Context, snapshotInstance *utilsv1beta1.Snapshot,
+	result collector.Result, logger logr.Logger) error {
+
+	trigger := snapshotInstance.Annotations[utilsv1beta1.SnapshotTriggerAnnotation]
+	if trigger == "" || trigger == snapshotInstance.Status.LastTrigger {
+		return nil
+	}
+
+	if sample := getOnDemandSample(snapshotInstance.Name, trigger); sample != "" {
+		logger.V(logs.LogInfo).Info(fmt.Sprintf("on-demand sample %s collected (trigger %s)", sample, trigger))
+		snapshotInstance.Status.LastTrigger = trigger
+		snapshotInstance.Status.LastTriggeredSample = sample
+		return nil
+	}
+
+	// A collection queued or in progress serves the trigger. If it started before trigger
+	// was set, a new collection is queued once it completes.
+	if result.ResultStatus == collector.InProgress {
+		return nil
+	}
+
+	logger.V(logs.LogInfo).Info(fmt.Sprintf("queuing on-demand collection job (trigger %s)", trigger))
+	err := collector.GetClient().Collect(ctx, snapshotInstance.Name, collector.Snapshot, collectSnapshotOnDemand)
+	if err != nil {
+		return err
+	}
+
+	inProgress := utilsv1beta1.CollectionStatusInProgress
+	snapshotInstance.Status.LastRunStatus = &inProgress
+	return nil
+}
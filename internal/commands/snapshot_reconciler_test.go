@@ -0,0 +1,515 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/v2/textlogger"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	configv1beta1 "github.com/projectsveltos/addon-controller/api/v1beta1"
+	eventv1beta1 "github.com/projectsveltos/event-manager/api/v1beta1"
+	libsveltosv1beta1 "github.com/projectsveltos/libsveltos/api/v1beta1"
+	utilsv1beta1 "github.com/projectsveltos/sveltosctl/api/v1beta1"
+	"github.com/projectsveltos/sveltosctl/internal/collector"
+	"github.com/projectsveltos/sveltosctl/internal/commands"
+	"github.com/projectsveltos/sveltosctl/internal/utils"
+)
+
+var _ = Describe("Snapshot Reconciler", func() {
+	var snapshotInstance *utilsv1beta1.Snapshot
+
+	BeforeEach(func() {
+		storageDir, err := os.MkdirTemp("", randomString())
+		Expect(err).To(BeNil())
+
+		snapshotInstance = &utilsv1beta1.Snapshot{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: randomString(),
+				Annotations: map[string]string{
+					utilsv1beta1.SnapshotTriggerAnnotation: randomString(),
+				},
+			},
+			Spec: utilsv1beta1.SnapshotSpec{
+				Schedule: "0 * * * *",
+				Storage:  storageDir,
+			},
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(snapshotInstance.Spec.Storage)
+	})
+
+	It("processSnapshotTrigger queues a collection and reports the on-demand sample", func() {
+		scheme, err := utils.GetScheme()
+		Expect(err).To(BeNil())
+		initObjects := []client.Object{snapshotInstance}
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initObjects...).Build()
+		utils.InitalizeManagementClusterAcces(scheme, nil, nil, c)
+		collector.InitializeClient(context.TODO(),
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))), c, 10)
+
+		trigger := snapshotInstance.Annotations[utilsv1beta1.SnapshotTriggerAnnotation]
+
+		// Trigger annotation has never been served: collection is queued. Trigger is
+		// recorded only once the sample is published.
+		Expect(commands.ProcessSnapshotTrigger(context.TODO(), snapshotInstance,
+			collector.Result{ResultStatus: collector.Unavailable},
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
+		Expect(snapshotInstance.Status.LastTrigger).To(BeEmpty())
+		Expect(snapshotInstance.Status.LastTriggeredSample).To(BeEmpty())
+		Expect(snapshotInstance.Status.LastRunStatus).ToNot(BeNil())
+		Expect(*snapshotInstance.Status.LastRunStatus).To(Equal(utilsv1beta1.CollectionStatusInProgress))
+
+		Expect(commands.CollectSnapshotOnDemand(context.TODO(), c, snapshotInstance.Name,
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
+
+		Expect(commands.ProcessSnapshotTrigger(context.TODO(), snapshotInstance,
+			collector.Result{ResultStatus: collector.Collected},
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
+		Expect(snapshotInstance.Status.LastTrigger).To(Equal(trigger))
+		Expect(snapshotInstance.Status.LastTriggeredSample).ToNot(BeEmpty())
+
+		storage := collector.NewFilesystemStorage(snapshotInstance.Spec.Storage)
+		metadata, err := collector.GetClient().GetSampleMetadata(storage,
+			path.Join("snapshot", snapshotInstance.Name, snapshotInstance.Status.LastTriggeredSample))
+		Expect(err).To(BeNil())
+		Expect(metadata.Trigger).To(Equal(collector.SampleTriggerOnDemand))
+
+		// A new trigger value queues a new collection while the previous one stays reported
+		sample := snapshotInstance.Status.LastTriggeredSample
+		snapshotInstance.Status.LastRunStatus = nil
+		snapshotInstance.Annotations[utilsv1beta1.SnapshotTriggerAnnotation] = randomString()
+		Expect(commands.ProcessSnapshotTrigger(context.TODO(), snapshotInstance,
+			collector.Result{ResultStatus: collector.Collected},
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
+		Expect(snapshotInstance.Status.LastTrigger).To(Equal(trigger))
+		Expect(snapshotInstance.Status.LastTriggeredSample).To(Equal(sample))
+		Expect(*snapshotInstance.Status.LastRunStatus).To(Equal(utilsv1beta1.CollectionStatusInProgress))
+	})
+
+	It("processSnapshotTrigger reports the sample of the collection which served the trigger", func() {
+		scheme, err := utils.GetScheme()
+		Expect(err).To(BeNil())
+		initObjects := []client.Object{snapshotInstance}
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initObjects...).Build()
+		utils.InitalizeManagementClusterAcces(scheme, nil, nil, c)
+		collector.InitializeClient(context.TODO(),
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))), c, 10)
+
+		logger := textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1)))
+
+		// On-demand request was dropped because a scheduled collection was already queued:
+		// scheduled collection serves it
+		trigger := snapshotInstance.Annotations[utilsv1beta1.SnapshotTriggerAnnotation]
+		Expect(commands.CollectSnapshot(context.TODO(), c, snapshotInstance.Name, logger)).To(Succeed())
+
+		Expect(commands.ProcessSnapshotTrigger(context.TODO(), snapshotInstance,
+			collector.Result{ResultStatus: collector.Collected}, logger)).To(Succeed())
+		Expect(snapshotInstance.Status.LastTrigger).To(Equal(trigger))
+		Expect(snapshotInstance.Status.LastTriggeredSample).ToNot(BeEmpty())
+		storage := collector.NewFilesystemStorage(snapshotInstance.Spec.Storage)
+		metadata, err := collector.GetClient().GetSampleMetadata(storage,
+			path.Join("snapshot", snapshotInstance.Name, snapshotInstance.Status.LastTriggeredSample))
+		Expect(err).To(BeNil())
+		Expect(metadata.Trigger).To(Equal(collector.SampleTriggerOnDemand))
+	})
+
+	It("processSnapshotTrigger queues a collection again when the on-demand one was not published", func() {
+		scheme, err := utils.GetScheme()
+		Expect(err).To(BeNil())
+		initObjects := []client.Object{snapshotInstance}
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initObjects...).Build()
+		utils.InitalizeManagementClusterAcces(scheme, nil, nil, c)
+		collector.InitializeClient(context.TODO(),
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))), c, 10)
+
+		logger := textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1)))
+
+		// Collection queued or in progress serves the trigger: nothing to do
+		Expect(commands.ProcessSnapshotTrigger(context.TODO(), snapshotInstance,
+			collector.Result{ResultStatus: collector.InProgress}, logger)).To(Succeed())
+		Expect(snapshotInstance.Status.LastRunStatus).To(BeNil())
+
+		// Collection failed: trigger is not recorded and collection is queued again
+		Expect(commands.ProcessSnapshotTrigger(context.TODO(), snapshotInstance,
+			collector.Result{ResultStatus: collector.Failed, Err: fmt.Errorf("storage not available")},
+			logger)).To(Succeed())
+		Expect(snapshotInstance.Status.LastTrigger).To(BeEmpty())
+		Expect(*snapshotInstance.Status.LastRunStatus).To(Equal(utilsv1beta1.CollectionStatusInProgress))
+
+		// sveltosctl restarted before the sample was published: collection is queued again
+		snapshotInstance.Status.LastRunStatus = nil
+		Expect(commands.ProcessSnapshotTrigger(context.TODO(), snapshotInstance,
+			collector.Result{ResultStatus: collector.Unavailable}, logger)).To(Succeed())
+		Expect(snapshotInstance.Status.LastTrigger).To(BeEmpty())
+		Expect(*snapshotInstance.Status.LastRunStatus).To(Equal(utilsv1beta1.CollectionStatusInProgress))
+
+		// Once served, trigger is not collected again
+		Expect(commands.CollectSnapshotOnDemand(context.TODO(), c, snapshotInstance.Name, logger)).To(Succeed())
+		Expect(commands.ProcessSnapshotTrigger(context.TODO(), snapshotInstance,
+			collector.Result{ResultStatus: collector.Collected}, logger)).To(Succeed())
+		Expect(snapshotInstance.Status.LastTrigger).To(Equal(
+			snapshotInstance.Annotations[utilsv1beta1.SnapshotTriggerAnnotation]))
+		snapshotInstance.Status.LastRunStatus = nil
+		Expect(commands.ProcessSnapshotTrigger(context.TODO(), snapshotInstance,
+			collector.Result{ResultStatus: collector.Unavailable}, logger)).To(Succeed())
+		Expect(snapshotInstance.Status.LastRunStatus).To(BeNil())
+	})
+
+	It("collectSnapshotOnDemand publishes samples and cleanupStagingSamples removes incomplete ones", func() {
+		scheme, err := utils.GetScheme()
+		Expect(err).To(BeNil())
+		initObjects := []client.Object{snapshotInstance}
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initObjects...).Build()
+		utils.InitalizeManagementClusterAcces(scheme, nil, nil, c)
+
+		logger := textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1)))
+		Expect(commands.CollectSnapshotOnDemand(context.TODO(), c, snapshotInstance.Name, logger)).To(Succeed())
+
+		artifactFolder := path.Join("snapshot", snapshotInstance.Name)
+		storage := collector.NewFilesystemStorage(snapshotInstance.Spec.Storage)
+		entries, err := storage.ReadDir(artifactFolder)
+		Expect(err).To(BeNil())
+		Expect(entries).To(HaveLen(1))
+		sample := entries[0].Name
+
+		// Collection interrupted before being published
+		staging, err := collector.GetClient().GetStagingFolderPath(snapshotInstance.Name, collector.Snapshot,
+			time.Now().Add(time.Hour))
+		Expect(err).To(BeNil())
+		Expect(storage.WriteFile(path.Join(staging, configv1beta1.ClusterProfileKind, randomString()+".yaml"),
+			[]byte(randomString()))).To(Succeed())
+
+		commands.CleanupStagingSamples(context.TODO(), logger)
+
+		entries, err = storage.ReadDir(artifactFolder)
+		Expect(err).To(BeNil())
+		Expect(entries).To(Equal([]collector.StorageEntry{{Name: sample, IsDir: true}}))
+	})
+
+	It("collectSnapshotOnDemand stores Secrets according to secret policies", func() {
+		fullSecret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: randomString(), Name: randomString()},
+			Data:       map[string][]byte{"key": []byte(randomString())},
+		}
+		hashedValue := randomString()
+		hashedSecret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: randomString(),
+				Name:      randomString(),
+				Labels:    map[string]string{"snapshot": "hashed"},
+				// Set by kubectl apply, it contains Secret Data
+				Annotations: map[string]string{
+					corev1.LastAppliedConfigAnnotation: fmt.Sprintf(
+						`{"apiVersion":"v1","kind":"Secret","stringData":{"key":%q}}`, hashedValue),
+				},
+			},
+			Data: map[string][]byte{"key": []byte(hashedValue)},
+		}
+		skippedSecret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: randomString(), Name: randomString()},
+			Data:       map[string][]byte{"key": []byte(randomString())},
+		}
+
+		snapshotInstance.Spec.SecretPolicies = []utilsv1beta1.SecretPolicy{
+			{
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"snapshot": "hashed"}},
+				Mode:     utilsv1beta1.SecretModeHashed,
+			},
+			{
+				Namespaces: []string{skippedSecret.Namespace},
+				Mode:       utilsv1beta1.SecretModeSkip,
+			},
+		}
+
+		clusterProfile := &configv1beta1.ClusterProfile{
+			ObjectMeta: metav1.ObjectMeta{Name: randomString()},
+			Spec: configv1beta1.Spec{
+				PolicyRefs: []configv1beta1.PolicyRef{
+					{Kind: string(libsveltosv1beta1.SecretReferencedResourceKind), Namespace: fullSecret.Namespace, Name: fullSecret.Name},
+					{Kind: string(libsveltosv1beta1.SecretReferencedResourceKind), Namespace: hashedSecret.Namespace, Name: hashedSecret.Name},
+					{Kind: string(libsveltosv1beta1.SecretReferencedResourceKind), Namespace: skippedSecret.Namespace, Name: skippedSecret.Name},
+				},
+			},
+		}
+
+		scheme, err := utils.GetScheme()
+		Expect(err).To(BeNil())
+		initObjects := []client.Object{snapshotInstance, clusterProfile, fullSecret, hashedSecret, skippedSecret}
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initObjects...).Build()
+		utils.InitalizeManagementClusterAcces(scheme, nil, nil, c)
+		collector.InitializeClient(context.TODO(),
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))), c, 10)
+
+		Expect(commands.CollectSnapshotOnDemand(context.TODO(), c, snapshotInstance.Name,
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
+
+		storage := collector.NewContentAddressedStorage(collector.NewFilesystemStorage(snapshotInstance.Spec.Storage))
+		sample, err := collector.GetClient().GetLatestSample(storage, snapshotInstance.Name, collector.Snapshot,
+			collector.SampleTriggerOnDemand, textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
+		Expect(err).To(BeNil())
+		folder := path.Join("snapshot", snapshotInstance.Name, sample)
+
+		content, err := storage.ReadFile(path.Join(folder, fullSecret.Namespace, "Secret", fullSecret.Name+".yaml"))
+		Expect(err).To(BeNil())
+		u, err := collector.GetClient().GetUnstructured(content)
+		Expect(err).To(BeNil())
+		Expect(collector.IsSecretStoredInFull(u)).To(BeTrue())
+
+		content, err = storage.ReadFile(path.Join(folder, hashedSecret.Namespace, "Secret", hashedSecret.Name+".yaml"))
+		Expect(err).To(BeNil())
+		u, err = collector.GetClient().GetUnstructured(content)
+		Expect(err).To(BeNil())
+		Expect(collector.IsSecretStoredInFull(u)).To(BeFalse())
+		currentSecret := &corev1.Secret{}
+		Expect(runtime.DefaultUnstructuredConverter.FromUnstructured(u.UnstructuredContent(), currentSecret)).To(Succeed())
+		Expect(currentSecret.Data["key"]).ToNot(Equal(hashedSecret.Data["key"]))
+		Expect(currentSecret.Annotations).ToNot(HaveKey(corev1.LastAppliedConfigAnnotation))
+		Expect(string(content)).ToNot(ContainSubstring(hashedValue))
+
+		exist, err := storage.Exists(path.Join(folder, skippedSecret.Namespace, "Secret", skippedSecret.Name+".yaml"))
+		Expect(err).To(BeNil())
+		Expect(exist).To(BeFalse())
+	})
+
+	It("collectSnapshotOnDemand stores every resource referenced by ClusterProfiles and EventTriggers", func() {
+		clusterNamespace := randomString()
+		valuesConfigMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: clusterNamespace, Name: randomString()},
+			Data:       map[string]string{"values": randomString()},
+		}
+		credentialsSecret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: randomString(), Name: randomString()},
+			Data:       map[string][]byte{"config.json": []byte(randomString())},
+		}
+		templateConfigMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: randomString(), Name: randomString()},
+		}
+		generatorConfigMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: randomString(), Name: randomString()},
+		}
+		gitRepository := &unstructured.Unstructured{}
+		gitRepository.SetAPIVersion("source.toolkit.fluxcd.io/v1")
+		gitRepository.SetKind("GitRepository")
+		gitRepository.SetNamespace(randomString())
+		gitRepository.SetName(randomString())
+		Expect(unstructured.SetNestedField(gitRepository.Object, "https://github.com/projectsveltos/sveltosctl",
+			"spec", "url")).To(Succeed())
+
+		clusterProfile := &configv1beta1.ClusterProfile{
+			ObjectMeta: metav1.ObjectMeta{Name: randomString()},
+			Spec: configv1beta1.Spec{
+				HelmCharts: []configv1beta1.HelmChart{
+					{
+						ReleaseName: randomString(),
+						ValuesFrom: []configv1beta1.ValueFrom{
+							// Empty namespace: namespace of each matching cluster
+							{Kind: string(libsveltosv1beta1.ConfigMapReferencedResourceKind), Name: valuesConfigMap.Name},
+							// Templated references are instantiated only at deployment time
+							{Kind: string(libsveltosv1beta1.ConfigMapReferencedResourceKind), Name: "{{ .Cluster.metadata.name }}"},
+						},
+						RegistryCredentialsConfig: &configv1beta1.RegistryCredentialsConfig{
+							CredentialsSecretRef: &corev1.SecretReference{
+								Namespace: credentialsSecret.Namespace, Name: credentialsSecret.Name,
+							},
+						},
+					},
+				},
+				KustomizationRefs: []configv1beta1.KustomizationRef{
+					{Kind: "GitRepository", Namespace: gitRepository.GetNamespace(), Name: gitRepository.GetName()},
+				},
+				TemplateResourceRefs: []configv1beta1.TemplateResourceRef{
+					{
+						Resource: corev1.ObjectReference{
+							APIVersion: "v1", Kind: "ConfigMap",
+							Namespace: templateConfigMap.Namespace, Name: templateConfigMap.Name,
+						},
+						Identifier: randomString(),
+					},
+				},
+			},
+		}
+		clusterProfile.Status.MatchingClusterRefs = []corev1.ObjectReference{
+			{Namespace: clusterNamespace, Name: randomString(), Kind: libsveltosv1beta1.SveltosClusterKind},
+		}
+
+		eventTrigger := &eventv1beta1.EventTrigger{
+			ObjectMeta: metav1.ObjectMeta{Name: randomString()},
+			Spec: eventv1beta1.EventTriggerSpec{
+				ConfigMapGenerator: []eventv1beta1.GeneratorReference{
+					{Namespace: generatorConfigMap.Namespace, Name: generatorConfigMap.Name},
+				},
+			},
+		}
+
+		scheme, err := utils.GetScheme()
+		Expect(err).To(BeNil())
+		initObjects := []client.Object{snapshotInstance, clusterProfile, eventTrigger, valuesConfigMap,
+			credentialsSecret, templateConfigMap, generatorConfigMap, gitRepository}
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initObjects...).
+			WithStatusSubresource(clusterProfile).Build()
+		utils.InitalizeManagementClusterAcces(scheme, nil, nil, c)
+		collector.InitializeClient(context.TODO(),
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))), c, 10)
+
+		Expect(commands.CollectSnapshotOnDemand(context.TODO(), c, snapshotInstance.Name,
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
+
+		storage := collector.NewContentAddressedStorage(collector.NewFilesystemStorage(snapshotInstance.Spec.Storage))
+		sample, err := collector.GetClient().GetLatestSample(storage, snapshotInstance.Name, collector.Snapshot,
+			collector.SampleTriggerOnDemand, textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
+		Expect(err).To(BeNil())
+		folder := path.Join("snapshot", snapshotInstance.Name, sample)
+
+		for _, file := range []string{
+			path.Join(valuesConfigMap.Namespace, "ConfigMap", valuesConfigMap.Name+".yaml"),
+			path.Join(credentialsSecret.Namespace, "Secret", credentialsSecret.Name+".yaml"),
+			path.Join(templateConfigMap.Namespace, "ConfigMap", templateConfigMap.Name+".yaml"),
+			path.Join(generatorConfigMap.Namespace, "ConfigMap", generatorConfigMap.Name+".yaml"),
+			path.Join(gitRepository.GetNamespace(), "GitRepository", gitRepository.GetName()+".yaml"),
+		} {
+			exist, err := storage.Exists(path.Join(folder, file))
+			Expect(err).To(BeNil())
+			Expect(exist).To(BeTrue(), file)
+		}
+
+		content, err := storage.ReadFile(path.Join(folder, gitRepository.GetNamespace(), "GitRepository",
+			gitRepository.GetName()+".yaml"))
+		Expect(err).To(BeNil())
+		u, err := collector.GetClient().GetUnstructured(content)
+		Expect(err).To(BeNil())
+		Expect(u.GetAPIVersion()).To(Equal(gitRepository.GetAPIVersion()))
+		Expect(u.Object["spec"]).To(Equal(gitRepository.Object["spec"]))
+	})
+
+	It("collectSnapshotOnDemand only collects resources in the snapshot scope", func() {
+		selectedNamespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: randomString(), Labels: map[string]string{"team": "platform"}},
+		}
+		otherNamespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: randomString()}}
+
+		selectedProfile := &configv1beta1.Profile{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: selectedNamespace.Name, Name: randomString(),
+				Labels: map[string]string{"team": "platform"},
+			},
+		}
+		otherNamespaceProfile := &configv1beta1.Profile{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: otherNamespace.Name, Name: randomString(),
+				Labels: map[string]string{"team": "platform"},
+			},
+		}
+		notSelectedClusterProfile := &configv1beta1.ClusterProfile{
+			ObjectMeta: metav1.ObjectMeta{Name: randomString()},
+		}
+		classifier := &libsveltosv1beta1.Classifier{
+			ObjectMeta: metav1.ObjectMeta{Name: randomString()},
+		}
+
+		snapshotInstance.Spec.IncludeKinds = []string{configv1beta1.ClusterProfileKind, configv1beta1.ProfileKind}
+		snapshotInstance.Spec.NamespaceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"team": "platform"}}
+		snapshotInstance.Spec.ProfileSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"team": "platform"}}
+
+		scheme, err := utils.GetScheme()
+		Expect(err).To(BeNil())
+		initObjects := []client.Object{snapshotInstance, selectedNamespace, otherNamespace, selectedProfile,
+			otherNamespaceProfile, notSelectedClusterProfile, classifier}
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initObjects...).Build()
+		utils.InitalizeManagementClusterAcces(scheme, nil, nil, c)
+		collector.InitializeClient(context.TODO(),
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))), c, 10)
+
+		Expect(commands.CollectSnapshotOnDemand(context.TODO(), c, snapshotInstance.Name,
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
+
+		storage := collector.NewContentAddressedStorage(collector.NewFilesystemStorage(snapshotInstance.Spec.Storage))
+		sample, err := collector.GetClient().GetLatestSample(storage, snapshotInstance.Name, collector.Snapshot,
+			collector.SampleTriggerOnDemand, textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
+		Expect(err).To(BeNil())
+		folder := path.Join("snapshot", snapshotInstance.Name, sample)
+
+		files := map[string]bool{
+			path.Join(selectedProfile.Namespace, configv1beta1.ProfileKind, selectedProfile.Name+".yaml"):             true,
+			path.Join(otherNamespaceProfile.Namespace, configv1beta1.ProfileKind, otherNamespaceProfile.Name+".yaml"): false,
+			path.Join(configv1beta1.ClusterProfileKind, notSelectedClusterProfile.Name+".yaml"):                       false,
+			path.Join(libsveltosv1beta1.ClassifierKind, classifier.Name+".yaml"):                                      false,
+		}
+		for file, expected := range files {
+			exist, err := storage.Exists(path.Join(folder, file))
+			Expect(err).To(BeNil())
+			Expect(exist).To(Equal(expected), file)
+		}
+
+		metadata, err := collector.GetClient().GetSampleMetadata(storage, folder)
+		Expect(err).To(BeNil())
+		Expect(metadata.Scope).ToNot(BeNil())
+		Expect(metadata.Scope.Namespaces).To(Equal([]string{selectedNamespace.Name}))
+		Expect(metadata.Scope.IsKindInScope(libsveltosv1beta1.ClassifierKind)).To(BeFalse())
+	})
+
+	It("collectLiveSample collects the live configuration, in scope, without storing it", func() {
+		inScope := &configv1beta1.ClusterProfile{ObjectMeta: metav1.ObjectMeta{Name: randomString()}}
+		classifier := &libsveltosv1beta1.Classifier{ObjectMeta: metav1.ObjectMeta{Name: randomString()}}
+		snapshotInstance.Spec.IncludeKinds = []string{configv1beta1.ClusterProfileKind}
+
+		scheme, err := utils.GetScheme()
+		Expect(err).To(BeNil())
+		initObjects := []client.Object{snapshotInstance, inScope, classifier}
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initObjects...).Build()
+		utils.InitalizeManagementClusterAcces(scheme, nil, nil, c)
+
+		logger := textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1)))
+		folder := path.Join("snapshot", snapshotInstance.Name, "live")
+		storage := collector.NewMemoryStorage()
+		Expect(commands.CollectLiveSample(context.TODO(), snapshotInstance.Name, storage, folder, logger)).To(Succeed())
+
+		clusterProfiles, err := collector.GetClient().GetClusterResources(storage, folder,
+			configv1beta1.ClusterProfileKind, logger)
+		Expect(err).To(BeNil())
+		Expect(clusterProfiles).To(HaveLen(1))
+		Expect(clusterProfiles[0].GetName()).To(Equal(inScope.Name))
+
+		exist, err := storage.Exists(path.Join(folder, libsveltosv1beta1.ClassifierKind))
+		Expect(err).To(BeNil())
+		Expect(exist).To(BeFalse())
+
+		metadata, err := collector.GetClient().GetSampleMetadata(storage, folder)
+		Expect(err).To(BeNil())
+		Expect(metadata.Trigger).To(Equal(collector.SampleTriggerLive))
+		Expect(metadata.Scope.IsKindInScope(libsveltosv1beta1.ClassifierKind)).To(BeFalse())
+
+		entries, err := os.ReadDir(snapshotInstance.Spec.Storage)
+		Expect(err).To(BeNil())
+		Expect(entries).To(BeEmpty())
+	})
+})
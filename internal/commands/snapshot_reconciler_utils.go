@@ -18,19 +18,25 @@ package commands
 
 import (
 	"context"
+	"crypto/ed25519"
 	"fmt"
 	"reflect"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	configv1beta1 "github.com/projectsveltos/addon-controller/api/v1beta1"
+	eventv1beta1 "github.com/projectsveltos/event-manager/api/v1beta1"
 	libsveltosv1beta1 "github.com/projectsveltos/libsveltos/api/v1beta1"
 	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
 	utilsv1beta1 "github.com/projectsveltos/sveltosctl/api/v1beta1"
@@ -78,9 +84,86 @@ func (c *collectionSnapshot) setFailureMessage(m string) {
 	c.snapshotInstance.Status.FailureMessage = &m
 }
 
+// onDemandSample is the sample collected for a trigger annotation value
+type onDemandSample struct {
+	trigger string
+	sample  string
+}
+
+var (
+	onDemandMux = &sync.Mutex{}
+	// onDemandSamples contains, per Snapshot, the sample published for the last trigger served.
+	// It is only kept until the reconciler records trigger and sample in Snapshot status.
+	onDemandSamples = make(map[string]onDemandSample)
+)
+
+// getPendingTrigger returns the trigger annotation value, if an on-demand collection was
+// requested and no sample has been collected for it yet. Returns an empty string otherwise.
+func getPendingTrigger(snapshotInstance *utilsv1beta1.Snapshot) string {
+	trigger := snapshotInstance.Annotations[utilsv1beta1.SnapshotTriggerAnnotation]
+	if trigger == "" {
+		return ""
+	}
+
+	if snapshotInstance.Status.LastTrigger == trigger {
+		return ""
+	}
+
+	onDemandMux.Lock()
+	defer onDemandMux.Unlock()
+	if onDemandSamples[snapshotInstance.Name].trigger == trigger {
+		return ""
+	}
+	return trigger
+}
+
+// setOnDemandSample records the sample collected for trigger
+func setOnDemandSample(snapshotName, trigger, sample string) {
+	onDemandMux.Lock()
+	defer onDemandMux.Unlock()
+	onDemandSamples[snapshotName] = onDemandSample{trigger: trigger, sample: sample}
+}
+
+// getOnDemandSample returns the sample collected for trigger. Returns an empty string
+// if no sample has been collected for it yet.
+func getOnDemandSample(snapshotName, trigger string) string {
+	onDemandMux.Lock()
+	defer onDemandMux.Unlock()
+	if s, ok := onDemandSamples[snapshotName]; ok && s.trigger == trigger {
+		return s.sample
+	}
+	return ""
+}
+
+// forgetOnDemandSample removes what is recorded for a deleted Snapshot
+func forgetOnDemandSample(snapshotName string) {
+	onDemandMux.Lock()
+	defer onDemandMux.Unlock()
+	delete(onDemandSamples, snapshotName)
+}
+
 func collectSnapshot(ctx context.Context, c client.Client, snapshotName string, logger logr.Logger) error {
+	return collectSnapshotWithTrigger(ctx, c, snapshotName, collector.SampleTriggerSchedule, logger)
+}
+
+// collectSnapshotOnDemand collects a sample requested outside the Snapshot schedule
+func collectSnapshotOnDemand(ctx context.Context, c client.Client, snapshotName string, logger logr.Logger) error {
+	return collectSnapshotWithTrigger(ctx, c, snapshotName, collector.SampleTriggerOnDemand, logger)
+}
+
+// getCollectSnapshotOnChange returns the method collecting a sample because resources
+// were modified
+func getCollectSnapshotOnChange() collector.CollectMethod {
+	return func(ctx context.Context, c client.Client, snapshotName string, logger logr.Logger) error {
+		return collectSnapshotWithTrigger(ctx, c, snapshotName, collector.SampleTriggerChange, logger)
+	}
+}
+
+func collectSnapshotWithTrigger(ctx context.Context, c client.Client, snapshotName string,
+	trigger collector.SampleTrigger, logger logr.Logger) error {
+
 	logger = logger.WithValues("snapshot", snapshotName)
-	logger.V(logs.LogInfo).Info("collect snapshot")
+	logger.V(logs.LogInfo).Info(fmt.Sprintf("collect snapshot (%s)", trigger))
 
 	// Get Snapshot instance
 	snapshotInstance := &utilsv1beta1.Snapshot{}
@@ -94,10 +177,26 @@ func collectSnapshot(ctx context.Context, c client.Client, snapshotName string,
 		return err
 	}
 
+	storage, err := collector.NewStorage(ctx, c, snapshotInstance.Spec.Storage, snapshotInstance.Spec.StorageBackend,
+		snapshotInstance.Spec.Encryption)
+	if err != nil {
+		logger.V(logs.LogInfo).Info(fmt.Sprintf("failed to get storage %v", err))
+		return err
+	}
+
+	// Collector runs a single collection per Snapshot at a time, so an on-demand request
+	// arriving while another collection is queued or running is served by that collection
+	// (or by the next one) instead.
+	pendingTrigger := getPendingTrigger(snapshotInstance)
+	if pendingTrigger != "" && trigger == collector.SampleTriggerSchedule {
+		logger.V(logs.LogDebug).Info(fmt.Sprintf("serving on-demand request (trigger %s)", pendingTrigger))
+		trigger = collector.SampleTriggerOnDemand
+	}
+
 	collectorClient := collector.GetClient()
 
 	if snapshotInstance.Spec.SuccessfulSnapshotLimit != nil {
-		err = collectorClient.CleanOldCollections(snapshotInstance.Spec.Storage, snapshotInstance.Name, collector.Snapshot,
+		err = collectorClient.CleanOldCollections(storage, snapshotInstance.Name, collector.Snapshot,
 			*snapshotInstance.Spec.SuccessfulSnapshotLimit, logger)
 		if err != nil {
 			logger.V(logs.LogInfo).Info(fmt.Sprintf("failed to clean %v", err))
@@ -105,53 +204,279 @@ func collectSnapshot(ctx context.Context, c client.Client, snapshotName string,
 		}
 	}
 
-	now := time.Now()
-	folder := collectorClient.GetFolderPath(snapshotInstance.Spec.Storage, snapshotInstance.Name, collector.Snapshot, now)
+	// Changes are taken only once the collection starts. A change-triggered request merged
+	// with a request already queued or in progress leaves them for the next change-triggered
+	// collection.
+	var changes []collector.SampleChange
+	if trigger == collector.SampleTriggerChange {
+		changes = snapshotChanges.takeChanges(snapshotInstance.Name)
+	}
 
-	// Collect all ClusterProfiles
-	err = dumpClusterProfiles(collectorClient, ctx, folder, logger)
+	sample, err := collectSample(ctx, storage, snapshotInstance, trigger, changes, logger)
 	if err != nil {
 		return err
 	}
-	err = dumpProfiles(collectorClient, ctx, folder, logger)
+	if pendingTrigger != "" && trigger == collector.SampleTriggerOnDemand {
+		setOnDemandSample(snapshotInstance.Name, pendingTrigger, sample)
+	}
+
+	if snapshotInstance.Spec.Retention != nil {
+		pruned, err := collectorClient.PruneCollections(storage, snapshotInstance.Name, collector.Snapshot,
+			snapshotInstance.Spec.Retention, time.Now(), false, logger)
+		if err != nil {
+			logger.V(logs.LogInfo).Info(fmt.Sprintf("failed to apply retention %v", err))
+			return err
+		}
+		logger.V(logs.LogDebug).Info(fmt.Sprintf("retention removed %d samples", len(pruned)))
+	}
+
+	// Objects referenced only by removed samples are not needed anymore
+	removed, err := collectorClient.CollectGarbage(storage, snapshotInstance.Name, collector.Snapshot, logger)
 	if err != nil {
+		logger.V(logs.LogInfo).Info(fmt.Sprintf("failed to collect garbage %v", err))
 		return err
 	}
-	err = dumpClusterConfigurations(collectorClient, ctx, folder, logger)
+	logger.V(logs.LogDebug).Info(fmt.Sprintf("removed %d unreferenced objects", removed))
+
+	logger.V(logs.LogInfo).Info("done collecting snapshot")
+
+	return nil
+}
+
+// takeSample synchronously collects a new sample for Snapshot snapshotName.
+// Contrary to scheduled collections, old samples are never cleaned up here: a sample
+// taken before a rollback must not remove the very sample the rollback restores.
+// Returns the name of the new sample.
+func takeSample(ctx context.Context, snapshotName string, trigger collector.SampleTrigger,
+	logger logr.Logger) (string, error) {
+
+	logger = logger.WithValues("snapshot", snapshotName)
+	logger.V(logs.LogDebug).Info(fmt.Sprintf("take %s sample", trigger))
+
+	c := utils.GetAccessInstance().GetClient()
+
+	snapshotInstance := &utilsv1beta1.Snapshot{}
+	err := c.Get(ctx, types.NamespacedName{Name: snapshotName}, snapshotInstance)
 	if err != nil {
-		return err
+		return "", err
 	}
-	err = dumpClusters(collectorClient, ctx, folder, logger)
+
+	storage, err := collector.NewStorage(ctx, c, snapshotInstance.Spec.Storage, snapshotInstance.Spec.StorageBackend,
+		snapshotInstance.Spec.Encryption)
 	if err != nil {
-		return err
+		return "", err
 	}
-	err = dumpClassifiers(collectorClient, ctx, folder, logger)
+
+	return collectSample(ctx, storage, snapshotInstance, trigger, nil, logger)
+}
+
+// cleanupStagingSamples removes, for every Snapshot, the staging folders left behind by
+// collections interrupted before being published. Failures are only logged.
+func cleanupStagingSamples(ctx context.Context, logger logr.Logger) {
+	accessInstance := utils.GetAccessInstance()
+
+	snapshots := &utilsv1beta1.SnapshotList{}
+	if err := accessInstance.ListResources(ctx, snapshots); err != nil {
+		logger.V(logs.LogInfo).Info(fmt.Sprintf("failed to list Snapshots: %v", err))
+		return
+	}
+
+	for i := range snapshots.Items {
+		snapshotInstance := &snapshots.Items[i]
+		l := logger.WithValues("snapshot", snapshotInstance.Name)
+
+		// Removing staging folders does not require decrypting them
+		storage, err := collector.NewStorage(ctx, accessInstance.GetClient(), snapshotInstance.Spec.Storage,
+			snapshotInstance.Spec.StorageBackend, nil)
+		if err != nil {
+			l.V(logs.LogInfo).Info(fmt.Sprintf("failed to get storage: %v", err))
+			continue
+		}
+
+		removed, err := collector.GetClient().CleanupStagingFolders(storage, snapshotInstance.Name,
+			collector.Snapshot, l)
+		if err != nil {
+			l.V(logs.LogInfo).Info(fmt.Sprintf("failed to remove staging folders: %v", err))
+			continue
+		}
+		if len(removed) > 0 {
+			l.V(logs.LogInfo).Info(fmt.Sprintf("removed incomplete samples %v", removed))
+		}
+	}
+}
+
+// collectSample dumps all Sveltos resources (and the ones those reference) in a new
+// sample folder for snapshotInstance and records why the sample was taken (and, for
+// change-triggered samples, the changed resources).
+// Returns the name of the new sample.
+func collectSample(ctx context.Context, storage collector.Storage, snapshotInstance *utilsv1beta1.Snapshot,
+	trigger collector.SampleTrigger, changes []collector.SampleChange, logger logr.Logger) (string, error) {
+
+	collectorClient := collector.GetClient()
+
+	// Resources are written in a staging folder, published only once everything has
+	// been collected. So a failed collection never leaves a partial sample behind.
+	now := time.Now()
+	folder, err := collectorClient.GetStagingFolderPath(snapshotInstance.Name, collector.Snapshot, now)
 	if err != nil {
-		return err
+		return "", err
 	}
-	err = dumpRoleRequests(collectorClient, ctx, folder, logger)
+	published := false
+	defer func() {
+		if !published {
+			if err := storage.RemoveAll(folder); err != nil {
+				logger.V(logs.LogInfo).Info(fmt.Sprintf("failed to remove staging folder %s: %v", folder, err))
+			}
+		}
+	}()
+
+	scope, err := getSampleScope(ctx, &snapshotInstance.Spec, logger)
 	if err != nil {
-		return err
+		return "", err
+	}
+
+	var signingKey ed25519.PrivateKey
+	if snapshotInstance.Spec.Signing != nil {
+		signingKey, err = collector.GetSigningKey(ctx, utils.GetAccessInstance().GetClient(), snapshotInstance.Spec.Signing)
+		if err != nil {
+			return "", err
+		}
 	}
-	err = dumpEventSources(collectorClient, ctx, folder, logger)
+
+	err = dumpSampleObjects(ctx, storage, snapshotInstance, folder, scope, logger)
 	if err != nil {
-		return err
+		return "", err
+	}
+
+	err = collectorClient.WriteSampleMetadata(storage, folder,
+		&collector.SampleMetadata{Trigger: trigger, Scope: scope, Changes: changes})
+	if err != nil {
+		return "", err
+	}
+
+	// Manifest is written once every object has been dumped
+	err = collectorClient.WriteSampleManifest(storage, folder, signingKey)
+	if err != nil {
+		return "", err
+	}
+
+	// Garbage collection must not remove objects this sample shares with previous samples
+	// until the sample is published
+	release, err := collectorClient.AcquirePackLease(storage, snapshotInstance.Name, collector.Snapshot, logger)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	// Objects already stored by previous samples are not stored again
+	err = collectorClient.PackCollection(storage, folder)
+	if err != nil {
+		return "", err
 	}
-	err = dumpEventTriggers(collectorClient, ctx, folder, logger)
+
+	sample, err := collectorClient.PublishCollection(storage, folder)
+	if err != nil {
+		return "", err
+	}
+
+	published = true
+	return sample, nil
+}
+
+// dumpSampleObjects dumps, in folder, all Sveltos resources in scope (and the ones those reference)
+func dumpSampleObjects(ctx context.Context, storage collector.Storage, snapshotInstance *utilsv1beta1.Snapshot,
+	folder string, scope *collector.SampleScope, logger logr.Logger) error {
+
+	collectorClient := collector.GetClient()
+	secretPolicies := snapshotInstance.Spec.SecretPolicies
+	dumpers := []struct {
+		kind string
+		dump func() error
+	}{
+		{kind: configv1beta1.ClusterProfileKind, dump: func() error {
+			return dumpClusterProfiles(collectorClient, ctx, storage, folder, secretPolicies, scope, logger)
+		}},
+		{kind: configv1beta1.ProfileKind, dump: func() error {
+			return dumpProfiles(collectorClient, ctx, storage, folder, secretPolicies, scope, logger)
+		}},
+		{kind: configv1beta1.ClusterConfigurationKind, dump: func() error {
+			return dumpClusterConfigurations(collectorClient, ctx, storage, folder, scope, logger)
+		}},
+		{kind: "Cluster", dump: func() error {
+			return dumpCAPIClusters(collectorClient, ctx, storage, folder, scope, logger)
+		}},
+		{kind: libsveltosv1beta1.SveltosClusterKind, dump: func() error {
+			return dumpSveltosClusters(collectorClient, ctx, storage, folder, scope, logger)
+		}},
+		{kind: libsveltosv1beta1.ClassifierKind, dump: func() error {
+			return dumpClassifiers(collectorClient, ctx, storage, folder, logger)
+		}},
+		{kind: libsveltosv1beta1.RoleRequestKind, dump: func() error {
+			return dumpRoleRequests(collectorClient, ctx, storage, folder, secretPolicies, scope, logger)
+		}},
+		{kind: libsveltosv1beta1.EventSourceKind, dump: func() error {
+			return dumpEventSources(collectorClient, ctx, storage, folder, logger)
+		}},
+		{kind: eventv1beta1.EventTriggerKind, dump: func() error {
+			return dumpEventTriggers(collectorClient, ctx, storage, folder, secretPolicies, scope, logger)
+		}},
+		{kind: libsveltosv1beta1.HealthCheckKind, dump: func() error {
+			return dumpHealthChecks(collectorClient, ctx, storage, folder, logger)
+		}},
+		{kind: libsveltosv1beta1.ClusterHealthCheckKind, dump: func() error {
+			return dumpClusterHealthChecks(collectorClient, ctx, storage, folder, logger)
+		}},
+		{kind: libsveltosv1beta1.ClusterSetKind, dump: func() error {
+			return dumpClusterSets(collectorClient, ctx, storage, folder, logger)
+		}},
+		{kind: libsveltosv1beta1.SetKind, dump: func() error {
+			return dumpSets(collectorClient, ctx, storage, folder, scope, logger)
+		}},
+	}
+
+	for i := range dumpers {
+		if !scope.IsKindInScope(dumpers[i].kind) {
+			logger.V(logs.LogDebug).Info(fmt.Sprintf("%s not in snapshot scope", dumpers[i].kind))
+			continue
+		}
+		if err := dumpers[i].dump(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// collectLiveSample dumps, in folder, the live configuration as a sample of Snapshot snapshotName
+// would contain it. Same scope and Secret policies apply, so the result can be compared with any
+// sample. Nothing is signed, packed or published: storage is expected to be a memory storage.
+func collectLiveSample(ctx context.Context, snapshotName string, storage collector.Storage, folder string,
+	logger logr.Logger) error {
+
+	logger = logger.WithValues("snapshot", snapshotName)
+	logger.V(logs.LogDebug).Info("collect live configuration")
+
+	snapshotInstance := &utilsv1beta1.Snapshot{}
+	err := utils.GetAccessInstance().GetResource(ctx, types.NamespacedName{Name: snapshotName}, snapshotInstance)
 	if err != nil {
 		return err
 	}
-	err = dumpHealthChecks(collectorClient, ctx, folder, logger)
+
+	scope, err := getSampleScope(ctx, &snapshotInstance.Spec, logger)
 	if err != nil {
 		return err
 	}
 
-	logger.V(logs.LogInfo).Info("done collecting snapshot")
+	err = dumpSampleObjects(ctx, storage, snapshotInstance, folder, scope, logger)
+	if err != nil {
+		return err
+	}
 
-	return nil
+	return collector.GetClient().WriteSampleMetadata(storage, folder,
+		&collector.SampleMetadata{Trigger: collector.SampleTriggerLive, Scope: scope})
 }
 
-func dumpHealthChecks(collectorClient *collector.Collector, ctx context.Context, folder string,
+func dumpHealthChecks(collectorClient *collector.Collector, ctx context.Context, storage collector.Storage, folder string,
 	logger logr.Logger) error {
 
 	logger.V(logs.LogDebug).Info("storing HealthChecks")
@@ -162,7 +487,67 @@ func dumpHealthChecks(collectorClient *collector.Collector, ctx context.Context,
 	logger.V(logs.LogDebug).Info(fmt.Sprintf("found %d HealthChecks", len(healthChecks.Items)))
 	for i := range healthChecks.Items {
 		rr := &healthChecks.Items[i]
-		err = collectorClient.DumpObject(rr, folder, logger)
+		err = collectorClient.DumpObject(storage, rr, folder, logger)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func dumpClusterHealthChecks(collectorClient *collector.Collector, ctx context.Context, storage collector.Storage, folder string,
+	logger logr.Logger) error {
+
+	logger.V(logs.LogDebug).Info("storing ClusterHealthChecks")
+	clusterHealthChecks, err := utils.GetAccessInstance().ListClusterHealthChecks(ctx, logger)
+	if err != nil {
+		return err
+	}
+	logger.V(logs.LogDebug).Info(fmt.Sprintf("found %d ClusterHealthChecks", len(clusterHealthChecks.Items)))
+	for i := range clusterHealthChecks.Items {
+		chc := &clusterHealthChecks.Items[i]
+		err = collectorClient.DumpObject(storage, chc, folder, logger)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func dumpClusterSets(collectorClient *collector.Collector, ctx context.Context, storage collector.Storage, folder string,
+	logger logr.Logger) error {
+
+	logger.V(logs.LogDebug).Info("storing ClusterSets")
+	clusterSets, err := utils.GetAccessInstance().ListClusterSets(ctx, logger)
+	if err != nil {
+		return err
+	}
+	logger.V(logs.LogDebug).Info(fmt.Sprintf("found %d ClusterSets", len(clusterSets.Items)))
+	for i := range clusterSets.Items {
+		cs := &clusterSets.Items[i]
+		err = collectorClient.DumpObject(storage, cs, folder, logger)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func dumpSets(collectorClient *collector.Collector, ctx context.Context, storage collector.Storage, folder string,
+	scope *collector.SampleScope, logger logr.Logger) error {
+
+	logger.V(logs.LogDebug).Info("storing Sets")
+	sets, err := utils.GetAccessInstance().ListSets(ctx, "", logger)
+	if err != nil {
+		return err
+	}
+	logger.V(logs.LogDebug).Info(fmt.Sprintf("found %d Sets", len(sets.Items)))
+	for i := range sets.Items {
+		s := &sets.Items[i]
+		_, err = dumpObjectInScope(collectorClient, storage, s, libsveltosv1beta1.SetKind, folder, scope, logger)
 		if err != nil {
 			return err
 		}
@@ -171,7 +556,7 @@ func dumpHealthChecks(collectorClient *collector.Collector, ctx context.Context,
 	return nil
 }
 
-func dumpEventSources(collectorClient *collector.Collector, ctx context.Context, folder string,
+func dumpEventSources(collectorClient *collector.Collector, ctx context.Context, storage collector.Storage, folder string,
 	logger logr.Logger) error {
 
 	logger.V(logs.LogDebug).Info("storing EventSources")
@@ -182,7 +567,7 @@ func dumpEventSources(collectorClient *collector.Collector, ctx context.Context,
 	logger.V(logs.LogDebug).Info(fmt.Sprintf("found %d EventSources", len(eventSources.Items)))
 	for i := range eventSources.Items {
 		rr := &eventSources.Items[i]
-		err = collectorClient.DumpObject(rr, folder, logger)
+		err = collectorClient.DumpObject(storage, rr, folder, logger)
 		if err != nil {
 			return err
 		}
@@ -191,8 +576,8 @@ func dumpEventSources(collectorClient *collector.Collector, ctx context.Context,
 	return nil
 }
 
-func dumpEventTriggers(collectorClient *collector.Collector, ctx context.Context, folder string,
-	logger logr.Logger) error {
+func dumpEventTriggers(collectorClient *collector.Collector, ctx context.Context, storage collector.Storage, folder string,
+	secretPolicies []utilsv1beta1.SecretPolicy, scope *collector.SampleScope, logger logr.Logger) error {
 
 	logger.V(logs.LogDebug).Info("storing EventTriggers")
 	eventTriggers, err := utils.GetAccessInstance().ListEventTriggers(ctx, logger)
@@ -202,14 +587,13 @@ func dumpEventTriggers(collectorClient *collector.Collector, ctx context.Context
 	logger.V(logs.LogDebug).Info(fmt.Sprintf("found %d EventTriggers", len(eventTriggers.Items)))
 	for i := range eventTriggers.Items {
 		r := &eventTriggers.Items[i]
-		err = collectorClient.DumpObject(r, folder, logger)
+		err = collectorClient.DumpObject(storage, r, folder, logger)
 		if err != nil {
 			return err
 		}
 
-		policyRefs := convertConfigPolicyRefsToLibsveltosPolicyRefs(r.Spec.PolicyRefs)
-
-		err = dumpReferencedObjects(collectorClient, ctx, policyRefs, folder, logger)
+		err = dumpReferencedObjects(collectorClient, ctx, storage, getEventTriggerReferences(r, logger),
+			folder, secretPolicies, scope, logger)
 		if err != nil {
 			return err
 		}
@@ -218,8 +602,8 @@ func dumpEventTriggers(collectorClient *collector.Collector, ctx context.Context
 	return nil
 }
 
-func dumpRoleRequests(collectorClient *collector.Collector, ctx context.Context, folder string,
-	logger logr.Logger) error {
+func dumpRoleRequests(collectorClient *collector.Collector, ctx context.Context, storage collector.Storage, folder string,
+	secretPolicies []utilsv1beta1.SecretPolicy, scope *collector.SampleScope, logger logr.Logger) error {
 
 	logger.V(logs.LogDebug).Info("storing RoleRequests")
 	roleRequests, err := utils.GetAccessInstance().ListRoleRequests(ctx, logger)
@@ -229,11 +613,12 @@ func dumpRoleRequests(collectorClient *collector.Collector, ctx context.Context,
 	logger.V(logs.LogDebug).Info(fmt.Sprintf("found %d RoleRequests", len(roleRequests.Items)))
 	for i := range roleRequests.Items {
 		rr := &roleRequests.Items[i]
-		err = collectorClient.DumpObject(rr, folder, logger)
+		err = collectorClient.DumpObject(storage, rr, folder, logger)
 		if err != nil {
 			return err
 		}
-		err = dumpReferencedObjects(collectorClient, ctx, rr.Spec.RoleRefs, folder, logger)
+		err = dumpReferencedObjects(collectorClient, ctx, storage, getRoleRequestReferences(rr, logger),
+			folder, secretPolicies, scope, logger)
 		if err != nil {
 			return err
 		}
@@ -242,7 +627,7 @@ func dumpRoleRequests(collectorClient *collector.Collector, ctx context.Context,
 	return nil
 }
 
-func dumpClassifiers(collectorClient *collector.Collector, ctx context.Context, folder string,
+func dumpClassifiers(collectorClient *collector.Collector, ctx context.Context, storage collector.Storage, folder string,
 	logger logr.Logger) error {
 
 	logger.V(logs.LogDebug).Info("storing Classifiers")
@@ -253,7 +638,7 @@ func dumpClassifiers(collectorClient *collector.Collector, ctx context.Context,
 	logger.V(logs.LogDebug).Info(fmt.Sprintf("found %d Classifiers", len(classifiers.Items)))
 	for i := range classifiers.Items {
 		cl := &classifiers.Items[i]
-		err = collectorClient.DumpObject(cl, folder, logger)
+		err = collectorClient.DumpObject(storage, cl, folder, logger)
 		if err != nil {
 			return err
 		}
@@ -262,8 +647,8 @@ func dumpClassifiers(collectorClient *collector.Collector, ctx context.Context,
 	return nil
 }
 
-func dumpClusterProfiles(collectorClient *collector.Collector, ctx context.Context, folder string,
-	logger logr.Logger) error {
+func dumpClusterProfiles(collectorClient *collector.Collector, ctx context.Context, storage collector.Storage, folder string,
+	secretPolicies []utilsv1beta1.SecretPolicy, scope *collector.SampleScope, logger logr.Logger) error {
 
 	logger.V(logs.LogDebug).Info("storing ClusterProfiles")
 	clusterProfiles, err := utils.GetAccessInstance().ListClusterProfiles(ctx, logger)
@@ -273,14 +658,18 @@ func dumpClusterProfiles(collectorClient *collector.Collector, ctx context.Conte
 	logger.V(logs.LogDebug).Info(fmt.Sprintf("found %d ClusterProfiles", len(clusterProfiles.Items)))
 	for i := range clusterProfiles.Items {
 		cc := &clusterProfiles.Items[i]
-		err = collectorClient.DumpObject(cc, folder, logger)
+		dumped, err := dumpObjectInScope(collectorClient, storage, cc, configv1beta1.ClusterProfileKind,
+			folder, scope, logger)
 		if err != nil {
 			return err
 		}
+		if !dumped {
+			continue
+		}
 
-		policyRefs := convertConfigPolicyRefsToLibsveltosPolicyRefs(cc.Spec.PolicyRefs)
-
-		err = dumpReferencedObjects(collectorClient, ctx, policyRefs, folder, logger)
+		references := getProfileReferences(&cc.Spec,
+			getMatchingClusterNamespaces(cc.Status.MatchingClusterRefs), logger)
+		err = dumpReferencedObjects(collectorClient, ctx, storage, references, folder, secretPolicies, scope, logger)
 		if err != nil {
 			return err
 		}
@@ -289,8 +678,8 @@ func dumpClusterProfiles(collectorClient *collector.Collector, ctx context.Conte
 	return nil
 }
 
-func dumpProfiles(collectorClient *collector.Collector, ctx context.Context, folder string,
-	logger logr.Logger) error {
+func dumpProfiles(collectorClient *collector.Collector, ctx context.Context, storage collector.Storage, folder string,
+	secretPolicies []utilsv1beta1.SecretPolicy, scope *collector.SampleScope, logger logr.Logger) error {
 
 	logger.V(logs.LogDebug).Info("storing Profiles")
 	profiles, err := utils.GetAccessInstance().ListProfiles(ctx, logger)
@@ -300,14 +689,18 @@ func dumpProfiles(collectorClient *collector.Collector, ctx context.Context, fol
 	logger.V(logs.LogDebug).Info(fmt.Sprintf("found %d Profiles", len(profiles.Items)))
 	for i := range profiles.Items {
 		cc := &profiles.Items[i]
-		err = collectorClient.DumpObject(cc, folder, logger)
+		dumped, err := dumpObjectInScope(collectorClient, storage, cc, configv1beta1.ProfileKind,
+			folder, scope, logger)
 		if err != nil {
 			return err
 		}
+		if !dumped {
+			continue
+		}
 
-		policyRefs := convertConfigPolicyRefsToLibsveltosPolicyRefs(cc.Spec.PolicyRefs)
-
-		err = dumpReferencedObjects(collectorClient, ctx, policyRefs, folder, logger)
+		// Profile can only reference resources in its own namespace
+		references := getProfileReferences(&cc.Spec, []string{cc.Namespace}, logger)
+		err = dumpReferencedObjects(collectorClient, ctx, storage, references, folder, secretPolicies, scope, logger)
 		if err != nil {
 			return err
 		}
@@ -316,41 +709,28 @@ func dumpProfiles(collectorClient *collector.Collector, ctx context.Context, fol
 	return nil
 }
 
-func dumpReferencedObjects(collectorClient *collector.Collector, ctx context.Context,
-	referencedObjects []libsveltosv1beta1.PolicyRef, folder string, logger logr.Logger) error {
+func dumpReferencedObjects(collectorClient *collector.Collector, ctx context.Context, storage collector.Storage,
+	referencedObjects []referencedObject, folder string, secretPolicies []utilsv1beta1.SecretPolicy,
+	scope *collector.SampleScope, logger logr.Logger) error {
 
-	logger.V(logs.LogDebug).Info("storing ClusterProfiles's referenced resources")
-	var object client.Object
+	logger.V(logs.LogDebug).Info("storing referenced resources")
 	for i := range referencedObjects {
 		ref := &referencedObjects[i]
-		if ref.Kind == string(libsveltosv1beta1.ConfigMapReferencedResourceKind) {
-			configMap := &corev1.ConfigMap{}
-			err := utils.GetAccessInstance().GetResource(ctx,
-				types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, configMap)
-			if err != nil {
-				if apierrors.IsNotFound(err) {
-					continue
-				}
-				return err
-			}
-			logger.V(logs.LogDebug).Info(fmt.Sprintf("Found referenced ConfigMap %s/%s", configMap.Namespace, configMap.Name))
-			object = configMap
-		} else {
-			// TODO: Allow certain Secret to be skipped
-			secret := &corev1.Secret{}
-			err := utils.GetAccessInstance().GetResource(ctx,
-				types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, secret)
-			if err != nil {
-				if apierrors.IsNotFound(err) {
-					continue
-				}
-				return err
-			}
-			logger.V(logs.LogDebug).Info(fmt.Sprintf("Found referenced Secret %s/%s", secret.Namespace, secret.Name))
-			object = secret
+		if !scope.IsReferencedKindInScope(ref.kind) || !scope.IsNamespaceInScope(ref.namespace) {
+			logger.V(logs.LogDebug).Info(fmt.Sprintf("referenced %s %s/%s not in snapshot scope",
+				ref.kind, ref.namespace, ref.name))
+			continue
 		}
 
-		if err := collectorClient.DumpObject(object, folder, logger); err != nil {
+		object, err := getReferencedObject(ctx, ref, secretPolicies, logger)
+		if err != nil {
+			return err
+		}
+		if object == nil {
+			continue
+		}
+
+		if err := collectorClient.DumpObject(storage, object, folder, logger); err != nil {
 			return err
 		}
 	}
@@ -358,7 +738,65 @@ func dumpReferencedObjects(collectorClient *collector.Collector, ctx context.Con
 	return nil
 }
 
-func dumpClusterConfigurations(collectorClient *collector.Collector, ctx context.Context, folder string, logger logr.Logger) error {
+// getReferencedObject fetches the object referenced by ref. Returns nil if object does not
+// exist or, for Secrets, if secret policies require to skip it.
+func getReferencedObject(ctx context.Context, ref *referencedObject, secretPolicies []utilsv1beta1.SecretPolicy,
+	logger logr.Logger) (client.Object, error) {
+
+	var object client.Object
+	switch ref.kind {
+	case string(libsveltosv1beta1.ConfigMapReferencedResourceKind):
+		object = &corev1.ConfigMap{}
+	case string(libsveltosv1beta1.SecretReferencedResourceKind):
+		object = &corev1.Secret{}
+	default:
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion(ref.apiVersion)
+		u.SetKind(ref.kind)
+		object = u
+	}
+
+	err := utils.GetAccessInstance().GetResource(ctx,
+		types.NamespacedName{Namespace: ref.namespace, Name: ref.name}, object)
+	if err != nil {
+		if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+			logger.V(logs.LogDebug).Info(fmt.Sprintf("referenced %s %s/%s not found", ref.kind, ref.namespace, ref.name))
+			return nil, nil
+		}
+		if apierrors.IsForbidden(err) {
+			// Resources referenced by TemplateResourceRefs can be of any kind
+			logger.V(logs.LogInfo).Info(fmt.Sprintf("not allowed to get referenced %s %s/%s. It is not collected.",
+				ref.kind, ref.namespace, ref.name))
+			return nil, nil
+		}
+		return nil, err
+	}
+	logger.V(logs.LogDebug).Info(fmt.Sprintf("Found referenced %s %s/%s", ref.kind, ref.namespace, ref.name))
+
+	secret, ok := object.(*corev1.Secret)
+	if !ok {
+		return object, nil
+	}
+
+	mode, err := collector.GetSecretMode(secretPolicies, secret)
+	if err != nil {
+		return nil, err
+	}
+	switch mode {
+	case utilsv1beta1.SecretModeSkip:
+		logger.V(logs.LogDebug).Info(fmt.Sprintf("Skipping Secret %s/%s", secret.Namespace, secret.Name))
+		return nil, nil
+	case utilsv1beta1.SecretModeHashed:
+		collector.HashSecret(secret)
+	case utilsv1beta1.SecretModeFull:
+	}
+
+	return secret, nil
+}
+
+func dumpClusterConfigurations(collectorClient *collector.Collector, ctx context.Context, storage collector.Storage, folder string,
+	scope *collector.SampleScope, logger logr.Logger) error {
+
 	logger.V(logs.LogDebug).Info("storing ClusterConfigurations")
 	clusterConfigurations, err := utils.GetAccessInstance().ListClusterConfigurations(ctx, "", logger)
 	if err != nil {
@@ -367,7 +805,7 @@ func dumpClusterConfigurations(collectorClient *collector.Collector, ctx context
 	logger.V(logs.LogDebug).Info(fmt.Sprintf("found %d ClusterConfigurations", len(clusterConfigurations.Items)))
 	for i := range clusterConfigurations.Items {
 		cc := &clusterConfigurations.Items[i]
-		err = collectorClient.DumpObject(cc, folder, logger)
+		_, err = dumpObjectInScope(collectorClient, storage, cc, configv1beta1.ClusterConfigurationKind, folder, scope, logger)
 		if err != nil {
 			return err
 		}
@@ -376,7 +814,9 @@ func dumpClusterConfigurations(collectorClient *collector.Collector, ctx context
 	return nil
 }
 
-func dumpCAPIClusters(collectorClient *collector.Collector, ctx context.Context, folder string, logger logr.Logger) error {
+func dumpCAPIClusters(collectorClient *collector.Collector, ctx context.Context, storage collector.Storage, folder string,
+	scope *collector.SampleScope, logger logr.Logger) error {
+
 	logger.V(logs.LogDebug).Info("storing CAPI Clusters")
 	clusterList := &clusterv1.ClusterList{}
 	err := utils.GetAccessInstance().ListResources(ctx, clusterList)
@@ -386,7 +826,7 @@ func dumpCAPIClusters(collectorClient *collector.Collector, ctx context.Context,
 	logger.V(logs.LogDebug).Info(fmt.Sprintf("found %d Clusters", len(clusterList.Items)))
 	for i := range clusterList.Items {
 		cc := &clusterList.Items[i]
-		err = collectorClient.DumpObject(cc, folder, logger)
+		_, err = dumpObjectInScope(collectorClient, storage, cc, "Cluster", folder, scope, logger)
 		if err != nil {
 			return err
 		}
@@ -395,7 +835,9 @@ func dumpCAPIClusters(collectorClient *collector.Collector, ctx context.Context,
 	return nil
 }
 
-func dumpSveltosClusters(collectorClient *collector.Collector, ctx context.Context, folder string, logger logr.Logger) error {
+func dumpSveltosClusters(collectorClient *collector.Collector, ctx context.Context, storage collector.Storage, folder string,
+	scope *collector.SampleScope, logger logr.Logger) error {
+
 	logger.V(logs.LogDebug).Info("storing Sveltos Clusters")
 	clusterList := &libsveltosv1beta1.SveltosClusterList{}
 	err := utils.GetAccessInstance().ListResources(ctx, clusterList)
@@ -405,7 +847,7 @@ func dumpSveltosClusters(collectorClient *collector.Collector, ctx context.Conte
 	logger.V(logs.LogDebug).Info(fmt.Sprintf("found %d Clusters", len(clusterList.Items)))
 	for i := range clusterList.Items {
 		cc := &clusterList.Items[i]
-		err = collectorClient.DumpObject(cc, folder, logger)
+		_, err = dumpObjectInScope(collectorClient, storage, cc, libsveltosv1beta1.SveltosClusterKind, folder, scope, logger)
 		if err != nil {
 			return err
 		}
@@ -414,37 +856,72 @@ func dumpSveltosClusters(collectorClient *collector.Collector, ctx context.Conte
 	return nil
 }
 
-func dumpClusters(collectorClient *collector.Collector, ctx context.Context, folder string, logger logr.Logger) error {
-	if err := dumpCAPIClusters(collectorClient, ctx, folder, logger); err != nil {
-		return err
+// dumpObjectInScope stores object, an instance of kind, if it is in scope.
+// Returns true if object was stored.
+func dumpObjectInScope(collectorClient *collector.Collector, storage collector.Storage, object client.Object,
+	kind, folder string, scope *collector.SampleScope, logger logr.Logger) (bool, error) {
+
+	inScope, err := scope.IsObjectInScope(kind, object)
+	if err != nil {
+		return false, err
+	}
+	if !inScope {
+		logger.V(logs.LogDebug).Info(fmt.Sprintf("%s %s/%s not in snapshot scope",
+			kind, object.GetNamespace(), object.GetName()))
+		return false, nil
 	}
 
-	if err := dumpSveltosClusters(collectorClient, ctx, folder, logger); err != nil {
-		return err
+	return true, collectorClient.DumpObject(storage, object, folder, logger)
+}
+
+// getSampleScope returns the scope of samples collected for a Snapshot with given spec.
+// Returns nil if samples contain every resource.
+func getSampleScope(ctx context.Context, spec *utilsv1beta1.SnapshotSpec, logger logr.Logger,
+) (*collector.SampleScope, error) {
+
+	if len(spec.IncludeKinds) == 0 && len(spec.ExcludeKinds) == 0 &&
+		spec.NamespaceSelector == nil && spec.ProfileSelector == nil {
+
+		return nil, nil
 	}
 
-	return nil
+	scope := &collector.SampleScope{
+		IncludeKinds:      spec.IncludeKinds,
+		ExcludeKinds:      spec.ExcludeKinds,
+		NamespaceSelector: spec.NamespaceSelector,
+		ProfileSelector:   spec.ProfileSelector,
+	}
+
+	if spec.NamespaceSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(spec.NamespaceSelector)
+		if err != nil {
+			return nil, err
+		}
+		namespaces := &corev1.NamespaceList{}
+		err = utils.GetAccessInstance().ListResources(ctx, namespaces,
+			client.MatchingLabelsSelector{Selector: selector})
+		if err != nil {
+			return nil, err
+		}
+		scope.Namespaces = make([]string, len(namespaces.Items))
+		for i := range namespaces.Items {
+			scope.Namespaces[i] = namespaces.Items[i].Name
+		}
+		sort.Strings(scope.Namespaces)
+		logger.V(logs.LogDebug).Info(fmt.Sprintf("namespaces in snapshot scope: %v", scope.Namespaces))
+	}
+
+	return scope, nil
 }
 
 func updateSnaphotPredicate(newObject, oldObject *utilsv1beta1.Snapshot) bool {
 	if oldObject == nil ||
-		!reflect.DeepEqual(newObject.Spec, oldObject.Spec) {
+		!reflect.DeepEqual(newObject.Spec, oldObject.Spec) ||
+		newObject.Annotations[utilsv1beta1.SnapshotTriggerAnnotation] !=
+			oldObject.Annotations[utilsv1beta1.SnapshotTriggerAnnotation] {
 
 		return true
 	}
 
 	return false
 }
-
-func convertConfigPolicyRefsToLibsveltosPolicyRefs(input []configv1beta1.PolicyRef) []libsveltosv1beta1.PolicyRef {
-	policyRefs := make([]libsveltosv1beta1.PolicyRef, len(input))
-
-	for i := range input {
-		policyRefs[i] = libsveltosv1beta1.PolicyRef{
-			Kind:      input[i].Kind,
-			Namespace: input[i].Namespace,
-			Name:      input[i].Name,
-		}
-	}
-	return policyRefs
-}
@@ -0,0 +1,208 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+
+	configv1beta1 "github.com/projectsveltos/addon-controller/api/v1beta1"
+	eventv1beta1 "github.com/projectsveltos/event-manager/api/v1beta1"
+	libsveltosv1beta1 "github.com/projectsveltos/libsveltos/api/v1beta1"
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+)
+
+// Flux sources can be referenced by PolicyRefs and KustomizationRefs.
+// Versions are the ones addon-controller watches.
+var fluxSourceAPIVersions = map[string]string{
+	"GitRepository": "source.toolkit.fluxcd.io/v1",
+	"OCIRepository": "source.toolkit.fluxcd.io/v1beta2",
+	"Bucket":        "source.toolkit.fluxcd.io/v1beta2",
+}
+
+// referencedObject is an object in the management cluster referenced by a
+// ClusterProfile/Profile, EventTrigger or RoleRequest
+type referencedObject struct {
+	apiVersion string
+	kind       string
+	namespace  string
+	name       string
+}
+
+// referencesBuilder collects, without duplicates, all objects referenced by an instance
+type referencesBuilder struct {
+	// defaultNamespaces are used for references with no namespace.
+	// For a Profile, that is the Profile namespace. Otherwise, empty namespace means
+	// the namespace of each matching cluster.
+	defaultNamespaces []string
+
+	references []referencedObject
+	seen       map[referencedObject]bool
+	logger     logr.Logger
+}
+
+func newReferencesBuilder(defaultNamespaces []string, logger logr.Logger) *referencesBuilder {
+	return &referencesBuilder{
+		defaultNamespaces: defaultNamespaces,
+		references:        make([]referencedObject, 0),
+		seen:              make(map[referencedObject]bool),
+		logger:            logger,
+	}
+}
+
+// getMatchingClusterNamespaces returns the namespaces of clusters in clusterRefs
+func getMatchingClusterNamespaces(clusterRefs ...[]corev1.ObjectReference) []string {
+	namespaces := make([]string, 0)
+	seen := make(map[string]bool)
+	for i := range clusterRefs {
+		for j := range clusterRefs[i] {
+			ns := clusterRefs[i][j].Namespace
+			if !seen[ns] {
+				seen[ns] = true
+				namespaces = append(namespaces, ns)
+			}
+		}
+	}
+
+	return namespaces
+}
+
+// add adds a reference. References whose namespace or name are templates, instantiated
+// only at deployment time for each cluster, cannot be resolved and are ignored.
+func (b *referencesBuilder) add(apiVersion, kind, namespace, name string) {
+	if strings.Contains(namespace, "{{") || strings.Contains(name, "{{") {
+		b.logger.V(logs.LogDebug).Info(fmt.Sprintf("ignoring templated reference %s %s/%s", kind, namespace, name))
+		return
+	}
+
+	if apiVersion == "" {
+		apiVersion = getReferencedKindAPIVersion(kind)
+	}
+
+	namespaces := []string{namespace}
+	if namespace == "" {
+		namespaces = b.defaultNamespaces
+	}
+
+	for i := range namespaces {
+		ref := referencedObject{apiVersion: apiVersion, kind: kind, namespace: namespaces[i], name: name}
+		if b.seen[ref] {
+			continue
+		}
+		b.seen[ref] = true
+		b.references = append(b.references, ref)
+	}
+}
+
+func getReferencedKindAPIVersion(kind string) string {
+	if v, ok := fluxSourceAPIVersions[kind]; ok {
+		return v
+	}
+	// ConfigMaps and Secrets
+	return "v1"
+}
+
+func (b *referencesBuilder) addPolicyRefs(policyRefs []configv1beta1.PolicyRef) {
+	for i := range policyRefs {
+		b.add("", policyRefs[i].Kind, policyRefs[i].Namespace, policyRefs[i].Name)
+	}
+}
+
+func (b *referencesBuilder) addValuesFrom(valuesFrom []configv1beta1.ValueFrom) {
+	for i := range valuesFrom {
+		b.add("", valuesFrom[i].Kind, valuesFrom[i].Namespace, valuesFrom[i].Name)
+	}
+}
+
+func (b *referencesBuilder) addHelmCharts(helmCharts []configv1beta1.HelmChart) {
+	for i := range helmCharts {
+		b.addValuesFrom(helmCharts[i].ValuesFrom)
+
+		credentials := helmCharts[i].RegistryCredentialsConfig
+		if credentials == nil {
+			continue
+		}
+		for _, secretRef := range []*corev1.SecretReference{credentials.CredentialsSecretRef, credentials.CASecretRef} {
+			if secretRef != nil {
+				b.add("", string(libsveltosv1beta1.SecretReferencedResourceKind), secretRef.Namespace, secretRef.Name)
+			}
+		}
+	}
+}
+
+func (b *referencesBuilder) addKustomizationRefs(kustomizationRefs []configv1beta1.KustomizationRef) {
+	for i := range kustomizationRefs {
+		b.add("", kustomizationRefs[i].Kind, kustomizationRefs[i].Namespace, kustomizationRefs[i].Name)
+		b.addValuesFrom(kustomizationRefs[i].ValuesFrom)
+	}
+}
+
+func (b *referencesBuilder) addTemplateResourceRefs(templateResourceRefs []configv1beta1.TemplateResourceRef) {
+	for i := range templateResourceRefs {
+		resource := &templateResourceRefs[i].Resource
+		b.add(resource.APIVersion, resource.Kind, resource.Namespace, resource.Name)
+	}
+}
+
+// getProfileReferences returns all objects referenced by a ClusterProfile/Profile
+func getProfileReferences(spec *configv1beta1.Spec, defaultNamespaces []string, logger logr.Logger,
+) []referencedObject {
+
+	b := newReferencesBuilder(defaultNamespaces, logger)
+	b.addPolicyRefs(spec.PolicyRefs)
+	b.addHelmCharts(spec.HelmCharts)
+	b.addKustomizationRefs(spec.KustomizationRefs)
+	b.addTemplateResourceRefs(spec.TemplateResourceRefs)
+
+	return b.references
+}
+
+// getEventTriggerReferences returns all objects referenced by an EventTrigger
+func getEventTriggerReferences(eventTrigger *eventv1beta1.EventTrigger, logger logr.Logger) []referencedObject {
+	b := newReferencesBuilder(getMatchingClusterNamespaces(eventTrigger.Status.MatchingClusterRefs,
+		eventTrigger.Status.DestinationMatchingClusterRefs), logger)
+
+	spec := &eventTrigger.Spec
+	b.addPolicyRefs(spec.PolicyRefs)
+	b.addHelmCharts(spec.HelmCharts)
+	b.addKustomizationRefs(spec.KustomizationRefs)
+	b.addTemplateResourceRefs(spec.TemplateResourceRefs)
+	for i := range spec.ConfigMapGenerator {
+		b.add("", string(libsveltosv1beta1.ConfigMapReferencedResourceKind),
+			spec.ConfigMapGenerator[i].Namespace, spec.ConfigMapGenerator[i].Name)
+	}
+	for i := range spec.SecretGenerator {
+		b.add("", string(libsveltosv1beta1.SecretReferencedResourceKind),
+			spec.SecretGenerator[i].Namespace, spec.SecretGenerator[i].Name)
+	}
+
+	return b.references
+}
+
+// getRoleRequestReferences returns all objects referenced by a RoleRequest
+func getRoleRequestReferences(roleRequest *libsveltosv1beta1.RoleRequest, logger logr.Logger) []referencedObject {
+	b := newReferencesBuilder(getMatchingClusterNamespaces(roleRequest.Status.MatchingClusterRefs), logger)
+	for i := range roleRequest.Spec.RoleRefs {
+		ref := &roleRequest.Spec.RoleRefs[i]
+		b.add("", ref.Kind, ref.Namespace, ref.Name)
+	}
+
+	return b.references
+}
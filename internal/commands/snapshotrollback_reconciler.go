@@ -0,0 +1,337 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2/textlogger"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+	utilsv1beta1 "github.com/projectsveltos/sveltosctl/api/v1beta1"
+	"github.com/projectsveltos/sveltosctl/internal/commands/snapshot"
+	"github.com/projectsveltos/sveltosctl/internal/utils"
+)
+
+// SnapshotRollbackReconciler executes a SnapshotRollback. Each SnapshotRollback is
+// executed once: once completed or failed, it is never reconciled again.
+func SnapshotRollbackReconciler(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	logger := textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1)))
+	logger.V(logs.LogInfo).Info("Reconciling")
+
+	accessInstance := utils.GetAccessInstance()
+
+	rollbackInstance := &utilsv1beta1.SnapshotRollback{}
+	if err := accessInstance.GetResource(ctx, req.NamespacedName, rollbackInstance); err != nil {
+		logger.Error(err, "unable to fetch SnapshotRollback")
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	logger = logger.WithValues("snapshotrollback", rollbackInstance.Name)
+
+	if !rollbackInstance.DeletionTimestamp.IsZero() || isSnapshotRollbackDone(rollbackInstance) {
+		logger.V(logs.LogDebug).Info("nothing to do")
+		return ctrl.Result{}, nil
+	}
+
+	// A SnapshotRollback found in Running phase was interrupted (for instance pod restarted)
+	// and might have modified part of the configuration already. It is never executed again:
+	// a new pre-rollback sample would capture the half rolled back configuration and prune
+	// would run a second time.
+	if isSnapshotRollbackRunning(rollbackInstance) {
+		logger.V(logs.LogInfo).Info("rollback was interrupted")
+		markSnapshotRollbackInterrupted(rollbackInstance)
+		err := accessInstance.UpdateResourceStatus(ctx, rollbackInstance)
+		if err != nil {
+			logger.V(logs.LogInfo).Info(fmt.Sprintf("failed to update status. Err: %v", err))
+		}
+		return ctrl.Result{}, err
+	}
+
+	phase := utilsv1beta1.SnapshotRollbackPhaseRunning
+	startTime := metav1.Now()
+	rollbackInstance.Status = utilsv1beta1.SnapshotRollbackStatus{
+		Phase:     &phase,
+		StartTime: &startTime,
+	}
+	err := accessInstance.UpdateResourceStatus(ctx, rollbackInstance)
+	if err != nil {
+		logger.V(logs.LogInfo).Info(fmt.Sprintf("failed to update status. Err: %v", err))
+		return ctrl.Result{}, err
+	}
+
+	result, err := executeSnapshotRollback(ctx, &rollbackInstance.Spec, logger)
+	updateSnapshotRollbackStatus(rollbackInstance, result, err)
+
+	logger.V(logs.LogInfo).Info(fmt.Sprintf("rollback %s", *rollbackInstance.Status.Phase))
+	err = accessInstance.UpdateResourceStatus(ctx, rollbackInstance)
+	if err != nil {
+		logger.V(logs.LogInfo).Info(fmt.Sprintf("failed to update status. Err: %v", err))
+		// Rollback was executed. Record its outcome without results rather than
+		// leaving the phase to Running, which would execute it again.
+		return ctrl.Result{}, recordSnapshotRollbackOutcome(ctx, req, &rollbackInstance.Status, err, logger)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func executeSnapshotRollback(ctx context.Context, spec *utilsv1beta1.SnapshotRollbackSpec, logger logr.Logger,
+) (*snapshot.RollbackResult, error) {
+
+	ignoreRules, err := snapshot.NewIgnoreRules(spec.IgnoreFields, spec.SkipDefaultIgnoreRules)
+	if err != nil {
+		return nil, err
+	}
+
+	options := &snapshot.RollbackOptions{
+		DryRun:           spec.DryRun,
+		Prune:            spec.Prune,
+		SkipConfirmation: true,
+		TakeSample:       takeSample,
+		Force:            spec.Force,
+		IgnoreRules:      ignoreRules,
+	}
+	return snapshot.ExecuteRollback(ctx, spec.SnapshotName, spec.Sample, getSnapshotRollbackFilters(spec),
+		options, logger)
+}
+
+// recordSnapshotRollbackOutcome records phase and completion time of an executed rollback
+// whose status, results included, could not be updated because of statusErr
+func recordSnapshotRollbackOutcome(ctx context.Context, req reconcile.Request,
+	status *utilsv1beta1.SnapshotRollbackStatus, statusErr error, logger logr.Logger) error {
+
+	accessInstance := utils.GetAccessInstance()
+
+	rollbackInstance := &utilsv1beta1.SnapshotRollback{}
+	if err := accessInstance.GetResource(ctx, req.NamespacedName, rollbackInstance); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	rollbackInstance.Status = *status
+	rollbackInstance.Status.UnreportedObjects += int32(len(status.Results))
+	rollbackInstance.Status.Results = nil
+
+	message := truncateSnapshotRollbackValue(fmt.Sprintf("failed to record results: %v", statusErr))
+	if status.FailureMessage != nil {
+		message = fmt.Sprintf("%s; %s", truncateSnapshotRollbackValue(*status.FailureMessage), message)
+	}
+	rollbackInstance.Status.FailureMessage = &message
+
+	err := accessInstance.UpdateResourceStatus(ctx, rollbackInstance)
+	if err != nil {
+		logger.V(logs.LogInfo).Info(fmt.Sprintf("failed to update status. Err: %v", err))
+		return err
+	}
+
+	return nil
+}
+
+func isSnapshotRollbackDone(rollbackInstance *utilsv1beta1.SnapshotRollback) bool {
+	phase := rollbackInstance.Status.Phase
+	return phase != nil &&
+		(*phase == utilsv1beta1.SnapshotRollbackPhaseCompleted || *phase == utilsv1beta1.SnapshotRollbackPhaseFailed)
+}
+
+func isSnapshotRollbackRunning(rollbackInstance *utilsv1beta1.SnapshotRollback) bool {
+	phase := rollbackInstance.Status.Phase
+	return phase != nil && *phase == utilsv1beta1.SnapshotRollbackPhaseRunning
+}
+
+// markSnapshotRollbackInterrupted marks a rollback found in Running phase as failed.
+// Results of the interrupted execution are unknown.
+func markSnapshotRollbackInterrupted(rollbackInstance *utilsv1beta1.SnapshotRollback) {
+	phase := utilsv1beta1.SnapshotRollbackPhaseFailed
+	message := "interrupted"
+	completionTime := metav1.Now()
+	rollbackInstance.Status.Phase = &phase
+	rollbackInstance.Status.FailureMessage = &message
+	rollbackInstance.Status.CompletionTime = &completionTime
+}
+
+func getSnapshotRollbackFilters(spec *utilsv1beta1.SnapshotRollbackSpec) *snapshot.RollbackFilters {
+	return &snapshot.RollbackFilters{
+		Namespace:          spec.Namespace,
+		Cluster:            spec.Cluster,
+		Profile:            spec.Profile,
+		Classifier:         spec.Classifier,
+		RoleRequest:        spec.RoleRequest,
+		EventSource:        spec.EventSource,
+		EventTrigger:       spec.EventTrigger,
+		HealthCheck:        spec.HealthCheck,
+		ClusterHealthCheck: spec.ClusterHealthCheck,
+		ClusterSet:         spec.ClusterSet,
+		Set:                spec.Set,
+	}
+}
+
+// updateSnapshotRollbackStatus records the outcome of the rollback. result might
+// be nil if rollback failed before processing any object.
+func updateSnapshotRollbackStatus(rollbackInstance *utilsv1beta1.SnapshotRollback,
+	result *snapshot.RollbackResult, rollbackErr error) {
+
+	phase := utilsv1beta1.SnapshotRollbackPhaseCompleted
+	if rollbackErr != nil {
+		phase = utilsv1beta1.SnapshotRollbackPhaseFailed
+		message := rollbackErr.Error()
+		rollbackInstance.Status.FailureMessage = &message
+	}
+	completionTime := metav1.Now()
+	rollbackInstance.Status.Phase = &phase
+	rollbackInstance.Status.CompletionTime = &completionTime
+
+	if result == nil {
+		return
+	}
+
+	rollbackInstance.Status.PreRollbackSample = result.PreRollbackSample
+
+	// Status must fit in a single object: unchanged objects are only counted and at most
+	// MaxSnapshotRollbackResults objects are reported
+	rollbackInstance.Status.Results = make([]utilsv1beta1.SnapshotRollbackObjectResult, 0)
+	for i := range result.Entries {
+		switch {
+		case result.Entries[i].Action == snapshot.RollbackActionUnchanged:
+			rollbackInstance.Status.UnchangedObjects++
+		case len(rollbackInstance.Status.Results) == utilsv1beta1.MaxSnapshotRollbackResults:
+			rollbackInstance.Status.UnreportedObjects++
+		default:
+			rollbackInstance.Status.Results = append(rollbackInstance.Status.Results,
+				getSnapshotRollbackObjectResult(&result.Entries[i]))
+		}
+	}
+}
+
+// getSnapshotRollbackObjectResult converts a rollback entry to a SnapshotRollback result.
+// At most MaxSnapshotRollbackChanges changes are reported and long values are truncated.
+// Secret values are redacted by rollback already.
+func getSnapshotRollbackObjectResult(entry *snapshot.RollbackPlanEntry) utilsv1beta1.SnapshotRollbackObjectResult {
+	result := utilsv1beta1.SnapshotRollbackObjectResult{
+		Kind:      entry.Kind,
+		Namespace: entry.Namespace,
+		Name:      entry.Name,
+		Action:    string(entry.Action),
+		Message:   entry.Message,
+	}
+
+	changes := entry.Changes
+	if len(changes) > utilsv1beta1.MaxSnapshotRollbackChanges {
+		dropped := fmt.Sprintf("%d more changes not reported", len(changes)-utilsv1beta1.MaxSnapshotRollbackChanges)
+		if result.Message == "" {
+			result.Message = dropped
+		} else {
+			result.Message = fmt.Sprintf("%s (%s)", result.Message, dropped)
+		}
+		changes = changes[:utilsv1beta1.MaxSnapshotRollbackChanges]
+	}
+
+	for i := range changes {
+		from, to := changes[i].Values()
+		result.Changes = append(result.Changes, utilsv1beta1.SnapshotRollbackFieldChange{
+			Path: changes[i].Path,
+			From: truncateSnapshotRollbackValue(from),
+			To:   truncateSnapshotRollbackValue(to),
+		})
+	}
+
+	return result
+}
+
+// truncateSnapshotRollbackValue keeps SnapshotRollback status small when large
+// fields (for instance helm values) change
+func truncateSnapshotRollbackValue(value string) string {
+	const maxLength = 256
+	if len(value) <= maxLength {
+		return value
+	}
+	return value[:maxLength] + "..."
+}
+
+func startSnapshotRollbackReconciler(ctx context.Context, mgr manager.Manager, logger logr.Logger) error {
+	// Create an un-managed controller
+	c, err := controller.NewUnmanaged("snapshotrollback-watcher", controller.Options{
+		Reconciler:              reconcile.Func(SnapshotRollbackReconciler),
+		MaxConcurrentReconciles: 1,
+	})
+
+	if err != nil {
+		logger.Error(err, "unable to create snapshotrollback watcher")
+		return err
+	}
+
+	sourceSnapshotRollback := source.Kind[*utilsv1beta1.SnapshotRollback](
+		mgr.GetCache(),
+		&utilsv1beta1.SnapshotRollback{},
+		handler.TypedEnqueueRequestsFromMapFunc(handlerSnapshotRollbackMapFun),
+		SnapshotRollbackPredicate{Logger: mgr.GetLogger().WithValues("predicate", "snapshotrollbackpredicate")},
+	)
+
+	if err := c.Watch(sourceSnapshotRollback); err != nil {
+		return err
+	}
+
+	// Start controller in a goroutine so not to block.
+	go func() {
+		logger.Info("Starting snapshotrollback watcher controller")
+		if err := c.Start(ctx); err != nil {
+			logger.Error(err, "cannot run controller")
+			panic(1)
+		}
+	}()
+
+	return nil
+}
+
+func handlerSnapshotRollbackMapFun(ctx context.Context, rollback *utilsv1beta1.SnapshotRollback) []reconcile.Request {
+	return handlerMapFun(rollback)
+}
+
+// SnapshotRollbackPredicate reacts only to SnapshotRollback creation.
+// Spec is immutable, so updates are only status changes.
+type SnapshotRollbackPredicate struct {
+	Logger logr.Logger
+}
+
+func (p SnapshotRollbackPredicate) Create(obj event.TypedCreateEvent[*utilsv1beta1.SnapshotRollback]) bool {
+	o := obj.Object
+	p.Logger.Info(fmt.Sprintf("Create kind: %s Info: %s/%s",
+		o.GetObjectKind().GroupVersionKind().Kind,
+		o.GetNamespace(), o.GetName()))
+	return true
+}
+
+func (p SnapshotRollbackPredicate) Update(obj event.TypedUpdateEvent[*utilsv1beta1.SnapshotRollback]) bool {
+	return false
+}
+
+func (p SnapshotRollbackPredicate) Delete(obj event.TypedDeleteEvent[*utilsv1beta1.SnapshotRollback]) bool {
+	return false
+}
+
+func (p SnapshotRollbackPredicate) Generic(obj event.TypedGenericEvent[*utilsv1beta1.SnapshotRollback]) bool {
+	return false
+}
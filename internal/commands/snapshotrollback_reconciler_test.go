@@ -0,0 +1,464 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2/textlogger"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	configv1beta1 "github.com/projectsveltos/addon-controller/api/v1beta1"
+	utilsv1beta1 "github.com/projectsveltos/sveltosctl/api/v1beta1"
+	"github.com/projectsveltos/sveltosctl/internal/collector"
+	"github.com/projectsveltos/sveltosctl/internal/commands"
+	"github.com/projectsveltos/sveltosctl/internal/commands/snapshot"
+	"github.com/projectsveltos/sveltosctl/internal/utils"
+)
+
+const (
+	timeFormat = "2006-01-02:15:04:05"
+)
+
+var _ = Describe("SnapshotRollback Reconciler", func() {
+	var snapshotInstance *utilsv1beta1.Snapshot
+	var sampleClusterProfile *configv1beta1.ClusterProfile
+	var newClusterProfile *configv1beta1.ClusterProfile
+	var sample string
+
+	BeforeEach(func() {
+		storageDir, err := os.MkdirTemp("", randomString())
+		Expect(err).To(BeNil())
+
+		snapshotInstance = &utilsv1beta1.Snapshot{
+			ObjectMeta: metav1.ObjectMeta{Name: randomString()},
+			Spec: utilsv1beta1.SnapshotSpec{
+				Schedule: "0 * * * *",
+				Storage:  storageDir,
+			},
+		}
+
+		sampleClusterProfile = &configv1beta1.ClusterProfile{
+			ObjectMeta: metav1.ObjectMeta{Name: randomString()},
+		}
+		newClusterProfile = &configv1beta1.ClusterProfile{
+			ObjectMeta: metav1.ObjectMeta{Name: randomString()},
+		}
+
+		// Sample is in the past, so it never collides with the pre-rollback sample
+		sample = time.Now().Add(-time.Hour).Format(timeFormat)
+		sampleFolder := path.Join("snapshot", snapshotInstance.Name, sample)
+		Expect(collector.GetClient().DumpObject(collector.NewFilesystemStorage(storageDir),
+			sampleClusterProfile.DeepCopy(), sampleFolder,
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
+		Expect(collector.GetClient().WriteSampleManifest(collector.NewFilesystemStorage(storageDir),
+			sampleFolder, nil)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(snapshotInstance.Spec.Storage)
+	})
+
+	It("SnapshotRollbackReconciler in DryRun mode reports results without modifying anything", func() {
+		rollback := &utilsv1beta1.SnapshotRollback{
+			ObjectMeta: metav1.ObjectMeta{Name: randomString()},
+			Spec: utilsv1beta1.SnapshotRollbackSpec{
+				SnapshotName: snapshotInstance.Name,
+				Sample:       sample,
+				DryRun:       true,
+			},
+		}
+
+		c := initializeSnapshotRollbackClient(snapshotInstance, rollback)
+
+		_, err := commands.SnapshotRollbackReconciler(context.TODO(),
+			reconcile.Request{NamespacedName: types.NamespacedName{Name: rollback.Name}})
+		Expect(err).To(BeNil())
+
+		currentRollback := &utilsv1beta1.SnapshotRollback{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: rollback.Name}, currentRollback)).To(Succeed())
+		Expect(currentRollback.Status.Phase).ToNot(BeNil())
+		Expect(*currentRollback.Status.Phase).To(Equal(utilsv1beta1.SnapshotRollbackPhaseCompleted))
+		Expect(currentRollback.Status.StartTime).ToNot(BeNil())
+		Expect(currentRollback.Status.CompletionTime).ToNot(BeNil())
+		Expect(currentRollback.Status.PreRollbackSample).To(BeEmpty())
+		Expect(currentRollback.Status.Results).To(ContainElement(utilsv1beta1.SnapshotRollbackObjectResult{
+			Kind: configv1beta1.ClusterProfileKind, Name: sampleClusterProfile.Name, Action: "create",
+		}))
+
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: sampleClusterProfile.Name},
+			&configv1beta1.ClusterProfile{})).ToNot(Succeed())
+	})
+
+	It("SnapshotRollbackReconciler rolls back, prunes and takes a pre-rollback sample", func() {
+		rollback := &utilsv1beta1.SnapshotRollback{
+			ObjectMeta: metav1.ObjectMeta{Name: randomString()},
+			Spec: utilsv1beta1.SnapshotRollbackSpec{
+				SnapshotName: snapshotInstance.Name,
+				Sample:       sample,
+				Prune:        true,
+			},
+		}
+
+		c := initializeSnapshotRollbackClient(snapshotInstance, rollback, newClusterProfile)
+
+		_, err := commands.SnapshotRollbackReconciler(context.TODO(),
+			reconcile.Request{NamespacedName: types.NamespacedName{Name: rollback.Name}})
+		Expect(err).To(BeNil())
+
+		currentRollback := &utilsv1beta1.SnapshotRollback{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: rollback.Name}, currentRollback)).To(Succeed())
+		Expect(*currentRollback.Status.Phase).To(Equal(utilsv1beta1.SnapshotRollbackPhaseCompleted))
+		Expect(currentRollback.Status.FailureMessage).To(BeNil())
+		Expect(currentRollback.Status.Results).To(ContainElements(
+			utilsv1beta1.SnapshotRollbackObjectResult{
+				Kind: configv1beta1.ClusterProfileKind, Name: sampleClusterProfile.Name, Action: "create",
+			},
+			utilsv1beta1.SnapshotRollbackObjectResult{
+				Kind: configv1beta1.ClusterProfileKind, Name: newClusterProfile.Name, Action: "delete",
+			},
+		))
+
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: sampleClusterProfile.Name},
+			&configv1beta1.ClusterProfile{})).To(Succeed())
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: newClusterProfile.Name},
+			&configv1beta1.ClusterProfile{})).ToNot(Succeed())
+
+		// Pre-rollback sample contains the ClusterProfile deleted by prune
+		Expect(currentRollback.Status.PreRollbackSample).ToNot(BeEmpty())
+		storage := collector.NewContentAddressedStorage(collector.NewFilesystemStorage(snapshotInstance.Spec.Storage))
+		preRollbackFolder := path.Join("snapshot", snapshotInstance.Name, currentRollback.Status.PreRollbackSample)
+		metadata, err := collector.GetClient().GetSampleMetadata(storage, preRollbackFolder)
+		Expect(err).To(BeNil())
+		Expect(metadata.Trigger).To(Equal(collector.SampleTriggerPreRollback))
+		Expect(storage.Exists(path.Join(preRollbackFolder, configv1beta1.ClusterProfileKind,
+			newClusterProfile.Name+".yaml"))).To(BeTrue())
+
+		// Once completed, SnapshotRollback is not executed again
+		Expect(c.Create(context.TODO(), &configv1beta1.ClusterProfile{
+			ObjectMeta: metav1.ObjectMeta{Name: newClusterProfile.Name},
+		})).To(Succeed())
+		_, err = commands.SnapshotRollbackReconciler(context.TODO(),
+			reconcile.Request{NamespacedName: types.NamespacedName{Name: rollback.Name}})
+		Expect(err).To(BeNil())
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: newClusterProfile.Name},
+			&configv1beta1.ClusterProfile{})).To(Succeed())
+	})
+
+	It("SnapshotRollbackReconciler in DryRun mode reports field level changes", func() {
+		// ClusterProfile clusterSelector was modified since sample was taken
+		matchLabels := make(map[string]string)
+		currentMatchLabels := make(map[string]string)
+		for i := 0; i < utilsv1beta1.MaxSnapshotRollbackChanges+5; i++ {
+			matchLabels[fmt.Sprintf("label%02d", i)] = randomString()
+			currentMatchLabels[fmt.Sprintf("label%02d", i)] = randomString()
+		}
+		sampleClusterProfile.Spec.ClusterSelector.MatchLabels = matchLabels
+		currentClusterProfile := sampleClusterProfile.DeepCopy()
+		currentClusterProfile.Spec.ClusterSelector.MatchLabels = currentMatchLabels
+
+		sample = time.Now().Add(-2 * time.Hour).Format(timeFormat)
+		storage := collector.NewFilesystemStorage(snapshotInstance.Spec.Storage)
+		sampleFolder := path.Join("snapshot", snapshotInstance.Name, sample)
+		Expect(collector.GetClient().DumpObject(storage, sampleClusterProfile.DeepCopy(), sampleFolder,
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
+		Expect(collector.GetClient().WriteSampleManifest(storage, sampleFolder, nil)).To(Succeed())
+
+		rollback := &utilsv1beta1.SnapshotRollback{
+			ObjectMeta: metav1.ObjectMeta{Name: randomString()},
+			Spec: utilsv1beta1.SnapshotRollbackSpec{
+				SnapshotName: snapshotInstance.Name,
+				Sample:       sample,
+				DryRun:       true,
+			},
+		}
+
+		c := initializeSnapshotRollbackClient(snapshotInstance, rollback, currentClusterProfile)
+
+		_, err := commands.SnapshotRollbackReconciler(context.TODO(),
+			reconcile.Request{NamespacedName: types.NamespacedName{Name: rollback.Name}})
+		Expect(err).To(BeNil())
+
+		currentRollback := &utilsv1beta1.SnapshotRollback{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: rollback.Name}, currentRollback)).To(Succeed())
+		Expect(*currentRollback.Status.Phase).To(Equal(utilsv1beta1.SnapshotRollbackPhaseCompleted))
+
+		var result *utilsv1beta1.SnapshotRollbackObjectResult
+		for i := range currentRollback.Status.Results {
+			if currentRollback.Status.Results[i].Name == sampleClusterProfile.Name {
+				result = &currentRollback.Status.Results[i]
+			}
+		}
+		Expect(result).ToNot(BeNil())
+		Expect(result.Action).To(Equal("update"))
+		Expect(result.Changes).To(HaveLen(utilsv1beta1.MaxSnapshotRollbackChanges))
+		Expect(result.Changes[0]).To(Equal(utilsv1beta1.SnapshotRollbackFieldChange{
+			Path: "spec.clusterSelector.matchLabels.label00",
+			From: currentMatchLabels["label00"], To: matchLabels["label00"],
+		}))
+		Expect(result.Message).To(Equal("5 more changes not reported"))
+	})
+
+	It("SnapshotRollbackReconciler applies ignore rules", func() {
+		// ClusterProfile differs from the sample only by an ignored annotation
+		currentClusterProfile := sampleClusterProfile.DeepCopy()
+		currentClusterProfile.Annotations = map[string]string{"example.com/build": randomString()}
+
+		rollback := &utilsv1beta1.SnapshotRollback{
+			ObjectMeta: metav1.ObjectMeta{Name: randomString()},
+			Spec: utilsv1beta1.SnapshotRollbackSpec{
+				SnapshotName: snapshotInstance.Name,
+				Sample:       sample,
+				DryRun:       true,
+				IgnoreFields: []string{`ClusterProfile:metadata.annotations["example.com/build"]`},
+			},
+		}
+
+		c := initializeSnapshotRollbackClient(snapshotInstance, rollback, currentClusterProfile)
+
+		_, err := commands.SnapshotRollbackReconciler(context.TODO(),
+			reconcile.Request{NamespacedName: types.NamespacedName{Name: rollback.Name}})
+		Expect(err).To(BeNil())
+
+		currentRollback := &utilsv1beta1.SnapshotRollback{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: rollback.Name}, currentRollback)).To(Succeed())
+		Expect(*currentRollback.Status.Phase).To(Equal(utilsv1beta1.SnapshotRollbackPhaseCompleted))
+		Expect(currentRollback.Status.Results).To(BeEmpty())
+		Expect(currentRollback.Status.UnchangedObjects).To(Equal(int32(1)))
+
+		// An invalid field path fails the rollback
+		rollback = &utilsv1beta1.SnapshotRollback{
+			ObjectMeta: metav1.ObjectMeta{Name: randomString()},
+			Spec: utilsv1beta1.SnapshotRollbackSpec{
+				SnapshotName: snapshotInstance.Name,
+				Sample:       sample,
+				DryRun:       true,
+				IgnoreFields: []string{"metadata.annotations["},
+			},
+		}
+		Expect(c.Create(context.TODO(), rollback)).To(Succeed())
+
+		_, err = commands.SnapshotRollbackReconciler(context.TODO(),
+			reconcile.Request{NamespacedName: types.NamespacedName{Name: rollback.Name}})
+		Expect(err).To(BeNil())
+
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: rollback.Name}, currentRollback)).To(Succeed())
+		Expect(*currentRollback.Status.Phase).To(Equal(utilsv1beta1.SnapshotRollbackPhaseFailed))
+		Expect(*currentRollback.Status.FailureMessage).To(ContainSubstring("invalid field path"))
+	})
+
+	It("SnapshotRollbackReconciler reports which object failed and why", func() {
+		rollback := &utilsv1beta1.SnapshotRollback{
+			ObjectMeta: metav1.ObjectMeta{Name: randomString()},
+			Spec: utilsv1beta1.SnapshotRollbackSpec{
+				SnapshotName: snapshotInstance.Name,
+				Sample:       sample,
+			},
+		}
+
+		funcs := interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				if obj.GetName() == sampleClusterProfile.Name {
+					return fmt.Errorf("admission webhook denied the request")
+				}
+				return c.Create(ctx, obj, opts...)
+			},
+		}
+		c := initializeSnapshotRollbackClientWithInterceptor(snapshotInstance, rollback, funcs)
+
+		_, err := commands.SnapshotRollbackReconciler(context.TODO(),
+			reconcile.Request{NamespacedName: types.NamespacedName{Name: rollback.Name}})
+		Expect(err).To(BeNil())
+
+		currentRollback := &utilsv1beta1.SnapshotRollback{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: rollback.Name}, currentRollback)).To(Succeed())
+		Expect(*currentRollback.Status.Phase).To(Equal(utilsv1beta1.SnapshotRollbackPhaseFailed))
+		Expect(currentRollback.Status.Results).To(ContainElement(utilsv1beta1.SnapshotRollbackObjectResult{
+			Kind: configv1beta1.ClusterProfileKind, Name: sampleClusterProfile.Name, Action: "failed",
+			Message: "create failed: admission webhook denied the request",
+		}))
+	})
+
+	It("updateSnapshotRollbackStatus counts unchanged objects and caps reported results", func() {
+		result := &snapshot.RollbackResult{}
+		const unchanged = 30
+		for i := 0; i < unchanged; i++ {
+			result.Entries = append(result.Entries, snapshot.RollbackPlanEntry{
+				Kind: configv1beta1.ClusterProfileKind, Name: randomString(), Action: snapshot.RollbackActionUnchanged,
+			})
+		}
+		const updated = utilsv1beta1.MaxSnapshotRollbackResults + 5
+		for i := 0; i < updated; i++ {
+			result.Entries = append(result.Entries, snapshot.RollbackPlanEntry{
+				Kind: configv1beta1.ClusterProfileKind, Name: randomString(), Action: snapshot.RollbackActionUpdate,
+			})
+		}
+
+		rollback := &utilsv1beta1.SnapshotRollback{}
+		commands.UpdateSnapshotRollbackStatus(rollback, result, nil)
+		Expect(*rollback.Status.Phase).To(Equal(utilsv1beta1.SnapshotRollbackPhaseCompleted))
+		Expect(rollback.Status.Results).To(HaveLen(utilsv1beta1.MaxSnapshotRollbackResults))
+		for i := range rollback.Status.Results {
+			Expect(rollback.Status.Results[i].Action).To(Equal(string(snapshot.RollbackActionUpdate)))
+		}
+		Expect(rollback.Status.UnchangedObjects).To(Equal(int32(unchanged)))
+		Expect(rollback.Status.UnreportedObjects).To(Equal(int32(updated - utilsv1beta1.MaxSnapshotRollbackResults)))
+	})
+
+	It("SnapshotRollbackReconciler is not executed again when results cannot be recorded", func() {
+		rollback := &utilsv1beta1.SnapshotRollback{
+			ObjectMeta: metav1.ObjectMeta{Name: randomString()},
+			Spec: utilsv1beta1.SnapshotRollbackSpec{
+				SnapshotName: snapshotInstance.Name,
+				Sample:       sample,
+			},
+		}
+
+		funcs := interceptor.Funcs{
+			SubResourceUpdate: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object,
+				opts ...client.SubResourceUpdateOption) error {
+
+				if len(obj.(*utilsv1beta1.SnapshotRollback).Status.Results) > 0 {
+					return fmt.Errorf("request is too large")
+				}
+				return c.SubResource(subResourceName).Update(ctx, obj, opts...)
+			},
+		}
+		c := initializeSnapshotRollbackClientWithInterceptor(snapshotInstance, rollback, funcs)
+
+		_, err := commands.SnapshotRollbackReconciler(context.TODO(),
+			reconcile.Request{NamespacedName: types.NamespacedName{Name: rollback.Name}})
+		Expect(err).To(BeNil())
+
+		currentRollback := &utilsv1beta1.SnapshotRollback{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: rollback.Name}, currentRollback)).To(Succeed())
+		Expect(*currentRollback.Status.Phase).To(Equal(utilsv1beta1.SnapshotRollbackPhaseCompleted))
+		Expect(currentRollback.Status.CompletionTime).ToNot(BeNil())
+		Expect(currentRollback.Status.Results).To(BeEmpty())
+		Expect(currentRollback.Status.UnreportedObjects).To(Equal(int32(1)))
+		Expect(currentRollback.Status.FailureMessage).ToNot(BeNil())
+		Expect(*currentRollback.Status.FailureMessage).To(ContainSubstring("request is too large"))
+
+		// Rollback was executed
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: sampleClusterProfile.Name},
+			&configv1beta1.ClusterProfile{})).To(Succeed())
+	})
+
+	It("SnapshotRollbackReconciler marks an interrupted rollback as failed without executing it again", func() {
+		phase := utilsv1beta1.SnapshotRollbackPhaseRunning
+		startTime := metav1.Now()
+		rollback := &utilsv1beta1.SnapshotRollback{
+			ObjectMeta: metav1.ObjectMeta{Name: randomString()},
+			Spec: utilsv1beta1.SnapshotRollbackSpec{
+				SnapshotName: snapshotInstance.Name,
+				Sample:       sample,
+				Prune:        true,
+			},
+			Status: utilsv1beta1.SnapshotRollbackStatus{
+				Phase:     &phase,
+				StartTime: &startTime,
+			},
+		}
+
+		c := initializeSnapshotRollbackClient(snapshotInstance, rollback, newClusterProfile)
+
+		_, err := commands.SnapshotRollbackReconciler(context.TODO(),
+			reconcile.Request{NamespacedName: types.NamespacedName{Name: rollback.Name}})
+		Expect(err).To(BeNil())
+
+		currentRollback := &utilsv1beta1.SnapshotRollback{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: rollback.Name}, currentRollback)).To(Succeed())
+		Expect(*currentRollback.Status.Phase).To(Equal(utilsv1beta1.SnapshotRollbackPhaseFailed))
+		Expect(currentRollback.Status.FailureMessage).ToNot(BeNil())
+		Expect(*currentRollback.Status.FailureMessage).To(Equal("interrupted"))
+		Expect(currentRollback.Status.CompletionTime).ToNot(BeNil())
+		Expect(currentRollback.Status.PreRollbackSample).To(BeEmpty())
+
+		// Neither rollback nor prune were executed and no pre-rollback sample was taken
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: sampleClusterProfile.Name},
+			&configv1beta1.ClusterProfile{})).ToNot(Succeed())
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: newClusterProfile.Name},
+			&configv1beta1.ClusterProfile{})).To(Succeed())
+		entries, err := os.ReadDir(path.Join(snapshotInstance.Spec.Storage, "snapshot", snapshotInstance.Name))
+		Expect(err).To(BeNil())
+		Expect(entries).To(HaveLen(1))
+	})
+
+	It("SnapshotRollbackReconciler reports failures", func() {
+		rollback := &utilsv1beta1.SnapshotRollback{
+			ObjectMeta: metav1.ObjectMeta{Name: randomString()},
+			Spec: utilsv1beta1.SnapshotRollbackSpec{
+				SnapshotName: snapshotInstance.Name,
+				Sample:       randomString(),
+			},
+		}
+
+		c := initializeSnapshotRollbackClient(snapshotInstance, rollback)
+
+		_, err := commands.SnapshotRollbackReconciler(context.TODO(),
+			reconcile.Request{NamespacedName: types.NamespacedName{Name: rollback.Name}})
+		Expect(err).To(BeNil())
+
+		currentRollback := &utilsv1beta1.SnapshotRollback{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: rollback.Name}, currentRollback)).To(Succeed())
+		Expect(*currentRollback.Status.Phase).To(Equal(utilsv1beta1.SnapshotRollbackPhaseFailed))
+		Expect(currentRollback.Status.FailureMessage).ToNot(BeNil())
+		Expect(currentRollback.Status.CompletionTime).ToNot(BeNil())
+	})
+})
+
+func initializeSnapshotRollbackClient(snapshotInstance *utilsv1beta1.Snapshot,
+	rollback *utilsv1beta1.SnapshotRollback, objects ...client.Object) client.Client {
+
+	return initializeSnapshotRollbackClientWithInterceptor(snapshotInstance, rollback, interceptor.Funcs{},
+		objects...)
+}
+
+func initializeSnapshotRollbackClientWithInterceptor(snapshotInstance *utilsv1beta1.Snapshot,
+	rollback *utilsv1beta1.SnapshotRollback, funcs interceptor.Funcs, objects ...client.Object) client.Client {
+
+	scheme, err := utils.GetScheme()
+	Expect(err).To(BeNil())
+
+	initObjects := append([]client.Object{snapshotInstance, rollback}, objects...)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initObjects...).
+		WithStatusSubresource(&utilsv1beta1.SnapshotRollback{}).WithInterceptorFuncs(funcs).Build()
+
+	utils.InitalizeManagementClusterAcces(scheme, nil, nil, c)
+	collector.InitializeClient(context.TODO(),
+		textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))), c, 10)
+
+	return c
+}
+
+func randomString() string {
+	const length = 10
+	return util.RandomString(length)
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	docopt "github.com/docopt/docopt-go"
+	"github.com/go-logr/logr"
+
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+	"github.com/projectsveltos/sveltosctl/internal/commands/techsupport"
+)
+
+// TechSupport collects logs and Sveltos resources in a single archive
+func TechSupport(ctx context.Context, args []string, logger logr.Logger) error {
+	doc := `Usage:
+  sveltosctl techsupport --output=<file> [--since=<duration>] [--verbose]
+
+     --output=<file>          Path of the gzip compressed tar archive to create (for instance bundle.tar.gz).
+     --since=<duration>       (Optional) Only collect logs newer than a relative duration like 5s, 2m, or 3h.
+                              If not set, all logs are collected.
+
+Options:
+  -h --help                  Show this screen.
+     --verbose               Verbose mode. Print each step.
+
+Description:
+  The techsupport command collects, in a single archive that can be attached to support tickets:
+  - logs of every pod in the projectsveltos namespace, including logs of previous containers;
+  - all Sveltos CRD instances (ClusterProfiles, ClusterSummaries, ClusterReports, SveltosClusters, etc.);
+  - all Events in the projectsveltos namespace.
+`
+	parsedArgs, err := docopt.ParseArgs(doc, nil, "1.0")
+	if err != nil {
+		logger.V(logs.LogInfo).Error(err, "failed to parse args")
+		return fmt.Errorf(
+			"invalid option: 'sveltosctl %s'. Use flag '--help' to read about a specific subcommand. Error: %w",
+			strings.Join(args, " "),
+			err,
+		)
+	}
+	if len(parsedArgs) == 0 {
+		return nil
+	}
+
+	_ = flag.Lookup("v").Value.Set(fmt.Sprint(logs.LogInfo))
+	verbose := parsedArgs["--verbose"].(bool)
+	if verbose {
+		err = flag.Lookup("v").Value.Set(fmt.Sprint(logs.LogDebug))
+		if err != nil {
+			return err
+		}
+	}
+
+	output := parsedArgs["--output"].(string)
+
+	var since *time.Duration
+	if passedSince := parsedArgs["--since"]; passedSince != nil {
+		since, err = parseSince(passedSince.(string))
+		if err != nil {
+			return err
+		}
+	}
+
+	return techsupport.Collect(ctx, output, since, logger)
+}
+
+// parseSince parses the --since value. Only positive durations are valid.
+func parseSince(value string) (*time.Duration, error) {
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid since value %q: %w", value, err)
+	}
+	if duration <= 0 {
+		return nil, fmt.Errorf("invalid since value %q: must be positive", value)
+	}
+	return &duration, nil
+}
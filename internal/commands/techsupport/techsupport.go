@@ -0,0 +1,215 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package techsupport
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+	"github.com/projectsveltos/sveltosctl/internal/collector"
+	"github.com/projectsveltos/sveltosctl/internal/utils"
+)
+
+const (
+	// sveltosNamespace is the namespace where Sveltos controllers run
+	sveltosNamespace = "projectsveltos"
+
+	// sveltosGroupSuffix is the suffix of all Sveltos API groups
+	sveltosGroupSuffix = ".projectsveltos.io"
+
+	// Within the bundle, logs are stored in logs/<namespace>/<pod>-<container>
+	// and resources (including Events) in resources/<namespace>/<kind>/<name>.yaml
+	resourcesFolder = "resources"
+
+	bundleTimeFormat = "2006-01-02-15-04-05"
+)
+
+// Collect creates a gzip compressed tar archive, output, containing:
+// - logs of all pods running in the projectsveltos namespace (previous containers included);
+// - all instances of Sveltos CRDs;
+// - all Events in the projectsveltos namespace.
+// If since is set, only logs more recent than since are collected.
+func Collect(ctx context.Context, output string, since *time.Duration, logger logr.Logger) error {
+	tmpDir, err := os.MkdirTemp("", "sveltosctl-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	folder := filepath.Join(tmpDir, "techsupport-"+time.Now().Format(bundleTimeFormat))
+
+	instance := utils.GetAccessInstance()
+	err = collectTechSupport(ctx, instance.GetClient(), instance.GetClientSet(), folder, getSinceSeconds(since), logger)
+	if err != nil {
+		return err
+	}
+
+	collectorClient := collector.GetClient()
+	err = collectorClient.TarDir(folder, output, logger)
+	if err != nil {
+		return err
+	}
+
+	//nolint: forbidigo // print success message
+	fmt.Printf("techsupport bundle stored in %s\n", output)
+	return nil
+}
+
+// getSinceSeconds converts since to the seconds passed to the API server when fetching logs.
+// Sub-second values are rounded up, as the API server rejects SinceSeconds lower than 1.
+func getSinceSeconds(since *time.Duration) *int64 {
+	if since == nil {
+		return nil
+	}
+	seconds := int64(math.Ceil(since.Seconds()))
+	return &seconds
+}
+
+// collectTechSupport stores in folder all information collected for techsupport
+func collectTechSupport(ctx context.Context, c client.Client, clientSet kubernetes.Interface,
+	folder string, since *int64, logger logr.Logger) error {
+
+	err := dumpPodLogs(ctx, c, clientSet, folder, since, logger)
+	if err != nil {
+		return err
+	}
+
+	err = dumpSveltosResources(ctx, c, folder, logger)
+	if err != nil {
+		return err
+	}
+
+	return dumpEvents(ctx, c, folder, logger)
+}
+
+// dumpPodLogs collects logs of all pods in the projectsveltos namespace.
+// Failing to collect logs for a pod is reported but does not stop the collection.
+func dumpPodLogs(ctx context.Context, c client.Client, clientSet kubernetes.Interface,
+	folder string, since *int64, logger logr.Logger) error {
+
+	pods := &corev1.PodList{}
+	err := c.List(ctx, pods, client.InNamespace(sveltosNamespace))
+	if err != nil {
+		logger.V(logs.LogInfo).Info(fmt.Sprintf("failed to list pods in namespace %s: %v", sveltosNamespace, err))
+		return err
+	}
+
+	collectorClient := collector.GetClient()
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		logger.V(logs.LogDebug).Info(fmt.Sprintf("collecting logs for pod %s/%s", pod.Namespace, pod.Name))
+		err = collectorClient.DumpPodLogs(ctx, clientSet, folder, since, pod)
+		if err != nil {
+			logger.V(logs.LogInfo).Info(fmt.Sprintf("failed to collect logs for pod %s/%s: %v",
+				pod.Namespace, pod.Name, err))
+		}
+	}
+
+	return nil
+}
+
+// dumpSveltosResources stores all instances of all Sveltos CRDs.
+// Failing to list instances of a CRD is reported but does not stop the collection.
+func dumpSveltosResources(ctx context.Context, c client.Client, folder string, logger logr.Logger) error {
+	crds := &apiextensionsv1.CustomResourceDefinitionList{}
+	err := c.List(ctx, crds)
+	if err != nil {
+		logger.V(logs.LogInfo).Info(fmt.Sprintf("failed to list CustomResourceDefinitions: %v", err))
+		return err
+	}
+
+	storage := collector.NewFilesystemStorage(folder)
+	for i := range crds.Items {
+		crd := &crds.Items[i]
+		if !strings.HasSuffix(crd.Spec.Group, sveltosGroupSuffix) {
+			continue
+		}
+
+		version := getStorageVersion(crd)
+		if version == "" {
+			continue
+		}
+
+		gvk := schema.GroupVersionKind{Group: crd.Spec.Group, Version: version, Kind: crd.Spec.Names.ListKind}
+		err = dumpResources(ctx, c, storage, gvk, nil, logger)
+		if err != nil {
+			logger.V(logs.LogInfo).Info(fmt.Sprintf("failed to collect %s: %v", crd.Spec.Names.Kind, err))
+		}
+	}
+
+	return nil
+}
+
+// dumpEvents stores all Events in the projectsveltos namespace
+func dumpEvents(ctx context.Context, c client.Client, folder string, logger logr.Logger) error {
+	storage := collector.NewFilesystemStorage(folder)
+	gvk := corev1.SchemeGroupVersion.WithKind("EventList")
+	err := dumpResources(ctx, c, storage, gvk, []client.ListOption{client.InNamespace(sveltosNamespace)}, logger)
+	if err != nil {
+		logger.V(logs.LogInfo).Info(fmt.Sprintf("failed to collect Events: %v", err))
+		return err
+	}
+
+	return nil
+}
+
+// dumpResources lists all resources of the list kind gvk and stores them
+func dumpResources(ctx context.Context, c client.Client, storage collector.Storage,
+	gvk schema.GroupVersionKind, opts []client.ListOption, logger logr.Logger) error {
+
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(gvk)
+	err := c.List(ctx, list, opts...)
+	if err != nil {
+		return err
+	}
+
+	collectorClient := collector.GetClient()
+	for i := range list.Items {
+		err = collectorClient.DumpObject(storage, &list.Items[i], resourcesFolder, logger)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getStorageVersion returns the version used to persist CRD instances
+func getStorageVersion(crd *apiextensionsv1.CustomResourceDefinition) string {
+	for i := range crd.Spec.Versions {
+		if crd.Spec.Versions[i].Storage {
+			return crd.Spec.Versions[i].Name
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package techsupport_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/klog/v2/textlogger"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	configv1beta1 "github.com/projectsveltos/addon-controller/api/v1beta1"
+	"github.com/projectsveltos/sveltosctl/internal/commands/techsupport"
+	"github.com/projectsveltos/sveltosctl/internal/utils"
+)
+
+var _ = Describe("TechSupport", func() {
+	It("collectTechSupport collects pod logs, Sveltos resources and Events", func() {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: techsupport.SveltosNamespace,
+				Name:      randomString(),
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: "manager"},
+				},
+			},
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{
+					{Name: "manager", RestartCount: 1},
+				},
+			},
+		}
+
+		clusterProfile := &configv1beta1.ClusterProfile{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: randomString(),
+			},
+		}
+
+		event := &corev1.Event{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: techsupport.SveltosNamespace,
+				Name:      randomString(),
+			},
+			Reason: randomString(),
+		}
+
+		// Events outside projectsveltos namespace are not collected
+		otherEvent := &corev1.Event{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: randomString(),
+				Name:      randomString(),
+			},
+		}
+
+		initObjects := []client.Object{pod, clusterProfile, event, otherEvent,
+			getCRD(configv1beta1.GroupVersion.Group, configv1beta1.ClusterProfileKind),
+			getCRD("example.com", "Foo"),
+		}
+
+		scheme, err := utils.GetScheme()
+		Expect(err).To(BeNil())
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initObjects...).Build()
+		clientSet := fakeclientset.NewClientset(pod)
+
+		folder, err := os.MkdirTemp("", randomString())
+		Expect(err).To(BeNil())
+		defer os.RemoveAll(folder)
+
+		Expect(techsupport.CollectTechSupport(context.TODO(), c, clientSet, folder, nil,
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))).To(Succeed())
+
+		By("verifying logs, including previous container logs, are collected")
+		logFile := filepath.Join(folder, "logs", pod.Namespace, pod.Name+"-manager")
+		Expect(logFile).To(BeAnExistingFile())
+		Expect(logFile + ".previous").To(BeAnExistingFile())
+
+		By("verifying Sveltos resources are collected")
+		Expect(filepath.Join(folder, techsupport.ResourcesFolder, configv1beta1.ClusterProfileKind,
+			clusterProfile.Name+".yaml")).To(BeAnExistingFile())
+		Expect(filepath.Join(folder, techsupport.ResourcesFolder, "Foo")).ToNot(BeADirectory())
+
+		By("verifying Events in projectsveltos namespace are collected")
+		Expect(filepath.Join(folder, techsupport.ResourcesFolder, event.Namespace, "Event",
+			event.Name+".yaml")).To(BeAnExistingFile())
+		Expect(filepath.Join(folder, techsupport.ResourcesFolder, otherEvent.Namespace)).ToNot(BeADirectory())
+	})
+
+	It("getSinceSeconds rounds sub-second durations up", func() {
+		Expect(techsupport.GetSinceSeconds(nil)).To(BeNil())
+
+		since := 500 * time.Millisecond
+		Expect(*techsupport.GetSinceSeconds(&since)).To(Equal(int64(1)))
+
+		since = 90*time.Second + time.Millisecond
+		Expect(*techsupport.GetSinceSeconds(&since)).To(Equal(int64(91)))
+
+		since = time.Hour
+		Expect(*techsupport.GetSinceSeconds(&since)).To(Equal(int64(3600)))
+	})
+})
+
+func getCRD(group, kind string) *apiextensionsv1.CustomResourceDefinition {
+	return &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: randomString(),
+		},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group: group,
+			Names: apiextensionsv1.CustomResourceDefinitionNames{
+				Kind:     kind,
+				ListKind: kind + "List",
+			},
+			Scope: apiextensionsv1.ClusterScoped,
+			Versions: []apiextensionsv1.CustomResourceDefinitionVersion{
+				{Name: "v1beta1", Served: true, Storage: true},
+			},
+		},
+	}
+}
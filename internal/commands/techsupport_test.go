@@ -0,0 +1,43 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectsveltos/sveltosctl/internal/commands"
+)
+
+var _ = Describe("TechSupport", func() {
+	It("parseSince accepts only positive durations", func() {
+		since, err := commands.ParseSince("1h")
+		Expect(err).To(BeNil())
+		Expect(*since).To(Equal(time.Hour))
+
+		since, err = commands.ParseSince("500ms")
+		Expect(err).To(BeNil())
+		Expect(*since).To(Equal(500 * time.Millisecond))
+
+		for _, value := range []string{"0s", "-1h", "one hour"} {
+			_, err = commands.ParseSince(value)
+			Expect(err).ToNot(BeNil())
+		}
+	})
+})
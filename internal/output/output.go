@@ -0,0 +1,125 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// APIVersion is the version of the structured (json/yaml) output.
+// Fields can be added to entries within the same version. Fields are never
+// removed or renamed without bumping this version.
+const APIVersion = "sveltosctl.projectsveltos.io/v1alpha1"
+
+// Format is the format used to display command results
+type Format string
+
+const (
+	// Table renders results as an ASCII table. This is the default.
+	Table = Format("table")
+
+	// Wide renders results as an ASCII table with additional columns.
+	Wide = Format("wide")
+
+	// JSON renders results as a versioned JSON document
+	JSON = Format("json")
+
+	// YAML renders results as a versioned YAML document
+	YAML = Format("yaml")
+
+	// Patch renders results as a unified diff series. It is not accepted by ParseFormat:
+	// only commands comparing files (snapshot diff) support it.
+	Patch = Format("patch")
+)
+
+// defaultFormat is the format used by commands whose --output flag is not passed
+var defaultFormat = Table
+
+// SetDefaultFormat sets the format used by commands whose --output flag is not passed.
+// It is set by the global sveltosctl --output flag.
+func SetDefaultFormat(format Format) {
+	defaultFormat = format
+}
+
+// DefaultFormat returns the format used by commands whose --output flag is not passed
+func DefaultFormat() Format {
+	return defaultFormat
+}
+
+// List is the versioned envelope used for json and yaml output
+type List struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Items      any    `json:"items"`
+}
+
+// ParseFormat validates value and returns the corresponding Format.
+// An empty value is treated as Table.
+func ParseFormat(value string) (Format, error) {
+	switch f := Format(strings.ToLower(value)); f {
+	case "":
+		return Table, nil
+	case Table, Wide, JSON, YAML:
+		return f, nil
+	default:
+		return "", fmt.Errorf("invalid output format %q. Possible values are: %s, %s, %s, %s",
+			value, Table, Wide, JSON, YAML)
+	}
+}
+
+// IsStructured returns true if format is json or yaml
+func (f Format) IsStructured() bool {
+	return f == JSON || f == YAML
+}
+
+// IsWide returns true if table needs to include additional columns
+func (f Format) IsWide() bool {
+	return f == Wide
+}
+
+// PrintList wraps items in a versioned List of given kind and writes it to w
+// in json or yaml format.
+func PrintList(w io.Writer, format Format, kind string, items any) error {
+	list := &List{
+		APIVersion: APIVersion,
+		Kind:       kind,
+		Items:      items,
+	}
+
+	var data []byte
+	var err error
+	switch format {
+	case JSON:
+		data, err = json.MarshalIndent(list, "", "  ")
+		data = append(data, '\n')
+	case YAML:
+		data, err = yaml.Marshal(list)
+	default:
+		return fmt.Errorf("format %q is not a structured format", format)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+	return err
+}
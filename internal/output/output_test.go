@@ -0,0 +1,98 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package output_test
+
+import (
+	"bytes"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/projectsveltos/sveltosctl/internal/output"
+)
+
+type entry struct {
+	Cluster string `json:"cluster"`
+	Name    string `json:"name"`
+}
+
+var _ = Describe("Output", func() {
+	AfterEach(func() {
+		output.SetDefaultFormat(output.Table)
+	})
+
+	It("ParseFormat accepts known formats case insensitively and rejects others", func() {
+		for value, expected := range map[string]output.Format{
+			"": output.Table, "table": output.Table, "WIDE": output.Wide,
+			"json": output.JSON, "Yaml": output.YAML,
+		} {
+			format, err := output.ParseFormat(value)
+			Expect(err).To(BeNil())
+			Expect(format).To(Equal(expected))
+		}
+
+		for _, value := range []string{"xml", "patch", "jsonpath"} {
+			_, err := output.ParseFormat(value)
+			Expect(err).ToNot(BeNil(), value)
+		}
+
+		Expect(output.JSON.IsStructured()).To(BeTrue())
+		Expect(output.YAML.IsStructured()).To(BeTrue())
+		Expect(output.Wide.IsStructured()).To(BeFalse())
+		Expect(output.Wide.IsWide()).To(BeTrue())
+	})
+
+	It("PrintList wraps items in a versioned envelope", func() {
+		items := []entry{{Cluster: "default/production", Name: "kyverno"}}
+
+		var buffer bytes.Buffer
+		Expect(output.PrintList(&buffer, output.JSON, "EntryList", items)).To(Succeed())
+		Expect(buffer.String()).To(HaveSuffix("\n"))
+		list := map[string]interface{}{}
+		Expect(json.Unmarshal(buffer.Bytes(), &list)).To(Succeed())
+		Expect(list).To(Equal(map[string]interface{}{
+			"apiVersion": output.APIVersion,
+			"kind":       "EntryList",
+			"items": []interface{}{
+				map[string]interface{}{"cluster": "default/production", "name": "kyverno"},
+			},
+		}))
+
+		buffer.Reset()
+		Expect(output.PrintList(&buffer, output.YAML, "EntryList", items)).To(Succeed())
+		Expect(buffer.String()).To(Equal("apiVersion: " + output.APIVersion + `
+items:
+- cluster: default/production
+  name: kyverno
+kind: EntryList
+`))
+		yamlList := map[string]interface{}{}
+		Expect(yaml.Unmarshal(buffer.Bytes(), &yamlList)).To(Succeed())
+		Expect(yamlList).To(Equal(list))
+
+		Expect(output.PrintList(&buffer, output.Table, "EntryList", items)).ToNot(Succeed())
+	})
+
+	It("DefaultFormat returns the format set with the global flag", func() {
+		Expect(output.DefaultFormat()).To(Equal(output.Table))
+		output.SetDefaultFormat(output.JSON)
+		Expect(output.DefaultFormat()).To(Equal(output.JSON))
+	})
+})
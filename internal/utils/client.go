@@ -131,6 +131,11 @@ func (a *k8sAccess) GetConfig() *rest.Config {
 	return a.restConfig
 }
 
+// GetClientSet returns clientset
+func (a *k8sAccess) GetClientSet() *kubernetes.Clientset {
+	return a.clientset
+}
+
 // ListNamespaces gets all namespaces.
 func (a *k8sAccess) ListNamespaces(ctx context.Context, logger logr.Logger) (*corev1.NamespaceList, error) {
 	logger.V(logs.LogDebug).Info("Get all Namespaces")
@@ -0,0 +1,41 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	configv1beta1 "github.com/projectsveltos/addon-controller/api/v1beta1"
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+)
+
+// ListClusterSummaries returns all current ClusterSummaries in a namespace (if specified)
+func (a *k8sAccess) ListClusterSummaries(ctx context.Context, namespace string,
+	logger logr.Logger) (*configv1beta1.ClusterSummaryList, error) {
+
+	listOptions := []client.ListOption{
+		client.InNamespace(namespace),
+	}
+
+	logger.V(logs.LogDebug).Info("Get all ClusterSummaries")
+	clusterSummaries := &configv1beta1.ClusterSummaryList{}
+	err := a.client.List(ctx, clusterSummaries, listOptions...)
+	return clusterSummaries, err
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2024. projectsveltos.io. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/v2/textlogger"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	libsveltosv1beta1 "github.com/projectsveltos/libsveltos/api/v1beta1"
+	"github.com/projectsveltos/sveltosctl/internal/utils"
+)
+
+var _ = Describe("ClusterSets and Sets", func() {
+	It("ListClusterSets returns list of all ClusterSets", func() {
+		initObjects := []client.Object{}
+
+		for i := 0; i < 10; i++ {
+			clusterSet := &libsveltosv1beta1.ClusterSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: randomString(),
+				},
+				Spec: libsveltosv1beta1.Spec{
+					MaxReplicas: 1,
+				},
+			}
+			initObjects = append(initObjects, clusterSet)
+		}
+
+		scheme := runtime.NewScheme()
+		Expect(utils.AddToScheme(scheme)).To(Succeed())
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initObjects...).Build()
+
+		k8sAccess := utils.GetK8sAccess(scheme, c)
+		clusterSets, err := k8sAccess.ListClusterSets(context.TODO(),
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
+		Expect(err).To(BeNil())
+		Expect(len(clusterSets.Items)).To(Equal(len(initObjects)))
+	})
+
+	It("ListSets returns list of all Sets in a namespace", func() {
+		namespace := randomString()
+		initObjects := []client.Object{
+			&libsveltosv1beta1.Set{
+				ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: randomString()},
+			},
+			&libsveltosv1beta1.Set{
+				ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: randomString()},
+			},
+			&libsveltosv1beta1.Set{
+				ObjectMeta: metav1.ObjectMeta{Namespace: randomString(), Name: randomString()},
+			},
+		}
+
+		scheme := runtime.NewScheme()
+		Expect(utils.AddToScheme(scheme)).To(Succeed())
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initObjects...).Build()
+
+		k8sAccess := utils.GetK8sAccess(scheme, c)
+		sets, err := k8sAccess.ListSets(context.TODO(), namespace,
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
+		Expect(err).To(BeNil())
+		Expect(len(sets.Items)).To(Equal(2))
+
+		sets, err = k8sAccess.ListSets(context.TODO(), "",
+			textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(1))))
+		Expect(err).To(BeNil())
+		Expect(len(sets.Items)).To(Equal(len(initObjects)))
+	})
+})